@@ -16,7 +16,12 @@ import (
 	"github.com/zachbroad/nitrohook/internal/config"
 	"github.com/zachbroad/nitrohook/internal/database"
 	"github.com/zachbroad/nitrohook/internal/handler"
+	"github.com/zachbroad/nitrohook/internal/logging"
+	"github.com/zachbroad/nitrohook/internal/metahook"
+	"github.com/zachbroad/nitrohook/internal/proxy"
+	"github.com/zachbroad/nitrohook/internal/signing"
 	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
 	"github.com/zachbroad/nitrohook/internal/worker"
 	"github.com/zachbroad/nitrohook/web"
 )
@@ -28,11 +33,16 @@ func main() {
 	_ = godotenv.Load()  // Load .env file
 	cfg := config.Load() // Load config from environment variables
 
+	if err := logging.Setup(os.Stderr, cfg.LogFormat, cfg.LogLevel, cfg.LogSampleRate); err != nil {
+		slog.Error("invalid logging config", "error", err)
+		os.Exit(1)
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	// Connect to Postgres
-	pool, err := database.Connect(ctx, cfg.DatabaseURL)
+	pool, err := database.Connect(ctx, cfg.DatabaseURL, cfg.StatementTimeout)
 	if err != nil {
 		slog.Error("failed to connect to database", "error", err)
 		os.Exit(1)
@@ -54,18 +64,130 @@ func main() {
 	defer rdb.Close()
 	slog.Info("connected to redis")
 
+	// Manifest signing is optional; endpoints respond 503 without a key
+	var manifestSigner *signing.ManifestSigner
+	if cfg.ManifestSigningKey != "" {
+		manifestSigner, err = signing.NewManifestSigner(cfg.ManifestSigningKey)
+		if err != nil {
+			slog.Error("invalid manifest signing key", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	trustedProxies, err := proxy.Parse(cfg.TrustedProxies)
+	if err != nil {
+		slog.Error("invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	publicURL, err := proxy.NewPublicURL(cfg.PublicBaseURL, trustedProxies)
+	if err != nil {
+		slog.Error("invalid public base URL", "error", err)
+		os.Exit(1)
+	}
+
+	archiveObjects, err := cfg.ArchiveObjects()
+	if err != nil {
+		slog.Error("invalid archive storage config", "error", err)
+		os.Exit(1)
+	}
+	responseCipher, err := cfg.ResponseCipher()
+	if err != nil {
+		slog.Error("invalid response body key", "error", err)
+		os.Exit(1)
+	}
+	secretsCipher, err := cfg.SecretsCipher()
+	if err != nil {
+		slog.Error("invalid secrets key", "error", err)
+		os.Exit(1)
+	}
+	outboundClients, err := cfg.OutboundClients(secretsCipher)
+	if err != nil {
+		slog.Error("invalid outbound proxy", "error", err)
+		os.Exit(1)
+	}
+	targetGuard := outboundClients.Guard()
+	trim, err := cfg.StreamTrimPolicy()
+	if err != nil {
+		slog.Error("invalid stream trim policy", "error", err)
+		os.Exit(1)
+	}
+	smtpServer, err := cfg.SMTPServer()
+	if err != nil {
+		slog.Error("invalid smtp config", "error", err)
+		os.Exit(1)
+	}
+	egressPrefixes, err := cfg.EgressPrefixes()
+	if err != nil {
+		slog.Error("invalid egress config", "error", err)
+		os.Exit(1)
+	}
+	objective, err := cfg.SLO()
+	if err != nil {
+		slog.Error("invalid slo config", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize store and handlers
 	s := store.New(pool)
-	webhookH := handler.NewWebhookHandler(s, rdb)
-	sourceH := handler.NewSourceHandler(s)
-	actionH := handler.NewActionHandler(s)
-	deliveryH := handler.NewDeliveryHandler(s)
-	webH := web.NewHandler(s)
+
+	// Fail fast on a stale schema or a Redis without streams
+	if err := s.CheckSchema(ctx); err != nil {
+		slog.Error("database schema check failed", "error", err)
+		os.Exit(1)
+	}
+	if err := worker.CheckStreams(ctx, rdb); err != nil {
+		slog.Error("redis check failed", "error", err)
+		os.Exit(1)
+	}
+
+	// Optional group commit for high-throughput ingest
+	// The batcher outlives the signal context: it stops once the server has
+	// drained in-flight ingest requests, answering every queued insert.
+	var batcher *store.DeliveryBatcher
+	batcherCtx, stopBatcher := context.WithCancel(context.Background())
+	defer stopBatcher()
+	batcherDone := make(chan struct{})
+	if cfg.IngestBatchWindow > 0 {
+		batcher = store.NewDeliveryBatcher(s, cfg.IngestBatchWindow, cfg.IngestBatchSize, cfg.IngestSynchronousCommit)
+		go func() {
+			batcher.Run(batcherCtx)
+			close(batcherDone)
+		}()
+		slog.Info("ingest batching enabled", "window", cfg.IngestBatchWindow, "size", cfg.IngestBatchSize, "synchronous_commit", cfg.IngestSynchronousCommit)
+	}
+
+	if cfg.IngestFastPath && trim.Mode != streamtrim.None {
+		slog.Warn("ingest fast path needs STREAM_TRIM=none; persisting deliveries instead", "stream_trim", trim.Mode)
+	}
+
+	meta := metahook.New(cfg.MetaWebhookURL, cfg.MetaWebhookSecret, cfg.DeliveryTimeout)
+	webhookH := handler.NewWebhookHandler(s, rdb, cfg.Limits(), batcher, cfg.IngestFastPath, cfg.IngestSyncTimeout, trim, cfg.IngestRateWarnAt, meta)
+	sourceH := handler.NewSourceHandler(s, cfg.Limits(), cfg.Caps(), publicURL)
+	egressH := handler.NewEgressHandler(egressPrefixes)
+	actionH := handler.NewActionHandler(s, cfg.RequireTargetVerification, secretsCipher, outboundClients, objective, cfg.Caps())
+	deliveryH := handler.NewDeliveryHandler(s, rdb, trim, responseCipher, cfg.ResponseBodyToken)
+	manifestH := handler.NewManifestHandler(s, manifestSigner)
+	adminH := handler.NewAdminHandler(s, rdb, trim)
+	quarantineH := handler.NewQuarantineHandler(s, rdb, trim)
+	reprocessH := handler.NewReprocessHandler(s, rdb, trim)
+	settingsH := handler.NewSettingsHandler(s)
+	eventTypeH := handler.NewEventTypeHandler(s)
+	credentialH := handler.NewCredentialHandler(s, secretsCipher)
+	portalH := handler.NewPortalHandler(s, responseCipher)
+	archiveH := handler.NewArchiveHandler(s, archiveObjects)
+	svixH := handler.NewSvixHandler(s, webhookH, cfg.RequireTargetVerification, targetGuard, cfg.Caps())
+	webH := web.NewHandler(s, cfg.RequireTargetVerification, publicURL, targetGuard, cfg.Limits())
 
 	// Routes
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Recovery(), logging.RequestIDMiddleware(), logging.Middleware())
 	r.RedirectFixedPath = true
 	r.RedirectTrailingSlash = true
+	// Client IPs come from X-Forwarded-For only when set by a trusted proxy
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		slog.Error("invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
 
 	r.GET("/healthz", func(c *gin.Context) {
 		c.String(http.StatusOK, ".")
@@ -92,12 +214,16 @@ func main() {
 	r.GET("/deliveries", webH.Deliveries)
 	r.GET("/deliveries/:id", webH.DeliveryDetail)
 
-	// Webhook ingest
+	// Webhook ingest; GETs only answer verification challenges
 	r.POST("/webhooks/:sourceSlug", webhookH.Ingest)
+	r.GET("/webhooks/:sourceSlug", webhookH.Ingest)
+	r.POST("/webhooks/:sourceSlug/:token", webhookH.Ingest)
+	r.GET("/webhooks/:sourceSlug/:token", webhookH.Ingest)
 
 	// JSON API
 	api := r.Group("/api")
 	{
+		api.GET("/egress", egressH.Get)
 		sources := api.Group("/sources")
 		{
 			sources.GET("", sourceH.List)
@@ -107,13 +233,47 @@ func main() {
 				srcGroup.GET("", sourceH.Get)
 				srcGroup.PATCH("", sourceH.Update)
 				srcGroup.DELETE("", sourceH.Delete)
+				srcGroup.GET("/limits", sourceH.GetLimits)
+				srcGroup.PATCH("/limits", sourceH.UpdateLimits)
+				srcGroup.GET("/script-stats", sourceH.ScriptStats)
+				srcGroup.GET("/action-stats", actionH.Stats)
+				srcGroup.GET("/suppressed", sourceH.ListSuppressed)
+				srcGroup.GET("/activity", sourceH.Activity)
+				srcGroup.GET("/provider-suggestion", sourceH.SuggestProvider)
+				srcGroup.POST("/simulate", webhookH.Simulate)
+				srcGroup.POST("/deliveries/import", webhookH.Import)
+				srcGroup.POST("/deliveries/reprocess", reprocessH.Source)
+				srcGroup.POST("/messages", webhookH.Send)
+				srcGroup.PUT("/signature", sourceH.SetInboundSignature)
+				srcGroup.DELETE("/signature", sourceH.ClearInboundSignature)
+				srcGroup.POST("/ingest-token", sourceH.RotateIngestToken)
+				srcGroup.DELETE("/ingest-token", sourceH.ClearIngestToken)
+				srcGroup.PUT("/challenge", sourceH.SetChallenge)
+				srcGroup.DELETE("/challenge", sourceH.ClearChallenge)
+				srcGroup.GET("/event-types", eventTypeH.List)
+				srcGroup.PUT("/event-types/:name", eventTypeH.Declare)
+				srcGroup.DELETE("/event-types/:name", eventTypeH.Delete)
 				actions := srcGroup.Group("/actions")
 				{
 					actions.POST("", actionH.Create)
 					actions.GET("", actionH.List)
 					actions.GET("/:id", actionH.Get)
+					actions.GET("/:id/attempts", actionH.ExportAttempts)
+					actions.POST("/:id/test", actionH.Test)
 					actions.PATCH("/:id", actionH.Update)
 					actions.DELETE("/:id", actionH.Delete)
+					actions.POST("/:id/verification", actionH.StartVerification)
+					actions.POST("/:id/verification/check", actionH.CheckVerification)
+					actions.POST("/:id/portal-token", actionH.RotatePortalToken)
+					actions.DELETE("/:id/portal-token", actionH.ClearPortalToken)
+					actions.PUT("/:id/tls", actionH.SetClientTLS)
+					actions.DELETE("/:id/tls", actionH.ClearClientTLS)
+					actions.PUT("/:id/slo", actionH.SetSLO)
+					actions.DELETE("/:id/slo", actionH.ClearSLO)
+					actions.PUT("/:id/credential", actionH.SetCredential)
+					actions.DELETE("/:id/credential", actionH.ClearCredential)
+					actions.PUT("/:id/auth", actionH.SetAuth)
+					actions.DELETE("/:id/auth", actionH.ClearAuth)
 				}
 			}
 		}
@@ -122,17 +282,101 @@ func main() {
 			deliveries.GET("", deliveryH.List)
 			deliveries.GET("/:id", deliveryH.Get)
 			deliveries.GET("/:id/attempts", deliveryH.ListAttempts)
+			deliveries.GET("/:id/attempts/:attemptId/response-body", deliveryH.ResponseBody)
+			deliveries.GET("/:id/manifest", manifestH.ForDelivery)
+			deliveries.POST("/:id/replay", webhookH.Replay)
+			deliveries.POST("/:id/reprocess", reprocessH.Delivery)
+			deliveries.POST("/:id/cancel", deliveryH.Cancel)
+			deliveries.POST("/:id/attempts/:attemptId/retry", deliveryH.RetryAttempt)
+		}
+		quarantine := api.Group("/quarantine")
+		{
+			quarantine.GET("", quarantineH.List)
+			quarantine.POST("/:id/approve", quarantineH.Approve)
+			quarantine.POST("/:id/discard", quarantineH.Discard)
+		}
+		credentials := api.Group("/credentials")
+		{
+			credentials.GET("", credentialH.List)
+			credentials.POST("", credentialH.Create)
+			credentials.GET("/:id", credentialH.Get)
+			credentials.PATCH("/:id", credentialH.Update)
+			credentials.DELETE("/:id", credentialH.Delete)
+		}
+		archives := api.Group("/archives")
+		{
+			archives.GET("", archiveH.List)
+			archives.POST("/:id/restore", archiveH.Restore)
+		}
+		manifests := api.Group("/manifests")
+		{
+			manifests.GET("", manifestH.ForRange)
+			manifests.GET("/public-key", manifestH.PublicKey)
+		}
+		admin := api.Group("/admin")
+		{
+			admin.POST("/requeue-pending", adminH.RequeuePending)
+			admin.GET("/metrics", adminH.Metrics)
+			admin.GET("/workers", adminH.Workers)
+			admin.GET("/retry-reasons", adminH.RetryReasons)
+		}
+		settings := api.Group("/settings")
+		{
+			settings.GET("/global-transform", settingsH.GetGlobalTransform)
+			settings.PUT("/global-transform", settingsH.SetGlobalTransform)
+			settings.DELETE("/global-transform", settingsH.ClearGlobalTransform)
+		}
+	}
+
+	// Svix-compatible API shim
+	svix := r.Group("/api/v1/app")
+	{
+		svix.GET("", svixH.ListApplications)
+		svix.POST("", svixH.CreateApplication)
+		app := svix.Group("/:appId")
+		{
+			app.GET("", svixH.GetApplication)
+			app.DELETE("", svixH.DeleteApplication)
+			app.GET("/endpoint", svixH.ListEndpoints)
+			app.POST("/endpoint", svixH.CreateEndpoint)
+			app.GET("/endpoint/:endpointId", svixH.GetEndpoint)
+			app.PUT("/endpoint/:endpointId", svixH.UpdateEndpoint)
+			app.DELETE("/endpoint/:endpointId", svixH.DeleteEndpoint)
+			app.GET("/msg", svixH.ListMessages)
+			app.POST("/msg", svixH.CreateMessage)
+			app.GET("/msg/:msgId", svixH.GetMessage)
 		}
 	}
 
+	// Consumer self-service portal, scoped to one action by its portal token
+	portal := r.Group("/portal", portalH.Authenticate)
+	{
+		portal.GET("/action", portalH.GetAction)
+		portal.POST("/signing-secret", portalH.RotateSigningSecret)
+		portal.GET("/deliveries", portalH.ListDeliveries)
+		portal.GET("/deliveries/:id", portalH.GetDelivery)
+		portal.GET("/deliveries/:id/attempts/:attemptId/response-body", portalH.ResponseBody)
+		portal.GET("/suppressed", portalH.ListSuppressed)
+		portal.POST("/deliveries/:id/retry", portalH.RetryDelivery)
+	}
+
 	// Optionally start fan-out worker in-process for local development
+	var w *worker.FanoutWorker
 	if *withWorker {
-		w := worker.New(s, rdb, cfg.WorkerConcurrency, cfg.MaxRetries, cfg.RetryBaseDelay, cfg.DeliveryTimeout, cfg.PollInterval)
-		if err := w.Start(ctx); err != nil {
+		w, err = worker.Run(ctx, cfg, s, rdb, worker.Deps{
+			ResponseCipher: responseCipher,
+			Secrets:        secretsCipher,
+			Clients:        outboundClients,
+			Trim:           trim,
+			SMTP:           smtpServer,
+			Objective:      objective,
+			Meta:           meta,
+			Archive:        archiveObjects,
+		})
+		if err != nil {
 			slog.Error("failed to start worker", "error", err)
 			os.Exit(1)
 		}
-		slog.Info("fan-out worker started", "concurrency", cfg.WorkerConcurrency)
 	}
 
 	// Start HTTP server
@@ -158,5 +402,16 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		slog.Error("shutdown error", "error", err)
 	}
+	if batcher != nil {
+		stopBatcher()
+		select {
+		case <-batcherDone:
+		case <-shutdownCtx.Done():
+			slog.Warn("timed out flushing ingest batches")
+		}
+	}
+	if w != nil {
+		w.Shutdown(shutdownCtx)
+	}
 	slog.Info("api server stopped")
 }
@@ -13,6 +13,8 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/zachbroad/nitrohook/internal/config"
 	"github.com/zachbroad/nitrohook/internal/database"
+	"github.com/zachbroad/nitrohook/internal/logging"
+	"github.com/zachbroad/nitrohook/internal/metahook"
 	"github.com/zachbroad/nitrohook/internal/store"
 	"github.com/zachbroad/nitrohook/internal/worker"
 )
@@ -21,11 +23,16 @@ func main() {
 	_ = godotenv.Load()
 	cfg := config.Load()
 
+	if err := logging.Setup(os.Stderr, cfg.LogFormat, cfg.LogLevel, cfg.LogSampleRate); err != nil {
+		slog.Error("invalid logging config", "error", err)
+		os.Exit(1)
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	// Connect to Postgres
-	pool, err := database.Connect(ctx, cfg.DatabaseURL)
+	pool, err := database.Connect(ctx, cfg.DatabaseURL, cfg.StatementTimeout)
 	if err != nil {
 		slog.Error("failed to connect to database", "error", err)
 		os.Exit(1)
@@ -47,14 +54,68 @@ func main() {
 	defer rdb.Close()
 	slog.Info("connected to redis")
 
+	archiveObjects, err := cfg.ArchiveObjects()
+	if err != nil {
+		slog.Error("invalid archive storage config", "error", err)
+		os.Exit(1)
+	}
+	responseCipher, err := cfg.ResponseCipher()
+	if err != nil {
+		slog.Error("invalid response body key", "error", err)
+		os.Exit(1)
+	}
+	secretsCipher, err := cfg.SecretsCipher()
+	if err != nil {
+		slog.Error("invalid secrets key", "error", err)
+		os.Exit(1)
+	}
+	outboundClients, err := cfg.OutboundClients(secretsCipher)
+	if err != nil {
+		slog.Error("invalid outbound proxy", "error", err)
+		os.Exit(1)
+	}
+	trim, err := cfg.StreamTrimPolicy()
+	if err != nil {
+		slog.Error("invalid stream trim policy", "error", err)
+		os.Exit(1)
+	}
+	smtpServer, err := cfg.SMTPServer()
+	if err != nil {
+		slog.Error("invalid smtp config", "error", err)
+		os.Exit(1)
+	}
+	objective, err := cfg.SLO()
+	if err != nil {
+		slog.Error("invalid slo config", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize store and start fan-out worker
 	s := store.New(pool)
-	w := worker.New(s, rdb, cfg.WorkerConcurrency, cfg.MaxRetries, cfg.RetryBaseDelay, cfg.DeliveryTimeout, cfg.PollInterval)
-	if err := w.Start(ctx); err != nil {
+
+	// Fail fast on a stale schema or a Redis without streams
+	if err := s.CheckSchema(ctx); err != nil {
+		slog.Error("database schema check failed", "error", err)
+		os.Exit(1)
+	}
+	if err := worker.CheckStreams(ctx, rdb); err != nil {
+		slog.Error("redis check failed", "error", err)
+		os.Exit(1)
+	}
+	w, err := worker.Run(ctx, cfg, s, rdb, worker.Deps{
+		ResponseCipher: responseCipher,
+		Secrets:        secretsCipher,
+		Clients:        outboundClients,
+		Trim:           trim,
+		SMTP:           smtpServer,
+		Objective:      objective,
+		Meta:           metahook.New(cfg.MetaWebhookURL, cfg.MetaWebhookSecret, cfg.DeliveryTimeout),
+		Archive:        archiveObjects,
+	})
+	if err != nil {
 		slog.Error("failed to start worker", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("fan-out worker started", "concurrency", cfg.WorkerConcurrency)
 
 	// Minimal health endpoint for k8s liveness probes
 	healthMux := http.NewServeMux()
@@ -81,6 +142,8 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
+	w.Shutdown(shutdownCtx)
+
 	if err := healthSrv.Shutdown(shutdownCtx); err != nil {
 		slog.Error("health server shutdown error", "error", err)
 	}
@@ -0,0 +1,446 @@
+// Package amqp publishes deliveries to RabbitMQ (or any AMQP 0-9-1 broker)
+// exchanges with publisher confirms. It speaks just enough of the protocol
+// for one confirmed publish per connection.
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// Reply codes the broker answers with.
+const (
+	ReplySuccess       = 200
+	ContentTooLarge    = 311
+	NoRoute            = 312
+	InvalidPath        = 402
+	AccessRefused      = 403
+	PreconditionFailed = 406
+	NotAllowed         = 530
+)
+
+const (
+	// maxShortString is the limit on exchange names and routing keys.
+	maxShortString = 255
+	// maxFrameSize caps the frame size negotiated with the broker.
+	maxFrameSize = 128 << 10
+	// defaultTimeout bounds a publish when ctx has no deadline.
+	defaultTimeout = 30 * time.Second
+	appID          = "nitrohook"
+)
+
+// ErrNacked is returned when the broker refuses the message with a nack.
+var ErrNacked = errors.New("amqp: broker nacked the message")
+
+// Error is a reply the broker closed the channel or connection with, or
+// returned an unroutable mandatory message with.
+type Error struct {
+	Code     int
+	Text     string
+	Returned bool
+}
+
+func (e *Error) Error() string {
+	if e.Returned {
+		return fmt.Sprintf("amqp: message returned: %d %s", e.Code, e.Text)
+	}
+	return fmt.Sprintf("amqp: %d %s", e.Code, e.Text)
+}
+
+// Reply returns the broker's reply code from a Publish error, if it sent one.
+func Reply(err error) (int, bool) {
+	var aerr *Error
+	if !errors.As(err, &aerr) {
+		return 0, false
+	}
+	return aerr.Code, true
+}
+
+// Permanent reports whether a Publish error is a refusal retrying won't
+// fix: bad credentials or vhost, a mismatched exchange or an oversized
+// message. A missing exchange or unroutable message may be fixed by
+// declaring it, so those are retried.
+func Permanent(err error) bool {
+	code, ok := Reply(err)
+	if !ok {
+		return false
+	}
+	switch code {
+	case ContentTooLarge, InvalidPath, AccessRefused, PreconditionFailed, NotAllowed:
+		return true
+	}
+	return false
+}
+
+// Config is an AMQP action's config. URL names the broker and user as
+// amqp[s]://user@host[:port]/vhost; the password is the action's secret.
+// RoutingKey is a reqtemplate over the payload, such as
+// "orders.{{.status}}". Mandatory makes unroutable messages fail the
+// attempt instead of being dropped.
+type Config struct {
+	URL        string `json:"url"`
+	Exchange   string `json:"exchange,omitempty"`
+	RoutingKey string `json:"routing_key,omitempty"`
+	Mandatory  bool   `json:"mandatory,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode amqp config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the user's password.
+func Validate(cfg Config, secret string) error {
+	b, err := parseBroker(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return errors.New("secret (the password for " + b.user + ") is required")
+	}
+	if len(cfg.Exchange) > maxShortString {
+		return fmt.Errorf("exchange must be at most %d bytes", maxShortString)
+	}
+	if cfg.Exchange == "" && cfg.RoutingKey == "" {
+		return errors.New("routing_key is required for the default exchange")
+	}
+	if cfg.RoutingKey != "" {
+		if _, err := reqtemplate.Parse(cfg.RoutingKey); err != nil {
+			return fmt.Errorf("invalid routing_key template: %w", err)
+		}
+	}
+	return nil
+}
+
+// broker is a parsed broker URL.
+type broker struct {
+	addr  string
+	host  string
+	tls   bool
+	user  string
+	vhost string
+}
+
+func parseBroker(raw string) (broker, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Hostname() == "" {
+		return broker{}, errors.New("url must be an amqp:// or amqps:// URL")
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return broker{}, errors.New("url must include a user, as amqp://user@host/vhost")
+	}
+	if _, ok := u.User.Password(); ok {
+		return broker{}, errors.New("url must not include a password; set it as the secret")
+	}
+	b := broker{host: u.Hostname(), tls: u.Scheme == "amqps", user: u.User.Username(), vhost: "/"}
+	port := u.Port()
+	if port == "" {
+		port = "5672"
+		if b.tls {
+			port = "5671"
+		}
+	}
+	b.addr = net.JoinHostPort(b.host, port)
+	if v := strings.TrimPrefix(u.Path, "/"); v != "" {
+		b.vhost = v
+	}
+	return b, nil
+}
+
+// RoutingKey renders the config's routing key for payload.
+func RoutingKey(cfg Config, payload json.RawMessage) (string, error) {
+	if cfg.RoutingKey == "" {
+		return "", nil
+	}
+	out, err := reqtemplate.Body(cfg.RoutingKey, payload, maxShortString*4)
+	if err != nil {
+		return "", fmt.Errorf("render routing key: %w", err)
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" && cfg.Exchange == "" {
+		return "", errors.New("routing key rendered empty")
+	}
+	if len(key) > maxShortString {
+		return "", fmt.Errorf("routing key is %d bytes, over the limit of %d", len(key), maxShortString)
+	}
+	return key, nil
+}
+
+// Message is what Publish sends. Headers become string message headers.
+type Message struct {
+	Body        []byte
+	ContentType string
+	MessageID   string
+	Timestamp   time.Time
+	Headers     map[string]string
+}
+
+// Dialer opens the TCP connection to the broker.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Publish publishes msg persistently to the config's exchange under
+// routingKey and waits for the broker to confirm it. It returns nil on an
+// ack, ErrNacked on a nack and *Error when the broker closes the channel or
+// returns a mandatory message.
+func Publish(ctx context.Context, dial Dialer, cfg Config, password, routingKey string, msg Message) error {
+	b, err := parseBroker(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+	conn, err := dial(ctx, "tcp", b.addr)
+	if err != nil {
+		return fmt.Errorf("dial amqp: %w", err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Unblock reads and writes if ctx is cancelled before the deadline
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if b.tls {
+		tconn := tls.Client(conn, &tls.Config{ServerName: b.host})
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("amqp tls handshake: %w", err)
+		}
+		conn = tconn
+	}
+
+	s := &session{conn: conn, r: bufio.NewReader(conn), frameMax: minFrameSize}
+	if err := s.open(b, password); err != nil {
+		return err
+	}
+	err = s.publish(cfg, routingKey, msg)
+	s.close()
+	return err
+}
+
+// session is one connection with channel 1 open in confirm mode.
+type session struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	frameMax uint32
+}
+
+func (s *session) send(channel uint16, e *encoder) error {
+	if err := writeFrame(s.conn, frameMethod, channel, e.Bytes()); err != nil {
+		return fmt.Errorf("amqp write: %w", err)
+	}
+	return nil
+}
+
+// next returns the next method frame, answering closes from the broker
+// with their close-ok and turning them into *Error. Heartbeats and content
+// frames are skipped.
+func (s *session) next() (class, id uint16, args *decoder, err error) {
+	for {
+		f, err := readFrame(s.r, max(s.frameMax, minFrameSize))
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("amqp read: %w", err)
+		}
+		if f.typ != frameMethod {
+			continue
+		}
+		class, id, args, err := methodFrame(f)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if (class == classConnection && id == methodConnectionClose) || (class == classChannel && id == methodChannelClose) {
+			code, text := args.short(), args.shortstr()
+			s.send(f.channel, method(class, id+1))
+			return 0, 0, nil, &Error{Code: int(code), Text: text}
+		}
+		return class, id, args, nil
+	}
+}
+
+// expect reads the next method and fails unless it is class.id.
+func (s *session) expect(class, id uint16) (*decoder, error) {
+	c, i, args, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	if c != class || i != id {
+		return nil, fmt.Errorf("amqp: expected method %d.%d, got %d.%d", class, id, c, i)
+	}
+	return args, nil
+}
+
+// open runs the connection handshake with PLAIN authentication, opens
+// channel 1 and puts it in confirm mode.
+func (s *session) open(b broker, password string) error {
+	if _, err := s.conn.Write(protocolHeader); err != nil {
+		return fmt.Errorf("amqp write: %w", err)
+	}
+	args, err := s.expect(classConnection, methodConnectionStart)
+	if err != nil {
+		return err
+	}
+	args.octet()
+	args.octet()
+	args.longstr() // server properties
+	if mechs := args.longstr(); !strings.Contains(" "+mechs+" ", " PLAIN ") {
+		return fmt.Errorf("amqp: broker doesn't offer PLAIN auth (offers %q)", mechs)
+	}
+
+	e := method(classConnection, methodConnectionStartOk)
+	e.table(map[string]string{"product": appID})
+	e.shortstr("PLAIN")
+	e.longstr("\x00" + b.user + "\x00" + password)
+	e.shortstr("en_US")
+	if err := s.send(0, e); err != nil {
+		return err
+	}
+
+	// A broker rejecting the login closes the connection, often without a
+	// close method
+	args, err = s.expect(classConnection, methodConnectionTune)
+	if err != nil {
+		if _, ok := Reply(err); !ok {
+			return fmt.Errorf("amqp login as %s: %w", b.user, err)
+		}
+		return err
+	}
+	args.short()
+	if fm := args.long(); fm == 0 || fm > maxFrameSize {
+		s.frameMax = maxFrameSize
+	} else {
+		s.frameMax = max(fm, minFrameSize)
+	}
+	e = method(classConnection, methodConnectionTuneOk)
+	e.short(1)
+	e.long(s.frameMax)
+	e.short(0) // no heartbeats
+	if err := s.send(0, e); err != nil {
+		return err
+	}
+
+	e = method(classConnection, methodConnectionOpen)
+	e.shortstr(b.vhost)
+	e.shortstr("")
+	e.octet(0)
+	if err := s.send(0, e); err != nil {
+		return err
+	}
+	if _, err := s.expect(classConnection, methodConnectionOpenOk); err != nil {
+		return err
+	}
+
+	e = method(classChannel, methodChannelOpen)
+	e.shortstr("")
+	if err := s.send(1, e); err != nil {
+		return err
+	}
+	if _, err := s.expect(classChannel, methodChannelOpenOk); err != nil {
+		return err
+	}
+
+	e = method(classConfirm, methodConfirmSelect)
+	e.octet(0)
+	if err := s.send(1, e); err != nil {
+		return err
+	}
+	_, err = s.expect(classConfirm, methodConfirmSelectOk)
+	return err
+}
+
+// publish sends the message and waits for its confirm. A mandatory message
+// the broker can't route comes back as basic.return before the ack.
+func (s *session) publish(cfg Config, routingKey string, msg Message) error {
+	e := method(classBasic, methodBasicPublish)
+	e.short(0)
+	e.shortstr(cfg.Exchange)
+	e.shortstr(routingKey)
+	var bits byte
+	if cfg.Mandatory {
+		bits = 1
+	}
+	e.octet(bits)
+	if err := s.send(1, e); err != nil {
+		return err
+	}
+	if msg.ContentType == "" {
+		msg.ContentType = "application/json"
+	}
+	if err := writeFrame(s.conn, frameHeader, 1, contentHeader(len(msg.Body), msg)); err != nil {
+		return fmt.Errorf("amqp write: %w", err)
+	}
+	chunk := int(s.frameMax) - 8
+	for body := msg.Body; len(body) > 0; {
+		n := min(len(body), chunk)
+		if err := writeFrame(s.conn, frameBody, 1, body[:n]); err != nil {
+			return fmt.Errorf("amqp write: %w", err)
+		}
+		body = body[n:]
+	}
+
+	var returned *Error
+	for {
+		class, id, args, err := s.next()
+		if err != nil {
+			return err
+		}
+		if class != classBasic {
+			continue
+		}
+		switch id {
+		case methodBasicReturn:
+			code, text := args.short(), args.shortstr()
+			returned = &Error{Code: int(code), Text: text, Returned: true}
+		case methodBasicAck:
+			if returned != nil {
+				return returned
+			}
+			return nil
+		case methodBasicNack:
+			return ErrNacked
+		}
+	}
+}
+
+// close closes the connection politely; errors don't matter by now.
+func (s *session) close() {
+	e := method(classConnection, methodConnectionClose)
+	e.short(ReplySuccess)
+	e.shortstr("")
+	e.short(0)
+	e.short(0)
+	if s.send(0, e) != nil {
+		return
+	}
+	s.conn.SetDeadline(time.Now().Add(time.Second))
+	for {
+		f, err := readFrame(s.r, max(s.frameMax, minFrameSize))
+		if err != nil {
+			return
+		}
+		if class, id, _, err := methodFrame(f); err != nil || (class == classConnection && id == methodConnectionCloseOk) {
+			return
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package amqp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		secret  string
+		wantErr bool
+	}{
+		{"exchange", Config{URL: "amqp://relay@mq.example.com/prod", Exchange: "events", RoutingKey: "orders.{{.status}}"}, "pw", false},
+		{"default exchange", Config{URL: "amqps://relay@mq.example.com", RoutingKey: "orders"}, "pw", false},
+		{"fanout exchange without key", Config{URL: "amqp://relay@mq.example.com", Exchange: "events"}, "pw", false},
+		{"default exchange without key", Config{URL: "amqp://relay@mq.example.com"}, "pw", true},
+		{"password in url", Config{URL: "amqp://relay:pw@mq.example.com", Exchange: "events"}, "pw", true},
+		{"no user", Config{URL: "amqp://mq.example.com", Exchange: "events"}, "pw", true},
+		{"http url", Config{URL: "http://relay@mq.example.com", Exchange: "events"}, "pw", true},
+		{"no secret", Config{URL: "amqp://relay@mq.example.com", Exchange: "events"}, "", true},
+		{"bad template", Config{URL: "amqp://relay@mq.example.com", RoutingKey: "{{.x"}, "pw", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.cfg, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseBroker(t *testing.T) {
+	b, err := parseBroker("amqps://relay@mq.example.com/prod%2Feu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.addr != "mq.example.com:5671" || !b.tls || b.user != "relay" || b.vhost != "prod/eu" {
+		t.Fatalf("got %+v", b)
+	}
+	if b, _ = parseBroker("amqp://relay@mq.example.com:5673"); b.addr != "mq.example.com:5673" || b.vhost != "/" {
+		t.Fatalf("got %+v", b)
+	}
+}
+
+func TestRoutingKey(t *testing.T) {
+	cfg := Config{Exchange: "events", RoutingKey: "orders.{{.status}}"}
+	key, err := RoutingKey(cfg, []byte(`{"status":"paid"}`))
+	if err != nil || key != "orders.paid" {
+		t.Fatalf("got %q, %v", key, err)
+	}
+	if _, err := RoutingKey(Config{RoutingKey: "{{.missing}}"}, []byte(`{}`)); err == nil {
+		t.Fatal("expected error for empty key on the default exchange")
+	}
+}
+
+// published is what the fake broker received.
+type published struct {
+	exchange, routingKey string
+	mandatory            bool
+	body                 []byte
+}
+
+// fakeBroker accepts one connection, runs the handshake and answers the
+// publish with reply. It sends what it received on the returned channel.
+func fakeBroker(t *testing.T, reply func(s *session)) (string, <-chan published) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan published, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		s := &session{conn: conn, r: bufio.NewReader(conn), frameMax: minFrameSize}
+		hdr := make([]byte, len(protocolHeader))
+		if _, err := io.ReadFull(s.r, hdr); err != nil {
+			return
+		}
+		e := method(classConnection, methodConnectionStart)
+		e.octet(0)
+		e.octet(9)
+		e.table(nil)
+		e.longstr("AMQPLAIN PLAIN")
+		e.longstr("en_US")
+		s.send(0, e)
+		args, err := s.expect(classConnection, methodConnectionStartOk)
+		if err != nil {
+			return
+		}
+		args.longstr()
+		args.shortstr()
+		if args.longstr() != "\x00relay\x00pw" {
+			e := method(classConnection, methodConnectionClose)
+			e.short(AccessRefused)
+			e.shortstr("ACCESS_REFUSED")
+			e.short(0)
+			e.short(0)
+			s.send(0, e)
+			return
+		}
+		e = method(classConnection, methodConnectionTune)
+		e.short(2047)
+		e.long(minFrameSize)
+		e.short(60)
+		s.send(0, e)
+		s.expect(classConnection, methodConnectionTuneOk)
+		s.expect(classConnection, methodConnectionOpen)
+		e = method(classConnection, methodConnectionOpenOk)
+		e.shortstr("")
+		s.send(0, e)
+		s.expect(classChannel, methodChannelOpen)
+		e = method(classChannel, methodChannelOpenOk)
+		e.longstr("")
+		s.send(1, e)
+		s.expect(classConfirm, methodConfirmSelect)
+		s.send(1, method(classConfirm, methodConfirmSelectOk))
+
+		args, err = s.expect(classBasic, methodBasicPublish)
+		if err != nil {
+			return
+		}
+		var p published
+		args.short()
+		p.exchange, p.routingKey = args.shortstr(), args.shortstr()
+		p.mandatory = args.octet()&1 != 0
+		hf, _ := readFrame(s.r, minFrameSize)
+		size := (&decoder{buf: hf.payload[4:12]}).longlong()
+		for uint64(len(p.body)) < size {
+			bf, err := readFrame(s.r, minFrameSize)
+			if err != nil {
+				return
+			}
+			p.body = append(p.body, bf.payload...)
+		}
+		got <- p
+		reply(s)
+		s.expect(classConnection, methodConnectionClose)
+		s.send(0, method(classConnection, methodConnectionCloseOk))
+	}()
+	return ln.Addr().String(), got
+}
+
+func ack(s *session) {
+	e := method(classBasic, methodBasicAck)
+	e.longlong(1)
+	e.octet(0)
+	s.send(1, e)
+}
+
+func publish(t *testing.T, addr, password string, cfg Config) error {
+	t.Helper()
+	cfg.URL = "amqp://relay@" + addr + "/"
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	body := make([]byte, 10000) // spans several frames
+	return Publish(ctx, d.DialContext, cfg, password, "orders.paid", Message{Body: body, MessageID: "d1", Timestamp: time.Now(), Headers: map[string]string{"delivery_id": "d1"}})
+}
+
+func TestPublishAck(t *testing.T) {
+	addr, got := fakeBroker(t, ack)
+	if err := publish(t, addr, "pw", Config{Exchange: "events", Mandatory: true}); err != nil {
+		t.Fatal(err)
+	}
+	p := <-got
+	if p.exchange != "events" || p.routingKey != "orders.paid" || !p.mandatory || len(p.body) != 10000 {
+		t.Fatalf("got %+v", p)
+	}
+}
+
+func TestPublishNack(t *testing.T) {
+	addr, _ := fakeBroker(t, func(s *session) {
+		e := method(classBasic, methodBasicNack)
+		e.longlong(1)
+		e.octet(0)
+		s.send(1, e)
+	})
+	if err := publish(t, addr, "pw", Config{Exchange: "events"}); !errors.Is(err, ErrNacked) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestPublishReturned(t *testing.T) {
+	addr, _ := fakeBroker(t, func(s *session) {
+		e := method(classBasic, methodBasicReturn)
+		e.short(NoRoute)
+		e.shortstr("NO_ROUTE")
+		e.shortstr("events")
+		e.shortstr("orders.paid")
+		s.send(1, e)
+		ack(s)
+	})
+	err := publish(t, addr, "pw", Config{Exchange: "events", Mandatory: true})
+	if code, ok := Reply(err); !ok || code != NoRoute || Permanent(err) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestPublishChannelClosed(t *testing.T) {
+	addr, _ := fakeBroker(t, func(s *session) {
+		e := method(classChannel, methodChannelClose)
+		e.short(404)
+		e.shortstr("NOT_FOUND - no exchange 'events'")
+		e.short(classBasic)
+		e.short(methodBasicPublish)
+		s.send(1, e)
+		s.expect(classChannel, methodChannelCloseOk)
+	})
+	err := publish(t, addr, "pw", Config{Exchange: "events"})
+	if code, ok := Reply(err); !ok || code != 404 {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestPublishAccessRefused(t *testing.T) {
+	addr, _ := fakeBroker(t, ack)
+	err := publish(t, addr, "wrong", Config{Exchange: "events"})
+	if !Permanent(err) {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Frame types and the frame terminator.
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+	frameEnd       = 0xCE
+)
+
+// Class and method IDs used by the publisher.
+const (
+	classConnection = 10
+	classChannel    = 20
+	classBasic      = 60
+	classConfirm    = 85
+
+	methodConnectionStart   = 10
+	methodConnectionStartOk = 11
+	methodConnectionTune    = 30
+	methodConnectionTuneOk  = 31
+	methodConnectionOpen    = 40
+	methodConnectionOpenOk  = 41
+	methodConnectionClose   = 50
+	methodConnectionCloseOk = 51
+
+	methodChannelOpen    = 10
+	methodChannelOpenOk  = 11
+	methodChannelClose   = 40
+	methodChannelCloseOk = 41
+
+	methodBasicPublish = 40
+	methodBasicReturn  = 50
+	methodBasicAck     = 80
+	methodBasicNack    = 120
+
+	methodConfirmSelect   = 10
+	methodConfirmSelectOk = 11
+)
+
+// protocolHeader opens an AMQP 0-9-1 connection.
+var protocolHeader = []byte("AMQP\x00\x00\x09\x01")
+
+// minFrameSize is the frame size every peer must accept.
+const minFrameSize = 4096
+
+type frame struct {
+	typ     byte
+	channel uint16
+	payload []byte
+}
+
+func readFrame(r *bufio.Reader, maxSize uint32) (frame, error) {
+	var hdr [7]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{typ: hdr[0], channel: binary.BigEndian.Uint16(hdr[1:3])}
+	size := binary.BigEndian.Uint32(hdr[3:7])
+	if size > maxSize {
+		return frame{}, fmt.Errorf("frame of %d bytes exceeds limit", size)
+	}
+	f.payload = make([]byte, size+1)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return frame{}, err
+	}
+	if f.payload[size] != frameEnd {
+		return frame{}, errors.New("malformed frame")
+	}
+	f.payload = f.payload[:size]
+	return f, nil
+}
+
+func writeFrame(w io.Writer, typ byte, channel uint16, payload []byte) error {
+	buf := make([]byte, 0, len(payload)+8)
+	buf = append(buf, typ)
+	buf = binary.BigEndian.AppendUint16(buf, channel)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = append(buf, payload...)
+	buf = append(buf, frameEnd)
+	_, err := w.Write(buf)
+	return err
+}
+
+// encoder builds method and header frame payloads.
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) octet(v byte)   { e.WriteByte(v) }
+func (e *encoder) short(v uint16) { e.Write(binary.BigEndian.AppendUint16(nil, v)) }
+func (e *encoder) long(v uint32)  { e.Write(binary.BigEndian.AppendUint32(nil, v)) }
+func (e *encoder) longlong(v uint64) {
+	e.Write(binary.BigEndian.AppendUint64(nil, v))
+}
+
+func (e *encoder) shortstr(s string) {
+	e.octet(byte(len(s)))
+	e.WriteString(s)
+}
+
+func (e *encoder) longstr(s string) {
+	e.long(uint32(len(s)))
+	e.WriteString(s)
+}
+
+// table writes a field table of long string values, in key order.
+func (e *encoder) table(fields map[string]string) {
+	var t encoder
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		t.shortstr(k)
+		t.octet('S')
+		t.longstr(fields[k])
+	}
+	e.long(uint32(t.Len()))
+	e.Write(t.Bytes())
+}
+
+func method(class, id uint16) *encoder {
+	e := &encoder{}
+	e.short(class)
+	e.short(id)
+	return e
+}
+
+// decoder reads method arguments, remembering the first error.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < n {
+		d.err = errors.New("truncated method frame")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) octet() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) short() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) long() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) longlong() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) shortstr() string {
+	return string(d.next(int(d.octet())))
+}
+
+func (d *decoder) longstr() string {
+	return string(d.next(int(d.long())))
+}
+
+// methodFrame splits a method frame into its class, method and arguments.
+func methodFrame(f frame) (class, id uint16, args *decoder, err error) {
+	if f.typ != frameMethod {
+		return 0, 0, nil, fmt.Errorf("unexpected frame type %d", f.typ)
+	}
+	d := &decoder{buf: f.payload}
+	class, id = d.short(), d.short()
+	return class, id, d, d.err
+}
+
+// contentHeader encodes a basic content header with the message's
+// properties: content type, headers, persistent delivery mode, message ID,
+// timestamp and app ID.
+func contentHeader(bodySize int, msg Message) []byte {
+	const (
+		flagContentType  = 1 << 15
+		flagHeaders      = 1 << 13
+		flagDeliveryMode = 1 << 12
+		flagMessageID    = 1 << 7
+		flagTimestamp    = 1 << 6
+		flagAppID        = 1 << 3
+	)
+	e := &encoder{}
+	e.short(classBasic)
+	e.short(0) // weight
+	e.longlong(uint64(bodySize))
+	flags := uint16(flagContentType | flagDeliveryMode | flagAppID)
+	if len(msg.Headers) > 0 {
+		flags |= flagHeaders
+	}
+	if msg.MessageID != "" {
+		flags |= flagMessageID
+	}
+	if !msg.Timestamp.IsZero() {
+		flags |= flagTimestamp
+	}
+	e.short(flags)
+	e.shortstr(msg.ContentType)
+	if flags&flagHeaders != 0 {
+		e.table(msg.Headers)
+	}
+	e.octet(2) // persistent
+	if flags&flagMessageID != 0 {
+		e.shortstr(msg.MessageID)
+	}
+	if flags&flagTimestamp != 0 {
+		e.longlong(uint64(msg.Timestamp.Unix()))
+	}
+	e.shortstr(appID)
+	return e.Bytes()
+}
@@ -0,0 +1,111 @@
+// Package archive writes deliveries to gzip-compressed NDJSON objects for
+// cold storage and reads them back.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// ErrNotFound is returned when an object or a delivery in it doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// ObjectStore is where archive objects live.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Record is one archived delivery with its attempts.
+type Record struct {
+	Delivery model.Delivery          `json:"delivery"`
+	Attempts []model.DeliveryAttempt `json:"attempts"`
+}
+
+// Key returns the object key for a source's deliveries received on day.
+func Key(prefix string, sourceID uuid.UUID, day time.Time) string {
+	return fmt.Sprintf("%s/%s/%s.ndjson.gz", prefix, sourceID, day.UTC().Format(time.DateOnly))
+}
+
+// Writer encodes records as one gzip member of NDJSON. Members can be
+// appended to an existing object: concatenated gzip streams read as one.
+type Writer struct {
+	buf bytes.Buffer
+	gz  *gzip.Writer
+	enc *json.Encoder
+	n   int
+}
+
+func NewWriter() *Writer {
+	w := &Writer{}
+	w.gz = gzip.NewWriter(&w.buf)
+	w.enc = json.NewEncoder(w.gz)
+	return w
+}
+
+// Write appends a record as one line.
+func (w *Writer) Write(rec *Record) error {
+	if err := w.enc.Encode(rec); err != nil {
+		return fmt.Errorf("encode record: %w", err)
+	}
+	w.n++
+	return nil
+}
+
+// Len returns the number of records written.
+func (w *Writer) Len() int {
+	return w.n
+}
+
+// Bytes closes the gzip member and returns the compressed data.
+func (w *Writer) Bytes() ([]byte, error) {
+	if err := w.gz.Close(); err != nil {
+		return nil, fmt.Errorf("compress records: %w", err)
+	}
+	return w.buf.Bytes(), nil
+}
+
+// Find returns the record for a delivery in an archive object. If the
+// delivery was archived more than once, the last copy wins.
+func Find(data []byte, id uuid.UUID) (*Record, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer gz.Close()
+
+	var found *Record
+	r := bufio.NewReader(gz)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec Record
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return nil, fmt.Errorf("decode record: %w", err)
+			}
+			if rec.Delivery.ID == id {
+				found = &rec
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
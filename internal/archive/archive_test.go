@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+func TestWriteAndFind(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+
+	w := NewWriter()
+	if err := w.Write(&Record{Delivery: model.Delivery{ID: first, Payload: []byte(`{"n":1}`)}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := w.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run appends a second gzip member to the same object
+	w = NewWriter()
+	w.Write(&Record{Delivery: model.Delivery{ID: second, Payload: []byte(`{"n":2}`)}, Attempts: []model.DeliveryAttempt{{AttemptNumber: 1}}})
+	more, err := w.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, more...)
+
+	rec, err := Find(data, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rec.Delivery.Payload) != `{"n":2}` || len(rec.Attempts) != 1 {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if _, err := Find(data, first); err != nil {
+		t.Fatalf("expected first record in first member: %v", err)
+	}
+	if _, err := Find(data, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	id := uuid.MustParse("6f1c1f7e-3a1b-4c55-9d2e-0b7a4a0f9c11")
+	day := time.Date(2026, 3, 4, 23, 0, 0, 0, time.UTC)
+	if got := Key("deliveries", id, day); got != "deliveries/6f1c1f7e-3a1b-4c55-9d2e-0b7a4a0f9c11/2026-03-04.ndjson.gz" {
+		t.Fatalf("unexpected key %q", got)
+	}
+}
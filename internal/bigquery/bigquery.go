@@ -0,0 +1,265 @@
+// Package bigquery streams deliveries into BigQuery tables through the
+// tabledata.insertAll API, authenticated with a service account key.
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/projection"
+)
+
+// APIURL is the BigQuery v2 API's base URL.
+const APIURL = "https://bigquery.googleapis.com/bigquery/v2"
+
+// audience is the self-signed JWT audience Google accepts for BigQuery.
+const audience = "https://bigquery.googleapis.com/"
+
+// maxName is the longest dataset or table name BigQuery accepts.
+const maxName = 1024
+
+// tokenLifetime is how long a signed token is valid; Google allows an hour.
+const tokenLifetime = time.Hour
+
+var (
+	projectPattern = regexp.MustCompile(`^([a-z][a-z0-9.-]*:)?[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	datasetPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	tablePattern   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	columnPattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,299}$`)
+)
+
+// Config is a BigQuery action's config; the secret is a service account's
+// JSON key. Project defaults to the key's project. Fields maps column names
+// to payload paths, such as {"order_id": "$.order.id"}; columns whose path
+// is missing are left NULL. Without Fields the payload object is the row.
+// IgnoreUnknownValues drops values for columns the table doesn't have
+// instead of rejecting the row.
+type Config struct {
+	Project             string            `json:"project,omitempty"`
+	Dataset             string            `json:"dataset"`
+	Table               string            `json:"table"`
+	Fields              map[string]string `json:"fields,omitempty"`
+	IgnoreUnknownValues bool              `json:"ignore_unknown_values,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode bigquery config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the service account key.
+func Validate(cfg Config, secret string) error {
+	key, err := ParseKey(secret)
+	if err != nil {
+		return err
+	}
+	if cfg.Project == "" && key.ProjectID == "" {
+		return errors.New("project is required when the key doesn't name one")
+	}
+	if cfg.Project != "" && !projectPattern.MatchString(cfg.Project) {
+		return fmt.Errorf("invalid project %q", cfg.Project)
+	}
+	if len(cfg.Dataset) > maxName || !datasetPattern.MatchString(cfg.Dataset) {
+		return errors.New("dataset is required and may only hold letters, digits and underscores")
+	}
+	if len(cfg.Table) > maxName || !tablePattern.MatchString(cfg.Table) {
+		return errors.New("table is required and may only hold letters, digits, underscores and dashes")
+	}
+	for column, path := range cfg.Fields {
+		if !columnPattern.MatchString(column) {
+			return fmt.Errorf("invalid column name %q", column)
+		}
+		if !projection.ValidPath(path) {
+			return fmt.Errorf("invalid path %q for column %s", path, column)
+		}
+	}
+	return nil
+}
+
+// Key is a parsed service account key.
+type Key struct {
+	ProjectID   string
+	ClientEmail string
+	KeyID       string
+	private     *rsa.PrivateKey
+}
+
+// ParseKey parses a service account's JSON key, as downloaded from the
+// Google Cloud console.
+func ParseKey(secret string) (*Key, error) {
+	var raw struct {
+		Type         string `json:"type"`
+		ProjectID    string `json:"project_id"`
+		PrivateKeyID string `json:"private_key_id"`
+		PrivateKey   string `json:"private_key"`
+		ClientEmail  string `json:"client_email"`
+	}
+	if err := json.Unmarshal([]byte(secret), &raw); err != nil || raw.Type != "service_account" {
+		return nil, errors.New("bigquery actions need a service account JSON key as their secret")
+	}
+	if raw.ClientEmail == "" {
+		return nil, errors.New("service account key has no client_email")
+	}
+	block, _ := pem.Decode([]byte(raw.PrivateKey))
+	if block == nil {
+		return nil, errors.New("service account key has no PEM private_key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse service account private key: %w", err)
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account private key is not an RSA key")
+	}
+	return &Key{ProjectID: raw.ProjectID, ClientEmail: raw.ClientEmail, KeyID: raw.PrivateKeyID, private: private}, nil
+}
+
+// Token signs a self-signed JWT for the BigQuery API, which Google accepts
+// as a bearer token without an OAuth exchange.
+func (k *Key) Token(now time.Time) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if k.KeyID != "" {
+		header["kid"] = k.KeyID
+	}
+	claims := map[string]any{
+		"iss": k.ClientEmail,
+		"sub": k.ClientEmail,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(tokenLifetime).Unix(),
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signing := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	sum := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, k.private, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("sign bigquery token: %w", err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Row maps payload to a row for the config. Without Fields the payload must
+// be an object.
+func Row(cfg Config, payload json.RawMessage) (json.RawMessage, error) {
+	if len(cfg.Fields) == 0 {
+		trimmed := bytes.TrimSpace(payload)
+		if len(trimmed) == 0 || trimmed[0] != '{' {
+			return nil, errors.New("payload is not a JSON object; map it to columns with fields")
+		}
+		return trimmed, nil
+	}
+	row := make(map[string]json.RawMessage, len(cfg.Fields))
+	for column, path := range cfg.Fields {
+		if v, ok := projection.Value(payload, path); ok {
+			row[column] = v
+		}
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		return nil, fmt.Errorf("marshal bigquery row: %w", err)
+	}
+	return b, nil
+}
+
+// NewRequest builds the insertAll request streaming row, with the delivery
+// ID as its insertId so BigQuery drops a retried attempt's duplicate.
+func NewRequest(ctx context.Context, cfg Config, key *Key, deliveryID string, row json.RawMessage, now time.Time) (*http.Request, error) {
+	project := cfg.Project
+	if project == "" {
+		project = key.ProjectID
+	}
+	body, err := json.Marshal(map[string]any{
+		"rows":                []map[string]any{{"insertId": deliveryID, "json": row}},
+		"ignoreUnknownValues": cfg.IgnoreUnknownValues,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal bigquery request: %w", err)
+	}
+	token, err := key.Token(now)
+	if err != nil {
+		return nil, err
+	}
+	target := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll",
+		APIURL, url.PathEscape(project), url.PathEscape(cfg.Dataset), url.PathEscape(cfg.Table))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build bigquery request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// CheckResponse reports a failed request with Google's error message, and a
+// rejected row with BigQuery's insert errors, such as "row rejected: amount:
+// invalid: Cannot convert value to integer".
+func CheckResponse(status int, body []byte) error {
+	var res struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		InsertErrors []struct {
+			Errors []struct {
+				Reason   string `json:"reason"`
+				Location string `json:"location"`
+				Message  string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	decoded := json.Unmarshal(body, &res) == nil
+	if status < 200 || status >= 300 {
+		if decoded && res.Error.Message != "" {
+			return fmt.Errorf("HTTP %d: %s", status, res.Error.Message)
+		}
+		return fmt.Errorf("HTTP %d", status)
+	}
+	if !decoded || len(res.InsertErrors) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, ie := range res.InsertErrors {
+		for _, e := range ie.Errors {
+			msg := e.Reason + ": " + e.Message
+			if e.Location != "" {
+				msg = e.Location + ": " + msg
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) == 0 {
+		return errors.New("row rejected")
+	}
+	return errors.New("row rejected: " + strings.Join(msgs, "; "))
+}
@@ -0,0 +1,162 @@
+package bigquery
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "analytics-prod",
+		"private_key_id": "kid1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "relay@analytics-prod.iam.gserviceaccount.com",
+	})
+	return string(secret), private
+}
+
+func TestValidate(t *testing.T) {
+	secret, _ := testKey(t)
+	cases := []struct {
+		cfg    Config
+		secret string
+		ok     bool
+	}{
+		{Config{Dataset: "staging", Table: "webhook_events"}, secret, true},
+		{Config{Project: "other-project", Dataset: "staging", Table: "events", Fields: map[string]string{"order_id": "$.order.id"}}, secret, true},
+		{Config{Dataset: "staging", Table: "events"}, "", false},
+		{Config{Dataset: "staging", Table: "events"}, `{"type":"authorized_user"}`, false},
+		{Config{Project: "Bad_Project", Dataset: "staging", Table: "events"}, secret, false},
+		{Config{Table: "events"}, secret, false},
+		{Config{Dataset: "staging"}, secret, false},
+		{Config{Dataset: "staging.x", Table: "events"}, secret, false},
+		{Config{Dataset: "staging", Table: "events", Fields: map[string]string{"order-id": "$.id"}}, secret, false},
+		{Config{Dataset: "staging", Table: "events", Fields: map[string]string{"id": "$..id"}}, secret, false},
+	}
+	for _, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %v", tc.cfg, err, tc.ok)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	secret, private := testKey(t)
+	key, err := ParseKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	token, err := key.Token(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&private.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+		t.Fatalf("signature doesn't verify: %v", err)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims struct {
+		Iss string `json:"iss"`
+		Aud string `json:"aud"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	json.Unmarshal(raw, &claims)
+	if claims.Iss != key.ClientEmail || claims.Aud != audience || claims.Iat != now.Unix() || claims.Exp != now.Add(time.Hour).Unix() {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestRow(t *testing.T) {
+	payload := json.RawMessage(`{"order":{"id":"o1","total":12.5,"items":[1,2]},"env":"prod"}`)
+	cfg := Config{Fields: map[string]string{"order_id": "$.order.id", "total": "order.total", "items": "$.order.items", "missing": "$.nope"}}
+	row, err := Row(cfg, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"items":[1,2],"order_id":"o1","total":12.5}`; string(row) != want {
+		t.Errorf("Row() = %s, want %s", row, want)
+	}
+	if row, err := Row(Config{}, payload); err != nil || string(row) != string(payload) {
+		t.Errorf("Row() without fields = %s, %v", row, err)
+	}
+	if _, err := Row(Config{}, json.RawMessage(`[1]`)); err == nil {
+		t.Error("expected error for a non-object payload without fields")
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	secret, _ := testKey(t)
+	key, err := ParseKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dataset: "staging", Table: "events", IgnoreUnknownValues: true}
+	req, err := NewRequest(context.Background(), cfg, key, "d-1", json.RawMessage(`{"a":1}`), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := APIURL + "/projects/analytics-prod/datasets/staging/tables/events/insertAll"; req.URL.String() != want {
+		t.Errorf("URL = %s, want %s", req.URL, want)
+	}
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ey") {
+		t.Errorf("Authorization = %q", req.Header.Get("Authorization"))
+	}
+	b, _ := io.ReadAll(req.Body)
+	if want := `{"ignoreUnknownValues":true,"rows":[{"insertId":"d-1","json":{"a":1}}]}`; string(b) != want {
+		t.Errorf("body = %s, want %s", b, want)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{200, `{"kind":"bigquery#tableDataInsertAllResponse"}`, ""},
+		{200, `{"insertErrors":[{"index":0,"errors":[{"reason":"invalid","location":"amount","message":"Cannot convert value to integer"}]}]}`, "row rejected: amount: invalid: Cannot convert value to integer"},
+		{404, `{"error":{"code":404,"message":"Not found: Table p:d.t","status":"NOT_FOUND"}}`, "HTTP 404: Not found: Table p:d.t"},
+		{503, `unavailable`, "HTTP 503"},
+	}
+	for _, tc := range cases {
+		err := CheckResponse(tc.status, []byte(tc.body))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("CheckResponse(%d, %s) = %q, want %q", tc.status, tc.body, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package challenge answers the GET requests some providers send to validate
+// a webhook endpoint before enabling deliveries.
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+)
+
+// Challenge presets, selected per source.
+const (
+	// ModeEcho returns the "challenge" query parameter as plain text.
+	ModeEcho = "echo"
+	// ModeHub answers WebSub-style verification (Meta, WhatsApp, Strava):
+	// hub.verify_token must match the secret and hub.challenge is echoed.
+	ModeHub = "hub"
+	// ModeCRC answers a CRC check (X/Twitter): the response token is the
+	// base64 HMAC-SHA256 of crc_token keyed with the secret.
+	ModeCRC = "crc"
+)
+
+var (
+	ErrMissingChallenge = errors.New("missing challenge parameter")
+	ErrTokenMismatch    = errors.New("verify token does not match")
+	ErrMissingSecret    = errors.New("challenge secret not configured")
+)
+
+// Response is what to send back for a challenge.
+type Response struct {
+	ContentType string
+	Body        []byte
+}
+
+// ValidMode reports whether mode is a supported preset.
+func ValidMode(mode string) bool {
+	switch mode {
+	case ModeEcho, ModeHub, ModeCRC:
+		return true
+	}
+	return false
+}
+
+// NeedsSecret reports whether mode requires a secret.
+func NeedsSecret(mode string) bool {
+	return mode == ModeHub || mode == ModeCRC
+}
+
+// Respond computes the answer to a challenge request with the given query.
+func Respond(mode, secret string, query url.Values) (*Response, error) {
+	switch mode {
+	case ModeEcho:
+		v := query.Get("challenge")
+		if v == "" {
+			return nil, ErrMissingChallenge
+		}
+		return plain(v), nil
+	case ModeHub:
+		if secret == "" {
+			return nil, ErrMissingSecret
+		}
+		v := query.Get("hub.challenge")
+		if v == "" || query.Get("hub.mode") != "subscribe" {
+			return nil, ErrMissingChallenge
+		}
+		if subtle.ConstantTimeCompare([]byte(query.Get("hub.verify_token")), []byte(secret)) != 1 {
+			return nil, ErrTokenMismatch
+		}
+		return plain(v), nil
+	case ModeCRC:
+		if secret == "" {
+			return nil, ErrMissingSecret
+		}
+		v := query.Get("crc_token")
+		if v == "" {
+			return nil, ErrMissingChallenge
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(v))
+		body, _ := json.Marshal(map[string]string{
+			"response_token": "sha256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		})
+		return &Response{ContentType: "application/json", Body: body}, nil
+	}
+	return nil, errors.New("unsupported challenge mode " + mode)
+}
+
+func plain(v string) *Response {
+	return &Response{ContentType: "text/plain; charset=utf-8", Body: []byte(v)}
+}
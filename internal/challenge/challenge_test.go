@@ -0,0 +1,54 @@
+package challenge
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestRespondEcho(t *testing.T) {
+	resp, err := Respond(ModeEcho, "", url.Values{"challenge": {"abc123"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "abc123" {
+		t.Fatalf("expected echoed challenge, got %q", resp.Body)
+	}
+	if _, err := Respond(ModeEcho, "", url.Values{}); !errors.Is(err, ErrMissingChallenge) {
+		t.Fatalf("expected ErrMissingChallenge, got %v", err)
+	}
+}
+
+func TestRespondHub(t *testing.T) {
+	q := url.Values{"hub.mode": {"subscribe"}, "hub.verify_token": {"s3cret"}, "hub.challenge": {"1158201444"}}
+	resp, err := Respond(ModeHub, "s3cret", q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "1158201444" {
+		t.Fatalf("expected echoed hub.challenge, got %q", resp.Body)
+	}
+
+	q.Set("hub.verify_token", "wrong")
+	if _, err := Respond(ModeHub, "s3cret", q); !errors.Is(err, ErrTokenMismatch) {
+		t.Fatalf("expected ErrTokenMismatch, got %v", err)
+	}
+	if _, err := Respond(ModeHub, "", q); !errors.Is(err, ErrMissingSecret) {
+		t.Fatalf("expected ErrMissingSecret, got %v", err)
+	}
+}
+
+func TestRespondCRC(t *testing.T) {
+	resp, err := Respond(ModeCRC, "secret", url.Values{"crc_token": {"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// base64(HMAC-SHA256("secret", "token"))
+	want := `{"response_token":"sha256=6UERDj0r/oJiHw4+FDRzDXMF0QbF9oyHFl0LJ6RhGko="}`
+	if string(resp.Body) != want {
+		t.Fatalf("expected %s, got %s", want, resp.Body)
+	}
+	if resp.ContentType != "application/json" {
+		t.Fatalf("unexpected content type %q", resp.ContentType)
+	}
+}
@@ -0,0 +1,35 @@
+// Package clienttls builds TLS configs for outbound requests to targets that
+// require a client certificate (mTLS) or are served under a private CA.
+package clienttls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// Config returns a TLS config presenting the PEM certificate/key pair, if
+// given, and trusting caPEM instead of the system roots, if given. The
+// certificate and key must be given together.
+func Config(certPEM, keyPEM, caPEM string) (*tls.Config, error) {
+	if (certPEM == "") != (keyPEM == "") {
+		return nil, errors.New("client certificate and key must be set together")
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if caPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+			return nil, errors.New("ca bundle contains no PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
@@ -0,0 +1,63 @@
+package clienttls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nitrohook-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
+
+func TestConfig(t *testing.T) {
+	cert, key := selfSigned(t)
+
+	cfg, err := Config(cert, key, cert)
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if len(cfg.Certificates) != 1 || cfg.RootCAs == nil {
+		t.Errorf("config = %+v, want a client certificate and root CAs", cfg)
+	}
+
+	if _, err := Config(cert, "", ""); err == nil {
+		t.Error("expected error for certificate without key")
+	}
+	if _, err := Config("", "", "not pem"); err == nil {
+		t.Error("expected error for invalid CA bundle")
+	}
+	_, otherKey := selfSigned(t)
+	if _, err := Config(cert, otherKey, ""); err == nil {
+		t.Error("expected error for mismatched key")
+	}
+}
@@ -0,0 +1,244 @@
+// Package cloudevents reads and writes CloudEvents 1.0 over HTTP, in binary
+// mode (attributes in ce-* headers) and structured mode (a JSON envelope).
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	// ContentTypeStructured is the media type of a structured-mode event.
+	ContentTypeStructured = "application/cloudevents+json"
+	contentTypeBatch      = "application/cloudevents-batch+json"
+
+	ModeBinary     = "binary"
+	ModeStructured = "structured"
+
+	headerPrefix = "ce-"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported CloudEvents specversion")
+	ErrMissingAttribute   = errors.New("missing required CloudEvents attribute")
+	ErrBatch              = errors.New("batched CloudEvents are not supported")
+	ErrNonJSONData        = errors.New("CloudEvents data must be JSON")
+)
+
+// Attributes are an event's context attributes. Extensions hold any other
+// attributes, stringified.
+type Attributes struct {
+	SpecVersion     string            `json:"specversion"`
+	ID              string            `json:"id"`
+	Source          string            `json:"source"`
+	Type            string            `json:"type"`
+	Subject         string            `json:"subject,omitempty"`
+	Time            string            `json:"time,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"`
+	DataSchema      string            `json:"dataschema,omitempty"`
+	Extensions      map[string]string `json:"extensions,omitempty"`
+}
+
+// core lists the attributes with dedicated fields, in envelope order.
+var core = []string{"specversion", "id", "source", "type", "subject", "time", "datacontenttype", "dataschema"}
+
+func (a *Attributes) field(name string) *string {
+	switch name {
+	case "specversion":
+		return &a.SpecVersion
+	case "id":
+		return &a.ID
+	case "source":
+		return &a.Source
+	case "type":
+		return &a.Type
+	case "subject":
+		return &a.Subject
+	case "time":
+		return &a.Time
+	case "datacontenttype":
+		return &a.DataContentType
+	case "dataschema":
+		return &a.DataSchema
+	}
+	return nil
+}
+
+func (a *Attributes) set(name, value string) {
+	if f := a.field(name); f != nil {
+		*f = value
+		return
+	}
+	if a.Extensions == nil {
+		a.Extensions = map[string]string{}
+	}
+	a.Extensions[name] = value
+}
+
+func (a *Attributes) validate() error {
+	if a.SpecVersion != SpecVersion {
+		return fmt.Errorf("%w: %q", ErrUnsupportedVersion, a.SpecVersion)
+	}
+	for _, name := range []string{"id", "source", "type"} {
+		if *a.field(name) == "" {
+			return fmt.Errorf("%w: %s", ErrMissingAttribute, name)
+		}
+	}
+	return nil
+}
+
+// FromRequest extracts a CloudEvent from an HTTP request's headers and body.
+// It returns nil attributes (and the body unchanged) when the request isn't a
+// CloudEvent. data is the event's JSON data; an event without data gets {}.
+func FromRequest(header http.Header, body []byte) (attrs *Attributes, data []byte, err error) {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	switch {
+	case mediaType == ContentTypeStructured:
+		return parseStructured(body)
+	case mediaType == contentTypeBatch:
+		return nil, nil, ErrBatch
+	case header.Get(headerPrefix+"specversion") != "":
+		return parseBinary(header, body)
+	}
+	return nil, body, nil
+}
+
+func parseBinary(header http.Header, body []byte) (*Attributes, []byte, error) {
+	attrs := &Attributes{}
+	for key, values := range header {
+		name := strings.ToLower(key)
+		if !strings.HasPrefix(name, headerPrefix) || len(values) == 0 {
+			continue
+		}
+		attrs.set(strings.TrimPrefix(name, headerPrefix), values[0])
+	}
+	attrs.DataContentType = header.Get("Content-Type")
+	if err := attrs.validate(); err != nil {
+		return nil, nil, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return attrs, []byte(`{}`), nil
+	}
+	if !json.Valid(body) {
+		return nil, nil, ErrNonJSONData
+	}
+	return attrs, body, nil
+}
+
+func parseStructured(body []byte) (*Attributes, []byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("parse CloudEvents envelope: %w", err)
+	}
+
+	attrs := &Attributes{}
+	var data []byte
+	for name, raw := range envelope {
+		switch name {
+		case "data":
+			data = raw
+		case "data_base64":
+			var encoded string
+			if err := json.Unmarshal(raw, &encoded); err != nil {
+				return nil, nil, fmt.Errorf("parse data_base64: %w", err)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, nil, fmt.Errorf("decode data_base64: %w", err)
+			}
+			if !json.Valid(decoded) {
+				return nil, nil, ErrNonJSONData
+			}
+			data = decoded
+		default:
+			attrs.set(name, attributeString(raw))
+		}
+	}
+	if err := attrs.validate(); err != nil {
+		return nil, nil, err
+	}
+	if len(data) == 0 {
+		data = []byte(`{}`)
+	}
+	return attrs, data, nil
+}
+
+// attributeString renders an envelope attribute value as a string: strings
+// are unquoted, other JSON values keep their literal form.
+func attributeString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// BinaryHeaders returns the ce-* headers carrying attrs in binary mode. The
+// data content type is left to the caller's Content-Type header.
+func BinaryHeaders(attrs Attributes) map[string]string {
+	headers := map[string]string{}
+	for _, name := range core {
+		if name == "datacontenttype" {
+			continue
+		}
+		if v := *attrs.field(name); v != "" {
+			headers[headerPrefix+name] = v
+		}
+	}
+	for name, v := range attrs.Extensions {
+		headers[headerPrefix+name] = v
+	}
+	return headers
+}
+
+// Structured encodes attrs and JSON data as a structured-mode envelope.
+func Structured(attrs Attributes, data json.RawMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	write := func(name string, value json.RawMessage) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	str := func(s string) json.RawMessage {
+		b, _ := json.Marshal(s)
+		return b
+	}
+
+	for _, name := range core {
+		if v := *attrs.field(name); v != "" {
+			write(name, str(v))
+		}
+	}
+	names := make([]string, 0, len(attrs.Extensions))
+	for name := range attrs.Extensions {
+		if name != "data" && name != "data_base64" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		write(name, str(attrs.Extensions[name]))
+	}
+	if !json.Valid(data) {
+		return nil, ErrNonJSONData
+	}
+	write("data", data)
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
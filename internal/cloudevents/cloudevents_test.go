@@ -0,0 +1,118 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestFromRequest_Binary(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	h.Set("Ce-Specversion", "1.0")
+	h.Set("Ce-Id", "evt-1")
+	h.Set("Ce-Source", "/orders")
+	h.Set("Ce-Type", "order.created")
+	h.Set("Ce-Traceparent", "00-abc-01")
+
+	attrs, data, err := FromRequest(h, []byte(`{"n":1}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attrs == nil || attrs.ID != "evt-1" || attrs.Source != "/orders" || attrs.Type != "order.created" {
+		t.Fatalf("unexpected attributes: %+v", attrs)
+	}
+	if attrs.DataContentType != "application/json" || attrs.Extensions["traceparent"] != "00-abc-01" {
+		t.Fatalf("unexpected attributes: %+v", attrs)
+	}
+	if string(data) != `{"n":1}` {
+		t.Fatalf("unexpected data: %s", data)
+	}
+}
+
+func TestFromRequest_Structured(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	body := `{"specversion":"1.0","id":"evt-2","source":"/billing","type":"invoice.paid","sequence":7,"data":{"amount":5}}`
+
+	attrs, data, err := FromRequest(h, []byte(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attrs.ID != "evt-2" || attrs.Extensions["sequence"] != "7" {
+		t.Fatalf("unexpected attributes: %+v", attrs)
+	}
+	if string(data) != `{"amount":5}` {
+		t.Fatalf("unexpected data: %s", data)
+	}
+}
+
+func TestFromRequest_NotCloudEvent(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	attrs, data, err := FromRequest(h, []byte(`{"a":1}`))
+	if err != nil || attrs != nil || string(data) != `{"a":1}` {
+		t.Fatalf("expected passthrough, got %+v %s %v", attrs, data, err)
+	}
+}
+
+func TestFromRequest_Invalid(t *testing.T) {
+	h := http.Header{}
+	h.Set("Ce-Specversion", "1.0")
+	h.Set("Ce-Id", "evt-3")
+	if _, _, err := FromRequest(h, nil); !errors.Is(err, ErrMissingAttribute) {
+		t.Fatalf("expected ErrMissingAttribute, got: %v", err)
+	}
+
+	h.Set("Ce-Specversion", "0.3")
+	if _, _, err := FromRequest(h, nil); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got: %v", err)
+	}
+
+	batch := http.Header{}
+	batch.Set("Content-Type", "application/cloudevents-batch+json")
+	if _, _, err := FromRequest(batch, []byte(`[]`)); !errors.Is(err, ErrBatch) {
+		t.Fatalf("expected ErrBatch, got: %v", err)
+	}
+}
+
+func TestStructuredRoundTrip(t *testing.T) {
+	attrs := Attributes{
+		SpecVersion: SpecVersion,
+		ID:          "evt-4",
+		Source:      "/src",
+		Type:        "thing.happened",
+		Extensions:  map[string]string{"partitionkey": "p1"},
+	}
+	body, err := Structured(attrs, json.RawMessage(`{"x":true}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h := http.Header{}
+	h.Set("Content-Type", ContentTypeStructured)
+	got, data, err := FromRequest(h, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != "evt-4" || got.Extensions["partitionkey"] != "p1" || string(data) != `{"x":true}` {
+		t.Fatalf("round trip mismatch: %+v %s", got, data)
+	}
+}
+
+func TestBinaryHeaders(t *testing.T) {
+	headers := BinaryHeaders(Attributes{
+		SpecVersion:     SpecVersion,
+		ID:              "evt-5",
+		Source:          "/src",
+		Type:            "t",
+		DataContentType: "application/json",
+	})
+	if headers["ce-id"] != "evt-5" || headers["ce-specversion"] != "1.0" {
+		t.Fatalf("unexpected headers: %v", headers)
+	}
+	if _, ok := headers["ce-datacontenttype"]; ok {
+		t.Fatalf("datacontenttype belongs in Content-Type: %v", headers)
+	}
+}
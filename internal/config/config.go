@@ -1,9 +1,24 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/zachbroad/nitrohook/internal/archive"
+	"github.com/zachbroad/nitrohook/internal/email"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/ingestlimit"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/outbound"
+	"github.com/zachbroad/nitrohook/internal/proxy"
+	"github.com/zachbroad/nitrohook/internal/slo"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
 )
 
 type Config struct {
@@ -11,10 +26,142 @@ type Config struct {
 	RedisURL          string
 	Port              string
 	WorkerConcurrency int
-	MaxRetries        int
-	RetryBaseDelay    time.Duration
-	DeliveryTimeout   time.Duration
-	PollInterval      time.Duration
+	FanoutParallelism int
+	// WorkerBatchSize is how many stream messages a consumer reads at once,
+	// WorkerBlockTimeout how long a read waits for messages, and
+	// WorkerPrefetch how many read messages a consumer processes
+	// concurrently.
+	WorkerBatchSize    int
+	WorkerBlockTimeout time.Duration
+	WorkerPrefetch     int
+	// WorkerReportInterval is how often a worker publishes its resource
+	// use to the worker registry; zero disables it.
+	WorkerReportInterval time.Duration
+
+	MaxRetries      int
+	RetryBaseDelay  time.Duration
+	DeliveryTimeout time.Duration
+	PollInterval    time.Duration
+	// StreamTrim is how the deliveries stream is trimmed: "length" keeps
+	// about StreamMaxLen entries, "ttl" drops entries older than
+	// StreamMaxAge and "none" never trims.
+	StreamTrim   string
+	StreamMaxLen int
+	StreamMaxAge time.Duration
+	// SchedulerLeaseTTL is how long the elected scheduler's lease lasts
+	// without renewal, bounding failover time.
+	SchedulerLeaseTTL time.Duration
+	// CircuitBreakerThreshold is the number of consecutive failures that
+	// opens a target's circuit; zero disables the breaker.
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+
+	StatementTimeout time.Duration
+
+	LogFormat     string  // "text" or "json"
+	LogLevel      string  // debug, info, warn, error
+	LogSampleRate float64 // fraction of per-delivery info logs kept
+
+	// Ingest group commit; disabled when IngestBatchWindow is zero.
+	IngestBatchWindow       time.Duration
+	IngestBatchSize         int
+	IngestSynchronousCommit bool
+	// IngestFastPath acknowledges active-mode deliveries once they're on the
+	// Redis stream; the worker persists them to Postgres.
+	IngestFastPath bool
+	// IngestSyncTimeout bounds how long ingest waits for sources in the
+	// "delivered" ack mode.
+	IngestSyncTimeout time.Duration
+	// IngestRateWarnAt is the fraction of a source's ingest rate limit past
+	// which responses carry a warning.
+	IngestRateWarnAt float64
+
+	// MetaWebhookURL receives events about the relay itself, such as sources
+	// nearing their ingest rate limit, signed with MetaWebhookSecret if set.
+	MetaWebhookURL    string
+	MetaWebhookSecret string
+
+	// SLOTarget and SLOWindow are the delivery success objective actions
+	// are held to unless they set their own target.
+	SLOTarget float64
+	SLOWindow time.Duration
+
+	// Global limits; sources may lower but not raise them.
+	MaxPayloadBytes  int
+	MaxResponseBytes int
+	MaxScriptTimeout time.Duration
+
+	// MaxSources and MaxActionsPerSource cap how many can be created; zero
+	// is unlimited.
+	MaxSources          int
+	MaxActionsPerSource int
+
+	// RequireTargetVerification blocks activating webhook actions until the
+	// target domain's ownership has been proven (multi-tenant deployments).
+	RequireTargetVerification bool
+
+	// PublicBaseURL is the externally visible base URL used for generated
+	// webhook URLs; when empty they are derived from the request.
+	PublicBaseURL string
+	// TrustedProxies are the IPs/CIDRs whose forwarding headers
+	// (X-Forwarded-*, Forwarded) are believed. Empty trusts none.
+	TrustedProxies []string
+
+	// Archiving moves settled deliveries older than ArchiveAfterDays to
+	// S3-compatible storage; off when zero or without a bucket.
+	ArchiveAfterDays   int
+	ArchiveS3Bucket    string
+	ArchiveS3Region    string
+	ArchiveS3Endpoint  string
+	ArchiveS3AccessKey string
+	ArchiveS3SecretKey string
+	ArchiveS3Prefix    string
+
+	// ManifestSigningKey is a base64-encoded Ed25519 seed used to sign audit
+	// manifests. Manifest endpoints are disabled when empty.
+	ManifestSigningKey string
+
+	// ResponseBodyKey is a base64-encoded 32-byte AES key; attempt response
+	// bodies are stored encrypted when set.
+	ResponseBodyKey string
+	// ResponseBodyToken, when set, is the bearer token required to read
+	// attempt response bodies through the API.
+	ResponseBodyToken string
+
+	// SecretsKey is a base64-encoded 32-byte AES key sealing secrets stored
+	// on actions, such as client certificate keys. Storing them is refused
+	// without it.
+	SecretsKey string
+
+	// OutboundProxyURL is an http, https or socks5 proxy that webhook
+	// requests egress through, e.g. for a static IP receivers can allowlist.
+	// Actions can override it.
+	OutboundProxyURL string
+
+	// OutboundLocalAddr or OutboundInterface pins the source address webhook
+	// requests (or their proxy connections) originate from, e.g. a NAT'd
+	// address partners allowlist. OutboundPreferIP ("ipv4" or "ipv6") dials
+	// targets' addresses of that family first.
+	OutboundLocalAddr string
+	OutboundInterface string
+	OutboundPreferIP  string
+	// EgressIPs are the IPs/CIDRs outbound requests leave the deployment
+	// from, published at GET /api/egress for receivers to allowlist.
+	EgressIPs []string
+
+	// AllowPrivateTargets lets actions target loopback, private, link-local
+	// and metadata addresses, for self-hosted setups delivering to internal
+	// services.
+	AllowPrivateTargets bool
+
+	// SMTP is the outgoing mail server smtp actions send through; they fail
+	// without SMTPHost and SMTPFrom. SMTPTLS is starttls, tls or none.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTLS      string
 }
 
 func Load() Config {
@@ -23,11 +170,193 @@ func Load() Config {
 		RedisURL:          envOrDefault("REDIS_URL", "redis://localhost:6379"),
 		Port:              envOrDefault("PORT", "8080"),
 		WorkerConcurrency: envOrDefaultInt("WORKER_CONCURRENCY", 4),
+		FanoutParallelism: envOrDefaultInt("FANOUT_PARALLELISM", 4),
+
+		WorkerBatchSize:    envOrDefaultInt("WORKER_BATCH_SIZE", 1),
+		WorkerBlockTimeout: envOrDefaultDuration("WORKER_BLOCK_TIMEOUT", 5*time.Second),
+		WorkerPrefetch:     envOrDefaultInt("WORKER_PREFETCH", 1),
+
+		WorkerReportInterval: envOrDefaultDuration("WORKER_REPORT_INTERVAL", 15*time.Second),
+
+		StreamTrim:   envOrDefault("STREAM_TRIM", streamtrim.Length),
+		StreamMaxLen: envOrDefaultInt("STREAM_MAX_LEN", streamtrim.DefaultMaxLen),
+		StreamMaxAge: envOrDefaultDuration("STREAM_MAX_AGE", 24*time.Hour),
+
 		MaxRetries:        envOrDefaultInt("MAX_RETRIES", 5),
 		RetryBaseDelay:    envOrDefaultDuration("RETRY_BASE_DELAY", 5*time.Second),
 		DeliveryTimeout:   envOrDefaultDuration("DELIVERY_TIMEOUT", 10*time.Second),
 		PollInterval:      envOrDefaultDuration("POLL_INTERVAL", 30*time.Second),
+		SchedulerLeaseTTL: envOrDefaultDuration("SCHEDULER_LEASE_TTL", 15*time.Second),
+
+		CircuitBreakerThreshold: envOrDefaultInt("CIRCUIT_BREAKER_THRESHOLD", 5),
+		CircuitBreakerCooldown:  envOrDefaultDuration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second),
+
+		StatementTimeout: envOrDefaultDuration("DB_STATEMENT_TIMEOUT", 5*time.Second),
+
+		LogFormat:     envOrDefault("LOG_FORMAT", "text"),
+		LogLevel:      envOrDefault("LOG_LEVEL", "info"),
+		LogSampleRate: envOrDefaultFloat("LOG_SAMPLE_RATE", 1),
+
+		IngestBatchWindow:       envOrDefaultDuration("INGEST_BATCH_WINDOW", 0),
+		IngestBatchSize:         envOrDefaultInt("INGEST_BATCH_SIZE", 100),
+		IngestSynchronousCommit: envOrDefaultBool("INGEST_SYNCHRONOUS_COMMIT", true),
+		IngestFastPath:          envOrDefaultBool("INGEST_FAST_PATH", false),
+		IngestSyncTimeout:       envOrDefaultDuration("INGEST_SYNC_TIMEOUT", 10*time.Second),
+		IngestRateWarnAt:        envOrDefaultFloat("INGEST_RATE_WARN_AT", ingestlimit.DefaultWarnAt),
+		MetaWebhookURL:          os.Getenv("META_WEBHOOK_URL"),
+		MetaWebhookSecret:       os.Getenv("META_WEBHOOK_SECRET"),
+		SLOTarget:               envOrDefaultFloat("SLO_TARGET", slo.DefaultTarget),
+		SLOWindow:               envOrDefaultDuration("SLO_WINDOW", slo.DefaultWindow),
+
+		MaxPayloadBytes:  envOrDefaultInt("MAX_PAYLOAD_BYTES", 1<<20),
+		MaxResponseBytes: envOrDefaultInt("MAX_RESPONSE_BYTES", 4096),
+		MaxScriptTimeout: envOrDefaultDuration("MAX_SCRIPT_TIMEOUT", 500*time.Millisecond),
+
+		MaxSources:          envOrDefaultInt("MAX_SOURCES", 1000),
+		MaxActionsPerSource: envOrDefaultInt("MAX_ACTIONS_PER_SOURCE", 100),
+
+		RequireTargetVerification: envOrDefaultBool("REQUIRE_TARGET_VERIFICATION", false),
+		PublicBaseURL:             os.Getenv("PUBLIC_BASE_URL"),
+		TrustedProxies:            envList("TRUSTED_PROXIES"),
+		ManifestSigningKey:        os.Getenv("MANIFEST_SIGNING_KEY"),
+		ResponseBodyKey:           os.Getenv("RESPONSE_BODY_KEY"),
+		ResponseBodyToken:         os.Getenv("RESPONSE_BODY_TOKEN"),
+		SecretsKey:                os.Getenv("SECRETS_KEY"),
+		OutboundProxyURL:          os.Getenv("OUTBOUND_PROXY_URL"),
+		OutboundLocalAddr:         os.Getenv("OUTBOUND_LOCAL_ADDR"),
+		OutboundInterface:         os.Getenv("OUTBOUND_INTERFACE"),
+		OutboundPreferIP:          os.Getenv("OUTBOUND_PREFER_IP"),
+		EgressIPs:                 envList("EGRESS_IPS"),
+		AllowPrivateTargets:       envOrDefaultBool("ALLOW_PRIVATE_TARGETS", false),
+		SMTPHost:                  os.Getenv("SMTP_HOST"),
+		SMTPPort:                  envOrDefaultInt("SMTP_PORT", 587),
+		SMTPUsername:              os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:              os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:                  os.Getenv("SMTP_FROM"),
+		SMTPTLS:                   envOrDefault("SMTP_TLS", email.TLSStartTLS),
+
+		ArchiveAfterDays:   envOrDefaultInt("ARCHIVE_AFTER_DAYS", 0),
+		ArchiveS3Bucket:    os.Getenv("ARCHIVE_S3_BUCKET"),
+		ArchiveS3Region:    envOrDefault("ARCHIVE_S3_REGION", "us-east-1"),
+		ArchiveS3Endpoint:  os.Getenv("ARCHIVE_S3_ENDPOINT"),
+		ArchiveS3AccessKey: os.Getenv("ARCHIVE_S3_ACCESS_KEY_ID"),
+		ArchiveS3SecretKey: os.Getenv("ARCHIVE_S3_SECRET_ACCESS_KEY"),
+		ArchiveS3Prefix:    envOrDefault("ARCHIVE_S3_PREFIX", "deliveries"),
+	}
+}
+
+// ArchiveObjects returns the archive object store, or nil when no bucket is
+// configured.
+func (c Config) ArchiveObjects() (archive.ObjectStore, error) {
+	if c.ArchiveS3Bucket == "" {
+		return nil, nil
+	}
+	return archive.NewS3(c.ArchiveS3Endpoint, c.ArchiveS3Bucket, c.ArchiveS3Region, c.ArchiveS3AccessKey, c.ArchiveS3SecretKey)
+}
+
+// ResponseCipher returns the cipher for attempt response bodies, or nil when
+// no key is configured.
+func (c Config) ResponseCipher() (*encryption.Cipher, error) {
+	if c.ResponseBodyKey == "" {
+		return nil, nil
+	}
+	return encryption.NewCipher(c.ResponseBodyKey)
+}
+
+// SecretsCipher returns the cipher for secrets stored on actions, or nil when
+// no key is configured.
+func (c Config) SecretsCipher() (*encryption.Cipher, error) {
+	if c.SecretsKey == "" {
+		return nil, nil
+	}
+	return encryption.NewCipher(c.SecretsKey)
+}
+
+// OutboundProxy returns the proxy for webhook requests, or nil for direct
+// connections.
+func (c Config) OutboundProxy() (*url.URL, error) {
+	if c.OutboundProxyURL == "" {
+		return nil, nil
+	}
+	return proxy.ParseOutbound(c.OutboundProxyURL)
+}
+
+// OutboundClients returns the clients webhook requests are sent with;
+// secrets opens actions' sealed client keys.
+func (c Config) OutboundClients(secrets *encryption.Cipher) (*outbound.Clients, error) {
+	proxyURL, err := c.OutboundProxy()
+	if err != nil {
+		return nil, err
+	}
+	egress, err := outbound.ParseEgress(c.OutboundLocalAddr, c.OutboundInterface, c.OutboundPreferIP)
+	if err != nil {
+		return nil, fmt.Errorf("outbound egress: %w", err)
+	}
+	return outbound.NewClients(c.DeliveryTimeout, proxyURL, ssrf.NewGuard(c.AllowPrivateTargets), secrets, egress), nil
+}
+
+// EgressPrefixes parses EgressIPs; a bare address is a single-address
+// prefix.
+func (c Config) EgressPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.EgressIPs))
+	for _, s := range c.EgressIPs {
+		if ip, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("EGRESS_IPS: invalid IP or CIDR %q", s)
+		}
+		prefixes = append(prefixes, p.Masked())
 	}
+	return prefixes, nil
+}
+
+// SMTPServer returns the outgoing mail server for smtp actions.
+func (c Config) SMTPServer() (email.Server, error) {
+	if !email.ValidTLS(c.SMTPTLS) {
+		return email.Server{}, fmt.Errorf("SMTP_TLS must be starttls, tls or none, got %q", c.SMTPTLS)
+	}
+	return email.Server{
+		Host:     c.SMTPHost,
+		Port:     c.SMTPPort,
+		Username: c.SMTPUsername,
+		Password: c.SMTPPassword,
+		From:     c.SMTPFrom,
+		TLS:      c.SMTPTLS,
+		Timeout:  c.DeliveryTimeout,
+	}, nil
+}
+
+// SLO returns the global delivery success objective.
+func (c Config) SLO() (slo.Objective, error) {
+	if err := slo.ValidTarget(c.SLOTarget); err != nil {
+		return slo.Objective{}, fmt.Errorf("SLO_TARGET: %w", err)
+	}
+	if c.SLOWindow < slo.BurnWindow {
+		return slo.Objective{}, fmt.Errorf("SLO_WINDOW must be at least %s", slo.BurnWindow)
+	}
+	return slo.Objective{Target: c.SLOTarget, Window: c.SLOWindow}, nil
+}
+
+// StreamTrimPolicy returns how XADD trims the deliveries stream.
+func (c Config) StreamTrimPolicy() (streamtrim.Policy, error) {
+	return streamtrim.Parse(c.StreamTrim, int64(c.StreamMaxLen), c.StreamMaxAge)
+}
+
+// Limits returns the global resource limits.
+func (c Config) Limits() model.Limits {
+	return model.Limits{
+		MaxPayloadBytes:  c.MaxPayloadBytes,
+		MaxResponseBytes: c.MaxResponseBytes,
+		ScriptTimeoutMs:  int(c.MaxScriptTimeout.Milliseconds()),
+	}
+}
+
+// Caps returns the caps on sources and actions.
+func (c Config) Caps() model.Caps {
+	return model.Caps{MaxSources: c.MaxSources, MaxActionsPerSource: c.MaxActionsPerSource}
 }
 
 func envOrDefault(key, fallback string) string {
@@ -54,3 +383,32 @@ func envOrDefaultDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return fallback
 }
+
+func envOrDefaultBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+func envOrDefaultFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+// envList splits a comma-separated variable, dropping empty entries.
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+// Package credential validates stored destination credentials and turns
+// them into the auth header webhook requests carry, fetching and caching
+// OAuth2 client-credentials tokens.
+package credential
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/outbound"
+)
+
+// Config holds a credential's non-secret settings; which fields apply
+// depends on the type. The secret is the bearer token, basic auth password,
+// header value or OAuth2 client secret.
+type Config struct {
+	// Username is the basic auth username.
+	Username string `json:"username,omitempty"`
+	// Header is the header a "header" credential sets.
+	Header string `json:"header,omitempty"`
+	// TokenURL, ClientID, Scopes and Audience configure the OAuth2 client
+	// credentials grant.
+	TokenURL string   `json:"token_url,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Audience string   `json:"audience,omitempty"`
+}
+
+// ParseConfig decodes a credential's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode credential config: %w", err)
+	}
+	return cfg, nil
+}
+
+// reservedHeaders are set by the dispatcher and can't be overridden.
+var reservedHeaders = map[string]bool{
+	"Content-Type":   true,
+	"Content-Length": true,
+	"Host":           true,
+	"X-Delivery-Id":  true,
+	http.CanonicalHeaderKey(outbound.SignatureHeader): true,
+}
+
+// Validate checks a credential of the given type with its secret.
+func Validate(credType string, cfg Config, secret string) error {
+	if secret == "" {
+		return errors.New("secret is required")
+	}
+	oauth := cfg.TokenURL != "" || cfg.ClientID != "" || len(cfg.Scopes) > 0 || cfg.Audience != ""
+	switch credType {
+	case model.CredentialBearer:
+		if cfg.Username != "" || cfg.Header != "" || oauth {
+			return errors.New("bearer credentials take only a secret")
+		}
+	case model.CredentialBasic:
+		if cfg.Username == "" || strings.Contains(cfg.Username, ":") {
+			return errors.New("basic credentials need a username without a colon")
+		}
+		if cfg.Header != "" || oauth {
+			return errors.New("basic credentials take only username and secret")
+		}
+	case model.CredentialHeader:
+		if !validHeaderName(cfg.Header) {
+			return errors.New("header must be a valid header name")
+		}
+		if reservedHeaders[http.CanonicalHeaderKey(cfg.Header)] {
+			return fmt.Errorf("header %s is set by the dispatcher", cfg.Header)
+		}
+		if strings.ContainsAny(secret, "\r\n") {
+			return errors.New("header value must not contain line breaks")
+		}
+		if cfg.Username != "" || oauth {
+			return errors.New("header credentials take only header and secret")
+		}
+	case model.CredentialOAuth2:
+		u, err := url.Parse(cfg.TokenURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New("token_url must be an http(s) URL")
+		}
+		if cfg.ClientID == "" {
+			return errors.New("client_id is required")
+		}
+		if cfg.Username != "" || cfg.Header != "" {
+			return errors.New("oauth2 credentials take token_url, client_id, scopes, audience and secret")
+		}
+	default:
+		return fmt.Errorf("type must be %s, %s, %s or %s", model.CredentialBearer, model.CredentialBasic, model.CredentialHeader, model.CredentialOAuth2)
+	}
+	return nil
+}
+
+func validHeaderName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > 127 || !(r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// StaticHeader returns the header a bearer, basic or header credential sets.
+func StaticHeader(credType string, cfg Config, secret string) (name, value string) {
+	switch credType {
+	case model.CredentialBasic:
+		return "Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+secret))
+	case model.CredentialHeader:
+		return cfg.Header, secret
+	default:
+		return "Authorization", "Bearer " + secret
+	}
+}
@@ -0,0 +1,93 @@
+package credential
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		cfg     Config
+		secret  string
+		wantErr bool
+	}{
+		{"bearer", model.CredentialBearer, Config{}, "tok", false},
+		{"bearer without secret", model.CredentialBearer, Config{}, "", true},
+		{"bearer with username", model.CredentialBearer, Config{Username: "u"}, "tok", true},
+		{"basic", model.CredentialBasic, Config{Username: "u"}, "pw", false},
+		{"basic without username", model.CredentialBasic, Config{}, "pw", true},
+		{"header", model.CredentialHeader, Config{Header: "X-Api-Key"}, "k", false},
+		{"reserved header", model.CredentialHeader, Config{Header: "content-type"}, "k", true},
+		{"bad header name", model.CredentialHeader, Config{Header: "X Key"}, "k", true},
+		{"header value newline", model.CredentialHeader, Config{Header: "X-Key"}, "a\nb", true},
+		{"oauth2", model.CredentialOAuth2, Config{TokenURL: "https://auth.example.com/token", ClientID: "id"}, "s", false},
+		{"oauth2 bad url", model.CredentialOAuth2, Config{TokenURL: "ftp://x", ClientID: "id"}, "s", true},
+		{"oauth2 without client", model.CredentialOAuth2, Config{TokenURL: "https://auth.example.com/token"}, "s", true},
+		{"unknown type", "digest", Config{}, "s", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.typ, tt.cfg, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfigRejectsUnknownFields(t *testing.T) {
+	if _, err := ParseConfig([]byte(`{"user":"x"}`)); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
+
+func TestStaticHeader(t *testing.T) {
+	name, value := StaticHeader(model.CredentialBasic, Config{Username: "user"}, "pass")
+	if name != "Authorization" || value != "Basic dXNlcjpwYXNz" {
+		t.Fatalf("basic = %s: %s", name, value)
+	}
+	name, value = StaticHeader(model.CredentialHeader, Config{Header: "X-Api-Key"}, "k")
+	if name != "X-Api-Key" || value != "k" {
+		t.Fatalf("header = %s: %s", name, value)
+	}
+	if _, value = StaticHeader(model.CredentialBearer, Config{}, "tok"); value != "Bearer tok" {
+		t.Fatalf("bearer = %s", value)
+	}
+}
+
+func TestFetchToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "id" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "a b" || r.FormValue("audience") != "api" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+
+	cfg := Config{TokenURL: srv.URL, ClientID: "id", Scopes: []string{"a", "b"}, Audience: "api"}
+	tok, ttl, err := FetchToken(context.Background(), srv.Client(), cfg, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != "abc" || ttl != time.Hour-expirySkew {
+		t.Fatalf("got %q %v", tok, ttl)
+	}
+
+	if _, _, err := FetchToken(context.Background(), srv.Client(), cfg, "wrong"); err == nil {
+		t.Fatal("expected error for rejected client")
+	}
+}
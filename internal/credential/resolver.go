@@ -0,0 +1,188 @@
+package credential
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+const (
+	// expirySkew renews tokens this long before they expire.
+	expirySkew = 30 * time.Second
+	// defaultTokenTTL is how long tokens without expires_in are cached.
+	defaultTokenTTL  = 5 * time.Minute
+	maxTokenResponse = 64 << 10
+)
+
+// Resolver looks up actions' credentials and returns the header to send,
+// caching OAuth2 tokens until shortly before they expire.
+type Resolver struct {
+	store   *store.Store
+	secrets *encryption.Cipher
+
+	mu     sync.Mutex
+	tokens map[uuid.UUID]token
+}
+
+type token struct {
+	value   string
+	expires time.Time
+	// version is the credential's updated_at, so edits drop the token.
+	version time.Time
+}
+
+// NewResolver creates a Resolver; secrets opens the sealed secrets.
+func NewResolver(s *store.Store, secrets *encryption.Cipher) *Resolver {
+	return &Resolver{store: s, secrets: secrets, tokens: map[uuid.UUID]token{}}
+}
+
+// Header returns the header name and value for the credential. OAuth2
+// tokens are fetched with client.
+func (r *Resolver) Header(ctx context.Context, client *http.Client, id uuid.UUID) (name, value string, err error) {
+	cred, err := r.store.Credentials.GetByID(ctx, id)
+	if err != nil {
+		return "", "", err
+	}
+	return r.header(ctx, client, cred)
+}
+
+func (r *Resolver) header(ctx context.Context, client *http.Client, cred *model.Credential) (name, value string, err error) {
+	secret, err := r.secrets.Open(cred.Secret)
+	if err != nil {
+		return "", "", fmt.Errorf("open credential secret: %w", err)
+	}
+	cfg, err := ParseConfig(cred.Config)
+	if err != nil {
+		return "", "", err
+	}
+	if cred.Type != model.CredentialOAuth2 {
+		name, value = StaticHeader(cred.Type, cfg, secret)
+		return name, value, nil
+	}
+	tok, err := r.token(ctx, client, cred, cfg, secret)
+	if err != nil {
+		return "", "", err
+	}
+	return "Authorization", "Bearer " + tok, nil
+}
+
+// ActionHeader returns the auth header for the action's webhook requests,
+// from its own auth settings or its stored credential; ok is false when it
+// has neither.
+func (r *Resolver) ActionHeader(ctx context.Context, client *http.Client, a *model.Action) (name, value string, ok bool, err error) {
+	switch {
+	case a.AuthType != nil && a.AuthSecret != nil:
+		secret, err := r.secrets.Open(*a.AuthSecret)
+		if err != nil {
+			return "", "", false, fmt.Errorf("open action auth secret: %w", err)
+		}
+		cfg, err := ParseConfig(a.AuthConfig)
+		if err != nil {
+			return "", "", false, err
+		}
+		name, value = StaticHeader(*a.AuthType, cfg, secret)
+		return name, value, true, nil
+	case a.CredentialID != nil:
+		name, value, err = r.Header(ctx, client, *a.CredentialID)
+		return name, value, err == nil, err
+	}
+	return "", "", false, nil
+}
+
+// Renew fetches a new OAuth2 token for the credential, for when the
+// receiver rejected the cached one, and returns the header carrying it.
+// renewed is false for other credential types, which have nothing to renew.
+func (r *Resolver) Renew(ctx context.Context, client *http.Client, id uuid.UUID) (name, value string, renewed bool, err error) {
+	cred, err := r.store.Credentials.GetByID(ctx, id)
+	if err != nil {
+		return "", "", false, err
+	}
+	if cred.Type != model.CredentialOAuth2 {
+		return "", "", false, nil
+	}
+	r.mu.Lock()
+	delete(r.tokens, id)
+	r.mu.Unlock()
+	name, value, err = r.header(ctx, client, cred)
+	return name, value, err == nil, err
+}
+
+func (r *Resolver) token(ctx context.Context, client *http.Client, cred *model.Credential, cfg Config, secret string) (string, error) {
+	r.mu.Lock()
+	cached, ok := r.tokens[cred.ID]
+	r.mu.Unlock()
+	if ok && cached.version.Equal(cred.UpdatedAt) && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	value, ttl, err := FetchToken(ctx, client, cfg, secret)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.tokens[cred.ID] = token{value: value, expires: time.Now().Add(ttl), version: cred.UpdatedAt}
+	r.mu.Unlock()
+	return value, nil
+}
+
+// FetchToken runs the OAuth2 client credentials grant, authenticating with
+// HTTP basic auth, and returns the access token and how long to cache it.
+func FetchToken(ctx context.Context, client *http.Client, cfg Config, secret string) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	if cfg.Audience != "" {
+		form.Set("audience", cfg.Audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(secret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("fetch oauth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponse))
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	_ = json.Unmarshal(body, &res)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if res.Error != "" {
+			return "", 0, fmt.Errorf("fetch oauth2 token: HTTP %d: %s", resp.StatusCode, res.Error)
+		}
+		return "", 0, fmt.Errorf("fetch oauth2 token: HTTP %d", resp.StatusCode)
+	}
+	if res.AccessToken == "" {
+		return "", 0, fmt.Errorf("fetch oauth2 token: response has no access_token")
+	}
+	if res.TokenType != "" && !strings.EqualFold(res.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("fetch oauth2 token: unsupported token type %q", res.TokenType)
+	}
+	ttl := defaultTokenTTL
+	if res.ExpiresIn > 0 {
+		ttl = max(time.Duration(res.ExpiresIn)*time.Second-expirySkew, 0)
+	}
+	return res.AccessToken, ttl, nil
+}
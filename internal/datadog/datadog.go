@@ -0,0 +1,233 @@
+// Package datadog creates Datadog events from deliveries through the v1
+// Events API.
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// DefaultSite is the Datadog site used when an action sets none.
+const DefaultSite = "datadoghq.com"
+
+// Sites are the Datadog sites events can be sent to; the API host is
+// "api." followed by the site.
+var Sites = []string{"datadoghq.com", "us3.datadoghq.com", "us5.datadoghq.com", "datadoghq.eu", "ap1.datadoghq.com", "ap2.datadoghq.com", "ddog-gov.com"}
+
+// AlertTypes are the alert_type values the Events API accepts.
+var AlertTypes = []string{"error", "warning", "info", "success", "user_update", "recommendation", "snapshot"}
+
+// Field limits from the Events API; longer values are truncated.
+const (
+	maxTitle          = 100
+	maxText           = 4000
+	maxAggregationKey = 100
+	maxTag            = 200
+)
+
+// DefaultTitle is used when an action has no title template.
+const DefaultTitle = "New delivery"
+
+var apiKeyPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Config is a Datadog action's config. Title, Text, AlertType,
+// AggregationKey and each of Tags are reqtemplates over the payload, such
+// as "Order {{.id}} failed" or "env:{{.env}}"; tags rendering empty are
+// dropped. Text defaults to the payload as a JSON code block. Priority is
+// "normal" (the default) or "low".
+type Config struct {
+	Site           string   `json:"site,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Text           string   `json:"text,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	AlertType      string   `json:"alert_type,omitempty"`
+	Priority       string   `json:"priority,omitempty"`
+	AggregationKey string   `json:"aggregation_key,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode datadog config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, a Datadog API key. A literal
+// alert type is checked here; a templated one when rendered.
+func Validate(cfg Config, secret string) error {
+	if !apiKeyPattern.MatchString(secret) {
+		return errors.New("datadog actions need a 32-character API key as their secret")
+	}
+	if cfg.Site != "" && !contains(Sites, cfg.Site) {
+		return fmt.Errorf("datadog site must be one of %s", strings.Join(Sites, ", "))
+	}
+	fields := map[string]string{"title": cfg.Title, "text": cfg.Text, "alert_type": cfg.AlertType, "aggregation_key": cfg.AggregationKey}
+	for i, tag := range cfg.Tags {
+		fields[fmt.Sprintf("tags[%d]", i)] = tag
+	}
+	for name, text := range fields {
+		if text == "" {
+			continue
+		}
+		if _, err := reqtemplate.Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %w", name, err)
+		}
+	}
+	if !strings.Contains(cfg.AlertType, "{{") && cfg.AlertType != "" && !contains(AlertTypes, cfg.AlertType) {
+		return fmt.Errorf("datadog alert_type must be one of %s or a template rendering one", strings.Join(AlertTypes, ", "))
+	}
+	if cfg.Priority != "" && cfg.Priority != "normal" && cfg.Priority != "low" {
+		return errors.New("datadog priority must be 'normal' or 'low'")
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRequest renders payload into an event request for the config,
+// authenticated with the API key.
+func NewRequest(ctx context.Context, cfg Config, apiKey string, payload json.RawMessage, maxBytes int) (*http.Request, error) {
+	title, err := render(cfg.Title, payload, DefaultTitle)
+	if err != nil {
+		return nil, fmt.Errorf("render title: %w", err)
+	}
+	if title == "" {
+		return nil, errors.New("datadog title rendered empty")
+	}
+	text, err := render(cfg.Text, payload, "")
+	if err != nil {
+		return nil, fmt.Errorf("render text: %w", err)
+	}
+	if cfg.Text == "" {
+		text = codeBlock(payload)
+	}
+	alertType, err := render(cfg.AlertType, payload, "")
+	if err != nil {
+		return nil, fmt.Errorf("render alert_type: %w", err)
+	}
+	if alertType != "" && !contains(AlertTypes, alertType) {
+		return nil, fmt.Errorf("datadog alert_type must be one of %s, got %q", strings.Join(AlertTypes, ", "), alertType)
+	}
+	aggregationKey, err := render(cfg.AggregationKey, payload, "")
+	if err != nil {
+		return nil, fmt.Errorf("render aggregation_key: %w", err)
+	}
+	tags := make([]string, 0, len(cfg.Tags))
+	for i, t := range cfg.Tags {
+		tag, err := render(t, payload, "")
+		if err != nil {
+			return nil, fmt.Errorf("render tags[%d]: %w", i, err)
+		}
+		if tag != "" {
+			tags = append(tags, truncate(tag, maxTag))
+		}
+	}
+
+	body := map[string]any{
+		"title": truncate(title, maxTitle),
+		"text":  truncate(text, maxText),
+	}
+	if len(tags) > 0 {
+		body["tags"] = tags
+	}
+	if alertType != "" {
+		body["alert_type"] = alertType
+	}
+	if cfg.Priority != "" {
+		body["priority"] = cfg.Priority
+	}
+	if aggregationKey != "" {
+		body["aggregation_key"] = truncate(aggregationKey, maxAggregationKey)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal datadog request: %w", err)
+	}
+	if len(b) > maxBytes {
+		return nil, reqtemplate.ErrTooLarge
+	}
+	site := cfg.Site
+	if site == "" {
+		site = DefaultSite
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api."+site+"/api/v1/events", bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("build datadog request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", apiKey)
+	return req, nil
+}
+
+// CheckResponse reports a failed request with Datadog's errors, such as
+// "HTTP 403: Forbidden".
+func CheckResponse(status int, body []byte) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	var res struct {
+		Errors []string `json:"errors"`
+	}
+	if json.Unmarshal(body, &res) == nil && len(res.Errors) > 0 {
+		return fmt.Errorf("HTTP %d: %s", status, strings.Join(res.Errors, "; "))
+	}
+	return fmt.Errorf("HTTP %d", status)
+}
+
+// render renders a template field, trimmed, or returns fallback when unset.
+func render(text string, payload json.RawMessage, fallback string) (string, error) {
+	if text == "" {
+		return fallback, nil
+	}
+	out, err := reqtemplate.Body(text, payload, maxText)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// codeBlock renders payload as indented JSON in a markdown code block,
+// truncated so the block stays closed within maxText.
+func codeBlock(payload json.RawMessage) string {
+	const open, closing = "%%%\n```\n", "\n```\n%%%"
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		buf.Reset()
+		buf.Write(payload)
+	}
+	return open + truncate(buf.String(), maxText-len(open)-len(closing)) + closing
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// Don't split a multi-byte rune
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
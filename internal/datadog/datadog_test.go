@@ -0,0 +1,100 @@
+package datadog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+const key = "0123456789abcdef0123456789abcdef"
+
+func TestValidate(t *testing.T) {
+	valid := Config{Site: "datadoghq.eu", Title: "Order {{.id}}", Tags: []string{"env:{{.env}}", "relay"}, AlertType: "{{.level}}", Priority: "low"}
+	if err := Validate(valid, key); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	cases := map[string]struct {
+		cfg    Config
+		secret string
+	}{
+		"no key":         {valid, ""},
+		"bad key":        {valid, "not-a-key"},
+		"bad site":       {Config{Site: "datadoghq.cn"}, key},
+		"bad alert type": {Config{AlertType: "critical"}, key},
+		"bad priority":   {Config{Priority: "high"}, key},
+		"bad title":      {Config{Title: "{{.x"}, key},
+		"bad tag":        {Config{Tags: []string{"{{.x"}}, key},
+	}
+	for name, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	cfg := Config{Site: "us5.datadoghq.com", Title: "Order {{.id}} {{.state}}", Tags: []string{"env:{{.env}}", "{{if .team}}team:{{.team}}{{end}}"}, AlertType: "{{.level}}", AggregationKey: "order-{{.id}}"}
+	req, err := NewRequest(context.Background(), cfg, key, []byte(`{"id":7,"state":"failed","env":"prod","team":"","level":"error"}`), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "https://api.us5.datadoghq.com/api/v1/events" {
+		t.Errorf("URL = %s", req.URL)
+	}
+	if req.Header.Get("DD-API-KEY") != key {
+		t.Errorf("DD-API-KEY = %q", req.Header.Get("DD-API-KEY"))
+	}
+	b, _ := io.ReadAll(req.Body)
+	var body map[string]any
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["title"] != "Order 7 failed" || body["alert_type"] != "error" || body["aggregation_key"] != "order-7" {
+		t.Errorf("body = %s", b)
+	}
+	if tags, _ := body["tags"].([]any); len(tags) != 1 || tags[0] != "env:prod" {
+		t.Errorf("tags = %v, want [env:prod]", body["tags"])
+	}
+	if text, _ := body["text"].(string); !strings.HasPrefix(text, "%%%\n```\n{") || !strings.HasSuffix(text, "}\n```\n%%%") {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestNewRequest_Defaults(t *testing.T) {
+	payload := []byte(`{"blob":"` + strings.Repeat("x", 5000) + `"}`)
+	req, err := NewRequest(context.Background(), Config{}, key, payload, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Host != "api."+DefaultSite {
+		t.Errorf("host = %s", req.URL.Host)
+	}
+	b, _ := io.ReadAll(req.Body)
+	var body map[string]any
+	json.Unmarshal(b, &body)
+	text, _ := body["text"].(string)
+	if body["title"] != DefaultTitle || len(text) > maxText || !strings.HasSuffix(text, "\n```\n%%%") {
+		t.Errorf("title = %v, text is %d bytes ending %q", body["title"], len(text), text[len(text)-10:])
+	}
+}
+
+func TestNewRequest_BadAlertType(t *testing.T) {
+	_, err := NewRequest(context.Background(), Config{AlertType: "{{.level}}"}, key, []byte(`{"level":"critical"}`), 1<<20)
+	if err == nil {
+		t.Fatal("expected error for rendered alert type")
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(202, []byte(`{"status":"ok"}`)); err != nil {
+		t.Errorf("202: %v", err)
+	}
+	if err := CheckResponse(403, []byte(`{"errors":["Forbidden"]}`)); err == nil || err.Error() != "HTTP 403: Forbidden" {
+		t.Errorf("403: %v", err)
+	}
+	if err := CheckResponse(500, []byte(`oops`)); err == nil || err.Error() != "HTTP 500" {
+		t.Errorf("500: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package elasticsearch indexes deliveries into Elasticsearch or OpenSearch
+// through the document index API, one document per delivery.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// maxIndex is the longest index name Elasticsearch accepts, in bytes.
+const maxIndex = 255
+
+// Auth schemes; the action's secret is the password or the API key.
+const (
+	AuthNone   = ""
+	AuthBasic  = "basic"
+	AuthAPIKey = "api_key"
+)
+
+// Config is an Elasticsearch action's config. URL is the cluster's base URL,
+// such as https://search.example.com:9200. Index is a reqtemplate over the
+// payload naming the target index, such as "events-{{.type}}". Auth is
+// "basic", with Username, or "api_key", whose secret is the encoded key sent
+// as "Authorization: ApiKey". Pipeline names an optional ingest pipeline.
+type Config struct {
+	URL      string `json:"url"`
+	Index    string `json:"index"`
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode elasticsearch config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret.
+func Validate(cfg Config, secret string) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an http(s) URL such as https://search.example.com:9200")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("url can't have credentials, a query or a fragment")
+	}
+	if cfg.Index == "" {
+		return errors.New("index is required")
+	}
+	if _, err := reqtemplate.Parse(cfg.Index); err != nil {
+		return fmt.Errorf("invalid index template: %w", err)
+	}
+	switch cfg.Auth {
+	case AuthNone:
+		if secret != "" || cfg.Username != "" {
+			return errors.New("username and secret need auth set to basic or api_key")
+		}
+	case AuthBasic:
+		if cfg.Username == "" || secret == "" {
+			return errors.New("basic auth needs a username and the password as the secret")
+		}
+	case AuthAPIKey:
+		if cfg.Username != "" {
+			return errors.New("api_key auth doesn't take a username")
+		}
+		if secret == "" {
+			return errors.New("api_key auth needs the encoded API key as the secret")
+		}
+	default:
+		return errors.New("auth must be basic or api_key")
+	}
+	if cfg.Pipeline != "" && strings.ContainsAny(cfg.Pipeline, "/?#& ") {
+		return fmt.Errorf("invalid pipeline name %q", cfg.Pipeline)
+	}
+	return nil
+}
+
+// Index renders the config's index name over payload and checks it against
+// Elasticsearch's naming rules.
+func Index(cfg Config, payload json.RawMessage) (string, error) {
+	out, err := reqtemplate.Body(cfg.Index, payload, maxIndex+1)
+	if err != nil {
+		return "", fmt.Errorf("render index: %w", err)
+	}
+	index := strings.TrimSpace(string(out))
+	switch {
+	case index == "":
+		return "", errors.New("index rendered empty")
+	case len(index) > maxIndex:
+		return "", fmt.Errorf("index %q is longer than %d bytes", index, maxIndex)
+	case index == "." || index == "..":
+		return "", fmt.Errorf("invalid index %q", index)
+	case strings.ToLower(index) != index:
+		return "", fmt.Errorf("index %q must be lowercase", index)
+	case strings.ContainsAny(index[:1], "-_+"):
+		return "", fmt.Errorf("index %q can't start with -, _ or +", index)
+	case strings.ContainsAny(index, `\/*?"<>| ,#:`):
+		return "", fmt.Errorf("index %q contains a character Elasticsearch rejects", index)
+	}
+	return index, nil
+}
+
+// Document is the payload as a document: objects as is, anything else
+// wrapped as {"payload": ...}, since documents must be objects.
+func Document(payload json.RawMessage) ([]byte, error) {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return trimmed, nil
+	}
+	return json.Marshal(map[string]json.RawMessage{"payload": trimmed})
+}
+
+// NewRequest builds the request indexing doc into index with the delivery ID
+// as the document ID, so a retried attempt overwrites its own document.
+func NewRequest(ctx context.Context, cfg Config, secret, index, deliveryID string, doc []byte) (*http.Request, error) {
+	target := strings.TrimRight(cfg.URL, "/") + "/" + url.PathEscape(index) + "/_doc/" + url.PathEscape(deliveryID)
+	if cfg.Pipeline != "" {
+		target += "?pipeline=" + url.QueryEscape(cfg.Pipeline)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(doc))
+	if err != nil {
+		return nil, fmt.Errorf("build elasticsearch request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	switch cfg.Auth {
+	case AuthBasic:
+		req.SetBasicAuth(cfg.Username, secret)
+	case AuthAPIKey:
+		req.Header.Set("Authorization", "ApiKey "+secret)
+	}
+	return req, nil
+}
+
+// CheckResponse reports a failed index request with the error's type and
+// reason, such as "HTTP 400: mapper_parsing_exception: failed to parse".
+func CheckResponse(status int, body []byte) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	var res struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &res) == nil && len(res.Error) > 0 {
+		var detail struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		}
+		if json.Unmarshal(res.Error, &detail) == nil && detail.Type != "" {
+			return fmt.Errorf("HTTP %d: %s: %s", status, detail.Type, detail.Reason)
+		}
+		// Some errors, such as a missing route, are just a string
+		var msg string
+		if json.Unmarshal(res.Error, &msg) == nil && msg != "" {
+			return fmt.Errorf("HTTP %d: %s", status, msg)
+		}
+	}
+	return fmt.Errorf("HTTP %d", status)
+}
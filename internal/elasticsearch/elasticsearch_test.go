@@ -0,0 +1,118 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const base = "https://search.example.com:9200"
+	cases := []struct {
+		cfg    Config
+		secret string
+		ok     bool
+	}{
+		{Config{URL: base, Index: "events"}, "", true},
+		{Config{URL: base, Index: "events-{{.type}}", Auth: AuthBasic, Username: "relay"}, "pw", true},
+		{Config{URL: base, Index: "events", Auth: AuthAPIKey, Pipeline: "enrich"}, "a2V5OnNlY3JldA==", true},
+		{Config{URL: base, Index: "events"}, "pw", false},
+		{Config{URL: base, Index: "events", Auth: AuthBasic}, "pw", false},
+		{Config{URL: base, Index: "events", Auth: AuthAPIKey}, "", false},
+		{Config{URL: base, Index: "events", Auth: "bearer"}, "tok", false},
+		{Config{URL: "https://relay:pw@search.example.com", Index: "events"}, "", false},
+		{Config{URL: "search.example.com:9200", Index: "events"}, "", false},
+		{Config{URL: base}, "", false},
+		{Config{URL: base, Index: "{{.type"}, "", false},
+		{Config{URL: base, Index: "events", Pipeline: "a/b"}, "", false},
+	}
+	for _, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v, %q) = %v, want ok %v", tc.cfg, tc.secret, err, tc.ok)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	payload := json.RawMessage(`{"type":"order.created","kind":"Order","path":"a/b"}`)
+	cases := []struct {
+		template string
+		want     string
+		ok       bool
+	}{
+		{"events", "events", true},
+		{"events-{{.type}}", "events-order.created", true},
+		{"events-{{.kind}}", "", false},
+		{"events-{{.path}}", "", false},
+		{"_{{.type}}", "", false},
+		{"events-{{.missing}}", "", false},
+		{"{{if false}}x{{end}}", "", false},
+	}
+	for _, tc := range cases {
+		got, err := Index(Config{Index: tc.template}, payload)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("Index(%q) = %q, %v, want %q ok %v", tc.template, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	cases := map[string]string{
+		`{"a":1}`:   `{"a":1}`,
+		` {"a":1} `: `{"a":1}`,
+		`[1,2]`:     `{"payload":[1,2]}`,
+		`"hi"`:      `{"payload":"hi"}`,
+	}
+	for in, want := range cases {
+		got, err := Document(json.RawMessage(in))
+		if err != nil || string(got) != want {
+			t.Errorf("Document(%s) = %s, %v, want %s", in, got, err, want)
+		}
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	cfg := Config{URL: "https://search.example.com/", Auth: AuthAPIKey, Pipeline: "enrich"}
+	req, err := NewRequest(context.Background(), cfg, "a2V5", "events", "0b9f", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "PUT" || req.URL.String() != "https://search.example.com/events/_doc/0b9f?pipeline=enrich" {
+		t.Errorf("request = %s %s", req.Method, req.URL)
+	}
+	if got := req.Header.Get("Authorization"); got != "ApiKey a2V5" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	cfg = Config{URL: "https://search.example.com", Auth: AuthBasic, Username: "relay"}
+	req, err = NewRequest(context.Background(), cfg, "pw", "events", "0b9f", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "relay" || pass != "pw" {
+		t.Errorf("BasicAuth() = %q, %q, %v", user, pass, ok)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{201, `{"result":"created"}`, ""},
+		{400, `{"error":{"type":"mapper_parsing_exception","reason":"failed to parse"},"status":400}`, "HTTP 400: mapper_parsing_exception: failed to parse"},
+		{405, `{"error":"Incorrect HTTP method","status":405}`, "HTTP 405: Incorrect HTTP method"},
+		{502, `bad gateway`, "HTTP 502"},
+	}
+	for _, tc := range cases {
+		err := CheckResponse(tc.status, []byte(tc.body))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("CheckResponse(%d, %s) = %q, want %q", tc.status, tc.body, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package email renders deliveries into multipart text/HTML messages and sends
+// them through the deployment's SMTP server.
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// DefaultSubject is used when an action has no subject template.
+const DefaultSubject = "New delivery"
+
+// maxSubject bounds a rendered subject, well under the 998 character line
+// limit.
+const maxSubject = 900
+
+// Config is an SMTP action's config. Subject and Text are reqtemplates over
+// the payload; HTML is the same syntax rendered with html/template so payload
+// values are escaped. Without templates the payload is sent as indented JSON.
+type Config struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject,omitempty"`
+	Text    string   `json:"text,omitempty"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode smtp config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config and its secret. Recipients are required; the
+// server credentials are global, so actions take no secret.
+func Validate(cfg Config, secret string) error {
+	if secret != "" {
+		return errors.New("smtp actions take no secret; the server is configured globally")
+	}
+	if len(cfg.To) == 0 {
+		return errors.New("smtp actions need at least one recipient in to")
+	}
+	for _, to := range cfg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+	for name, text := range map[string]string{"subject": cfg.Subject, "text": cfg.Text} {
+		if text == "" {
+			continue
+		}
+		if _, err := reqtemplate.Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %w", name, err)
+		}
+	}
+	if cfg.HTML != "" {
+		if _, err := reqtemplate.ParseHTML(cfg.HTML); err != nil {
+			return fmt.Errorf("invalid html template: %w", err)
+		}
+	}
+	return nil
+}
+
+// Recipients returns the bare addresses of cfg.To for the SMTP envelope.
+func Recipients(cfg Config) ([]string, error) {
+	out := make([]string, 0, len(cfg.To))
+	for _, to := range cfg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		out = append(out, addr.Address)
+	}
+	return out, nil
+}
+
+// Message renders payload into a multipart/alternative message from from.
+// messageID is the left-hand side of the Message-ID header, typically the
+// delivery ID. Rendered bodies are limited to maxBytes each.
+func Message(cfg Config, from, messageID string, payload json.RawMessage, maxBytes int) ([]byte, error) {
+	subject := DefaultSubject
+	if cfg.Subject != "" {
+		out, err := reqtemplate.Body(cfg.Subject, payload, maxSubject)
+		if err != nil {
+			return nil, fmt.Errorf("render subject: %w", err)
+		}
+		subject = strings.Join(strings.Fields(string(out)), " ")
+	}
+
+	text := indent(payload)
+	if cfg.Text != "" {
+		out, err := reqtemplate.Body(cfg.Text, payload, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		text = out
+	}
+	htmlBody := []byte("<pre>" + html.EscapeString(string(text)) + "</pre>")
+	if cfg.HTML != "" {
+		out, err := reqtemplate.HTML(cfg.HTML, payload, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		htmlBody = out
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	header := []string{
+		"From: " + from,
+		"To: " + strings.Join(cfg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + messageID + "@nitrohook>",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	buf.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{{"text/plain; charset=utf-8", text}, {"text/html; charset=utf-8", htmlBody}} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("write message part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write(part.body); err != nil {
+			return nil, fmt.Errorf("write message part: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("write message part: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("close message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func indent(payload json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		return payload
+	}
+	return buf.Bytes()
+}
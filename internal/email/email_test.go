@@ -0,0 +1,106 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Config{To: []string{"Ops <ops@example.com>", "dev@example.com"}, Subject: "{{.event}}"}
+	if err := Validate(valid, ""); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	cases := map[string]struct {
+		cfg    Config
+		secret string
+	}{
+		"secret":        {valid, "hunter2"},
+		"no recipients": {Config{}, ""},
+		"bad recipient": {Config{To: []string{"not an address"}}, ""},
+		"bad subject":   {Config{To: valid.To, Subject: "{{.x"}, ""},
+		"bad html":      {Config{To: valid.To, HTML: "{{end}}"}, ""},
+	}
+	for name, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	got, err := Recipients(Config{To: []string{"Ops <ops@example.com>", "dev@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "ops@example.com,dev@example.com" {
+		t.Fatalf("unexpected recipients %v", got)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	cfg := Config{
+		To:      []string{"ops@example.com"},
+		Subject: "Order {{.id}}\nshipped",
+		HTML:    "<b>{{.note}}</b>",
+	}
+	raw, err := Message(cfg, "relay@example.com", "d1", []byte(`{"id":7,"note":"<x>"}`), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Order 7 shipped" {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+	if msg.Header.Get("Message-ID") != "<d1@nitrohook>" {
+		t.Fatalf("unexpected message id %q", msg.Header.Get("Message-ID"))
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(p))
+		parts[p.Header.Get("Content-Type")] = string(body)
+	}
+	if !strings.Contains(parts["text/plain; charset=utf-8"], `"note": "<x>"`) {
+		t.Fatalf("expected indented payload as text, got %q", parts["text/plain; charset=utf-8"])
+	}
+	if parts["text/html; charset=utf-8"] != "<b>&lt;x&gt;</b>" {
+		t.Fatalf("expected escaped html, got %q", parts["text/html; charset=utf-8"])
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	rejected := fmt.Errorf("smtp rcpt to: %w", &textproto.Error{Code: 550, Msg: "no such user"})
+	if !Permanent(rejected) {
+		t.Fatal("expected 5xx to be permanent")
+	}
+	if Permanent(&textproto.Error{Code: 451, Msg: "try again later"}) {
+		t.Fatal("expected 4xx to be temporary")
+	}
+	if Permanent(errors.New("connection refused")) {
+		t.Fatal("expected network error to be temporary")
+	}
+}
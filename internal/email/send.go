@@ -0,0 +1,127 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// TLS modes for the SMTP connection.
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+// ErrNotConfigured is returned by Send without a host and sender.
+var ErrNotConfigured = errors.New("SMTP_HOST and SMTP_FROM must be configured for smtp actions")
+
+// Server is the deployment's outgoing mail server.
+type Server struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// From is the envelope sender and From header of every message.
+	From string
+	// TLS is one of TLSStartTLS, TLSImplicit or TLSNone.
+	TLS string
+	// Timeout bounds a whole Send; zero relies on ctx alone.
+	Timeout time.Duration
+}
+
+// Configured reports whether mail can be sent.
+func (s Server) Configured() bool {
+	return s.Host != "" && s.From != ""
+}
+
+// ValidTLS reports whether mode is a known TLS mode.
+func ValidTLS(mode string) bool {
+	return mode == TLSStartTLS || mode == TLSImplicit || mode == TLSNone
+}
+
+// Send delivers msg to the recipients. Errors the server answered with are
+// *textproto.Error; see Reply.
+func (s Server) Send(ctx context.Context, to []string, msg []byte) error {
+	if !s.Configured() {
+		return ErrNotConfigured
+	}
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: s.Host}
+	if s.TLS == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if s.TLS == TLSStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp rcpt to %s: %w", rcpt, err)
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := wc.Write(msg); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
+
+// Reply returns the server's reply code and text from a Send error, if the
+// server answered with one.
+func Reply(err error) (code int, msg string, ok bool) {
+	var perr *textproto.Error
+	if !errors.As(err, &perr) {
+		return 0, "", false
+	}
+	return perr.Code, perr.Msg, true
+}
+
+// Permanent reports whether a Send error is a permanent (5xx) rejection that
+// retrying won't fix.
+func Permanent(err error) bool {
+	code, _, ok := Reply(err)
+	return ok && code >= 500
+}
@@ -0,0 +1,80 @@
+// Package encryption seals sensitive values, such as attempt response
+// bodies, for storage with AES-256-GCM.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks sealed values, so values stored before a key was configured
+// are still read as plain text.
+const prefix = "enc:v1:"
+
+// ErrNoKey is returned when opening a sealed value without a key.
+var ErrNoKey = errors.New("value is encrypted but no key is configured")
+
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a base64-encoded 32-byte key.
+func NewCipher(keyB64 string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(keyB64)
+	if err != nil {
+		return nil, fmt.Errorf("decode encryption key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts s. A nil cipher returns s unchanged.
+func (c *Cipher) Seal(s string) string {
+	if c == nil {
+		return s
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	rand.Read(nonce)
+	sealed := c.aead.Seal(nonce, nonce, []byte(s), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// Open decrypts a value from Seal. Values that weren't sealed are returned
+// as is.
+func (c *Cipher) Open(s string) (string, error) {
+	enc, ok := strings.CutPrefix(s, prefix)
+	if !ok {
+		return s, nil
+	}
+	if c == nil {
+		return "", ErrNoKey
+	}
+	data, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", fmt.Errorf("decode sealed value: %w", err)
+	}
+	n := c.aead.NonceSize()
+	if len(data) < n {
+		return "", fmt.Errorf("sealed value too short")
+	}
+	plain, err := c.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt sealed value: %w", err)
+	}
+	return string(plain), nil
+}
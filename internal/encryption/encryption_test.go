@@ -0,0 +1,47 @@
+package encryption
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // "0123456789abcdef0123456789abcdef"
+
+func TestSealOpen(t *testing.T) {
+	c, err := NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := c.Seal(`{"card":"4242"}`)
+	if !strings.HasPrefix(sealed, prefix) || strings.Contains(sealed, "4242") {
+		t.Fatalf("value not sealed: %s", sealed)
+	}
+	if c.Seal("x") == c.Seal("x") {
+		t.Fatal("expected a fresh nonce per seal")
+	}
+	got, err := c.Open(sealed)
+	if err != nil || got != `{"card":"4242"}` {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	// Values stored before a key was configured read as plain text
+	if got, err := c.Open("ok"); err != nil || got != "ok" {
+		t.Fatalf("Open plain = %q, %v", got, err)
+	}
+
+	var none *Cipher
+	if none.Seal("ok") != "ok" {
+		t.Fatal("nil cipher should not seal")
+	}
+	if _, err := none.Open(sealed); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+}
+
+func TestNewCipherKeyLength(t *testing.T) {
+	if _, err := NewCipher("c2hvcnQ="); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
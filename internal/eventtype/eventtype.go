@@ -0,0 +1,57 @@
+// Package eventtype works out a delivery's event type and matches it against
+// action subscriptions.
+package eventtype
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Header is the generic event type header, set by the send API and honoured
+// on ingest.
+const Header = "X-Event-Type"
+
+// providerHeaders carry the event type for providers that send it out of band.
+var providerHeaders = []string{Header, "X-GitHub-Event", "X-Shopify-Topic", "Stripe-Event-Type", "X-Gitlab-Event"}
+
+// Detect returns the event type of an inbound request: from a known event
+// type header, else from a top-level string "type" field in the payload (as
+// Stripe and Slack send it). It returns "" if neither is present.
+func Detect(header http.Header, payload []byte) string {
+	for _, h := range providerHeaders {
+		if v := strings.TrimSpace(header.Get(h)); v != "" {
+			return v
+		}
+	}
+	var body struct {
+		Type any `json:"type"`
+	}
+	if json.Unmarshal(payload, &body) == nil {
+		if s, ok := body.Type.(string); ok {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// Matches reports whether an action subscribed to subscriptions should receive
+// eventType. No subscriptions means all events. A subscription ending in ".*"
+// matches any type under that prefix, and "*" matches every typed event.
+func Matches(subscriptions []string, eventType string) bool {
+	if len(subscriptions) == 0 {
+		return true
+	}
+	if eventType == "" {
+		return false
+	}
+	for _, s := range subscriptions {
+		switch {
+		case s == "*" || s == eventType:
+			return true
+		case strings.HasSuffix(s, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(s, "*")):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package eventtype
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	gh := http.Header{}
+	gh.Set("X-GitHub-Event", "push")
+	if got := Detect(gh, []byte(`{"type":"ignored"}`)); got != "push" {
+		t.Fatalf("expected header to win, got %q", got)
+	}
+	if got := Detect(http.Header{}, []byte(`{"type":"invoice.paid"}`)); got != "invoice.paid" {
+		t.Fatalf("expected payload type, got %q", got)
+	}
+	if got := Detect(http.Header{}, []byte(`{"type":{"nested":true}}`)); got != "" {
+		t.Fatalf("expected no type, got %q", got)
+	}
+	if got := Detect(http.Header{}, []byte(`[1,2]`)); got != "" {
+		t.Fatalf("expected no type, got %q", got)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	cases := []struct {
+		subs      []string
+		eventType string
+		want      bool
+	}{
+		{nil, "", true},
+		{nil, "push", true},
+		{[]string{"push"}, "push", true},
+		{[]string{"push"}, "pull_request", false},
+		{[]string{"push"}, "", false},
+		{[]string{"invoice.*"}, "invoice.paid", true},
+		{[]string{"invoice.*"}, "invoices.paid", false},
+		{[]string{"invoice.*"}, "invoice", false},
+		{[]string{"*"}, "anything", true},
+		{[]string{"*"}, "", false},
+	}
+	for _, tc := range cases {
+		if got := Matches(tc.subs, tc.eventType); got != tc.want {
+			t.Errorf("Matches(%v, %q) = %v, want %v", tc.subs, tc.eventType, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package fingerprint guesses which provider sent a webhook from its
+// request headers.
+package fingerprint
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/signing"
+)
+
+// Sample is how many recent deliveries a provider suggestion is based on.
+const Sample = 50
+
+type rule struct {
+	provider string
+	// headers are any of which identifies the provider.
+	headers []string
+	// userAgent is a User-Agent prefix that identifies the provider.
+	userAgent string
+}
+
+// rules are checked in order; more specific ones (SendGrid's Twilio-named
+// header) come before broader ones.
+var rules = []rule{
+	{provider: signing.ProviderGitHub, headers: []string{"X-GitHub-Event", "X-GitHub-Delivery", "X-Hub-Signature-256"}, userAgent: "GitHub-Hookshot/"},
+	{provider: signing.ProviderStripe, headers: []string{"Stripe-Signature"}, userAgent: "Stripe/"},
+	{provider: signing.ProviderShopify, headers: []string{"X-Shopify-Hmac-Sha256", "X-Shopify-Topic"}},
+	{provider: signing.ProviderSlack, headers: []string{"X-Slack-Signature"}, userAgent: "Slackbot"},
+	{provider: "sendgrid", headers: []string{"X-Twilio-Email-Event-Webhook-Signature"}},
+	{provider: "twilio", headers: []string{"X-Twilio-Signature"}, userAgent: "TwilioProxy/"},
+	{provider: "gitlab", headers: []string{"X-Gitlab-Event", "X-Gitlab-Token"}},
+	{provider: "bitbucket", headers: []string{"X-Hook-UUID"}, userAgent: "Bitbucket-Webhooks/"},
+	{provider: "paypal", headers: []string{"Paypal-Transmission-Id"}},
+	{provider: "square", headers: []string{"X-Square-Hmacsha256-Signature"}},
+	{provider: "linear", headers: []string{"Linear-Signature", "Linear-Delivery"}},
+	{provider: "svix", headers: []string{"Svix-Id"}},
+	{provider: "standard-webhooks", headers: []string{"Webhook-Id"}},
+}
+
+// Detect returns the likely provider of a request, or "" if none matches.
+func Detect(header http.Header) string {
+	ua := header.Get("User-Agent")
+	for _, r := range rules {
+		for _, h := range r.headers {
+			if header.Get(h) != "" {
+				return r.provider
+			}
+		}
+		if r.userAgent != "" && strings.HasPrefix(ua, r.userAgent) {
+			return r.provider
+		}
+	}
+	return ""
+}
+
+// Preset returns the verification preset for a detected provider, or "" if
+// it has none.
+func Preset(provider string) string {
+	if signing.ValidProvider(provider) {
+		return provider
+	}
+	return ""
+}
+
+// Suggest builds the suggestion for a source whose recent deliveries were
+// mostly detected as provider.
+func Suggest(src *model.Source, provider string, matches, sampled int) model.ProviderSuggestion {
+	s := model.ProviderSuggestion{
+		Provider: provider,
+		Preset:   Preset(provider),
+		Matches:  matches,
+		Sampled:  sampled,
+	}
+	s.Configured = s.Preset != "" && src.Provider != nil && *src.Provider == s.Preset
+	return s
+}
@@ -0,0 +1,44 @@
+package fingerprint
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   string
+	}{
+		{"github header", map[string]string{"X-GitHub-Event": "push"}, "github"},
+		{"github user agent", map[string]string{"User-Agent": "GitHub-Hookshot/abc123"}, "github"},
+		{"stripe", map[string]string{"Stripe-Signature": "t=1,v1=x"}, "stripe"},
+		{"sendgrid before twilio", map[string]string{"X-Twilio-Email-Event-Webhook-Signature": "x", "User-Agent": "TwilioProxy/1.1"}, "sendgrid"},
+		{"twilio", map[string]string{"X-Twilio-Signature": "x"}, "twilio"},
+		{"unknown", map[string]string{"User-Agent": "curl/8.0"}, ""},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.header {
+			h.Set(k, v)
+		}
+		if got := Detect(h); got != tt.want {
+			t.Errorf("%s: Detect = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	configured := "github"
+	s := Suggest(&model.Source{Provider: &configured}, "github", 3, 4)
+	if s.Preset != "github" || !s.Configured {
+		t.Errorf("github suggestion = %+v, want configured github preset", s)
+	}
+	s = Suggest(&model.Source{}, "twilio", 1, 1)
+	if s.Preset != "" || s.Configured {
+		t.Errorf("twilio suggestion = %+v, want no preset", s)
+	}
+}
@@ -0,0 +1,391 @@
+// Package grpcsink forwards deliveries to gRPC services implementing the
+// WebhookSink service in proto/nitrohook/sink/v1/webhook_sink.proto. It
+// encodes the two small messages by hand and speaks gRPC's HTTP/2 framing
+// directly over net/http.
+package grpcsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMethod is WebhookSink's Deliver RPC.
+const DefaultMethod = "/nitrohook.sink.v1.WebhookSink/Deliver"
+
+// MaxTimeout bounds a config's deadline.
+const MaxTimeout = 5 * time.Minute
+
+// maxResponse bounds the response message read.
+const maxResponse = 1 << 20
+
+// gRPC status codes.
+const (
+	OK                 = 0
+	Cancelled          = 1
+	Unknown            = 2
+	InvalidArgument    = 3
+	DeadlineExceeded   = 4
+	NotFound           = 5
+	AlreadyExists      = 6
+	PermissionDenied   = 7
+	ResourceExhausted  = 8
+	FailedPrecondition = 9
+	Aborted            = 10
+	OutOfRange         = 11
+	Unimplemented      = 12
+	Internal           = 13
+	Unavailable        = 14
+	DataLoss           = 15
+	Unauthenticated    = 16
+)
+
+var codeNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// StatusError is a call that ended with a non-OK gRPC status.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	name := "code " + strconv.Itoa(e.Code)
+	if e.Code >= 0 && e.Code < len(codeNames) {
+		name = codeNames[e.Code]
+	}
+	if e.Message == "" {
+		return "grpc status " + name
+	}
+	return "grpc status " + name + ": " + e.Message
+}
+
+// Permanent reports whether a Deliver error is a status retrying won't fix.
+// Transient statuses (UNAVAILABLE, DEADLINE_EXCEEDED, RESOURCE_EXHAUSTED,
+// ABORTED, INTERNAL, UNKNOWN, CANCELLED) and transport errors are retried.
+func Permanent(err error) bool {
+	var serr *StatusError
+	if !errors.As(err, &serr) {
+		return false
+	}
+	switch serr.Code {
+	case Unavailable, DeadlineExceeded, ResourceExhausted, Aborted, Internal, Unknown, Cancelled:
+		return false
+	}
+	return true
+}
+
+var (
+	methodPattern   = regexp.MustCompile(`^/[A-Za-z0-9_.]+/[A-Za-z0-9_]+$`)
+	metadataPattern = regexp.MustCompile(`^[0-9a-z_.-]+$`)
+)
+
+// reservedMetadata are headers the call sets itself.
+var reservedMetadata = map[string]bool{
+	"content-type": true, "te": true, "user-agent": true, "host": true,
+	"authorization": true,
+}
+
+// Config is a gRPC action's config. URL names the server as
+// grpc://host:port (plaintext HTTP/2) or grpcs://host[:port] (TLS). Method
+// defaults to WebhookSink's Deliver. TimeoutMs is the call's deadline,
+// defaulting to the delivery timeout. Metadata is sent with every call; the
+// secret, if set, is sent as a bearer token.
+type Config struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method,omitempty"`
+	TimeoutMs int               `json:"timeout_ms,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode grpc config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config. The secret is optional.
+func Validate(cfg Config) error {
+	if _, _, err := target(cfg); err != nil {
+		return err
+	}
+	if cfg.TimeoutMs < 0 || time.Duration(cfg.TimeoutMs)*time.Millisecond > MaxTimeout {
+		return fmt.Errorf("timeout_ms must be between 0 and %d", MaxTimeout.Milliseconds())
+	}
+	for k := range cfg.Metadata {
+		switch {
+		case !metadataPattern.MatchString(k):
+			return fmt.Errorf("metadata key %q must be lowercase letters, digits, '-', '_' or '.'", k)
+		case strings.HasPrefix(k, "grpc-") || reservedMetadata[k]:
+			return fmt.Errorf("metadata key %q is reserved", k)
+		case strings.HasSuffix(k, "-bin"):
+			return fmt.Errorf("binary metadata key %q isn't supported", k)
+		}
+	}
+	return nil
+}
+
+// Plaintext reports whether the config's server speaks HTTP/2 without TLS.
+func Plaintext(cfg Config) bool {
+	_, plaintext, _ := target(cfg)
+	return plaintext
+}
+
+// Timeout returns the call's deadline: the config's, or def.
+func Timeout(cfg Config, def time.Duration) time.Duration {
+	if cfg.TimeoutMs > 0 {
+		return time.Duration(cfg.TimeoutMs) * time.Millisecond
+	}
+	return def
+}
+
+// target returns the URL the call is posted to.
+func target(cfg Config) (string, bool, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "grpc" && u.Scheme != "grpcs") || u.Hostname() == "" {
+		return "", false, errors.New("url must be a grpc:// or grpcs:// URL")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", false, errors.New("url must be just grpc[s]://host:port; set the method instead")
+	}
+	method := cfg.Method
+	if method == "" {
+		method = DefaultMethod
+	}
+	if !methodPattern.MatchString(method) {
+		return "", false, errors.New("method must look like /package.Service/Method")
+	}
+	plaintext := u.Scheme == "grpc"
+	host := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if plaintext {
+			port = "80"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	scheme := "https"
+	if plaintext {
+		scheme = "http"
+	}
+	return scheme + "://" + host + method, plaintext, nil
+}
+
+// Message is a DeliverRequest.
+type Message struct {
+	Payload    []byte
+	DeliveryID string
+	ActionID   string
+	Source     string
+	EventType  string
+	Attempt    int
+	ReceivedAt time.Time
+	Headers    map[string]string
+}
+
+// Marshal encodes the message in protobuf wire format. Map entries are
+// written in key order so the encoding is stable.
+func (m Message) Marshal() []byte {
+	var b []byte
+	b = appendBytes(b, 1, m.Payload)
+	b = appendBytes(b, 2, []byte(m.DeliveryID))
+	b = appendBytes(b, 3, []byte(m.ActionID))
+	b = appendBytes(b, 4, []byte(m.Source))
+	b = appendBytes(b, 5, []byte(m.EventType))
+	if m.Attempt != 0 {
+		b = appendVarint(appendTag(b, 6, wireVarint), uint64(int64(m.Attempt)))
+	}
+	if !m.ReceivedAt.IsZero() {
+		b = appendVarint(appendTag(b, 7, wireVarint), uint64(m.ReceivedAt.UnixMilli()))
+	}
+	keys := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = appendBytes(entry, 1, []byte(k))
+		entry = appendBytes(entry, 2, []byte(m.Headers[k]))
+		b = appendVarint(appendTag(b, 8, wireBytes), uint64(len(entry)))
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// Protobuf wire types.
+const (
+	wireVarint = 0
+	wire64     = 1
+	wireBytes  = 2
+	wire32     = 5
+)
+
+func appendTag(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field<<3|wire))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytes writes a length-delimited field, skipping empty values as
+// proto3 does.
+func appendBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// responseMessage decodes DeliverResponse's message, skipping unknown
+// fields.
+func responseMessage(b []byte) (string, error) {
+	var msg string
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return "", errors.New("malformed response message")
+		}
+		b = b[n:]
+		field, wire := tag>>3, tag&7
+		switch wire {
+		case wireVarint:
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return "", errors.New("malformed response message")
+			}
+			b = b[n:]
+		case wire64, wire32:
+			size := 8
+			if wire == wire32 {
+				size = 4
+			}
+			if len(b) < size {
+				return "", errors.New("malformed response message")
+			}
+			b = b[size:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return "", errors.New("malformed response message")
+			}
+			if field == 1 {
+				msg = string(b[n : n+int(size)])
+			}
+			b = b[n+int(size):]
+		default:
+			return "", fmt.Errorf("unsupported wire type %d in response message", wire)
+		}
+	}
+	return msg, nil
+}
+
+// Deliver calls the config's method with msg through client, which must
+// speak HTTP/2 (h2c for plaintext URLs). It returns DeliverResponse's
+// message; non-OK statuses are *StatusError.
+func Deliver(ctx context.Context, client *http.Client, cfg Config, secret string, timeout time.Duration, msg Message) (string, error) {
+	endpoint, _, err := target(cfg)
+	if err != nil {
+		return "", err
+	}
+	body := msg.Marshal()
+	frame := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+	frame = append(frame, body...)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(frame))
+	if err != nil {
+		return "", fmt.Errorf("build grpc request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", "nitrohook")
+	if timeout > 0 {
+		req.Header.Set("Grpc-Timeout", strconv.FormatInt(timeout.Milliseconds(), 10)+"m")
+	}
+	for k, v := range cfg.Metadata {
+		req.Header.Set(k, v)
+	}
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	// Errors usually come as headers only, with no body
+	if s := resp.Header.Get("Grpc-Status"); s != "" {
+		return "", status(s, resp.Header.Get("Grpc-Message"))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse+5))
+	if err != nil {
+		return "", fmt.Errorf("read grpc response: %w", err)
+	}
+	if err := status(resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")); err != nil {
+		return "", err
+	}
+	if len(data) < 5 {
+		return "", errors.New("grpc response has no message")
+	}
+	if data[0] != 0 {
+		return "", errors.New("grpc response is compressed")
+	}
+	size := binary.BigEndian.Uint32(data[1:5])
+	if uint64(len(data)-5) < uint64(size) {
+		return "", errors.New("grpc response message truncated")
+	}
+	return responseMessage(data[5 : 5+size])
+}
+
+// status turns grpc-status and grpc-message into an error, nil for OK.
+func status(code, message string) error {
+	if code == "" {
+		return errors.New("grpc response has no status")
+	}
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return fmt.Errorf("invalid grpc-status %q", code)
+	}
+	if n == OK {
+		return nil
+	}
+	if m, err := url.PathUnescape(message); err == nil {
+		message = m
+	}
+	return &StatusError{Code: n, Message: message}
+}
@@ -0,0 +1,131 @@
+package grpcsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"plaintext", Config{URL: "grpc://sink.internal:50051"}, false},
+		{"tls with method", Config{URL: "grpcs://sink.example.com", Method: "/acme.events.v1.Sink/Push", TimeoutMs: 2000}, false},
+		{"metadata", Config{URL: "grpc://sink:50051", Metadata: map[string]string{"x-tenant": "acme"}}, false},
+		{"http url", Config{URL: "http://sink:50051"}, true},
+		{"path in url", Config{URL: "grpc://sink:50051/Deliver"}, true},
+		{"bad method", Config{URL: "grpc://sink:50051", Method: "Deliver"}, true},
+		{"timeout too long", Config{URL: "grpc://sink:50051", TimeoutMs: 600000}, true},
+		{"reserved metadata", Config{URL: "grpc://sink:50051", Metadata: map[string]string{"grpc-timeout": "1S"}}, true},
+		{"uppercase metadata", Config{URL: "grpc://sink:50051", Metadata: map[string]string{"X-Tenant": "acme"}}, true},
+		{"binary metadata", Config{URL: "grpc://sink:50051", Metadata: map[string]string{"trace-bin": "AAA="}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.cfg); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	got := Message{
+		Payload:    []byte(`{}`),
+		DeliveryID: "d1",
+		Attempt:    2,
+		Headers:    map[string]string{"b": "2", "a": "1"},
+	}.Marshal()
+	want := []byte{
+		0x0a, 2, '{', '}', // payload
+		0x12, 2, 'd', '1', // delivery_id
+		0x30, 2, // attempt
+		0x42, 6, 0x0a, 1, 'a', 0x12, 1, '1', // headers, in key order
+		0x42, 6, 0x0a, 1, 'b', 0x12, 1, '2',
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got % x\nwant % x", got, want)
+	}
+}
+
+func TestResponseMessage(t *testing.T) {
+	// An unknown varint field before the message
+	msg, err := responseMessage([]byte{0x10, 0x96, 0x01, 0x0a, 2, 'o', 'k'})
+	if err != nil || msg != "ok" {
+		t.Fatalf("got %q, %v", msg, err)
+	}
+	if _, err := responseMessage([]byte{0x0a, 5, 'o'}); err == nil {
+		t.Fatal("expected error for a truncated field")
+	}
+}
+
+// sink serves h2c, answering each call with handle.
+func sink(t *testing.T, handle http.HandlerFunc) (Config, *http.Client) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(handle)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	return Config{URL: "grpc://" + addr.String()}, &http.Client{Transport: transport}
+}
+
+func deliver(t *testing.T, cfg Config, client *http.Client) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return Deliver(ctx, client, cfg, "s3cret", 2*time.Second, Message{Payload: []byte(`{"a":1}`), DeliveryID: "d1"})
+}
+
+func TestDeliver(t *testing.T) {
+	cfg, client := sink(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := append([]byte{0, 0, 0, 0, 13}, Message{Payload: []byte(`{"a":1}`), DeliveryID: "d1"}.Marshal()...)
+		if r.URL.Path != DefaultMethod || r.ProtoMajor != 2 || !bytes.Equal(body, want) ||
+			r.Header.Get("Content-Type") != "application/grpc" || r.Header.Get("Grpc-Timeout") != "2000m" ||
+			r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Tenant") != "acme" {
+			w.Header().Set("Grpc-Status", "3")
+			w.Header().Set("Grpc-Message", "bad%20request")
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		msg := []byte{0x0a, 8, 'a', 'c', 'c', 'e', 'p', 't', 'e', 'd'}
+		frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+		w.Write(append(frame, msg...))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	cfg.Metadata = map[string]string{"x-tenant": "acme"}
+	msg, err := deliver(t, cfg, client)
+	if err != nil || msg != "accepted" {
+		t.Fatalf("got %q, %v", msg, err)
+	}
+}
+
+func TestDeliverStatus(t *testing.T) {
+	for code, permanent := range map[string]bool{"3": true, "14": false, "16": true} {
+		cfg, client := sink(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", code)
+			w.Header().Set("Grpc-Message", "no%20dice")
+		})
+		_, err := deliver(t, cfg, client)
+		serr, ok := err.(*StatusError)
+		if !ok || serr.Message != "no dice" || Permanent(err) != permanent {
+			t.Fatalf("code %s: got %v", code, err)
+		}
+	}
+}
@@ -1,36 +1,156 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/zachbroad/nitrohook/internal/amqp"
+	"github.com/zachbroad/nitrohook/internal/bigquery"
+	"github.com/zachbroad/nitrohook/internal/clienttls"
+	"github.com/zachbroad/nitrohook/internal/cloudevents"
+	"github.com/zachbroad/nitrohook/internal/credential"
+	"github.com/zachbroad/nitrohook/internal/datadog"
+	"github.com/zachbroad/nitrohook/internal/elasticsearch"
+	"github.com/zachbroad/nitrohook/internal/email"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/grpcsink"
+	"github.com/zachbroad/nitrohook/internal/kinesis"
 	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/mqtt"
+	"github.com/zachbroad/nitrohook/internal/opsgenie"
+	"github.com/zachbroad/nitrohook/internal/outbound"
+	"github.com/zachbroad/nitrohook/internal/pgsink"
+	"github.com/zachbroad/nitrohook/internal/projection"
+	"github.com/zachbroad/nitrohook/internal/proxy"
+	"github.com/zachbroad/nitrohook/internal/redispub"
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
 	"github.com/zachbroad/nitrohook/internal/script"
+	"github.com/zachbroad/nitrohook/internal/slack"
+	"github.com/zachbroad/nitrohook/internal/slo"
+	"github.com/zachbroad/nitrohook/internal/sqs"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
 	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/telegram"
+	"github.com/zachbroad/nitrohook/internal/verify"
+	"github.com/zachbroad/nitrohook/internal/window"
 )
 
 type ActionHandler struct {
-	store *store.Store
+	store               *store.Store
+	requireVerification bool
+	httpClient          *http.Client
+	// secrets seals client certificate keys; without it they can't be set.
+	secrets *encryption.Cipher
+	// guard rejects target URLs that resolve to internal addresses.
+	guard *ssrf.Guard
+	// clients send test events the way the worker sends deliveries.
+	clients *outbound.Clients
+	// objective is the global SLO reported by Stats.
+	objective slo.Objective
+	// credentials authenticate test events like the worker's requests.
+	credentials *credential.Resolver
+	// caps bound how many actions a source may have.
+	caps model.Caps
 }
 
-func NewActionHandler(s *store.Store) *ActionHandler {
-	return &ActionHandler{store: s}
+func NewActionHandler(s *store.Store, requireVerification bool, secrets *encryption.Cipher, clients *outbound.Clients, objective slo.Objective, caps model.Caps) *ActionHandler {
+	return &ActionHandler{
+		store:               s,
+		requireVerification: requireVerification,
+		httpClient:          &http.Client{Timeout: 10 * time.Second, Transport: clients.DirectTransport()},
+		secrets:             secrets,
+		guard:               clients.Guard(),
+		clients:             clients,
+		objective:           objective,
+		credentials:         credential.NewResolver(s, secrets),
+		caps:                caps,
+	}
 }
 
 type createActionRequest struct {
-	Type          string  `json:"type"`
-	TargetURL     *string `json:"target_url,omitempty"`
-	SigningSecret *string `json:"signing_secret,omitempty"`
-	ScriptBody    *string `json:"script_body,omitempty"`
+	Type          string            `json:"type"`
+	TargetURL     *string           `json:"target_url,omitempty"`
+	SigningSecret *string           `json:"signing_secret,omitempty"`
+	ScriptBody    *string           `json:"script_body,omitempty"`
+	Projection    *model.Projection `json:"projection,omitempty"`
+
+	MaxAttemptsPerHour *int `json:"max_attempts_per_hour,omitempty"`
+	MaxAttemptsPerDay  *int `json:"max_attempts_per_day,omitempty"`
+	// MaxRequestsPerSecond rate-limits requests to the target.
+	MaxRequestsPerSecond *int `json:"max_requests_per_second,omitempty"`
+	// CloudEventsMode is "binary", "structured", or "" to send plain payloads.
+	CloudEventsMode *string `json:"cloudevents_mode,omitempty"`
+	// HTTPMethod, URLTemplate and BodyTemplate shape webhook requests; ""
+	// clears them.
+	HTTPMethod   *string `json:"http_method,omitempty"`
+	URLTemplate  *string `json:"url_template,omitempty"`
+	BodyTemplate *string `json:"body_template,omitempty"`
+	// ProxyURL routes webhook requests through a proxy; "" clears it.
+	ProxyURL *string `json:"proxy_url,omitempty"`
+	// EventTypes limits the action to these event types; [] clears it.
+	EventTypes *[]string `json:"event_types,omitempty"`
+	// Config and Secret configure integration actions (slack, smtp,
+	// opsgenie, sqs, kinesis, amqp, mqtt, telegram, grpc, postgres,
+	// elasticsearch, datadog, bigquery, redis). Secrets, such as a slack bot
+	// token or AWS key pair, are stored sealed; smtp actions take none.
+	Config json.RawMessage `json:"config,omitempty"`
+	Secret *string         `json:"secret,omitempty"`
+	// DeliveryWindow holds deliveries outside it until it opens; {} clears
+	// it.
+	DeliveryWindow *model.DeliveryWindow `json:"delivery_window,omitempty"`
+	// ExternalID makes the request an upsert: an existing action on the
+	// source with this external ID has its settings replaced instead.
+	ExternalID string `json:"external_id,omitempty"`
 }
 
 type updateActionRequest struct {
-	TargetURL     *string `json:"target_url,omitempty"`
-	SigningSecret *string `json:"signing_secret,omitempty"`
-	IsActive      *bool   `json:"is_active,omitempty"`
+	TargetURL     *string           `json:"target_url,omitempty"`
+	SigningSecret *string           `json:"signing_secret,omitempty"`
+	IsActive      *bool             `json:"is_active,omitempty"`
+	Projection    *model.Projection `json:"projection,omitempty"`
+
+	MaxAttemptsPerHour *int `json:"max_attempts_per_hour,omitempty"`
+	MaxAttemptsPerDay  *int `json:"max_attempts_per_day,omitempty"`
+	// MaxRequestsPerSecond rate-limits requests to the target.
+	MaxRequestsPerSecond *int `json:"max_requests_per_second,omitempty"`
+	// CloudEventsMode is "binary", "structured", or "" to send plain payloads.
+	CloudEventsMode *string `json:"cloudevents_mode,omitempty"`
+	// HTTPMethod, URLTemplate and BodyTemplate shape webhook requests; ""
+	// clears them.
+	HTTPMethod   *string `json:"http_method,omitempty"`
+	URLTemplate  *string `json:"url_template,omitempty"`
+	BodyTemplate *string `json:"body_template,omitempty"`
+	// ProxyURL routes webhook requests through a proxy; "" clears it.
+	ProxyURL *string `json:"proxy_url,omitempty"`
+	// EventTypes limits the action to these event types; [] clears it.
+	EventTypes *[]string `json:"event_types,omitempty"`
+	// Config and Secret configure integration actions (slack, smtp,
+	// opsgenie, sqs, kinesis, amqp, mqtt, telegram, grpc, postgres,
+	// elasticsearch, datadog, bigquery, redis). Secrets, such as a slack bot
+	// token or AWS key pair, are stored sealed; smtp actions take none.
+	Config json.RawMessage `json:"config,omitempty"`
+	Secret *string         `json:"secret,omitempty"`
+	// DeliveryWindow holds deliveries outside it until it opens; {} clears
+	// it.
+	DeliveryWindow *model.DeliveryWindow `json:"delivery_window,omitempty"`
 }
 
+type checkVerificationRequest struct {
+	Method string `json:"method"`
+}
+
+// errRateLimitJavascript rejects a rate limit on a javascript action, which
+// sends no requests for it to apply to.
+const errRateLimitJavascript = "max_requests_per_second doesn't apply to javascript actions"
+
 func (h *ActionHandler) Create(c *gin.Context) {
 	sourceSlug := c.Param("sourceSlug")
 
@@ -57,6 +177,10 @@ func (h *ActionHandler) Create(c *gin.Context) {
 			c.String(http.StatusBadRequest, "target_url is required for webhook actions")
 			return
 		}
+		if err := h.guard.CheckURL(c.Request.Context(), *req.TargetURL); err != nil {
+			c.String(http.StatusBadRequest, "invalid target_url: "+err.Error())
+			return
+		}
 	case model.ActionTypeJavascript:
 		if req.ScriptBody == nil || *req.ScriptBody == "" {
 			c.String(http.StatusBadRequest, "script_body is required for javascript actions")
@@ -66,12 +190,120 @@ func (h *ActionHandler) Create(c *gin.Context) {
 			c.String(http.StatusBadRequest, "invalid script: %s", err.Error())
 			return
 		}
+	case model.ActionTypeSlack, model.ActionTypeSMTP, model.ActionTypeOpsGenie, model.ActionTypeSQS, model.ActionTypeKinesis, model.ActionTypeAMQP, model.ActionTypeMQTT, model.ActionTypeTelegram, model.ActionTypeGRPC, model.ActionTypePostgres, model.ActionTypeElasticsearch, model.ActionTypeDatadog, model.ActionTypeBigQuery, model.ActionTypeRedis:
 	default:
-		c.String(http.StatusBadRequest, "invalid action type: must be 'webhook' or 'javascript'")
+		c.String(http.StatusBadRequest, "invalid action type: must be 'webhook', 'javascript', 'slack', 'smtp', 'opsgenie', 'sqs', 'kinesis', 'amqp', 'mqtt', 'telegram', 'grpc', 'postgres', 'elasticsearch', 'datadog', 'bigquery' or 'redis'")
+		return
+	}
+	secret := ""
+	if req.Secret != nil {
+		secret = *req.Secret
+	}
+	if err := validActionConfig(actionType, req.Config, secret); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	sealedSecret, ok := h.sealSecret(c, req.Secret)
+	if !ok {
+		return
+	}
+
+	if err := projection.Validate(req.Projection); err != nil {
+		c.String(http.StatusBadRequest, "invalid projection: %s", err.Error())
+		return
+	}
+	if !validAttemptCap(req.MaxAttemptsPerHour) || !validAttemptCap(req.MaxAttemptsPerDay) {
+		c.String(http.StatusBadRequest, "attempt caps must be positive")
+		return
+	}
+	if !validAttemptCap(req.MaxRequestsPerSecond) {
+		c.String(http.StatusBadRequest, "max_requests_per_second must be positive")
+		return
+	}
+	if req.MaxRequestsPerSecond != nil && actionType == model.ActionTypeJavascript {
+		c.String(http.StatusBadRequest, errRateLimitJavascript)
+		return
+	}
+	if !validCloudEventsMode(req.CloudEventsMode) {
+		c.String(http.StatusBadRequest, "cloudevents_mode must be 'binary' or 'structured'")
+		return
+	}
+	targetURL := ""
+	if req.TargetURL != nil {
+		targetURL = *req.TargetURL
+	}
+	if err := validRequestTemplate(req.HTTPMethod, req.URLTemplate, req.BodyTemplate, targetURL); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validProxyURL(req.ProxyURL) {
+		c.String(http.StatusBadRequest, "proxy_url must be an http, https, socks5 or socks5h URL")
+		return
+	}
+	// Unlike the operator's proxy, an action's own is held to the guard
+	if req.ProxyURL != nil && *req.ProxyURL != "" {
+		if err := h.guard.CheckURL(c.Request.Context(), *req.ProxyURL); err != nil {
+			c.String(http.StatusBadRequest, "invalid proxy_url: "+err.Error())
+			return
+		}
+	}
+	if !validEventTypes(req.EventTypes) {
+		c.String(http.StatusBadRequest, "event_types must not contain empty names")
+		return
+	}
+	if !window.Empty(req.DeliveryWindow) {
+		if err := window.Validate(req.DeliveryWindow); err != nil {
+			c.String(http.StatusBadRequest, "invalid delivery_window: "+err.Error())
+			return
+		}
+	}
+
+	fields := store.ActionFields{
+		TargetURL:            req.TargetURL,
+		SigningSecret:        req.SigningSecret,
+		ScriptBody:           req.ScriptBody,
+		Projection:           req.Projection,
+		MaxAttemptsPerHour:   req.MaxAttemptsPerHour,
+		MaxAttemptsPerDay:    req.MaxAttemptsPerDay,
+		MaxRequestsPerSecond: req.MaxRequestsPerSecond,
+		CloudEventsMode:      req.CloudEventsMode,
+		EventTypes:           req.EventTypes,
+		HTTPMethod:           req.HTTPMethod,
+		URLTemplate:          req.URLTemplate,
+		BodyTemplate:         req.BodyTemplate,
+		ProxyURL:             req.ProxyURL,
+		Config:               req.Config,
+		Secret:               sealedSecret,
+		DeliveryWindow:       req.DeliveryWindow,
+	}
+	// Unverified webhook targets start inactive until ownership is proven
+	if h.requireVerification && actionType == model.ActionTypeWebhook {
+		inactive := false
+		fields.IsActive = &inactive
+	}
+
+	if req.ExternalID != "" {
+		_, err := h.store.Actions.GetByExternalID(c.Request.Context(), src.ID, req.ExternalID)
+		if errors.Is(err, pgx.ErrNoRows) && !allowNewAction(c, h.store, h.caps, src.ID) {
+			return
+		}
+		action, created, err := h.store.Actions.UpsertByExternalID(c.Request.Context(), src.ID, actionType, req.ExternalID, fields, h.requireVerification)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "failed to upsert action")
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		c.JSON(status, action)
 		return
 	}
 
-	action, err := h.store.Actions.Create(c.Request.Context(), src.ID, actionType, req.TargetURL, req.SigningSecret, req.ScriptBody)
+	if !allowNewAction(c, h.store, h.caps, src.ID) {
+		return
+	}
+	action, err := h.store.Actions.Create(c.Request.Context(), src.ID, actionType, fields)
 	if err != nil {
 		c.String(http.StatusInternalServerError, "failed to create action")
 		return
@@ -89,6 +321,20 @@ func (h *ActionHandler) List(c *gin.Context) {
 		return
 	}
 
+	if externalID := c.Query("external_id"); externalID != "" {
+		action, err := h.store.Actions.GetByExternalID(c.Request.Context(), src.ID, externalID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.Data(http.StatusOK, "application/json", []byte("[]"))
+			return
+		}
+		if err != nil {
+			c.String(http.StatusInternalServerError, "failed to list actions")
+			return
+		}
+		c.JSON(http.StatusOK, []*model.Action{action})
+		return
+	}
+
 	actions, err := h.store.Actions.List(c.Request.Context(), src.ID)
 	if err != nil {
 		c.String(http.StatusInternalServerError, "failed to list actions")
@@ -117,6 +363,56 @@ func (h *ActionHandler) Get(c *gin.Context) {
 	c.JSON(http.StatusOK, action)
 }
 
+// ExportAttempts streams every attempt to the action since ?since= (RFC3339,
+// default all time) as NDJSON, oldest first, for handing a subscriber their
+// delivery history. Response bodies are omitted as in other listings.
+func (h *ActionHandler) ExportAttempts(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+	action, err := h.store.Actions.GetByID(ctx, id)
+	if err != nil || action.SourceID != src.ID {
+		c.String(http.StatusNotFound, "action not found")
+		return
+	}
+
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			c.String(http.StatusBadRequest, "since must be an RFC3339 timestamp")
+			return
+		}
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="attempts-%s.ndjson"`, action.ID))
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	n := 0
+	err = h.store.Deliveries.EachAttemptByAction(ctx, action.ID, since, func(a *model.DeliveryAttempt) error {
+		redactResponseBody(a)
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+		if n++; n%500 == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent; the truncated stream is all we can do
+		slog.ErrorContext(ctx, "failed to export attempts", "error", err, "action_id", action.ID)
+	}
+}
+
 func (h *ActionHandler) Update(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -130,7 +426,139 @@ func (h *ActionHandler) Update(c *gin.Context) {
 		return
 	}
 
-	action, err := h.store.Actions.Update(c.Request.Context(), id, req.TargetURL, req.SigningSecret, req.IsActive, nil)
+	if !projection.Empty(req.Projection) {
+		if err := projection.Validate(req.Projection); err != nil {
+			c.String(http.StatusBadRequest, "invalid projection: %s", err.Error())
+			return
+		}
+	}
+	if !validAttemptCap(req.MaxAttemptsPerHour) || !validAttemptCap(req.MaxAttemptsPerDay) {
+		c.String(http.StatusBadRequest, "attempt caps must be positive")
+		return
+	}
+	if !validAttemptCap(req.MaxRequestsPerSecond) {
+		c.String(http.StatusBadRequest, "max_requests_per_second must be positive")
+		return
+	}
+	if req.MaxRequestsPerSecond != nil {
+		existing, err := h.store.Actions.GetByID(c.Request.Context(), id)
+		if err != nil {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		if existing.Type == model.ActionTypeJavascript {
+			c.String(http.StatusBadRequest, errRateLimitJavascript)
+			return
+		}
+	}
+	if !validCloudEventsMode(req.CloudEventsMode) {
+		c.String(http.StatusBadRequest, "cloudevents_mode must be 'binary' or 'structured'")
+		return
+	}
+	urlTemplate, targetURL, ok := h.effectiveURLs(c, id, req.URLTemplate, req.TargetURL)
+	if !ok {
+		return
+	}
+	if err := validRequestTemplate(req.HTTPMethod, urlTemplate, req.BodyTemplate, targetURL); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validProxyURL(req.ProxyURL) {
+		c.String(http.StatusBadRequest, "proxy_url must be an http, https, socks5 or socks5h URL")
+		return
+	}
+	// Unlike the operator's proxy, an action's own is held to the guard
+	if req.ProxyURL != nil && *req.ProxyURL != "" {
+		if err := h.guard.CheckURL(c.Request.Context(), *req.ProxyURL); err != nil {
+			c.String(http.StatusBadRequest, "invalid proxy_url: "+err.Error())
+			return
+		}
+	}
+	if !validEventTypes(req.EventTypes) {
+		c.String(http.StatusBadRequest, "event_types must not contain empty names")
+		return
+	}
+	if !window.Empty(req.DeliveryWindow) {
+		if err := window.Validate(req.DeliveryWindow); err != nil {
+			c.String(http.StatusBadRequest, "invalid delivery_window: "+err.Error())
+			return
+		}
+	}
+	if req.TargetURL != nil {
+		if err := h.guard.CheckURL(c.Request.Context(), *req.TargetURL); err != nil {
+			c.String(http.StatusBadRequest, "invalid target_url: "+err.Error())
+			return
+		}
+	}
+	if req.Config != nil || req.Secret != nil {
+		existing, err := h.store.Actions.GetByID(c.Request.Context(), id)
+		if err != nil {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		// Validate the settings the action will end up with
+		config := existing.Config
+		if req.Config != nil {
+			config = req.Config
+		}
+		var secret string
+		if req.Secret != nil {
+			secret = *req.Secret
+		} else if existing.Secret != nil {
+			if secret, err = h.secrets.Open(*existing.Secret); err != nil {
+				slog.ErrorContext(c.Request.Context(), "failed to open action secret", "error", err, "action_id", id)
+				c.String(http.StatusInternalServerError, "failed to read action secret")
+				return
+			}
+		}
+		if err := validActionConfig(existing.Type, config, secret); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	sealedSecret, ok := h.sealSecret(c, req.Secret)
+	if !ok {
+		return
+	}
+
+	if h.requireVerification {
+		existing, err := h.store.Actions.GetByID(c.Request.Context(), id)
+		if err != nil {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		if existing.Type == model.ActionTypeWebhook {
+			targetChanged := req.TargetURL != nil && (existing.TargetURL == nil || *req.TargetURL != *existing.TargetURL)
+			if req.IsActive != nil && *req.IsActive && (targetChanged || existing.VerifiedAt == nil) {
+				c.String(http.StatusConflict, "target URL must be verified before the action can be activated")
+				return
+			}
+			// A new target needs a fresh verification, so deactivate until then
+			if targetChanged {
+				inactive := false
+				req.IsActive = &inactive
+			}
+		}
+	}
+
+	action, err := h.store.Actions.Update(c.Request.Context(), id, store.ActionFields{
+		TargetURL:            req.TargetURL,
+		SigningSecret:        req.SigningSecret,
+		IsActive:             req.IsActive,
+		Projection:           req.Projection,
+		MaxAttemptsPerHour:   req.MaxAttemptsPerHour,
+		MaxAttemptsPerDay:    req.MaxAttemptsPerDay,
+		MaxRequestsPerSecond: req.MaxRequestsPerSecond,
+		CloudEventsMode:      req.CloudEventsMode,
+		EventTypes:           req.EventTypes,
+		HTTPMethod:           req.HTTPMethod,
+		URLTemplate:          req.URLTemplate,
+		BodyTemplate:         req.BodyTemplate,
+		ProxyURL:             req.ProxyURL,
+		Config:               req.Config,
+		Secret:               sealedSecret,
+		DeliveryWindow:       req.DeliveryWindow,
+	})
 	if err != nil {
 		c.String(http.StatusInternalServerError, "failed to update action")
 		return
@@ -139,6 +567,31 @@ func (h *ActionHandler) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, action)
 }
 
+// effectiveURLs returns the URL template and target URL an update leaves the
+// action with, so a new target is checked against the kept template and a
+// new template against the kept target. The template is nil when the update
+// leaves neither to check.
+func (h *ActionHandler) effectiveURLs(c *gin.Context, id uuid.UUID, urlTemplate, targetURL *string) (*string, string, bool) {
+	if urlTemplate == nil && targetURL == nil {
+		return nil, "", true
+	}
+	existing, err := h.store.Actions.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.String(http.StatusNotFound, "action not found")
+		return nil, "", false
+	}
+	if urlTemplate == nil {
+		urlTemplate = existing.URLTemplate
+	}
+	if targetURL == nil {
+		targetURL = existing.TargetURL
+	}
+	if targetURL == nil {
+		return urlTemplate, "", true
+	}
+	return urlTemplate, *targetURL, true
+}
+
 func (h *ActionHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -153,3 +606,628 @@ func (h *ActionHandler) Delete(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+func validAttemptCap(n *int) bool {
+	return n == nil || *n > 0
+}
+
+func validCloudEventsMode(mode *string) bool {
+	return mode == nil || *mode == "" || *mode == cloudevents.ModeBinary || *mode == cloudevents.ModeStructured
+}
+
+// validProxyURL reports whether u is empty (clearing it) or a usable proxy.
+func validProxyURL(u *string) bool {
+	if u == nil || *u == "" {
+		return true
+	}
+	_, err := proxy.ParseOutbound(*u)
+	return err == nil
+}
+
+// validRequestTemplate checks the request shaping fields; empty strings
+// clear them and are always valid. A URL template must keep targetURL's
+// scheme and host.
+func validRequestTemplate(method, urlTemplate, bodyTemplate *string, targetURL string) error {
+	if method != nil && *method != "" && !reqtemplate.ValidMethod(*method) {
+		return fmt.Errorf("http_method must be one of %s", strings.Join(reqtemplate.Methods, ", "))
+	}
+	if urlTemplate != nil && *urlTemplate != "" {
+		if err := reqtemplate.CheckURL(*urlTemplate, targetURL); err != nil {
+			return fmt.Errorf("invalid url_template: %w", err)
+		}
+	}
+	if bodyTemplate != nil && *bodyTemplate != "" {
+		if _, err := reqtemplate.Parse(*bodyTemplate); err != nil {
+			return fmt.Errorf("invalid body_template: %w", err)
+		}
+	}
+	return nil
+}
+
+// validActionConfig checks the type-specific config and plaintext secret of
+// an action. Types without settings take neither.
+func validActionConfig(t model.ActionType, config json.RawMessage, secret string) error {
+	switch t {
+	case model.ActionTypeSlack:
+		cfg, err := slack.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return slack.Validate(cfg, secret)
+	case model.ActionTypeSMTP:
+		cfg, err := email.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return email.Validate(cfg, secret)
+	case model.ActionTypeOpsGenie:
+		cfg, err := opsgenie.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return opsgenie.Validate(cfg, secret)
+	case model.ActionTypeSQS:
+		cfg, err := sqs.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return sqs.Validate(cfg, secret)
+	case model.ActionTypeKinesis:
+		cfg, err := kinesis.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return kinesis.Validate(cfg, secret)
+	case model.ActionTypeAMQP:
+		cfg, err := amqp.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return amqp.Validate(cfg, secret)
+	case model.ActionTypeMQTT:
+		cfg, err := mqtt.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return mqtt.Validate(cfg, secret)
+	case model.ActionTypeTelegram:
+		cfg, err := telegram.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return telegram.Validate(cfg, secret)
+	case model.ActionTypeGRPC:
+		cfg, err := grpcsink.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return grpcsink.Validate(cfg)
+	case model.ActionTypePostgres:
+		cfg, err := pgsink.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return pgsink.Validate(cfg, secret)
+	case model.ActionTypeElasticsearch:
+		cfg, err := elasticsearch.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return elasticsearch.Validate(cfg, secret)
+	case model.ActionTypeDatadog:
+		cfg, err := datadog.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return datadog.Validate(cfg, secret)
+	case model.ActionTypeBigQuery:
+		cfg, err := bigquery.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return bigquery.Validate(cfg, secret)
+	case model.ActionTypeRedis:
+		cfg, err := redispub.ParseConfig(config)
+		if err != nil {
+			return err
+		}
+		return redispub.Validate(cfg, secret)
+	}
+	if len(config) > 0 || secret != "" {
+		return fmt.Errorf("config and secret don't apply to %s actions", t)
+	}
+	return nil
+}
+
+// sealSecret seals a non-empty secret for storage; "" passes through to
+// clear it. Without a secrets key it answers 503 and reports false.
+func (h *ActionHandler) sealSecret(c *gin.Context, secret *string) (*string, bool) {
+	if secret == nil || *secret == "" {
+		return secret, true
+	}
+	if h.secrets == nil {
+		c.String(http.StatusServiceUnavailable, "SECRETS_KEY must be configured to store action secrets")
+		return nil, false
+	}
+	sealed := h.secrets.Seal(*secret)
+	return &sealed, true
+}
+
+func validEventTypes(types *[]string) bool {
+	if types == nil {
+		return true
+	}
+	for _, t := range *types {
+		if strings.TrimSpace(t) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// RotatePortalToken issues a consumer portal token for the action. The token
+// is only shown in this response.
+func (h *ActionHandler) RotatePortalToken(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	token, err := h.store.Actions.RotatePortalToken(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		c.String(http.StatusInternalServerError, "failed to rotate portal token")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"portal_token": token})
+}
+
+func (h *ActionHandler) ClearPortalToken(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	if err := h.store.Actions.ClearPortalToken(c.Request.Context(), id); err != nil {
+		c.String(http.StatusInternalServerError, "failed to clear portal token")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// StartVerification issues a new ownership challenge for a webhook action's
+// target URL and returns the DNS and HTTP instructions for satisfying it.
+func (h *ActionHandler) StartVerification(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	action, err := h.store.Actions.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.String(http.StatusNotFound, "action not found")
+		return
+	}
+	if action.Type != model.ActionTypeWebhook || action.TargetURL == nil {
+		c.String(http.StatusBadRequest, "only webhook actions can be verified")
+		return
+	}
+
+	token, err := verify.NewToken()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to generate verification token")
+		return
+	}
+
+	instructions, err := verify.InstructionsFor(*action.TargetURL, token)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.store.Actions.SetVerificationToken(c.Request.Context(), id, token); err != nil {
+		c.String(http.StatusInternalServerError, "failed to start verification")
+		return
+	}
+
+	c.JSON(http.StatusOK, instructions)
+}
+
+// CheckVerification checks the published challenge using the requested method
+// and marks the action verified on success.
+func (h *ActionHandler) CheckVerification(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	var req checkVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	action, err := h.store.Actions.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.String(http.StatusNotFound, "action not found")
+		return
+	}
+	if action.TargetURL == nil || action.VerificationToken == nil {
+		c.String(http.StatusBadRequest, "verification has not been started for this action")
+		return
+	}
+
+	switch verify.Method(req.Method) {
+	case verify.MethodDNS:
+		err = verify.CheckDNS(c.Request.Context(), *action.TargetURL, *action.VerificationToken)
+	case verify.MethodHTTP:
+		err = verify.CheckHTTP(c.Request.Context(), h.httpClient, *action.TargetURL, *action.VerificationToken)
+	default:
+		c.String(http.StatusBadRequest, "method must be 'dns' or 'http'")
+		return
+	}
+	if err != nil {
+		if errors.Is(err, verify.ErrTokenNotFound) {
+			c.String(http.StatusUnprocessableEntity, "verification failed: token not found")
+			return
+		}
+		c.String(http.StatusUnprocessableEntity, "verification failed: %s", err.Error())
+		return
+	}
+
+	action, err = h.store.Actions.MarkVerified(c.Request.Context(), id)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to mark action verified")
+		return
+	}
+
+	c.JSON(http.StatusOK, action)
+}
+
+type clientTLSRequest struct {
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
+	CABundle   string `json:"ca_bundle"`
+}
+
+// SetClientTLS configures the client certificate and/or CA bundle used for
+// the action's webhook requests. The key is stored sealed and not returned.
+func (h *ActionHandler) SetClientTLS(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	var req clientTLSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.ClientCert == "" && req.CABundle == "" {
+		c.String(http.StatusBadRequest, "client_cert and client_key, or ca_bundle, are required")
+		return
+	}
+	if _, err := clienttls.Config(req.ClientCert, req.ClientKey, req.CABundle); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ClientKey != "" && h.secrets == nil {
+		c.String(http.StatusServiceUnavailable, "SECRETS_KEY must be configured to store client keys")
+		return
+	}
+
+	var cert, key, ca *string
+	if req.ClientCert != "" {
+		sealed := h.secrets.Seal(req.ClientKey)
+		cert, key = &req.ClientCert, &sealed
+	}
+	if req.CABundle != "" {
+		ca = &req.CABundle
+	}
+	h.setClientTLS(c, id, cert, key, ca)
+}
+
+// ClearClientTLS removes the action's client certificate and CA bundle.
+func (h *ActionHandler) ClearClientTLS(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+	h.setClientTLS(c, id, nil, nil, nil)
+}
+
+func (h *ActionHandler) setClientTLS(c *gin.Context, id uuid.UUID, cert, key, ca *string) {
+	action, err := h.store.Actions.SetClientTLS(c.Request.Context(), id, cert, key, ca)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to set client tls", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update action")
+		return
+	}
+	c.JSON(http.StatusOK, action)
+}
+
+type sloRequest struct {
+	Target float64 `json:"target"`
+}
+
+// SetSLO sets the action's delivery success target, overriding SLO_TARGET.
+func (h *ActionHandler) SetSLO(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	var req sloRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := slo.ValidTarget(req.Target); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	h.setSLOTarget(c, id, &req.Target)
+}
+
+// ClearSLO reverts the action to the global delivery success target.
+func (h *ActionHandler) ClearSLO(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+	h.setSLOTarget(c, id, nil)
+}
+
+func (h *ActionHandler) setSLOTarget(c *gin.Context, id uuid.UUID, target *float64) {
+	action, err := h.store.Actions.SetSLOTarget(c.Request.Context(), id, target)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to set slo target", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update action")
+		return
+	}
+	c.JSON(http.StatusOK, action)
+}
+
+type credentialRequest struct {
+	CredentialID uuid.UUID `json:"credential_id" binding:"required"`
+}
+
+// SetCredential makes the worker authenticate the webhook action's requests
+// with a stored credential.
+func (h *ActionHandler) SetCredential(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	var req credentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "credential_id is required")
+		return
+	}
+	ctx := c.Request.Context()
+	action, err := h.store.Actions.GetByID(ctx, id)
+	if err != nil {
+		c.String(http.StatusNotFound, "action not found")
+		return
+	}
+	if action.Type != model.ActionTypeWebhook {
+		c.String(http.StatusBadRequest, "credentials apply to webhook actions only")
+		return
+	}
+	if action.AuthType != nil {
+		c.String(http.StatusConflict, "action has its own auth; remove it first")
+		return
+	}
+	if _, err := h.store.Credentials.GetByID(ctx, req.CredentialID); err != nil {
+		c.String(http.StatusBadRequest, "credential not found")
+		return
+	}
+	h.setCredential(c, id, &req.CredentialID)
+}
+
+// ClearCredential detaches the action's credential.
+func (h *ActionHandler) ClearCredential(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+	h.setCredential(c, id, nil)
+}
+
+func (h *ActionHandler) setCredential(c *gin.Context, id uuid.UUID, credentialID *uuid.UUID) {
+	action, err := h.store.Actions.SetCredential(c.Request.Context(), id, credentialID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to set credential", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update action")
+		return
+	}
+	c.JSON(http.StatusOK, action)
+}
+
+type authRequest struct {
+	Type     string `json:"type" binding:"required"`
+	Username string `json:"username,omitempty"`
+	Header   string `json:"header,omitempty"`
+	Secret   string `json:"secret"`
+}
+
+// SetAuth gives the webhook action a static auth header: basic auth, a
+// bearer token or a custom header. The secret is sealed.
+func (h *ActionHandler) SetAuth(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+
+	var req authRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "type is required")
+		return
+	}
+	if req.Type == model.CredentialOAuth2 {
+		c.String(http.StatusBadRequest, "oauth2 needs a stored credential; see /api/credentials")
+		return
+	}
+	cfg := credential.Config{Username: req.Username, Header: req.Header}
+	if err := credential.Validate(req.Type, cfg, req.Secret); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.secrets == nil {
+		c.String(http.StatusServiceUnavailable, "SECRETS_KEY must be configured to store action auth")
+		return
+	}
+
+	ctx := c.Request.Context()
+	action, err := h.store.Actions.GetByID(ctx, id)
+	if err != nil {
+		c.String(http.StatusNotFound, "action not found")
+		return
+	}
+	if action.Type != model.ActionTypeWebhook {
+		c.String(http.StatusBadRequest, "auth applies to webhook actions only")
+		return
+	}
+	if action.CredentialID != nil {
+		c.String(http.StatusConflict, "action uses a stored credential; remove it first")
+		return
+	}
+	rawConfig, err := json.Marshal(cfg)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to update action")
+		return
+	}
+	sealed := h.secrets.Seal(req.Secret)
+	h.setAuth(c, id, &req.Type, rawConfig, &sealed)
+}
+
+// ClearAuth removes the action's static auth header.
+func (h *ActionHandler) ClearAuth(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+	h.setAuth(c, id, nil, nil, nil)
+}
+
+func (h *ActionHandler) setAuth(c *gin.Context, id uuid.UUID, authType *string, config json.RawMessage, secret *string) {
+	action, err := h.store.Actions.SetAuth(c.Request.Context(), id, authType, config, secret)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusNotFound, "action not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to set auth", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update action")
+		return
+	}
+	c.JSON(http.StatusOK, action)
+}
+
+type actionStats struct {
+	ActionID   uuid.UUID        `json:"action_id"`
+	Type       model.ActionType `json:"type"`
+	IsActive   bool             `json:"is_active"`
+	Deliveries int64            `json:"deliveries"`
+	Failed     int64            `json:"failed"`
+	slo.Status
+	ExhaustedAt *time.Time `json:"exhausted_at,omitempty"`
+	// RetryReasons counts retries scheduled in the window by reason code.
+	RetryReasons map[string]int64 `json:"retry_reasons,omitempty"`
+}
+
+// Stats reports each of the source's actions against its delivery success
+// objective over SLO_WINDOW: the success rate, error budget left and burn
+// rate over the last hour. Outcomes are each delivery's latest finished
+// attempt, so a failure later retried successfully doesn't count.
+func (h *ActionHandler) Stats(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	actions, err := h.store.Actions.List(ctx, src.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list actions", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get action stats")
+		return
+	}
+	now := time.Now()
+	outcomes, err := h.store.Actions.Outcomes(ctx, &src.ID, now.Add(-h.objective.Window), now.Add(-slo.BurnWindow))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get action outcomes", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get action stats")
+		return
+	}
+	byAction := make(map[uuid.UUID]model.ActionOutcomes, len(outcomes))
+	for _, o := range outcomes {
+		byAction[o.ActionID] = o
+	}
+	reasons, err := h.store.Actions.RetryReasons(ctx, &src.ID, now.Add(-h.objective.Window))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get retry reasons", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get action stats")
+		return
+	}
+	reasonsByAction := make(map[uuid.UUID]map[string]int64)
+	for _, r := range reasons {
+		if reasonsByAction[r.ActionID] == nil {
+			reasonsByAction[r.ActionID] = map[string]int64{}
+		}
+		reasonsByAction[r.ActionID][r.Reason] += r.Count
+	}
+
+	stats := make([]actionStats, 0, len(actions))
+	for _, a := range actions {
+		target := h.objective.Target
+		if a.SLOTarget != nil {
+			target = *a.SLOTarget
+		}
+		o := byAction[a.ID]
+		stats = append(stats, actionStats{
+			ActionID:     a.ID,
+			Type:         a.Type,
+			IsActive:     a.IsActive,
+			Deliveries:   o.Total,
+			Failed:       o.Failed,
+			Status:       slo.Evaluate(target, o),
+			ExhaustedAt:  a.SLOExhaustedAt,
+			RetryReasons: reasonsByAction[a.ID],
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"window":      h.objective.Window.String(),
+		"burn_window": slo.BurnWindow.String(),
+		"actions":     stats,
+	})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/metrics"
+	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
+)
+
+const (
+	defaultRequeueLimit = 1000
+	maxRequeueLimit     = 10000
+)
+
+type AdminHandler struct {
+	store *store.Store
+	rdb   *redis.Client
+	trim  streamtrim.Policy
+}
+
+func NewAdminHandler(s *store.Store, rdb *redis.Client, trim streamtrim.Policy) *AdminHandler {
+	return &AdminHandler{store: s, rdb: rdb, trim: trim}
+}
+
+// RequeuePending runs the catch-up scan immediately, publishing pending
+// deliveries (oldest first) to the stream instead of waiting for the poller.
+// Useful after Redis has lost stream messages.
+func (h *AdminHandler) RequeuePending(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit := defaultRequeueLimit
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > maxRequeueLimit {
+			c.String(http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxRequeueLimit))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.store.Deliveries.ListPending(ctx, limit, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list pending deliveries", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list pending deliveries")
+		return
+	}
+
+	enqueued := 0
+	for _, d := range deliveries {
+		if err := publishToStream(ctx, h.rdb, h.trim, &d); err != nil {
+			slog.ErrorContext(ctx, "failed to requeue delivery", "error", err, "delivery_id", d.ID)
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":    "failed to publish to stream",
+				"scanned":  len(deliveries),
+				"enqueued": enqueued,
+			})
+			return
+		}
+		enqueued++
+	}
+
+	slog.InfoContext(ctx, "requeued pending deliveries", "count", enqueued)
+	c.JSON(http.StatusOK, gin.H{
+		"scanned":  len(deliveries),
+		"enqueued": enqueued,
+	})
+}
+
+// Metrics returns the operational counters shared by all processes, plus
+// gauges totalled over the workers currently reporting.
+func (h *AdminHandler) Metrics(c *gin.Context) {
+	ctx := c.Request.Context()
+	counters, err := metrics.All(ctx, h.rdb)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read metrics", "error", err)
+		c.String(http.StatusInternalServerError, "failed to read metrics")
+		return
+	}
+	reports, err := metrics.Workers(ctx, h.rdb)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read worker reports", "error", err)
+		c.String(http.StatusInternalServerError, "failed to read metrics")
+		return
+	}
+	maps.Copy(counters, metrics.Totals(reports))
+	c.JSON(http.StatusOK, counters)
+}
+
+// Workers lists the latest self-report of every worker process, for sizing
+// WORKER_CONCURRENCY.
+func (h *AdminHandler) Workers(c *gin.Context) {
+	reports, err := metrics.Workers(c.Request.Context(), h.rdb)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to read worker reports", "error", err)
+		c.String(http.StatusInternalServerError, "failed to read worker reports")
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// RetryReasons totals the retries scheduled across all sources within
+// ?window= (default 24h, up to 7 days) by reason code.
+func (h *AdminHandler) RetryReasons(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	window := defaultScriptStatsWindow
+	if v := c.Query("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxScriptStatsWindow {
+			c.String(http.StatusBadRequest, fmt.Sprintf("window must be a duration up to %s", maxScriptStatsWindow))
+			return
+		}
+		window = d
+	}
+
+	counts, err := h.store.Actions.RetryReasons(ctx, nil, time.Now().Add(-window))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get retry reasons", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get retry reasons")
+		return
+	}
+	reasons := map[string]int64{}
+	for _, r := range counts {
+		reasons[r.Reason] += r.Count
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"window":  window.String(),
+		"reasons": reasons,
+	})
+}
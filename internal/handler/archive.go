@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/archive"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+type ArchiveHandler struct {
+	store *store.Store
+	// objects is nil when archive storage isn't configured.
+	objects archive.ObjectStore
+}
+
+func NewArchiveHandler(s *store.Store, objects archive.ObjectStore) *ArchiveHandler {
+	return &ArchiveHandler{store: s, objects: objects}
+}
+
+type restoreRequest struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// List returns archives newest first, optionally filtered by ?source=<slug>.
+func (h *ArchiveHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var sourceID *uuid.UUID
+	if slug := c.Query("source"); slug != "" {
+		src, err := h.store.Sources.GetBySlug(ctx, slug)
+		if err != nil {
+			c.String(http.StatusNotFound, "source not found")
+			return
+		}
+		sourceID = &src.ID
+	}
+
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+
+	archives, err := h.store.Archives.List(ctx, sourceID, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list archives", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list archives")
+		return
+	}
+	if archives == nil {
+		archives = []model.DeliveryArchive{}
+	}
+	c.JSON(http.StatusOK, archives)
+}
+
+// Restore rehydrates one delivery from an archive back into Postgres. It
+// stays out of the next archiving runs for the archive retention period.
+func (h *ArchiveHandler) Restore(c *gin.Context) {
+	if h.objects == nil {
+		c.String(http.StatusServiceUnavailable, "archive storage is not configured")
+		return
+	}
+	ctx := c.Request.Context()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid archive id")
+		return
+	}
+	var req restoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.DeliveryID == uuid.Nil {
+		c.String(http.StatusBadRequest, "delivery_id is required")
+		return
+	}
+
+	arc, err := h.store.Archives.GetByID(ctx, id)
+	if err != nil {
+		c.String(http.StatusNotFound, "archive not found")
+		return
+	}
+	data, err := h.objects.Get(ctx, arc.ObjectKey)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read archive object", "object_key", arc.ObjectKey, "error", err)
+		c.String(http.StatusBadGateway, "failed to read archive")
+		return
+	}
+	rec, err := archive.Find(data, req.DeliveryID)
+	if err != nil {
+		if errors.Is(err, archive.ErrNotFound) {
+			c.String(http.StatusNotFound, "delivery not found in archive")
+			return
+		}
+		slog.ErrorContext(ctx, "failed to decode archive", "object_key", arc.ObjectKey, "error", err)
+		c.String(http.StatusInternalServerError, "failed to read archive")
+		return
+	}
+
+	restored, err := h.store.Archives.Restore(ctx, &rec.Delivery, rec.Attempts)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to restore delivery", "error", err)
+		c.String(http.StatusInternalServerError, "failed to restore delivery")
+		return
+	}
+	if !restored {
+		c.String(http.StatusConflict, "delivery already exists")
+		return
+	}
+
+	delivery, err := h.store.Deliveries.GetByID(ctx, req.DeliveryID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get restored delivery", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get delivery")
+		return
+	}
+	c.JSON(http.StatusCreated, delivery)
+}
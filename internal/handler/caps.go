@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+// allowNewSource answers 422 and returns false when caps.MaxSources sources
+// already exist.
+func allowNewSource(c *gin.Context, s *store.Store, caps model.Caps) bool {
+	if caps.MaxSources <= 0 {
+		return true
+	}
+	ctx := c.Request.Context()
+	n, err := s.Sources.Count(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to count sources", "error", err)
+		c.String(http.StatusInternalServerError, "failed to create source")
+		return false
+	}
+	if n >= caps.MaxSources {
+		c.String(http.StatusUnprocessableEntity, fmt.Sprintf("source limit reached: at most %d sources may exist (MAX_SOURCES)", caps.MaxSources))
+		return false
+	}
+	return true
+}
+
+// allowNewAction answers 422 and returns false when the source already has
+// caps.MaxActionsPerSource actions.
+func allowNewAction(c *gin.Context, s *store.Store, caps model.Caps, sourceID uuid.UUID) bool {
+	if caps.MaxActionsPerSource <= 0 {
+		return true
+	}
+	ctx := c.Request.Context()
+	n, err := s.Actions.CountBySource(ctx, sourceID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to count actions", "error", err)
+		c.String(http.StatusInternalServerError, "failed to create action")
+		return false
+	}
+	if n >= caps.MaxActionsPerSource {
+		c.String(http.StatusUnprocessableEntity, fmt.Sprintf("action limit reached: a source may have at most %d actions (MAX_ACTIONS_PER_SOURCE)", caps.MaxActionsPerSource))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/zachbroad/nitrohook/internal/credential"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+// CredentialHandler manages the reusable destination credentials actions
+// reference. Secrets are sealed on write and never returned.
+type CredentialHandler struct {
+	store   *store.Store
+	secrets *encryption.Cipher
+}
+
+func NewCredentialHandler(s *store.Store, secrets *encryption.Cipher) *CredentialHandler {
+	return &CredentialHandler{store: s, secrets: secrets}
+}
+
+type createCredentialRequest struct {
+	Name   string          `json:"name" binding:"required"`
+	Type   string          `json:"type" binding:"required"`
+	Config json.RawMessage `json:"config"`
+	Secret string          `json:"secret"`
+}
+
+type updateCredentialRequest struct {
+	Name   *string         `json:"name"`
+	Config json.RawMessage `json:"config"`
+	Secret *string         `json:"secret"`
+}
+
+func (h *CredentialHandler) Create(c *gin.Context) {
+	var req createCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "name and type are required")
+		return
+	}
+	cfg, err := credential.ParseConfig(req.Config)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid config")
+		return
+	}
+	if err := credential.Validate(req.Type, cfg, req.Secret); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.secrets == nil {
+		c.String(http.StatusServiceUnavailable, "SECRETS_KEY must be configured to store credentials")
+		return
+	}
+	if len(req.Config) == 0 {
+		req.Config = json.RawMessage(`{}`)
+	}
+
+	ctx := c.Request.Context()
+	cred, err := h.store.Credentials.Create(ctx, strings.TrimSpace(req.Name), req.Type, req.Config, h.secrets.Seal(req.Secret))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.String(http.StatusConflict, "a credential with this name already exists")
+			return
+		}
+		slog.ErrorContext(ctx, "failed to create credential", "error", err)
+		c.String(http.StatusInternalServerError, "failed to create credential")
+		return
+	}
+	c.JSON(http.StatusCreated, cred)
+}
+
+func (h *CredentialHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+	creds, err := h.store.Credentials.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list credentials", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list credentials")
+		return
+	}
+	if creds == nil {
+		creds = []model.Credential{}
+	}
+	c.JSON(http.StatusOK, creds)
+}
+
+func (h *CredentialHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid credential id")
+		return
+	}
+	cred, err := h.store.Credentials.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.String(http.StatusNotFound, "credential not found")
+		return
+	}
+	c.JSON(http.StatusOK, cred)
+}
+
+// Update renames the credential or replaces its config or secret; the type
+// is fixed. The result is validated as a whole, so the stored secret is
+// opened when only the config changes.
+func (h *CredentialHandler) Update(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid credential id")
+		return
+	}
+	var req updateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		c.String(http.StatusBadRequest, "name must not be empty")
+		return
+	}
+	if h.secrets == nil {
+		c.String(http.StatusServiceUnavailable, "SECRETS_KEY must be configured to store credentials")
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := h.store.Credentials.GetByID(ctx, id)
+	if err != nil {
+		c.String(http.StatusNotFound, "credential not found")
+		return
+	}
+	rawConfig := existing.Config
+	if len(req.Config) > 0 {
+		rawConfig = req.Config
+	}
+	cfg, err := credential.ParseConfig(rawConfig)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid config")
+		return
+	}
+	var secret string
+	if req.Secret != nil {
+		secret = *req.Secret
+	} else if secret, err = h.secrets.Open(existing.Secret); err != nil {
+		slog.ErrorContext(ctx, "failed to open credential secret", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update credential")
+		return
+	}
+	if err := credential.Validate(existing.Type, cfg, secret); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var name, sealed *string
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		name = &trimmed
+	}
+	if req.Secret != nil {
+		s := h.secrets.Seal(*req.Secret)
+		sealed = &s
+	}
+	var config json.RawMessage
+	if len(req.Config) > 0 {
+		config = req.Config
+	}
+	cred, err := h.store.Credentials.Update(ctx, id, name, config, sealed)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusNotFound, "credential not found")
+			return
+		}
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.String(http.StatusConflict, "a credential with this name already exists")
+			return
+		}
+		slog.ErrorContext(ctx, "failed to update credential", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update credential")
+		return
+	}
+	c.JSON(http.StatusOK, cred)
+}
+
+// Delete removes a credential no action references.
+func (h *CredentialHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid credential id")
+		return
+	}
+	ctx := c.Request.Context()
+	if err := h.store.Credentials.Delete(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.String(http.StatusNotFound, "credential not found")
+		case errors.Is(err, store.ErrCredentialInUse):
+			c.String(http.StatusConflict, err.Error())
+		default:
+			slog.ErrorContext(ctx, "failed to delete credential", "error", err)
+			c.String(http.StatusInternalServerError, "failed to delete credential")
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,20 +1,33 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/model"
 	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
 )
 
 type DeliveryHandler struct {
 	store *store.Store
+	rdb   *redis.Client
+	trim  streamtrim.Policy
+	// responseCipher decrypts stored response bodies; bodyToken, when set,
+	// is the bearer token required to read them.
+	responseCipher *encryption.Cipher
+	bodyToken      string
 }
 
-func NewDeliveryHandler(s *store.Store) *DeliveryHandler {
-	return &DeliveryHandler{store: s}
+func NewDeliveryHandler(s *store.Store, rdb *redis.Client, trim streamtrim.Policy, responseCipher *encryption.Cipher, bodyToken string) *DeliveryHandler {
+	return &DeliveryHandler{store: s, rdb: rdb, trim: trim, responseCipher: responseCipher, bodyToken: bodyToken}
 }
 
 func (h *DeliveryHandler) List(c *gin.Context) {
@@ -50,7 +63,7 @@ func (h *DeliveryHandler) Get(c *gin.Context) {
 		return
 	}
 
-	delivery, err := h.store.Deliveries.GetByID(c.Request.Context(), id)
+	delivery, err := h.store.Deliveries.GetWithSummary(c.Request.Context(), id)
 	if err != nil {
 		c.String(http.StatusNotFound, "delivery not found")
 		return
@@ -72,15 +85,181 @@ func (h *DeliveryHandler) ListAttempts(c *gin.Context) {
 		return
 	}
 
-	attempts, err := h.store.Deliveries.ListAttemptsByDelivery(c.Request.Context(), id)
+	filter := store.AttemptFilter{Limit: 50}
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 500 {
+			c.String(http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		filter.Limit = n
+	}
+	if o := c.Query("offset"); o != "" {
+		n, err := strconv.Atoi(o)
+		if err != nil || n < 0 {
+			c.String(http.StatusBadRequest, "invalid offset")
+			return
+		}
+		filter.Offset = n
+	}
+	if st := c.Query("status"); st != "" {
+		status := model.AttemptStatus(st)
+		if !validAttemptStatus(status) {
+			c.String(http.StatusBadRequest, "invalid status")
+			return
+		}
+		filter.Status = &status
+	}
+	if a := c.Query("action_id"); a != "" {
+		actionID, err := uuid.Parse(a)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid action_id")
+			return
+		}
+		filter.ActionID = &actionID
+	}
+
+	// Fetch one extra row to tell whether another page exists
+	pageSize := filter.Limit
+	filter.Limit++
+	attempts, err := h.store.Deliveries.ListAttemptsByDelivery(c.Request.Context(), id, filter)
 	if err != nil {
 		c.String(http.StatusInternalServerError, "failed to list attempts")
 		return
 	}
+	if len(attempts) > pageSize {
+		attempts = attempts[:pageSize]
+		c.Header("X-Next-Offset", strconv.Itoa(filter.Offset+pageSize))
+	}
 
 	if attempts == nil {
 		c.Data(http.StatusOK, "application/json", []byte("[]"))
 		return
 	}
+	redactResponseBodies(attempts)
 	c.JSON(http.StatusOK, attempts)
 }
+
+// ResponseBody returns the decrypted response body of one attempt. It
+// requires RESPONSE_BODY_TOKEN as a bearer token when one is configured.
+func (h *DeliveryHandler) ResponseBody(c *gin.Context) {
+	if h.bodyToken != "" {
+		got, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(h.bodyToken)) != 1 {
+			c.String(http.StatusUnauthorized, "response body token required")
+			return
+		}
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+	attemptID, err := uuid.Parse(c.Param("attemptId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid attempt id")
+		return
+	}
+
+	attempt, err := h.store.Deliveries.GetAttempt(c.Request.Context(), id, attemptID)
+	if err != nil {
+		c.String(http.StatusNotFound, "attempt not found")
+		return
+	}
+	writeResponseBody(c, h.responseCipher, attempt)
+}
+
+func validAttemptStatus(s model.AttemptStatus) bool {
+	switch s {
+	case model.AttemptPending, model.AttemptSuccess, model.AttemptFailed:
+		return true
+	}
+	return false
+}
+
+// RetryAttempt sends a failed attempt's action the delivery again without
+// waiting for the backoff: the attempt is made due now and the worker's retry
+// loop dispatches the next attempt.
+func (h *DeliveryHandler) RetryAttempt(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+	attemptID, err := uuid.Parse(c.Param("attemptId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid attempt id")
+		return
+	}
+
+	retried, err := h.store.Deliveries.RetryAttemptNow(c.Request.Context(), id, attemptID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to retry attempt")
+		return
+	}
+	if !retried {
+		c.String(http.StatusConflict, "only the latest failed attempt of an action can be retried")
+		return
+	}
+
+	// Hand the retry to a worker now; if that fails it is still due, so the
+	// retry poll sends it instead
+	err = publishValues(c.Request.Context(), h.rdb, h.trim, map[string]any{
+		"delivery_id":      id.String(),
+		"retry_attempt_id": attemptID.String(),
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to publish manual retry", "error", err)
+	}
+
+	// The dashboard's Retry button reloads to show the new attempt
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Refresh", "true")
+	}
+	c.JSON(http.StatusAccepted, gin.H{"delivery_id": id, "attempt_id": attemptID, "status": "retry_scheduled"})
+}
+
+type cancelDeliveryRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// Cancel stops a delivery that is still pending or has retries scheduled.
+// Attempts already in flight finish, but nothing further is dispatched.
+func (h *DeliveryHandler) Cancel(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+
+	var req cancelDeliveryRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "cancelled via API"
+	}
+
+	if _, err := h.store.Deliveries.GetByID(c.Request.Context(), id); err != nil {
+		c.String(http.StatusNotFound, "delivery not found")
+		return
+	}
+	cancelled, err := h.store.Deliveries.CancelPending(c.Request.Context(), id, req.Reason)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to cancel delivery")
+		return
+	}
+	if !cancelled {
+		c.String(http.StatusConflict, "delivery has nothing pending to cancel")
+		return
+	}
+
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Refresh", "true")
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery_id": id, "status": model.DeliveryCancelled})
+}
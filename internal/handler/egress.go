@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EgressHandler publishes the addresses outbound requests come from.
+type EgressHandler struct {
+	ipv4 []string
+	ipv6 []string
+}
+
+// NewEgressHandler creates an EgressHandler for the configured egress
+// prefixes.
+func NewEgressHandler(prefixes []netip.Prefix) *EgressHandler {
+	h := &EgressHandler{ipv4: []string{}, ipv6: []string{}}
+	for _, p := range prefixes {
+		if p.Addr().Is4() {
+			h.ipv4 = append(h.ipv4, p.String())
+		} else {
+			h.ipv6 = append(h.ipv6, p.String())
+		}
+	}
+	return h
+}
+
+// Get returns the EGRESS_IPS CIDRs by family, for receivers to allowlist.
+// Both lists are empty when none are configured.
+func (h *EgressHandler) Get(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"ipv4": h.ipv4,
+		"ipv6": h.ipv6,
+	})
+}
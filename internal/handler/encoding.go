@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errDecodedTooLarge     = errors.New("decompressed body too large")
+)
+
+// decodeBody undoes a gzip or deflate Content-Encoding, refusing to inflate
+// beyond maxBytes. Identity (or no) encoding returns body unchanged.
+func decodeBody(encoding string, body []byte, maxBytes int64) ([]byte, error) {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("open gzip body: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some senders use raw
+		// deflate streams.
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			r = flate.NewReader(bytes.NewReader(body))
+		} else {
+			defer zr.Close()
+			r = zr
+		}
+	default:
+		return nil, errUnsupportedEncoding
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompress body: %w", err)
+	}
+	if int64(len(out)) > maxBytes {
+		return nil, errDecodedTooLarge
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+// EventTypeHandler exposes a source's event type catalog, for building
+// subscription pickers for action owners.
+type EventTypeHandler struct {
+	store *store.Store
+}
+
+func NewEventTypeHandler(s *store.Store) *EventTypeHandler {
+	return &EventTypeHandler{store: s}
+}
+
+type declareEventTypeRequest struct {
+	Description *string `json:"description,omitempty"`
+}
+
+func (h *EventTypeHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	types, err := h.store.EventTypes.List(ctx, src.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list event types", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list event types")
+		return
+	}
+	if types == nil {
+		types = []model.EventType{}
+	}
+	c.JSON(http.StatusOK, types)
+}
+
+// Declare adds an event type to the catalog ahead of any delivery of it.
+func (h *EventTypeHandler) Declare(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	name := strings.TrimSpace(c.Param("name"))
+	if name == "" || strings.Contains(name, "*") {
+		c.String(http.StatusBadRequest, "invalid event type name")
+		return
+	}
+	var req declareEventTypeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	t, err := h.store.EventTypes.Declare(ctx, src.ID, name, req.Description)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to declare event type", "error", err)
+		c.String(http.StatusInternalServerError, "failed to declare event type")
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *EventTypeHandler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	deleted, err := h.store.EventTypes.Delete(ctx, src.ID, c.Param("name"))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to delete event type", "error", err)
+		c.String(http.StatusInternalServerError, "failed to delete event type")
+		return
+	}
+	if !deleted {
+		c.String(http.StatusNotFound, "event type not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
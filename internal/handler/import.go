@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/eventtype"
+	"github.com/zachbroad/nitrohook/internal/fingerprint"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+const maxImportLines = 10000
+
+// importLine is one NDJSON record of an import file.
+type importLine struct {
+	Payload        json.RawMessage   `json:"payload"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+	ReceivedAt     *time.Time        `json:"received_at,omitempty"`
+}
+
+type importError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Import creates deliveries from an NDJSON body, one {payload, headers,
+// idempotency_key, received_at} object per line, to backfill missed events.
+// With ?fanout=true, imported deliveries of an active source are queued for
+// fan-out; otherwise they are stored as recorded. Bad lines are reported and
+// skipped.
+func (h *WebhookHandler) Import(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+	fanout := c.Query("fanout") == "true" && src.Mode != "record"
+
+	// A line holds a payload plus its envelope, so allow some headroom
+	maxLine := model.EffectiveLimits(h.limits, src).MaxPayloadBytes + 64*1024
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+
+	var (
+		imported, duplicates int
+		failures             = []importError{}
+	)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if lineNo > maxImportLines {
+			c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("import is limited to %d lines", maxImportLines))
+			return
+		}
+
+		nd, err := parseImportLine(raw)
+		if err != nil {
+			failures = append(failures, importError{Line: lineNo, Error: err.Error()})
+			continue
+		}
+		nd.SourceID = src.ID
+
+		delivery, created, err := h.store.Deliveries.Create(ctx, nd)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to import delivery", "error", err, "line", lineNo)
+			failures = append(failures, importError{Line: lineNo, Error: "failed to store delivery"})
+			continue
+		}
+		if !created {
+			duplicates++
+			continue
+		}
+		imported++
+
+		if !fanout {
+			if err := h.store.Deliveries.UpdateStatus(ctx, delivery.ID, model.DeliveryRecorded); err != nil {
+				slog.ErrorContext(ctx, "failed to update delivery status to recorded", "error", err, "delivery_id", delivery.ID)
+			}
+			continue
+		}
+		if err := publishToStream(ctx, h.rdb, h.trim, delivery); err != nil {
+			// Still pending in Postgres; the catch-up poll will pick it up
+			slog.ErrorContext(ctx, "failed to publish to redis stream", "error", err, "delivery_id", delivery.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("line %d exceeds %d bytes", lineNo+1, maxLine))
+			return
+		}
+		c.String(http.StatusBadRequest, "failed to read body")
+		return
+	}
+
+	slog.InfoContext(ctx, "imported deliveries", "source", src.Slug, "imported", imported, "duplicates", duplicates, "failed", len(failures))
+	c.JSON(http.StatusOK, gin.H{
+		"imported":   imported,
+		"duplicates": duplicates,
+		"failed":     failures,
+		"fanout":     fanout,
+	})
+}
+
+func parseImportLine(raw []byte) (store.NewDelivery, error) {
+	var line importLine
+	if err := json.Unmarshal(raw, &line); err != nil {
+		return store.NewDelivery{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if len(line.Payload) == 0 || bytes.Equal(line.Payload, []byte("null")) {
+		return store.NewDelivery{}, errors.New("payload is required")
+	}
+	if line.IdempotencyKey == "" {
+		line.IdempotencyKey = uuid.New().String()
+	}
+	headers := line.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return store.NewDelivery{}, fmt.Errorf("marshal headers: %w", err)
+	}
+	return store.NewDelivery{
+		IdempotencyKey:   line.IdempotencyKey,
+		Headers:          headersJSON,
+		Payload:          line.Payload,
+		ReceivedAt:       line.ReceivedAt,
+		EventType:        eventtype.Detect(headerOf(headers), line.Payload),
+		DetectedProvider: fingerprint.Detect(headerOf(headers)),
+	}, nil
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/signing"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+const (
+	maxManifestEntries = 10000
+	maxManifestRange   = 31 * 24 * time.Hour
+)
+
+type ManifestHandler struct {
+	store  *store.Store
+	signer *signing.ManifestSigner
+}
+
+// NewManifestHandler returns a handler for signed audit manifests. A nil signer
+// disables the endpoints.
+func NewManifestHandler(s *store.Store, signer *signing.ManifestSigner) *ManifestHandler {
+	return &ManifestHandler{store: s, signer: signer}
+}
+
+// signedManifest carries the exact manifest bytes that were signed so partners
+// can verify the signature without re-serializing.
+type signedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Algorithm string          `json:"algorithm"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+func (h *ManifestHandler) PublicKey(c *gin.Context) {
+	if h.signer == nil {
+		c.String(http.StatusServiceUnavailable, "manifest signing is not configured")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "ed25519",
+		"public_key": h.signer.PublicKey(),
+	})
+}
+
+func (h *ManifestHandler) ForDelivery(c *gin.Context) {
+	if h.signer == nil {
+		c.String(http.StatusServiceUnavailable, "manifest signing is not configured")
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+
+	if _, err := h.store.Deliveries.GetByID(c.Request.Context(), id); err != nil {
+		c.String(http.StatusNotFound, "delivery not found")
+		return
+	}
+
+	entries, err := h.store.Deliveries.ListManifestEntries(c.Request.Context(), &id, nil, nil, maxManifestEntries)
+	if err != nil {
+		slog.Error("failed to list manifest entries", "error", err, "delivery_id", id)
+		c.String(http.StatusInternalServerError, "failed to build manifest")
+		return
+	}
+
+	h.respond(c, model.Manifest{DeliveryID: &id, Entries: entries})
+}
+
+func (h *ManifestHandler) ForRange(c *gin.Context) {
+	if h.signer == nil {
+		c.String(http.StatusServiceUnavailable, "manifest signing is not configured")
+		return
+	}
+
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "from must be an RFC3339 timestamp")
+		return
+	}
+	to, err := time.Parse(time.RFC3339, c.Query("to"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "to must be an RFC3339 timestamp")
+		return
+	}
+	if !to.After(from) {
+		c.String(http.StatusBadRequest, "to must be after from")
+		return
+	}
+	if to.Sub(from) > maxManifestRange {
+		c.String(http.StatusBadRequest, "range must not exceed 31 days")
+		return
+	}
+
+	entries, err := h.store.Deliveries.ListManifestEntries(c.Request.Context(), nil, &from, &to, maxManifestEntries)
+	if err != nil {
+		slog.Error("failed to list manifest entries", "error", err)
+		c.String(http.StatusInternalServerError, "failed to build manifest")
+		return
+	}
+
+	h.respond(c, model.Manifest{From: &from, To: &to, Entries: entries})
+}
+
+func (h *ManifestHandler) respond(c *gin.Context, m model.Manifest) {
+	m.GeneratedAt = time.Now().UTC()
+	if m.Entries == nil {
+		m.Entries = []model.ManifestEntry{}
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to build manifest")
+		return
+	}
+
+	c.JSON(http.StatusOK, signedManifest{
+		Manifest:  body,
+		Algorithm: "ed25519",
+		PublicKey: h.signer.PublicKey(),
+		Signature: h.signer.Sign(body),
+	})
+}
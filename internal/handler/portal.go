@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/projection"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+const portalActionKey = "portal_action"
+
+// PortalHandler serves the consumer self-service portal: endpoints scoped to
+// a single action, authenticated with that action's portal token, so the
+// owner of a target endpoint can debug and manage it without dashboard access.
+type PortalHandler struct {
+	store          *store.Store
+	responseCipher *encryption.Cipher
+}
+
+func NewPortalHandler(s *store.Store, responseCipher *encryption.Cipher) *PortalHandler {
+	return &PortalHandler{store: s, responseCipher: responseCipher}
+}
+
+// portalAction is what a consumer sees of their action; scripts, projections
+// and secrets stay hidden.
+type portalAction struct {
+	ID         uuid.UUID        `json:"id"`
+	Type       model.ActionType `json:"type"`
+	TargetURL  *string          `json:"target_url,omitempty"`
+	IsActive   bool             `json:"is_active"`
+	EventTypes []string         `json:"event_types,omitempty"`
+	VerifiedAt *time.Time       `json:"verified_at,omitempty"`
+}
+
+type portalDelivery struct {
+	ID         uuid.UUID            `json:"id"`
+	Status     model.DeliveryStatus `json:"status"`
+	EventType  *string              `json:"event_type,omitempty"`
+	ReceivedAt time.Time            `json:"received_at"`
+	// Payload is the delivery as the action receives it (after the source
+	// transform and the action's projection).
+	Payload  json.RawMessage         `json:"payload,omitempty"`
+	Attempts []model.DeliveryAttempt `json:"attempts,omitempty"`
+}
+
+// portalSuppressed is a request suppressed as a duplicate of one of the
+// action's deliveries.
+type portalSuppressed struct {
+	ID           uuid.UUID `json:"id"`
+	DuplicateOf  uuid.UUID `json:"duplicate_of"`
+	Reason       string    `json:"reason"`
+	EventType    *string   `json:"event_type,omitempty"`
+	SuppressedAt time.Time `json:"suppressed_at"`
+}
+
+// Authenticate resolves the bearer portal token to its action, rejecting the
+// request otherwise.
+func (h *PortalHandler) Authenticate(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.String(http.StatusUnauthorized, "portal token required")
+		c.Abort()
+		return
+	}
+	action, err := h.store.Actions.GetByPortalToken(c.Request.Context(), token)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.ErrorContext(c.Request.Context(), "failed to look up portal token", "error", err)
+		}
+		c.String(http.StatusUnauthorized, "invalid portal token")
+		c.Abort()
+		return
+	}
+	c.Set(portalActionKey, action)
+	c.Next()
+}
+
+func portalActionFrom(c *gin.Context) *model.Action {
+	return c.MustGet(portalActionKey).(*model.Action)
+}
+
+func (h *PortalHandler) GetAction(c *gin.Context) {
+	a := portalActionFrom(c)
+	c.JSON(http.StatusOK, portalAction{
+		ID:         a.ID,
+		Type:       a.Type,
+		TargetURL:  a.TargetURL,
+		IsActive:   a.IsActive,
+		EventTypes: a.EventTypes,
+		VerifiedAt: a.VerifiedAt,
+	})
+}
+
+func (h *PortalHandler) ListDeliveries(c *gin.Context) {
+	action := portalActionFrom(c)
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	deliveries, err := h.store.Deliveries.ListByAction(c.Request.Context(), action.ID, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list portal deliveries", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list deliveries")
+		return
+	}
+
+	out := make([]portalDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		out = append(out, portalDelivery{ID: d.ID, Status: d.Status, EventType: d.EventType, ReceivedAt: d.ReceivedAt})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ListSuppressed returns requests that weren't delivered to the action
+// because they duplicated a delivery it was already attempted for.
+func (h *PortalHandler) ListSuppressed(c *gin.Context) {
+	action := portalActionFrom(c)
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	suppressed, err := h.store.Suppressed.ListByAction(c.Request.Context(), action.ID, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list portal suppressed deliveries", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list suppressed deliveries")
+		return
+	}
+
+	out := make([]portalSuppressed, 0, len(suppressed))
+	for _, sd := range suppressed {
+		out = append(out, portalSuppressed{ID: sd.ID, DuplicateOf: sd.DuplicateOf, Reason: sd.Reason, EventType: sd.EventType, SuppressedAt: sd.SuppressedAt})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetDelivery returns one of the action's deliveries with the action's own
+// attempts. Deliveries the action was never attempted for are not found.
+func (h *PortalHandler) GetDelivery(c *gin.Context) {
+	action := portalActionFrom(c)
+	delivery, attempts, ok := h.delivery(c, action)
+	if !ok {
+		return
+	}
+
+	payload := delivery.Payload
+	if len(delivery.TransformedPayload) > 0 {
+		payload = delivery.TransformedPayload
+	}
+	// A payload the projection can't apply to was never sent; omit it
+	projected, _ := projection.Apply(payload, action.Projection)
+	redactResponseBodies(attempts)
+
+	c.JSON(http.StatusOK, portalDelivery{
+		ID:         delivery.ID,
+		Status:     delivery.Status,
+		EventType:  delivery.EventType,
+		ReceivedAt: delivery.ReceivedAt,
+		Payload:    projected,
+		Attempts:   attempts,
+	})
+}
+
+// ResponseBody returns what the action's endpoint answered to one attempt.
+func (h *PortalHandler) ResponseBody(c *gin.Context) {
+	action := portalActionFrom(c)
+	_, attempts, ok := h.delivery(c, action)
+	if !ok {
+		return
+	}
+	attemptID, err := uuid.Parse(c.Param("attemptId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid attempt id")
+		return
+	}
+	for i := range attempts {
+		if attempts[i].ID == attemptID {
+			writeResponseBody(c, h.responseCipher, &attempts[i])
+			return
+		}
+	}
+	c.String(http.StatusNotFound, "attempt not found")
+}
+
+// RetryDelivery sends a delivery to the action's endpoint again by making its
+// latest failed attempt due now; the worker's retry loop picks it up.
+func (h *PortalHandler) RetryDelivery(c *gin.Context) {
+	action := portalActionFrom(c)
+	delivery, _, ok := h.delivery(c, action)
+	if !ok {
+		return
+	}
+
+	retried, err := h.store.Deliveries.RetryNow(c.Request.Context(), delivery.ID, action.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to schedule portal retry", "error", err)
+		c.String(http.StatusInternalServerError, "failed to retry delivery")
+		return
+	}
+	if !retried {
+		c.String(http.StatusConflict, "latest attempt did not fail")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"delivery_id": delivery.ID, "status": "retry_scheduled"})
+}
+
+// RotateSigningSecret replaces the action's signing secret and returns the
+// new one. Requests are signed with it from the next attempt on.
+func (h *PortalHandler) RotateSigningSecret(c *gin.Context) {
+	action := portalActionFrom(c)
+	if action.Type != model.ActionTypeWebhook {
+		c.String(http.StatusBadRequest, "only webhook actions have a signing secret")
+		return
+	}
+
+	updated, err := h.store.Actions.RotateSigningSecret(c.Request.Context(), action.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to rotate signing secret", "error", err)
+		c.String(http.StatusInternalServerError, "failed to rotate signing secret")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"signing_secret": updated.SigningSecret})
+}
+
+// delivery loads the delivery named in the path with the action's attempts
+// for it, writing the error response itself when it returns false.
+func (h *PortalHandler) delivery(c *gin.Context, action *model.Action) (*model.Delivery, []model.DeliveryAttempt, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return nil, nil, false
+	}
+
+	ctx := c.Request.Context()
+	delivery, err := h.store.Deliveries.GetByID(ctx, id)
+	if err != nil || delivery.SourceID != action.SourceID {
+		c.String(http.StatusNotFound, "delivery not found")
+		return nil, nil, false
+	}
+	attempts, err := h.store.Deliveries.ListAttemptsByDelivery(ctx, id, store.AttemptFilter{ActionID: &action.ID})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list portal attempts", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get delivery")
+		return nil, nil, false
+	}
+	if len(attempts) == 0 {
+		c.String(http.StatusNotFound, "delivery not found")
+		return nil, nil, false
+	}
+	return delivery, attempts, true
+}
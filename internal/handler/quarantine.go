@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
+)
+
+// QuarantineHandler reviews quarantined deliveries: those with bad inbound
+// signatures on sources set to flag them, and those whose transform failed
+// under the quarantine policy.
+type QuarantineHandler struct {
+	store *store.Store
+	rdb   *redis.Client
+	trim  streamtrim.Policy
+}
+
+func NewQuarantineHandler(s *store.Store, rdb *redis.Client, trim streamtrim.Policy) *QuarantineHandler {
+	return &QuarantineHandler{store: s, rdb: rdb, trim: trim}
+}
+
+// List returns quarantined deliveries, newest first, optionally filtered by
+// ?source=.
+func (h *QuarantineHandler) List(c *gin.Context) {
+	var sourceSlug *string
+	if s := c.Query("source"); s != "" {
+		sourceSlug = &s
+	}
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	deliveries, err := h.store.Deliveries.ListQuarantined(c.Request.Context(), sourceSlug, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list quarantined deliveries", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list quarantined deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.Delivery{}
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+type approveQuarantinedRequest struct {
+	// SkipTransform dispatches the original payload without running
+	// transforms; otherwise they run again, as for any delivery.
+	SkipTransform bool `json:"skip_transform,omitempty"`
+}
+
+// Approve releases a quarantined delivery and queues it for fan-out.
+func (h *QuarantineHandler) Approve(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+	var req approveQuarantinedRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.store.Deliveries.GetByID(ctx, id); err != nil {
+		c.String(http.StatusNotFound, "delivery not found")
+		return
+	}
+	d, err := h.store.Deliveries.ReleaseQuarantined(ctx, id, req.SkipTransform)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusConflict, "delivery is not quarantined")
+			return
+		}
+		if errors.Is(err, store.ErrDuplicateKey) {
+			c.String(http.StatusConflict, "another delivery already has this idempotency key")
+			return
+		}
+		slog.ErrorContext(ctx, "failed to release quarantined delivery", "error", err)
+		c.String(http.StatusInternalServerError, "failed to approve delivery")
+		return
+	}
+
+	queued := true
+	if err := publishToStream(ctx, h.rdb, h.trim, d); err != nil {
+		// Pending in Postgres, so the catch-up poll still delivers it
+		slog.ErrorContext(ctx, "failed to publish approved delivery", "error", err, "delivery_id", id)
+		queued = false
+	}
+	slog.InfoContext(ctx, "approved quarantined delivery", "delivery_id", id, "skip_transform", req.SkipTransform)
+
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Refresh", "true")
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery_id": id, "status": d.Status, "queued": queued})
+}
+
+type discardQuarantinedRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// Discard cancels a quarantined delivery; it is never dispatched.
+func (h *QuarantineHandler) Discard(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+	var req discardQuarantinedRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "discarded from quarantine"
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.store.Deliveries.GetByID(ctx, id); err != nil {
+		c.String(http.StatusNotFound, "delivery not found")
+		return
+	}
+	discarded, err := h.store.Deliveries.DiscardQuarantined(ctx, id, req.Reason)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to discard quarantined delivery", "error", err)
+		c.String(http.StatusInternalServerError, "failed to discard delivery")
+		return
+	}
+	if !discarded {
+		c.String(http.StatusConflict, "delivery is not quarantined")
+		return
+	}
+
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Refresh", "true")
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery_id": id, "status": model.DeliveryCancelled})
+}
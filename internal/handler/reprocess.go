@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
+)
+
+// maxReprocessBatch caps how many deliveries one source reprocess resets.
+const maxReprocessBatch = 500
+
+// ReprocessHandler re-runs deliveries stopped by a failed transform through
+// the current scripts, in place rather than as replays.
+type ReprocessHandler struct {
+	store *store.Store
+	rdb   *redis.Client
+	trim  streamtrim.Policy
+}
+
+func NewReprocessHandler(s *store.Store, rdb *redis.Client, trim streamtrim.Policy) *ReprocessHandler {
+	return &ReprocessHandler{store: s, rdb: rdb, trim: trim}
+}
+
+// Delivery reprocesses one delivery whose transform failed.
+func (h *ReprocessHandler) Delivery(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.store.Deliveries.GetByID(ctx, id); err != nil {
+		c.String(http.StatusNotFound, "delivery not found")
+		return
+	}
+	d, err := h.store.Deliveries.Reprocess(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.String(http.StatusConflict, "delivery was not stopped by a failed transform")
+			return
+		}
+		slog.ErrorContext(ctx, "failed to reprocess delivery", "error", err)
+		c.String(http.StatusInternalServerError, "failed to reprocess delivery")
+		return
+	}
+
+	queued := true
+	if err := publishToStream(ctx, h.rdb, h.trim, d); err != nil {
+		// Pending in Postgres, so the catch-up poll still delivers it
+		slog.ErrorContext(ctx, "failed to publish reprocessed delivery", "error", err, "delivery_id", id)
+		queued = false
+	}
+	slog.InfoContext(ctx, "reprocessing delivery", "delivery_id", id, "reprocess_count", d.ReprocessCount)
+
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Refresh", "true")
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery_id": id, "status": d.Status, "reprocess_count": d.ReprocessCount, "queued": queued})
+}
+
+// Source reprocesses the source's deliveries whose transform failed, oldest
+// first, up to maxReprocessBatch per call; call again while reprocessed
+// equals the batch size.
+func (h *ReprocessHandler) Source(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	deliveries, err := h.store.Deliveries.ReprocessSource(ctx, src.ID, maxReprocessBatch)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to reprocess deliveries", "error", err, "source_id", src.ID)
+		c.String(http.StatusInternalServerError, "failed to reprocess deliveries")
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(deliveries))
+	unqueued := 0
+	for i := range deliveries {
+		ids = append(ids, deliveries[i].ID)
+		if err := publishToStream(ctx, h.rdb, h.trim, &deliveries[i]); err != nil {
+			slog.ErrorContext(ctx, "failed to publish reprocessed delivery", "error", err, "delivery_id", deliveries[i].ID)
+			unqueued++
+		}
+	}
+	slog.InfoContext(ctx, "reprocessing deliveries", "source_id", src.ID, "count", len(deliveries))
+
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Refresh", "true")
+	}
+	c.JSON(http.StatusOK, gin.H{"reprocessed": len(deliveries), "delivery_ids": ids, "unqueued": unqueued})
+}
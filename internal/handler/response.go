@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// redactResponseBodies drops response bodies from attempts being listed;
+// they are read one at a time through a response-body endpoint.
+func redactResponseBodies(attempts []model.DeliveryAttempt) {
+	for i := range attempts {
+		redactResponseBody(&attempts[i])
+	}
+}
+
+func redactResponseBody(a *model.DeliveryAttempt) {
+	a.HasResponseBody = a.ResponseBody != nil
+	a.ResponseBody = nil
+}
+
+// writeResponseBody decrypts and returns an attempt's response body.
+func writeResponseBody(c *gin.Context, cipher *encryption.Cipher, a *model.DeliveryAttempt) {
+	body := ""
+	if a.ResponseBody != nil {
+		var err error
+		if body, err = cipher.Open(*a.ResponseBody); err != nil {
+			if errors.Is(err, encryption.ErrNoKey) {
+				c.String(http.StatusServiceUnavailable, "response body encryption key is not configured")
+				return
+			}
+			slog.ErrorContext(c.Request.Context(), "failed to decrypt response body", "attempt_id", a.ID, "error", err)
+			c.String(http.StatusInternalServerError, "failed to decrypt response body")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"attempt_id":      a.ID,
+		"response_status": a.ResponseStatus,
+		"response_body":   body,
+	})
+}
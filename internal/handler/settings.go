@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zachbroad/nitrohook/internal/script"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+type SettingsHandler struct {
+	store *store.Store
+}
+
+func NewSettingsHandler(s *store.Store) *SettingsHandler {
+	return &SettingsHandler{store: s}
+}
+
+type globalTransformRequest struct {
+	ScriptBody string `json:"script_body"`
+}
+
+// GetGlobalTransform returns the transform run before every source's own.
+func (h *SettingsHandler) GetGlobalTransform(c *gin.Context) {
+	body, err := h.store.Settings.Get(c.Request.Context(), store.SettingGlobalTransform)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to get global transform", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get global transform")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"script_body": body})
+}
+
+func (h *SettingsHandler) SetGlobalTransform(c *gin.Context) {
+	var req globalTransformRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.ScriptBody) == "" {
+		c.String(http.StatusBadRequest, "script_body is required")
+		return
+	}
+	if err := script.Validate(req.ScriptBody); err != nil {
+		c.String(http.StatusBadRequest, "invalid script: "+err.Error())
+		return
+	}
+
+	if err := h.store.Settings.Set(c.Request.Context(), store.SettingGlobalTransform, req.ScriptBody); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to set global transform", "error", err)
+		c.String(http.StatusInternalServerError, "failed to set global transform")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"script_body": req.ScriptBody})
+}
+
+func (h *SettingsHandler) ClearGlobalTransform(c *gin.Context) {
+	if err := h.store.Settings.Delete(c.Request.Context(), store.SettingGlobalTransform); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to clear global transform", "error", err)
+		c.String(http.StatusInternalServerError, "failed to clear global transform")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,22 +1,39 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/zachbroad/nitrohook/internal/challenge"
+	"github.com/zachbroad/nitrohook/internal/fingerprint"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/projection"
+	"github.com/zachbroad/nitrohook/internal/proxy"
 	"github.com/zachbroad/nitrohook/internal/script"
+	"github.com/zachbroad/nitrohook/internal/scriptcheck"
+	"github.com/zachbroad/nitrohook/internal/signing"
 	"github.com/zachbroad/nitrohook/internal/store"
 )
 
 type SourceHandler struct {
-	store *store.Store
+	store  *store.Store
+	limits model.Limits
+	caps   model.Caps
+	urls   *proxy.PublicURL
 }
 
-func NewSourceHandler(s *store.Store) *SourceHandler {
-	return &SourceHandler{store: s}
+// NewSourceHandler creates a SourceHandler. limits are the global limits,
+// which also bound per-source overrides; caps bound how many sources exist.
+func NewSourceHandler(s *store.Store, limits model.Limits, caps model.Caps, urls *proxy.PublicURL) *SourceHandler {
+	return &SourceHandler{store: s, limits: limits, caps: caps, urls: urls}
 }
 
 type createSourceRequest struct {
@@ -24,12 +41,57 @@ type createSourceRequest struct {
 	Slug       string  `json:"slug,omitempty"`
 	Mode       string  `json:"mode,omitempty"`
 	ScriptBody *string `json:"script_body,omitempty"`
+	// RequireIngestToken generates an ingest token for the source.
+	RequireIngestToken bool `json:"require_ingest_token,omitempty"`
+	// ExternalID makes the request an upsert: an existing source with this
+	// external ID is updated instead.
+	ExternalID string `json:"external_id,omitempty"`
 }
 
 type updateSourceRequest struct {
 	Name       *string `json:"name,omitempty"`
 	Mode       *string `json:"mode,omitempty"`
 	ScriptBody *string `json:"script_body,omitempty"`
+	// AckMode is "accepted", "queued" or "delivered".
+	AckMode *string `json:"ack_mode,omitempty"`
+	// DeliveryDelaySeconds delays fan-out of each delivery; zero removes
+	// the delay.
+	DeliveryDelaySeconds *int `json:"delivery_delay_seconds,omitempty"`
+	// DedupWindowSeconds suppresses repeated payloads within the window;
+	// zero removes it.
+	DedupWindowSeconds *int `json:"dedup_window_seconds,omitempty"`
+	// MaxInFlight caps deliveries processed at once; zero removes the cap.
+	MaxInFlight *int `json:"max_in_flight,omitempty"`
+	// CoalesceKey and CoalesceWindowSeconds are set together; an empty key
+	// turns coalescing off.
+	CoalesceKey           *string `json:"coalesce_key,omitempty"`
+	CoalesceWindowSeconds *int    `json:"coalesce_window_seconds,omitempty"`
+	// IngestRateLimit caps requests accepted per minute; zero removes it.
+	IngestRateLimit *int `json:"ingest_rate_limit,omitempty"`
+	// TransformErrorPolicy is "fail_closed", "fail_open" or "quarantine".
+	TransformErrorPolicy *string `json:"transform_error_policy,omitempty"`
+	// RetrySignal answers repeats of failed deliveries with 503.
+	RetrySignal *bool `json:"retry_signal,omitempty"`
+}
+
+// maxDedupWindow bounds the content duplicate suppression window.
+const maxDedupWindow = 7 * 24 * time.Hour
+
+// maxCoalesceWindow bounds how long deliveries are held for coalescing.
+const maxCoalesceWindow = 24 * time.Hour
+
+// updateLimitsRequest overrides a source's limits. Omitted fields are left
+// unchanged; zero resets a limit to the global default.
+type updateLimitsRequest struct {
+	MaxPayloadBytes  *int `json:"max_payload_bytes,omitempty"`
+	MaxResponseBytes *int `json:"max_response_bytes,omitempty"`
+	ScriptTimeoutMs  *int `json:"script_timeout_ms,omitempty"`
+}
+
+type limitsResponse struct {
+	Effective model.Limits        `json:"effective"`
+	Overrides updateLimitsRequest `json:"overrides"`
+	Bounds    model.Limits        `json:"bounds"`
 }
 
 var nonAlphanumDash = regexp.MustCompile(`[^a-z0-9-]+`)
@@ -48,7 +110,48 @@ func validateMode(mode string) bool {
 	return mode == "record" || mode == "active"
 }
 
+func validAckMode(mode string) bool {
+	return mode == model.AckAccepted || mode == model.AckQueued || mode == model.AckDelivered
+}
+
+func validTransformErrorPolicy(policy string) bool {
+	return policy == model.TransformFailClosed || policy == model.TransformFailOpen || policy == model.TransformQuarantine
+}
+
+// validCoalesce checks a coalesce update, returning a message for the client
+// if it is invalid. An empty key clears coalescing and takes no window.
+func validCoalesce(key *string, seconds *int) string {
+	switch {
+	case key == nil && seconds == nil:
+		return ""
+	case key == nil:
+		return "coalesce_window_seconds requires coalesce_key"
+	case *key == "":
+		return ""
+	case !projection.ValidPath(*key):
+		return "coalesce_key must be a field path such as $.record.id"
+	case seconds == nil || *seconds <= 0 || time.Duration(*seconds)*time.Second > maxCoalesceWindow:
+		return fmt.Sprintf("coalesce_window_seconds must be between 1 and %d", int(maxCoalesceWindow.Seconds()))
+	}
+	return ""
+}
+
 func (h *SourceHandler) List(c *gin.Context) {
+	if externalID := c.Query("external_id"); externalID != "" {
+		src, err := h.store.Sources.GetByExternalID(c.Request.Context(), externalID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.Data(http.StatusOK, "application/json", []byte("[]"))
+			return
+		}
+		if err != nil {
+			c.String(http.StatusInternalServerError, "failed to list sources")
+			return
+		}
+		h.setWebhookURLs(c, src)
+		c.JSON(http.StatusOK, []*model.Source{src})
+		return
+	}
+
 	sources, err := h.store.Sources.List(c.Request.Context())
 	if err != nil {
 		slog.Error("failed to list sources", "error", err)
@@ -60,6 +163,9 @@ func (h *SourceHandler) List(c *gin.Context) {
 		c.Data(http.StatusOK, "application/json", []byte("[]"))
 		return
 	}
+	for i := range sources {
+		h.setWebhookURLs(c, &sources[i])
+	}
 	c.JSON(http.StatusOK, sources)
 }
 
@@ -102,7 +208,33 @@ func (h *SourceHandler) Create(c *gin.Context) {
 		}
 	}
 
-	src, err := h.store.Sources.Create(c.Request.Context(), req.Name, slug, mode, req.ScriptBody)
+	if req.ExternalID != "" {
+		_, err := h.store.Sources.GetByExternalID(c.Request.Context(), req.ExternalID)
+		if errors.Is(err, pgx.ErrNoRows) && !allowNewSource(c, h.store, h.caps) {
+			return
+		}
+		src, created, err := h.store.Sources.UpsertByExternalID(c.Request.Context(), req.ExternalID, req.Name, slug, mode, req.ScriptBody, req.RequireIngestToken)
+		if err != nil {
+			if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
+				c.String(http.StatusConflict, "source with this slug already exists")
+				return
+			}
+			c.String(http.StatusInternalServerError, "failed to upsert source")
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		h.setWebhookURLs(c, src)
+		c.JSON(status, src)
+		return
+	}
+
+	if !allowNewSource(c, h.store, h.caps) {
+		return
+	}
+	src, err := h.store.Sources.Create(c.Request.Context(), req.Name, slug, mode, req.ScriptBody, req.RequireIngestToken)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
 			c.String(http.StatusConflict, "source with this slug already exists")
@@ -112,6 +244,7 @@ func (h *SourceHandler) Create(c *gin.Context) {
 		return
 	}
 
+	h.setWebhookURLs(c, src)
 	c.JSON(http.StatusCreated, src)
 }
 
@@ -124,6 +257,7 @@ func (h *SourceHandler) Get(c *gin.Context) {
 		return
 	}
 
+	h.setWebhookURLs(c, src)
 	c.JSON(http.StatusOK, src)
 }
 
@@ -140,6 +274,34 @@ func (h *SourceHandler) Update(c *gin.Context) {
 		c.String(http.StatusBadRequest, "mode must be 'record' or 'active'")
 		return
 	}
+	if req.AckMode != nil && !validAckMode(*req.AckMode) {
+		c.String(http.StatusBadRequest, "ack_mode must be 'accepted', 'queued' or 'delivered'")
+		return
+	}
+	if req.TransformErrorPolicy != nil && !validTransformErrorPolicy(*req.TransformErrorPolicy) {
+		c.String(http.StatusBadRequest, "transform_error_policy must be 'fail_closed', 'fail_open' or 'quarantine'")
+		return
+	}
+	if d := req.DeliveryDelaySeconds; d != nil && (*d < 0 || time.Duration(*d)*time.Second > maxDeliveryDelay) {
+		c.String(http.StatusBadRequest, fmt.Sprintf("delivery_delay_seconds must be between 0 and %d", int(maxDeliveryDelay.Seconds())))
+		return
+	}
+	if d := req.DedupWindowSeconds; d != nil && (*d < 0 || time.Duration(*d)*time.Second > maxDedupWindow) {
+		c.String(http.StatusBadRequest, fmt.Sprintf("dedup_window_seconds must be between 0 and %d", int(maxDedupWindow.Seconds())))
+		return
+	}
+	if req.MaxInFlight != nil && *req.MaxInFlight < 0 {
+		c.String(http.StatusBadRequest, "max_in_flight must not be negative")
+		return
+	}
+	if req.IngestRateLimit != nil && *req.IngestRateLimit < 0 {
+		c.String(http.StatusBadRequest, "ingest_rate_limit must not be negative")
+		return
+	}
+	if msg := validCoalesce(req.CoalesceKey, req.CoalesceWindowSeconds); msg != "" {
+		c.String(http.StatusBadRequest, msg)
+		return
+	}
 
 	// Validate script if provided and non-empty
 	if req.ScriptBody != nil && *req.ScriptBody != "" {
@@ -161,10 +323,91 @@ func (h *SourceHandler) Update(c *gin.Context) {
 		c.String(http.StatusInternalServerError, "failed to update source")
 		return
 	}
+	if req.AckMode != nil {
+		if src, err = h.store.Sources.SetAckMode(c.Request.Context(), slug, *req.AckMode); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
+	if req.TransformErrorPolicy != nil {
+		if src, err = h.store.Sources.SetTransformErrorPolicy(c.Request.Context(), slug, *req.TransformErrorPolicy); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
+	if req.RetrySignal != nil {
+		if src, err = h.store.Sources.SetRetrySignal(c.Request.Context(), slug, *req.RetrySignal); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
+	if d := req.DeliveryDelaySeconds; d != nil {
+		if *d == 0 {
+			d = nil
+		}
+		if src, err = h.store.Sources.SetDeliveryDelay(c.Request.Context(), slug, d); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
+	if d := req.DedupWindowSeconds; d != nil {
+		if *d == 0 {
+			d = nil
+		}
+		if src, err = h.store.Sources.SetDedupWindow(c.Request.Context(), slug, d); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
+	if n := req.MaxInFlight; n != nil {
+		if *n == 0 {
+			n = nil
+		}
+		if src, err = h.store.Sources.SetMaxInFlight(c.Request.Context(), slug, n); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
+	if n := req.IngestRateLimit; n != nil {
+		if *n == 0 {
+			n = nil
+		}
+		if src, err = h.store.Sources.SetIngestRateLimit(c.Request.Context(), slug, n); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
+	if key := req.CoalesceKey; key != nil {
+		d := req.CoalesceWindowSeconds
+		if *key == "" {
+			key, d = nil, nil
+		}
+		if src, err = h.store.Sources.SetCoalesce(c.Request.Context(), slug, key, d); err != nil {
+			c.String(http.StatusInternalServerError, "failed to update source")
+			return
+		}
+	}
 
+	h.setWebhookURLs(c, src)
+	if req.ScriptBody != nil && *req.ScriptBody != "" {
+		report, err := scriptcheck.ForSource(c.Request.Context(), h.store, src, *req.ScriptBody, h.limits)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to check script", "error", err)
+		}
+		c.JSON(http.StatusOK, checkedSource{Source: src, ScriptCheck: report})
+		return
+	}
 	c.JSON(http.StatusOK, src)
 }
 
+// checkedSource is a source saved with a new transform, along with the
+// report of running it against payloads generated from recent traffic; the
+// report is null if the check couldn't run.
+type checkedSource struct {
+	*model.Source
+	ScriptCheck *scriptcheck.Report `json:"script_check"`
+}
+
 func (h *SourceHandler) Delete(c *gin.Context) {
 	slug := c.Param("sourceSlug")
 
@@ -179,3 +422,359 @@ func (h *SourceHandler) Delete(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// GetLimits returns the limits applied to the source's deliveries along with
+// any overrides and the global bounds.
+func (h *SourceHandler) GetLimits(c *gin.Context) {
+	src, err := h.store.Sources.GetBySlug(c.Request.Context(), c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+	c.JSON(http.StatusOK, h.limitsFor(src))
+}
+
+// UpdateLimits sets per-source limit overrides within the global bounds.
+func (h *SourceHandler) UpdateLimits(c *gin.Context) {
+	var req updateLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	checks := []struct {
+		name  string
+		value *int
+		bound int
+	}{
+		{"max_payload_bytes", req.MaxPayloadBytes, h.limits.MaxPayloadBytes},
+		{"max_response_bytes", req.MaxResponseBytes, h.limits.MaxResponseBytes},
+		{"script_timeout_ms", req.ScriptTimeoutMs, h.limits.ScriptTimeoutMs},
+	}
+	for _, chk := range checks {
+		if chk.value != nil && (*chk.value < 0 || *chk.value > chk.bound) {
+			c.String(http.StatusBadRequest, fmt.Sprintf("%s must be between 0 and %d", chk.name, chk.bound))
+			return
+		}
+	}
+
+	src, err := h.store.Sources.SetLimits(c.Request.Context(), c.Param("sourceSlug"), req.MaxPayloadBytes, req.MaxResponseBytes, req.ScriptTimeoutMs)
+	if err != nil {
+		if strings.Contains(err.Error(), "source not found") {
+			c.String(http.StatusNotFound, "source not found")
+			return
+		}
+		slog.Error("failed to update source limits", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update source limits")
+		return
+	}
+	c.JSON(http.StatusOK, h.limitsFor(src))
+}
+
+func (h *SourceHandler) limitsFor(src *model.Source) limitsResponse {
+	return limitsResponse{
+		Effective: model.EffectiveLimits(h.limits, src),
+		Overrides: updateLimitsRequest{
+			MaxPayloadBytes:  src.MaxPayloadBytes,
+			MaxResponseBytes: src.MaxResponseBytes,
+			ScriptTimeoutMs:  src.ScriptTimeoutMs,
+		},
+		Bounds: h.limits,
+	}
+}
+
+const (
+	defaultScriptStatsWindow = 24 * time.Hour
+	maxScriptStatsWindow     = 7 * 24 * time.Hour
+)
+
+// Bounds on the activity window, in days.
+const (
+	defaultActivityDays = 30
+	maxActivityDays     = 90
+)
+
+// Activity returns hourly ingest counts for the last ?days=N days (default
+// 30), one entry per hour including empty ones, for the activity heatmap.
+func (h *SourceHandler) Activity(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	days := defaultActivityDays
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxActivityDays {
+			c.String(http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxActivityDays))
+			return
+		}
+		days = n
+	}
+
+	activity, err := h.store.Sources.Activity(ctx, src.ID, days*24)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get source activity", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get source activity")
+		return
+	}
+	if activity == nil {
+		activity = []model.HourlyActivity{}
+	}
+	c.JSON(http.StatusOK, activity)
+}
+
+// SuggestProvider reports which provider the source's recent deliveries look
+// like they come from and the matching verification preset, to help pick
+// one when setting up the inbound signature.
+func (h *SourceHandler) SuggestProvider(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	provider, matches, sampled, err := h.store.Deliveries.DetectedProvider(ctx, src.ID, fingerprint.Sample)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to detect provider", "error", err)
+		c.String(http.StatusInternalServerError, "failed to detect provider")
+		return
+	}
+	c.JSON(http.StatusOK, fingerprint.Suggest(src, provider, matches, sampled))
+}
+
+// ListSuppressed returns requests suppressed as duplicates of the source's
+// deliveries, newest first.
+func (h *SourceHandler) ListSuppressed(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+
+	suppressed, err := h.store.Suppressed.ListBySource(ctx, src.ID, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list suppressed deliveries", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list suppressed deliveries")
+		return
+	}
+	if suppressed == nil {
+		suppressed = []model.SuppressedDelivery{}
+	}
+	c.JSON(http.StatusOK, suppressed)
+}
+
+// ScriptStats reports execution times and timeouts for the source's
+// transforms and JS actions over a recent window (default 24h), flagging
+// scripts whose p95 is close to the source's script timeout.
+func (h *SourceHandler) ScriptStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	window := defaultScriptStatsWindow
+	if v := c.Query("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxScriptStatsWindow {
+			c.String(http.StatusBadRequest, fmt.Sprintf("window must be a duration up to %s", maxScriptStatsWindow))
+			return
+		}
+		window = d
+	}
+
+	stats, err := h.store.ScriptRuns.StatsBySource(ctx, src.ID, time.Now().Add(-window))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get script stats", "error", err)
+		c.String(http.StatusInternalServerError, "failed to get script stats")
+		return
+	}
+	if stats == nil {
+		stats = []model.ScriptStats{}
+	}
+
+	timeoutMs := model.EffectiveLimits(h.limits, src).ScriptTimeoutMs
+	for i := range stats {
+		stats[i].TimeoutMs = timeoutMs
+		stats[i].Slow = stats[i].P95Ms >= model.SlowScriptRatio*float64(timeoutMs)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"window":  window.String(),
+		"scripts": stats,
+	})
+}
+
+// RotateIngestToken generates a new ingest token for the source. The old
+// token stops working immediately.
+func (h *SourceHandler) RotateIngestToken(c *gin.Context) {
+	src, err := h.store.Sources.RotateIngestToken(c.Request.Context(), c.Param("sourceSlug"))
+	if err != nil {
+		if strings.Contains(err.Error(), "source not found") {
+			c.String(http.StatusNotFound, "source not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to rotate ingest token", "error", err)
+		c.String(http.StatusInternalServerError, "failed to rotate ingest token")
+		return
+	}
+	h.setWebhookURLs(c, src)
+	c.JSON(http.StatusOK, src)
+}
+
+// ClearIngestToken stops requiring an ingest token for the source.
+func (h *SourceHandler) ClearIngestToken(c *gin.Context) {
+	src, err := h.store.Sources.ClearIngestToken(c.Request.Context(), c.Param("sourceSlug"))
+	if err != nil {
+		if strings.Contains(err.Error(), "source not found") {
+			c.String(http.StatusNotFound, "source not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to clear ingest token", "error", err)
+		c.String(http.StatusInternalServerError, "failed to clear ingest token")
+		return
+	}
+	h.setWebhookURLs(c, src)
+	c.JSON(http.StatusOK, src)
+}
+
+type inboundSignatureRequest struct {
+	Provider *string `json:"provider,omitempty"`
+	Scheme   string  `json:"scheme"`
+	Header   *string `json:"header,omitempty"`
+	Secret   string  `json:"secret"`
+	// OnFailure is "reject" (default) or "flag", which quarantines
+	// deliveries with bad signatures instead.
+	OnFailure string `json:"on_failure,omitempty"`
+}
+
+// SetInboundSignature enables verification of incoming webhook signatures.
+func (h *SourceHandler) SetInboundSignature(c *gin.Context) {
+	var req inboundSignatureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Provider != nil && !signing.ValidProvider(*req.Provider) {
+		c.String(http.StatusBadRequest, "provider must be one of stripe, github, shopify, slack")
+		return
+	}
+	if req.Provider != nil {
+		// The preset decides the scheme and header
+		req.Scheme, req.Header = "", nil
+	}
+	if req.Scheme == "" && req.Provider == nil {
+		req.Scheme = signing.SchemeHMACSHA256
+	}
+	if req.Scheme != "" && !signing.ValidScheme(req.Scheme) {
+		c.String(http.StatusBadRequest, "scheme must be one of hmac-sha256, hmac-sha256-base64, hmac-sha1")
+		return
+	}
+	if req.Secret == "" {
+		c.String(http.StatusBadRequest, "secret is required")
+		return
+	}
+	if req.Header != nil && *req.Header == "" {
+		req.Header = nil
+	}
+	if req.OnFailure == "" {
+		req.OnFailure = model.SignatureReject
+	}
+	if req.OnFailure != model.SignatureReject && req.OnFailure != model.SignatureFlag {
+		c.String(http.StatusBadRequest, "on_failure must be 'reject' or 'flag'")
+		return
+	}
+
+	var scheme *string
+	if req.Scheme != "" {
+		scheme = &req.Scheme
+	}
+	h.setInboundSignature(c, req.Provider, scheme, req.Header, &req.Secret, req.OnFailure)
+}
+
+// ClearInboundSignature disables inbound signature verification.
+func (h *SourceHandler) ClearInboundSignature(c *gin.Context) {
+	h.setInboundSignature(c, nil, nil, nil, nil, model.SignatureReject)
+}
+
+func (h *SourceHandler) setInboundSignature(c *gin.Context, provider, scheme, header, secret *string, failure string) {
+	src, err := h.store.Sources.SetInboundSignature(c.Request.Context(), c.Param("sourceSlug"), provider, scheme, header, secret, failure)
+	if err != nil {
+		if strings.Contains(err.Error(), "source not found") {
+			c.String(http.StatusNotFound, "source not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to set inbound signature", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update source")
+		return
+	}
+	h.setWebhookURLs(c, src)
+	c.JSON(http.StatusOK, src)
+}
+
+type challengeRequest struct {
+	Mode   string `json:"mode"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// SetChallenge selects how GET verification challenges to the source's ingest
+// URL are answered. The hub and crc presets need the provider's secret.
+func (h *SourceHandler) SetChallenge(c *gin.Context) {
+	var req challengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !challenge.ValidMode(req.Mode) {
+		c.String(http.StatusBadRequest, "mode must be one of echo, hub, crc")
+		return
+	}
+	var secret *string
+	if challenge.NeedsSecret(req.Mode) {
+		if req.Secret == "" {
+			c.String(http.StatusBadRequest, "secret is required for mode "+req.Mode)
+			return
+		}
+		secret = &req.Secret
+	}
+	h.setChallenge(c, &req.Mode, secret)
+}
+
+// ClearChallenge turns challenge handling off; GETs are refused again.
+func (h *SourceHandler) ClearChallenge(c *gin.Context) {
+	h.setChallenge(c, nil, nil)
+}
+
+func (h *SourceHandler) setChallenge(c *gin.Context, mode, secret *string) {
+	src, err := h.store.Sources.SetChallenge(c.Request.Context(), c.Param("sourceSlug"), mode, secret)
+	if err != nil {
+		if strings.Contains(err.Error(), "source not found") {
+			c.String(http.StatusNotFound, "source not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to set challenge", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update source")
+		return
+	}
+	h.setWebhookURLs(c, src)
+	c.JSON(http.StatusOK, src)
+}
+
+// setWebhookURLs fills in the public ingest URL of each source.
+func (h *SourceHandler) setWebhookURLs(c *gin.Context, sources ...*model.Source) {
+	for _, src := range sources {
+		src.WebhookURL = h.urls.Webhook(c.Request, src.Slug)
+	}
+}
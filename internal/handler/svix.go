@@ -0,0 +1,500 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/eventtype"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+// Svix-compatible shim: a subset of the Svix REST API (/api/v1) mapped onto
+// this relay so Svix SDKs and tooling can drive it. Applications are sources,
+// endpoints are webhook actions and messages are deliveries. Svix-only
+// features (event type filters, channels, rate limits, metadata) are accepted
+// where harmless and otherwise ignored.
+
+const (
+	defaultSvixLimit = 50
+	maxSvixLimit     = 250
+)
+
+type SvixHandler struct {
+	store               *store.Store
+	webhooks            *WebhookHandler
+	requireVerification bool
+	guard               *ssrf.Guard
+	caps                model.Caps
+}
+
+// NewSvixHandler creates a SvixHandler. Messages are ingested through
+// webhooks so they follow the same path as real webhook requests.
+func NewSvixHandler(s *store.Store, webhooks *WebhookHandler, requireVerification bool, guard *ssrf.Guard, caps model.Caps) *SvixHandler {
+	return &SvixHandler{store: s, webhooks: webhooks, requireVerification: requireVerification, guard: guard, caps: caps}
+}
+
+type svixListResponse[T any] struct {
+	Data     []T     `json:"data"`
+	Iterator *string `json:"iterator"`
+	Done     bool    `json:"done"`
+}
+
+type svixApplicationIn struct {
+	Name string  `json:"name"`
+	UID  *string `json:"uid,omitempty"`
+}
+
+type svixApplicationOut struct {
+	ID        string    `json:"id"`
+	UID       string    `json:"uid"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Metadata  struct{}  `json:"metadata"`
+}
+
+type svixEndpointIn struct {
+	URL         string  `json:"url"`
+	Description string  `json:"description,omitempty"`
+	Disabled    *bool   `json:"disabled,omitempty"`
+	Secret      *string `json:"secret,omitempty"`
+}
+
+type svixEndpointOut struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Disabled    bool      `json:"disabled"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Metadata    struct{}  `json:"metadata"`
+}
+
+type svixMessageIn struct {
+	EventType string          `json:"eventType"`
+	EventID   *string         `json:"eventId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type svixMessageOut struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"eventType"`
+	EventID   *string         `json:"eventId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+func toSvixApplication(src *model.Source) svixApplicationOut {
+	return svixApplicationOut{
+		ID:        src.ID.String(),
+		UID:       src.Slug,
+		Name:      src.Name,
+		CreatedAt: src.CreatedAt,
+		UpdatedAt: src.UpdatedAt,
+	}
+}
+
+func toSvixEndpoint(a *model.Action) svixEndpointOut {
+	out := svixEndpointOut{
+		ID:        a.ID.String(),
+		Disabled:  !a.IsActive,
+		Version:   1,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+	if a.TargetURL != nil {
+		out.URL = *a.TargetURL
+	}
+	return out
+}
+
+func toSvixMessage(d *model.Delivery) svixMessageOut {
+	var headers map[string]string
+	_ = json.Unmarshal(d.Headers, &headers)
+	out := svixMessageOut{
+		ID:        d.ID.String(),
+		EventType: headers[eventtype.Header],
+		Payload:   d.Payload,
+		Timestamp: d.ReceivedAt,
+	}
+	// Generated idempotency keys are UUIDs; only caller-supplied ones are
+	// Svix event IDs.
+	if _, err := uuid.Parse(d.IdempotencyKey); err != nil {
+		key := d.IdempotencyKey
+		out.EventID = &key
+	}
+	return out
+}
+
+func svixLimit(c *gin.Context) (int, bool) {
+	limit := defaultSvixLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSvixLimit {
+			c.String(http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxSvixLimit))
+			return 0, false
+		}
+		limit = n
+	}
+	return limit, true
+}
+
+// application resolves the :appId parameter, which may be the source's ID or
+// its slug (the Svix uid).
+func (h *SvixHandler) application(c *gin.Context) (*model.Source, bool) {
+	appID := c.Param("appId")
+	var src *model.Source
+	var err error
+	if id, perr := uuid.Parse(appID); perr == nil {
+		src, err = h.store.Sources.GetByID(c.Request.Context(), id)
+	} else {
+		src, err = h.store.Sources.GetBySlug(c.Request.Context(), appID)
+	}
+	if err != nil {
+		c.String(http.StatusNotFound, "application not found")
+		return nil, false
+	}
+	return src, true
+}
+
+// endpoint resolves :endpointId to a webhook action of src.
+func (h *SvixHandler) endpoint(c *gin.Context, src *model.Source) (*model.Action, bool) {
+	id, err := uuid.Parse(c.Param("endpointId"))
+	if err != nil {
+		c.String(http.StatusNotFound, "endpoint not found")
+		return nil, false
+	}
+	action, err := h.store.Actions.GetByID(c.Request.Context(), id)
+	if err != nil || action.SourceID != src.ID || action.Type != model.ActionTypeWebhook {
+		c.String(http.StatusNotFound, "endpoint not found")
+		return nil, false
+	}
+	return action, true
+}
+
+func (h *SvixHandler) ListApplications(c *gin.Context) {
+	limit, ok := svixLimit(c)
+	if !ok {
+		return
+	}
+	sources, err := h.store.Sources.List(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list sources", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list applications")
+		return
+	}
+
+	resp := svixListResponse[svixApplicationOut]{Data: []svixApplicationOut{}, Done: len(sources) <= limit}
+	for i := range sources[:min(limit, len(sources))] {
+		resp.Data = append(resp.Data, toSvixApplication(&sources[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateApplication creates a source in active mode, as Svix applications
+// deliver immediately. The uid, if given, becomes the slug.
+func (h *SvixHandler) CreateApplication(c *gin.Context) {
+	var req svixApplicationIn
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		c.String(http.StatusBadRequest, "name is required")
+		return
+	}
+
+	slug := generateSlug(req.Name)
+	if req.UID != nil && *req.UID != "" {
+		slug = *req.UID
+	}
+	if slug == "" {
+		c.String(http.StatusBadRequest, "could not generate uid from name")
+		return
+	}
+
+	if !allowNewSource(c, h.store, h.caps) {
+		return
+	}
+	src, err := h.store.Sources.Create(c.Request.Context(), req.Name, slug, "active", nil, false)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
+			c.String(http.StatusConflict, "application with this uid already exists")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "failed to create source", "error", err)
+		c.String(http.StatusInternalServerError, "failed to create application")
+		return
+	}
+	c.JSON(http.StatusCreated, toSvixApplication(src))
+}
+
+func (h *SvixHandler) GetApplication(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toSvixApplication(src))
+}
+
+func (h *SvixHandler) DeleteApplication(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	if err := h.store.Sources.Delete(c.Request.Context(), src.Slug); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to delete source", "error", err)
+		c.String(http.StatusInternalServerError, "failed to delete application")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListEndpoints lists the application's webhook actions; other action types
+// have no Svix equivalent and are left out.
+func (h *SvixHandler) ListEndpoints(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	limit, ok := svixLimit(c)
+	if !ok {
+		return
+	}
+	actions, err := h.store.Actions.List(c.Request.Context(), src.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list actions", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list endpoints")
+		return
+	}
+
+	resp := svixListResponse[svixEndpointOut]{Data: []svixEndpointOut{}, Done: true}
+	for i := range actions {
+		if actions[i].Type != model.ActionTypeWebhook {
+			continue
+		}
+		if len(resp.Data) == limit {
+			resp.Done = false
+			break
+		}
+		resp.Data = append(resp.Data, toSvixEndpoint(&actions[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *SvixHandler) CreateEndpoint(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	var req svixEndpointIn
+	if err := c.ShouldBindJSON(&req); err != nil || req.URL == "" {
+		c.String(http.StatusBadRequest, "url is required")
+		return
+	}
+	if err := h.guard.CheckURL(c.Request.Context(), req.URL); err != nil {
+		c.String(http.StatusBadRequest, "invalid url: "+err.Error())
+		return
+	}
+
+	active := req.Disabled == nil || !*req.Disabled
+	// Unverified webhook targets start inactive until ownership is proven
+	if h.requireVerification {
+		active = false
+	}
+	if !allowNewAction(c, h.store, h.caps, src.ID) {
+		return
+	}
+	action, err := h.store.Actions.Create(c.Request.Context(), src.ID, model.ActionTypeWebhook, store.ActionFields{
+		TargetURL:     &req.URL,
+		SigningSecret: req.Secret,
+		IsActive:      &active,
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to create action", "error", err)
+		c.String(http.StatusInternalServerError, "failed to create endpoint")
+		return
+	}
+	out := toSvixEndpoint(action)
+	out.Description = req.Description
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *SvixHandler) GetEndpoint(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	action, ok := h.endpoint(c, src)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toSvixEndpoint(action))
+}
+
+func (h *SvixHandler) UpdateEndpoint(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	action, ok := h.endpoint(c, src)
+	if !ok {
+		return
+	}
+	var req svixEndpointIn
+	if err := c.ShouldBindJSON(&req); err != nil || req.URL == "" {
+		c.String(http.StatusBadRequest, "url is required")
+		return
+	}
+	if err := h.guard.CheckURL(c.Request.Context(), req.URL); err != nil {
+		c.String(http.StatusBadRequest, "invalid url: "+err.Error())
+		return
+	}
+
+	var active *bool
+	if req.Disabled != nil {
+		a := !*req.Disabled
+		active = &a
+	}
+	if h.requireVerification {
+		targetChanged := action.TargetURL == nil || *action.TargetURL != req.URL
+		if active != nil && *active && (targetChanged || action.VerifiedAt == nil) {
+			c.String(http.StatusConflict, "target URL must be verified before the endpoint can be enabled")
+			return
+		}
+		// A new target needs a fresh verification, so disable until then
+		if targetChanged {
+			inactive := false
+			active = &inactive
+		}
+	}
+
+	updated, err := h.store.Actions.Update(c.Request.Context(), action.ID, store.ActionFields{
+		TargetURL: &req.URL,
+		IsActive:  active,
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to update action", "error", err)
+		c.String(http.StatusInternalServerError, "failed to update endpoint")
+		return
+	}
+	c.JSON(http.StatusOK, toSvixEndpoint(updated))
+}
+
+func (h *SvixHandler) DeleteEndpoint(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	action, ok := h.endpoint(c, src)
+	if !ok {
+		return
+	}
+	if err := h.store.Actions.Delete(c.Request.Context(), action.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to delete action", "error", err)
+		c.String(http.StatusInternalServerError, "failed to delete endpoint")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SvixHandler) ListMessages(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	limit, ok := svixLimit(c)
+	if !ok {
+		return
+	}
+	// Fetch one extra row to tell whether there are more
+	deliveries, err := h.store.Deliveries.List(c.Request.Context(), &src.Slug, limit+1)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list deliveries", "error", err)
+		c.String(http.StatusInternalServerError, "failed to list messages")
+		return
+	}
+
+	resp := svixListResponse[svixMessageOut]{Data: []svixMessageOut{}, Done: len(deliveries) <= limit}
+	for i := range deliveries[:min(limit, len(deliveries))] {
+		resp.Data = append(resp.Data, toSvixMessage(&deliveries[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateMessage ingests a message as a delivery. The eventId, if given, is
+// the idempotency key; the event type travels as the X-Event-Type header.
+func (h *SvixHandler) CreateMessage(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	// Svix's message shape has no room for a warning; the headers carry it
+	if src.IngestRateLimit != nil && !h.webhooks.checkIngestRate(c, src) {
+		return
+	}
+	var req svixMessageIn
+	if err := c.ShouldBindJSON(&req); err != nil || req.EventType == "" || len(req.Payload) == 0 {
+		c.String(http.StatusBadRequest, "eventType and payload are required")
+		return
+	}
+
+	var idempotencyKey string
+	if req.EventID != nil {
+		idempotencyKey = *req.EventID
+	}
+	headersJSON, _ := json.Marshal(map[string]string{
+		"Content-Type":   "application/json",
+		eventtype.Header: req.EventType,
+	})
+
+	ctx := c.Request.Context()
+	res, err := h.webhooks.enqueue(ctx, src, store.NewDelivery{
+		IdempotencyKey: idempotencyKey,
+		Headers:        headersJSON,
+		Payload:        req.Payload,
+		Method:         c.Request.Method,
+		RemoteAddr:     c.ClientIP(),
+		EventType:      req.EventType,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create delivery", "error", err)
+		c.String(http.StatusInternalServerError, "failed to create message")
+		return
+	}
+
+	status := http.StatusAccepted
+	if res.Duplicate {
+		status = http.StatusConflict
+	}
+	c.JSON(status, svixMessageOut{
+		ID:        res.ID.String(),
+		EventType: req.EventType,
+		EventID:   req.EventID,
+		Payload:   req.Payload,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+func (h *SvixHandler) GetMessage(c *gin.Context) {
+	src, ok := h.application(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("msgId"))
+	if err != nil {
+		c.String(http.StatusNotFound, "message not found")
+		return
+	}
+	d, err := h.store.Deliveries.GetByID(c.Request.Context(), id)
+	if err != nil || d.SourceID != src.ID {
+		c.String(http.StatusNotFound, "message not found")
+		return
+	}
+	c.JSON(http.StatusOK, toSvixMessage(d))
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/eventtype"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/outbound"
+	"github.com/zachbroad/nitrohook/internal/signing"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
+)
+
+const (
+	// testEventType marks test pings so receivers can tell them apart.
+	testEventType = "nitrohook.test"
+	// maxTestResponseBytes bounds the response body returned by a test.
+	maxTestResponseBytes = 4096
+)
+
+type testEvent struct {
+	Type     string    `json:"type"`
+	ID       uuid.UUID `json:"id"`
+	Source   string    `json:"source"`
+	ActionID uuid.UUID `json:"action_id"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+type testResult struct {
+	RequestID      uuid.UUID            `json:"request_id"`
+	ResponseStatus int                  `json:"response_status,omitempty"`
+	LatencyMs      int64                `json:"latency_ms"`
+	Timing         *model.AttemptTiming `json:"timing"`
+	ResponseBody   string               `json:"response_body,omitempty"`
+	Error          string               `json:"error,omitempty"`
+}
+
+// Test sends a small signed test event to a webhook action's target, with the
+// action's method, proxy and TLS settings, and reports the response. Nothing
+// is recorded as a delivery. URL and body templates aren't applied.
+func (h *ActionHandler) Test(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid action id")
+		return
+	}
+	src, err := h.store.Sources.GetBySlug(ctx, c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+	action, err := h.store.Actions.GetByID(ctx, id)
+	if err != nil || action.SourceID != src.ID {
+		c.String(http.StatusNotFound, "action not found")
+		return
+	}
+	if action.Type != model.ActionTypeWebhook || action.TargetURL == nil {
+		c.String(http.StatusBadRequest, "only webhook actions can be tested")
+		return
+	}
+	if err := h.guard.CheckURL(ctx, *action.TargetURL); errors.Is(err, ssrf.ErrBlocked) {
+		c.String(http.StatusBadRequest, "invalid target_url: "+err.Error())
+		return
+	}
+	client, err := h.clients.For(action)
+	if err != nil {
+		c.String(http.StatusUnprocessableEntity, "invalid action tls or proxy settings: "+err.Error())
+		return
+	}
+
+	event := testEvent{Type: testEventType, ID: uuid.New(), Source: src.Slug, ActionID: action.ID, SentAt: time.Now().UTC()}
+	body, _ := json.Marshal(event)
+	method := http.MethodPost
+	if action.HTTPMethod != nil {
+		method = *action.HTTPMethod
+	}
+	req, err := http.NewRequestWithContext(ctx, method, *action.TargetURL, bytes.NewReader(body))
+	if err != nil {
+		c.String(http.StatusUnprocessableEntity, "invalid target_url: "+err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Delivery-ID", event.ID.String())
+	req.Header.Set(eventtype.Header, testEventType)
+	if action.SigningSecret != nil {
+		req.Header.Set(outbound.SignatureHeader, signing.Sign(body, *action.SigningSecret))
+	}
+
+	result := testResult{RequestID: event.ID}
+	if name, value, ok, err := h.credentials.ActionHeader(ctx, client, action); err != nil {
+		result.Error = "auth: " + err.Error()
+		c.JSON(http.StatusOK, result)
+		return
+	} else if ok {
+		req.Header.Set(name, value)
+	}
+	req, tracer := outbound.Trace(req)
+	start := time.Now()
+	resp, err := client.Do(req)
+	// Like the worker, renew a rejected OAuth2 token and resend once
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && action.CredentialID != nil {
+		if name, value, renewed, _ := h.credentials.Renew(ctx, client, *action.CredentialID); renewed {
+			resp.Body.Close()
+			req = req.Clone(ctx)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.Header.Set(name, value)
+			req, tracer = outbound.Trace(req)
+			start = time.Now()
+			resp, err = client.Do(req)
+		}
+	}
+	result.LatencyMs = time.Since(start).Milliseconds()
+	result.Timing = tracer.Timing()
+	if err != nil {
+		slog.InfoContext(ctx, "test ping failed", "action_id", action.ID, "error", err)
+		result.Error = err.Error()
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxTestResponseBytes))
+	result.ResponseStatus = resp.StatusCode
+	result.ResponseBody = string(respBody)
+	c.JSON(http.StatusOK, result)
+}
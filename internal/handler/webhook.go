@@ -2,27 +2,63 @@ package handler
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/challenge"
+	"github.com/zachbroad/nitrohook/internal/cloudevents"
+	"github.com/zachbroad/nitrohook/internal/eventtype"
+	"github.com/zachbroad/nitrohook/internal/fingerprint"
+	"github.com/zachbroad/nitrohook/internal/ingestlimit"
+	"github.com/zachbroad/nitrohook/internal/logging"
+	"github.com/zachbroad/nitrohook/internal/metahook"
 	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/projection"
+	"github.com/zachbroad/nitrohook/internal/signing"
+	"github.com/zachbroad/nitrohook/internal/simulate"
 	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
 )
 
 type WebhookHandler struct {
-	store *store.Store
-	rdb   *redis.Client
+	store    *store.Store
+	rdb      *redis.Client
+	limits   model.Limits
+	batcher  *store.DeliveryBatcher
+	fastPath bool
+	// syncTimeout bounds the wait for sources in the delivered ack mode.
+	syncTimeout time.Duration
+	trim        streamtrim.Policy
+	// ingestWarnAt is the fraction of a source's ingest rate limit past
+	// which responses warn; meta is told when a source first crosses it.
+	ingestWarnAt float64
+	meta         *metahook.Notifier
 }
 
-func NewWebhookHandler(s *store.Store, rdb *redis.Client) *WebhookHandler {
-	return &WebhookHandler{store: s, rdb: rdb}
+// NewWebhookHandler creates a WebhookHandler. batcher is optional; when set,
+// delivery inserts are group-committed through it. With fastPath, active-mode
+// deliveries are written only to the stream and persisted by the worker. trim
+// bounds the stream's size. ingestWarnAt and meta (optional) drive ingest rate
+// limit warnings.
+func NewWebhookHandler(s *store.Store, rdb *redis.Client, limits model.Limits, batcher *store.DeliveryBatcher, fastPath bool, syncTimeout time.Duration, trim streamtrim.Policy, ingestWarnAt float64, meta *metahook.Notifier) *WebhookHandler {
+	return &WebhookHandler{store: s, rdb: rdb, limits: limits, batcher: batcher, fastPath: fastPath, syncTimeout: syncTimeout, trim: trim, ingestWarnAt: ingestWarnAt, meta: meta}
 }
 
+// rateWarningKey holds the ingest rate limit warning for the response body.
+const rateWarningKey = "ingestRateWarning"
+
 func (h *WebhookHandler) Ingest(c *gin.Context) {
 	sourceSlug := c.Param("sourceSlug")
 
@@ -32,12 +68,86 @@ func (h *WebhookHandler) Ingest(c *gin.Context) {
 		return
 	}
 
-	body, err := io.ReadAll(c.Request.Body)
+	if src.IngestToken != nil && !validIngestToken(c, *src.IngestToken) {
+		c.String(http.StatusUnauthorized, "invalid ingest token")
+		return
+	}
+
+	// GETs only serve the challenge handshake; browsers and link unfurlers
+	// loading the URL must not trigger deliveries
+	if c.Request.Method == http.MethodGet {
+		if src.ChallengeMode == nil {
+			c.Header("Allow", http.MethodPost)
+			c.String(http.StatusMethodNotAllowed, "webhooks must be POSTed")
+			return
+		}
+		answerChallenge(c, src)
+		return
+	}
+
+	// Reject oversized bodies up front when the length is declared, and cap
+	// the read otherwise so nothing beyond the limit is buffered.
+	maxPayload := int64(model.EffectiveLimits(h.limits, src).MaxPayloadBytes)
+	if c.Request.ContentLength > maxPayload {
+		c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("payload exceeds %d bytes", maxPayload))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPayload))
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("payload exceeds %d bytes", maxPayload))
+			return
+		}
 		c.String(http.StatusBadRequest, "failed to read body")
 		return
 	}
 
+	// The cap above applies to the compressed body; decodeBody applies it
+	// again to the decompressed one.
+	body, err = decodeBody(c.GetHeader("Content-Encoding"), body, maxPayload)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnsupportedEncoding):
+			c.String(http.StatusUnsupportedMediaType, "unsupported Content-Encoding")
+		case errors.Is(err, errDecodedTooLarge):
+			c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("decompressed payload exceeds %d bytes", maxPayload))
+		default:
+			c.String(http.StatusBadRequest, "failed to decompress body")
+		}
+		return
+	}
+
+	var quarantine string
+	if src.InboundSecret != nil {
+		if err := signing.VerifyInbound(inboundConfig(src), c.Request.Header, body); err != nil {
+			if src.InboundSignatureFailure != model.SignatureFlag {
+				slog.WarnContext(c.Request.Context(), "rejected webhook with bad signature", "source", src.Slug, "error", err)
+				c.String(http.StatusUnauthorized, "invalid signature")
+				return
+			}
+			slog.WarnContext(c.Request.Context(), "quarantining webhook with bad signature", "source", src.Slug, "error", err)
+			quarantine = "invalid signature: " + err.Error()
+		}
+	}
+
+	// Only authenticated requests count against the producer's quota, so
+	// forged ones can't use it up
+	if src.IngestRateLimit != nil && quarantine == "" && !h.checkIngestRate(c, src) {
+		return
+	}
+
+	// CloudEvents: keep the data as the payload and the attributes alongside
+	ceAttrs, body, err := cloudevents.FromRequest(c.Request.Header, body)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid CloudEvent: "+err.Error())
+		return
+	}
+	var ceJSON json.RawMessage
+	if ceAttrs != nil {
+		ceJSON, _ = json.Marshal(ceAttrs)
+	}
+
 	if !json.Valid(body) {
 		c.String(http.StatusBadRequest, "invalid JSON payload")
 		return
@@ -45,55 +155,612 @@ func (h *WebhookHandler) Ingest(c *gin.Context) {
 
 	// Extract relevant headers
 	headerMap := map[string]string{}
-	for _, key := range []string{"Content-Type", "X-Request-ID", "X-Webhook-ID"} {
+	for _, key := range []string{"Content-Type", logging.RequestIDHeader, "X-Webhook-ID"} {
 		if v := c.GetHeader(key); v != "" {
 			headerMap[key] = v
 		}
 	}
 	headersJSON, _ := json.Marshal(headerMap)
 
-	// Use X-Idempotency-Key header or generate one
+	eventType := eventtype.Detect(c.Request.Header, body)
+	if ceAttrs != nil {
+		eventType = ceAttrs.Type
+	}
+
+	// Use X-Idempotency-Key header or the CloudEvent's source and id (unique
+	// per event by spec); enqueue generates one otherwise
 	idempotencyKey := c.GetHeader("X-Idempotency-Key")
-	if idempotencyKey == "" {
-		idempotencyKey = uuid.New().String()
+	if idempotencyKey == "" && ceAttrs != nil {
+		idempotencyKey = "ce:" + ceAttrs.Source + ":" + ceAttrs.ID
+	}
+
+	var queryJSON json.RawMessage
+	if query := c.Request.URL.Query(); len(query) > 0 {
+		queryJSON, _ = json.Marshal(query)
+	}
+
+	deliverAt, delay, err := scheduledAt(c.Request.Header, src, time.Now())
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.accept(c, src, store.NewDelivery{
+		IdempotencyKey:   idempotencyKey,
+		Headers:          headersJSON,
+		Payload:          body,
+		Method:           c.Request.Method,
+		QueryParams:      queryJSON,
+		RemoteAddr:       c.ClientIP(),
+		CloudEvent:       ceJSON,
+		EventType:        eventType,
+		DeliverAt:        deliverAt,
+		DelaySeconds:     delay,
+		DetectedProvider: fingerprint.Detect(c.Request.Header),
+		Quarantine:       quarantine,
+	})
+}
+
+// checkIngestRate counts the request against the source's ingest rate limit
+// and sets the X-RateLimit-* headers. Over the limit it answers 429 and
+// reports false; near it the warning is kept for the response body. The
+// first warning and the first rejection in each window are sent to the
+// meta-webhook. Redis errors fail open.
+func (h *WebhookHandler) checkIngestRate(c *gin.Context, src *model.Source) bool {
+	ctx := c.Request.Context()
+	now := time.Now()
+	st, err := ingestlimit.Check(ctx, h.rdb, src.ID, *src.IngestRateLimit, h.ingestWarnAt, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check ingest rate limit", "error", err, "source", src.Slug)
+		return true
+	}
+	st.SetHeaders(c.Writer.Header(), now)
+	if st.Crossed {
+		event := metahook.EventIngestRateWarning
+		if st.Exceeded {
+			event = metahook.EventIngestRateExceeded
+		}
+		h.meta.Notify(ctx, event, gin.H{
+			"source":    src.Slug,
+			"source_id": src.ID,
+			"limit":     st.Limit,
+			"remaining": st.Remaining,
+			"reset_at":  st.Reset,
+		})
+	}
+	if st.Exceeded {
+		c.String(http.StatusTooManyRequests, "ingest rate limit exceeded")
+		return false
+	}
+	if st.Warn {
+		c.Set(rateWarningKey, st.Warning())
 	}
+	return true
+}
+
+// maxDeliveryDelay bounds how far ahead a delivery can be scheduled.
+const maxDeliveryDelay = 30 * 24 * time.Hour
 
-	delivery, err := h.store.Deliveries.Create(c.Request.Context(), src.ID, idempotencyKey, headersJSON, body)
+// scheduledAt returns when the delivery should be fanned out: an X-Deliver-At
+// (RFC 3339) time, or a delay in seconds that the insert adds to the database
+// clock. X-Deliver-At or X-Delay on the request take precedence over the
+// source's delivery delay. Both are nil for immediate delivery.
+func scheduledAt(header http.Header, src *model.Source, now time.Time) (*time.Time, *int, error) {
+	delay := 0
+	switch {
+	case header.Get("X-Deliver-At") != "":
+		at, err := time.Parse(time.RFC3339, header.Get("X-Deliver-At"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("X-Deliver-At must be an RFC 3339 timestamp")
+		}
+		if !at.After(now) {
+			return nil, nil, nil
+		}
+		if at.Sub(now) > maxDeliveryDelay {
+			return nil, nil, fmt.Errorf("delivery can be scheduled at most %s ahead", maxDeliveryDelay)
+		}
+		return &at, nil, nil
+	case header.Get("X-Delay") != "":
+		n, err := strconv.Atoi(header.Get("X-Delay"))
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("X-Delay must be a number of seconds")
+		}
+		delay = n
+	case src.DeliveryDelaySeconds != nil:
+		delay = *src.DeliveryDelaySeconds
+	}
+	if delay <= 0 {
+		return nil, nil, nil
+	}
+	if time.Duration(delay)*time.Second > maxDeliveryDelay {
+		return nil, nil, fmt.Errorf("delivery can be scheduled at most %s ahead", maxDeliveryDelay)
+	}
+	return nil, &delay, nil
+}
+
+type sendRequest struct {
+	EventType      string            `json:"event_type,omitempty"`
+	Payload        json.RawMessage   `json:"payload"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+}
+
+// Send lets internal services publish an event for the source's actions
+// without going through an inbound webhook. The delivery then takes the same
+// path as an ingested one (transform, fan-out, retries, signing).
+func (h *WebhookHandler) Send(c *gin.Context) {
+	src, err := h.store.Sources.GetBySlug(c.Request.Context(), c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	maxPayload := int64(model.EffectiveLimits(h.limits, src).MaxPayloadBytes)
+	var req sendRequest
+	if err := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxPayload+64*1024)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("payload exceeds %d bytes", maxPayload))
+			return
+		}
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Payload) == 0 || string(req.Payload) == "null" {
+		c.String(http.StatusBadRequest, "payload is required")
+		return
+	}
+	if int64(len(req.Payload)) > maxPayload {
+		c.String(http.StatusRequestEntityTooLarge, fmt.Sprintf("payload exceeds %d bytes", maxPayload))
+		return
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range req.Headers {
+		headers[k] = v
+	}
+	if req.EventType != "" {
+		headers[eventtype.Header] = req.EventType
+	}
+	headersJSON, _ := json.Marshal(headers)
+
+	h.accept(c, src, store.NewDelivery{
+		IdempotencyKey: req.IdempotencyKey,
+		Headers:        headersJSON,
+		Payload:        req.Payload,
+		Method:         c.Request.Method,
+		RemoteAddr:     c.ClientIP(),
+		EventType:      req.EventType,
+	})
+}
+
+type simulateRequest struct {
+	Provider  string `json:"provider"`
+	EventType string `json:"event_type"`
+}
+
+// Simulate injects a sample provider event for the source through the normal
+// ingest pipeline. The resulting delivery is flagged as simulated.
+func (h *WebhookHandler) Simulate(c *gin.Context) {
+	src, err := h.store.Sources.GetBySlug(c.Request.Context(), c.Param("sourceSlug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	var req simulateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sample, err := simulate.Generate(req.Provider, req.EventType)
+	if err != nil {
+		if errors.Is(err, simulate.ErrUnknownProvider) || errors.Is(err, simulate.ErrUnknownEvent) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":     err.Error(),
+				"providers": simulate.Providers(),
+			})
+			return
+		}
+		c.String(http.StatusInternalServerError, "failed to generate sample")
+		return
+	}
+
+	headersJSON, _ := json.Marshal(sample.Headers)
+	h.accept(c, src, store.NewDelivery{
+		Headers:   headersJSON,
+		Payload:   sample.Payload,
+		Simulated: true,
+		EventType: eventtype.Detect(headerOf(sample.Headers), sample.Payload),
+	})
+}
+
+// Replay re-enqueues a delivery as a new one referencing the original. It
+// takes the source's current mode and actions and starts from the original
+// payload, so transforms run again.
+func (h *WebhookHandler) Replay(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+
+	ctx := c.Request.Context()
+	orig, err := h.store.Deliveries.GetByID(ctx, id)
+	if err != nil {
+		c.String(http.StatusNotFound, "delivery not found")
+		return
+	}
+	src, err := h.store.Sources.GetByID(ctx, orig.SourceID)
 	if err != nil {
-		slog.Error("failed to create delivery", "error", err)
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+
+	nd := store.NewDelivery{
+		IdempotencyKey: "replay:" + orig.ID.String() + ":" + uuid.New().String(),
+		Headers:        orig.Headers,
+		Payload:        orig.Payload,
+		Simulated:      orig.Simulated,
+		QueryParams:    orig.QueryParams,
+		CloudEvent:     orig.CloudEvent,
+		ReplayOf:       &orig.ID,
+	}
+	if orig.Method != nil {
+		nd.Method = *orig.Method
+	}
+	if orig.RemoteAddr != nil {
+		nd.RemoteAddr = *orig.RemoteAddr
+	}
+	if orig.EventType != nil {
+		nd.EventType = *orig.EventType
+	}
+	if orig.DetectedProvider != nil {
+		nd.DetectedProvider = *orig.DetectedProvider
+	}
+
+	res, err := h.enqueue(ctx, src, nd)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to replay delivery", "error", err, "delivery_id", orig.ID)
+		c.String(http.StatusInternalServerError, "failed to replay delivery")
+		return
+	}
+	slog.InfoContext(ctx, "replayed delivery", "delivery_id", res.ID, "replay_of", orig.ID)
+
+	// The dashboard's Replay button follows through to the new delivery
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Redirect", "/deliveries/"+res.ID.String())
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"delivery_id": res.ID,
+		"replay_of":   orig.ID,
+		"request_id":  logging.RequestID(ctx),
+		"status":      res.Status,
+	})
+}
+
+// accepted describes the outcome of enqueue.
+type accepted struct {
+	ID     uuid.UUID
+	Status model.DeliveryStatus
+	// Duplicate is set when the idempotency key matched an existing
+	// delivery, which is reported instead and not fanned out again.
+	Duplicate bool
+	// Queued is set once the delivery is on the stream for fan-out.
+	Queued bool
+	// Scheduled is set when fan-out waits until DeliverAt.
+	Scheduled bool
+	DeliverAt *time.Time
+}
+
+// recordSuppressed records a duplicate for auditing. Failing to record it
+// doesn't fail the request.
+func (h *WebhookHandler) recordSuppressed(ctx context.Context, nd store.NewDelivery, duplicateOf uuid.UUID, reason string) {
+	if err := h.store.Suppressed.Record(ctx, nd, duplicateOf, reason); err != nil {
+		slog.ErrorContext(ctx, "failed to record suppressed delivery", "error", err, "duplicate_of", duplicateOf)
+	}
+}
+
+// accept stores the delivery and, for active sources, queues it for fan-out.
+// The source and request ID are filled in here.
+func (h *WebhookHandler) accept(c *gin.Context, src *model.Source, nd store.NewDelivery) {
+	ctx := c.Request.Context()
+	res, err := h.enqueue(ctx, src, nd)
+	if errors.Is(err, store.ErrSourceNotFound) {
+		c.String(http.StatusNotFound, "source not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create delivery", "error", err)
 		c.String(http.StatusInternalServerError, "failed to store delivery")
 		return
 	}
 
-	// Record mode: store only, no fanout
-	if src.Mode == "record" {
-		if err := h.store.Deliveries.UpdateStatus(c.Request.Context(), delivery.ID, model.DeliveryRecorded); err != nil {
-			slog.Error("failed to update delivery status to recorded", "error", err, "delivery_id", delivery.ID)
+	requestID := logging.RequestID(ctx)
+	if !res.Duplicate && !res.Scheduled && src.Mode != "record" && res.Status != model.DeliveryQuarantined {
+		switch src.AckMode {
+		case model.AckQueued:
+			if !res.Queued {
+				// Stored, so the catch-up poll still delivers it; a retry
+				// with the same idempotency key is deduplicated
+				c.Header("Retry-After", "1")
+				c.String(http.StatusServiceUnavailable, "failed to queue delivery")
+				return
+			}
+		case model.AckDelivered:
+			h.respondDelivered(c, res.ID, nd.Simulated)
+			return
 		}
-		c.JSON(http.StatusAccepted, gin.H{
-			"delivery_id": delivery.ID,
-			"status":      "recorded",
+	}
+	if res.Duplicate {
+		code := http.StatusOK
+		if src.RetrySignal && res.Status == model.DeliveryFailed {
+			// Every action failed for good: keep the provider retrying
+			// rather than let it consider the event delivered
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{
+			"delivery_id": res.ID,
+			"request_id":  requestID,
+			"status":      res.Status,
+			"duplicate":   true,
 		})
 		return
 	}
+	body := gin.H{
+		"delivery_id": res.ID,
+		"request_id":  requestID,
+		"status":      res.Status,
+		"simulated":   nd.Simulated,
+	}
+	if res.Scheduled {
+		body["deliver_at"] = res.DeliverAt
+	}
+	if warning := c.GetString(rateWarningKey); warning != "" {
+		body["warning"] = warning
+	}
+	c.JSON(http.StatusAccepted, body)
+}
+
+// Bounds on the poll interval while waiting for a delivery in the delivered
+// ack mode.
+const (
+	syncPollMin = 20 * time.Millisecond
+	syncPollMax = 500 * time.Millisecond
+)
+
+// respondDelivered waits for the delivery to settle and reports its outcome:
+// 200 when every action succeeded, 502 when any failed, and 202 if it is
+// still in flight when the sync timeout expires.
+func (h *WebhookHandler) respondDelivered(c *gin.Context, id uuid.UUID, simulated bool) {
+	ctx := c.Request.Context()
+	status := h.awaitDelivery(ctx, id)
+
+	code := http.StatusAccepted
+	switch status {
+	case model.DeliveryCompleted:
+		code = http.StatusOK
+	case model.DeliveryFailed, model.DeliveryPartiallyFailed, model.DeliveryCancelledConfigRemoved, model.DeliveryNeedsInvestigation:
+		code = http.StatusBadGateway
+	}
+	c.JSON(code, gin.H{
+		"delivery_id": id,
+		"request_id":  logging.RequestID(ctx),
+		"status":      status,
+		"simulated":   simulated,
+	})
+}
+
+// awaitDelivery polls the delivery until it leaves pending/processing or the
+// sync timeout expires, returning the last status seen. A fast-path delivery
+// the worker hasn't persisted yet counts as pending.
+func (h *WebhookHandler) awaitDelivery(ctx context.Context, id uuid.UUID) model.DeliveryStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.syncTimeout)
+	defer cancel()
+
+	status := model.DeliveryPending
+	interval := syncPollMin
+	for {
+		select {
+		case <-ctx.Done():
+			return status
+		case <-time.After(interval):
+		}
+		if d, err := h.store.Deliveries.GetByID(ctx, id); err == nil {
+			status = d.Status
+			if status != model.DeliveryPending && status != model.DeliveryProcessing {
+				return status
+			}
+		}
+		interval = min(interval*2, syncPollMax)
+	}
+}
+
+// enqueue persists a delivery for src (or, on the fast path, hands it to the
+// worker via the stream) and queues active-mode deliveries for fan-out. An
+// empty idempotency key is replaced with a generated one.
+func (h *WebhookHandler) enqueue(ctx context.Context, src *model.Source, nd store.NewDelivery) (accepted, error) {
+	nd.SourceID = src.ID
+	nd.RequestID = logging.RequestID(ctx)
+	keyed := nd.IdempotencyKey != ""
+	if !keyed {
+		nd.IdempotencyKey = uuid.New().String()
+	}
+	if src.Mode == "record" || nd.Quarantine != "" {
+		nd.DeliverAt, nd.DelaySeconds = nil, nil
+	}
+
+	// Replays and simulations repeat payloads on purpose
+	if src.DedupWindowSeconds != nil && nd.ReplayOf == nil && !nd.Simulated {
+		since := time.Now().Add(-time.Duration(*src.DedupWindowSeconds) * time.Second)
+		dup, err := h.store.Deliveries.FindByContent(ctx, src.ID, store.ContentHash(nd.Payload), since)
+		if err == nil {
+			h.recordSuppressed(ctx, nd, dup.ID, model.SuppressedContentHash)
+			return accepted{ID: dup.ID, Status: dup.Status, Duplicate: true}, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.ErrorContext(ctx, "failed to check for duplicate content", "error", err)
+		}
+	}
+
+	// Coalesced deliveries are held for the window so later events with the
+	// same key can supersede them
+	if src.Mode != "record" && src.CoalesceKey != nil && nd.ReplayOf == nil && !nd.Simulated && nd.Quarantine == "" {
+		if key, ok := projection.Lookup(nd.Payload, *src.CoalesceKey); ok {
+			nd.CoalesceKey = key
+			if nd.DeliverAt == nil && nd.DelaySeconds == nil {
+				// The insert adds the window to the database clock
+				nd.DelaySeconds = src.CoalesceWindowSeconds
+			}
+		}
+	}
+
+	// Scheduled deliveries are persisted; the worker publishes them when due.
+	// A caller's idempotency key may repeat an earlier delivery, which only
+	// the insert can tell, so keyed deliveries are persisted too.
+	if h.fastPathAllowed() && src.Mode != "record" && nd.DeliverAt == nil && nd.DelaySeconds == nil && nd.Quarantine == "" && !keyed {
+		id := uuid.New()
+		now := time.Now()
+		nd.ID, nd.ReceivedAt = &id, &now
+		err := publishValues(ctx, h.rdb, h.trim, nd.StreamValues())
+		if err == nil {
+			return accepted{ID: id, Status: model.DeliveryPending, Queued: true}, nil
+		}
+		// Fall back to persisting the delivery here
+		slog.ErrorContext(ctx, "fast-path publish failed, persisting directly", "error", err, "delivery_id", id)
+	}
+
+	var delivery *model.Delivery
+	var created bool
+	var err error
+	if h.batcher != nil {
+		delivery, created, err = h.batcher.Create(ctx, nd)
+	} else {
+		delivery, created, err = h.store.Deliveries.Create(ctx, nd)
+	}
+	if err != nil {
+		return accepted{}, err
+	}
+	if !created {
+		h.recordSuppressed(ctx, nd, delivery.ID, model.SuppressedIdempotencyKey)
+		return accepted{ID: delivery.ID, Status: delivery.Status, Duplicate: true}, nil
+	}
+
+	// Inserted as quarantined, to wait for review instead of being queued
+	if nd.Quarantine != "" {
+		return accepted{ID: delivery.ID, Status: model.DeliveryQuarantined}, nil
+	}
+
+	// Record mode: store only, no fanout
+	if src.Mode == "record" {
+		if err := h.store.Deliveries.UpdateStatus(ctx, delivery.ID, model.DeliveryRecorded); err != nil {
+			slog.ErrorContext(ctx, "failed to update delivery status to recorded", "error", err, "delivery_id", delivery.ID)
+		}
+		return accepted{ID: delivery.ID, Status: model.DeliveryRecorded}, nil
+	}
+
+	if nd.CoalesceKey != "" {
+		if _, at, err := h.store.Deliveries.Coalesce(ctx, src.ID, nd.CoalesceKey); err != nil {
+			slog.ErrorContext(ctx, "failed to coalesce deliveries", "error", err, "delivery_id", delivery.ID)
+		} else {
+			delivery.DeliverAt = &at
+		}
+	}
+
+	if delivery.DeliverAt != nil {
+		return accepted{ID: delivery.ID, Status: delivery.Status, Scheduled: true, DeliverAt: delivery.DeliverAt}, nil
+	}
 
 	// Active mode: publish to Redis Stream for fan-out
-	if err := h.publishToStream(c.Request.Context(), delivery.ID); err != nil {
-		slog.Error("failed to publish to redis stream", "error", err, "delivery_id", delivery.ID)
+	if err := publishToStream(ctx, h.rdb, h.trim, delivery); err != nil {
+		slog.ErrorContext(ctx, "failed to publish to redis stream", "error", err, "delivery_id", delivery.ID)
 		// Delivery is in Postgres with status=pending, catch-up poll will handle it
+		return accepted{ID: delivery.ID, Status: delivery.Status}, nil
 	}
+	return accepted{ID: delivery.ID, Status: delivery.Status, Queued: true}, nil
+}
 
-	c.JSON(http.StatusAccepted, gin.H{
-		"delivery_id": delivery.ID,
-		"status":      delivery.Status,
+// fastPathAllowed reports whether deliveries may skip the insert. The stream
+// entry is then the only copy of the payload, so the fast path only runs
+// while the stream is never trimmed.
+func (h *WebhookHandler) fastPathAllowed() bool {
+	return h.fastPath && h.trim.Mode == streamtrim.None
+}
+
+// answerChallenge responds to a provider's GET endpoint verification per the
+// source's challenge preset. Challenges are not recorded as deliveries.
+func answerChallenge(c *gin.Context, src *model.Source) {
+	secret := ""
+	if src.ChallengeSecret != nil {
+		secret = *src.ChallengeSecret
+	}
+	resp, err := challenge.Respond(*src.ChallengeMode, secret, c.Request.URL.Query())
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "rejected verification challenge", "source", src.Slug, "error", err)
+		switch {
+		case errors.Is(err, challenge.ErrTokenMismatch):
+			c.String(http.StatusForbidden, err.Error())
+		case errors.Is(err, challenge.ErrMissingChallenge):
+			c.String(http.StatusBadRequest, err.Error())
+		default:
+			c.String(http.StatusInternalServerError, "challenge misconfigured")
+		}
+		return
+	}
+	c.Data(http.StatusOK, resp.ContentType, resp.Body)
+}
+
+func validIngestToken(c *gin.Context, want string) bool {
+	got := c.Param("token")
+	if got == "" {
+		got, _ = strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+func inboundConfig(src *model.Source) signing.InboundConfig {
+	cfg := signing.InboundConfig{Secret: *src.InboundSecret, Scheme: signing.SchemeHMACSHA256}
+	if src.InboundSignatureScheme != nil {
+		cfg.Scheme = *src.InboundSignatureScheme
+	}
+	if src.InboundSignatureHeader != nil {
+		cfg.Header = *src.InboundSignatureHeader
+	}
+	if src.Provider != nil {
+		cfg.Provider = *src.Provider
+	}
+	return cfg
+}
+
+// publishToStream queues a persisted delivery for the fan-out worker. The
+// source and receive time travel with the ID so the worker can tell a row that
+// isn't visible yet from one removed with its source.
+func publishToStream(ctx context.Context, rdb *redis.Client, trim streamtrim.Policy, d *model.Delivery) error {
+	requestID := ""
+	if d.RequestID != nil {
+		requestID = *d.RequestID
+	}
+	return publishValues(ctx, rdb, trim, map[string]any{
+		"delivery_id": d.ID.String(),
+		"request_id":  requestID,
+		"source_id":   d.SourceID.String(),
+		"received_at": d.ReceivedAt.Format(time.RFC3339Nano),
 	})
 }
 
-func (h *WebhookHandler) publishToStream(ctx context.Context, deliveryID uuid.UUID) error {
-	return h.rdb.XAdd(ctx, &redis.XAddArgs{
-		Stream: "deliveries",
-		MaxLen: 10000,
-		Approx: true,
-		Values: map[string]any{"delivery_id": deliveryID.String()},
-	}).Err()
+func publishValues(ctx context.Context, rdb *redis.Client, trim streamtrim.Policy, values map[string]any) error {
+	args := &redis.XAddArgs{Stream: "deliveries", Values: values}
+	trim.Apply(args, time.Now())
+	return rdb.XAdd(ctx, args).Err()
+}
+
+// headerOf converts a stored header map for detection helpers that take an
+// http.Header.
+func headerOf(m map[string]string) http.Header {
+	h := http.Header{}
+	for k, v := range m {
+		h.Set(k, v)
+	}
+	return h
 }
@@ -0,0 +1,97 @@
+// Package ingestlimit enforces per-source ingest rate limits with a fixed
+// one-minute window counted in Redis, and tells producers how close they are
+// through X-RateLimit-* headers before requests start being rejected.
+package ingestlimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Window is the period a source's limit applies to.
+const Window = time.Minute
+
+// DefaultWarnAt is the fraction of the limit past which responses carry a
+// warning.
+const DefaultWarnAt = 0.8
+
+const keyPrefix = "nitrohook:ingestlimit:"
+
+// Status is a source's standing in the current window after counting a
+// request.
+type Status struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	// Exceeded is set once the request is over the limit; it should be
+	// rejected.
+	Exceeded bool
+	// Warn is set once usage has passed the warning threshold but the
+	// request is still within the limit.
+	Warn bool
+	// Crossed is set on the single request in a window that first warned
+	// or first exceeded, so notifications go out once per window.
+	Crossed bool
+}
+
+// Check counts a request against the source's limit for the window containing
+// now. warnAt is the warning threshold as a fraction of limit.
+func Check(ctx context.Context, rdb *redis.Client, sourceID uuid.UUID, limit int, warnAt float64, now time.Time) (Status, error) {
+	start := now.Truncate(Window)
+	key := keyPrefix + sourceID.String() + ":" + strconv.FormatInt(start.Unix(), 10)
+	pipe := rdb.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 2*Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Status{}, fmt.Errorf("count ingest request: %w", err)
+	}
+	return evaluate(int(incr.Val()), limit, warnAt, start.Add(Window)), nil
+}
+
+// evaluate works out the status of the count-th request in a window.
+func evaluate(count, limit int, warnAt float64, reset time.Time) Status {
+	st := Status{Limit: limit, Remaining: max(limit-count, 0), Reset: reset}
+	threshold := warnThreshold(limit, warnAt)
+	switch {
+	case count > limit:
+		st.Exceeded = true
+		st.Crossed = count == limit+1
+	case count >= threshold:
+		st.Warn = true
+		st.Crossed = count == threshold
+	}
+	return st
+}
+
+// warnThreshold is the request count at which warnings start. A warnAt
+// outside (0, 1) disables warnings short of the limit.
+func warnThreshold(limit int, warnAt float64) int {
+	if warnAt <= 0 || warnAt >= 1 {
+		return limit + 1
+	}
+	return max(int(math.Ceil(float64(limit)*warnAt)), 1)
+}
+
+// SetHeaders writes the X-RateLimit-* headers, and Retry-After when the
+// request is over the limit. Reset is in seconds from now.
+func (s Status) SetHeaders(h http.Header, now time.Time) {
+	reset := max(int(math.Ceil(s.Reset.Sub(now).Seconds())), 0)
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(reset))
+	if s.Exceeded {
+		h.Set("Retry-After", strconv.Itoa(max(reset, 1)))
+	}
+}
+
+// Warning describes the status for the response body.
+func (s Status) Warning() string {
+	return fmt.Sprintf("approaching ingest rate limit: %d of %d requests per minute remaining", s.Remaining, s.Limit)
+}
@@ -0,0 +1,56 @@
+package ingestlimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	reset := time.Unix(120, 0)
+	cases := []struct {
+		count                   int
+		remaining               int
+		warn, exceeded, crossed bool
+	}{
+		{count: 1, remaining: 9},
+		{count: 7, remaining: 3},
+		{count: 8, remaining: 2, warn: true, crossed: true},
+		{count: 10, remaining: 0, warn: true},
+		{count: 11, remaining: 0, exceeded: true, crossed: true},
+		{count: 12, remaining: 0, exceeded: true},
+	}
+	for _, tc := range cases {
+		st := evaluate(tc.count, 10, 0.8, reset)
+		if st.Remaining != tc.remaining || st.Warn != tc.warn || st.Exceeded != tc.exceeded || st.Crossed != tc.crossed {
+			t.Errorf("count %d: got %+v", tc.count, st)
+		}
+	}
+}
+
+func TestEvaluate_WarningsDisabled(t *testing.T) {
+	if st := evaluate(10, 10, 0, time.Time{}); st.Warn || st.Exceeded {
+		t.Fatalf("expected no warning at the limit with warnings off, got %+v", st)
+	}
+	if st := evaluate(1, 1, 0.5, time.Time{}); !st.Warn || !st.Crossed {
+		t.Fatalf("expected the only allowed request to warn, got %+v", st)
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	now := time.Unix(100, 0)
+	h := http.Header{}
+	Status{Limit: 10, Remaining: 0, Reset: time.Unix(120, 0), Exceeded: true}.SetHeaders(h, now)
+	if h.Get("X-RateLimit-Limit") != "10" || h.Get("X-RateLimit-Remaining") != "0" || h.Get("X-RateLimit-Reset") != "20" {
+		t.Fatalf("unexpected headers %v", h)
+	}
+	if h.Get("Retry-After") != "20" {
+		t.Fatalf("expected Retry-After 20, got %q", h.Get("Retry-After"))
+	}
+
+	h = http.Header{}
+	Status{Limit: 10, Remaining: 5, Reset: time.Unix(120, 0)}.SetHeaders(h, now)
+	if h.Get("Retry-After") != "" {
+		t.Fatal("expected no Retry-After within the limit")
+	}
+}
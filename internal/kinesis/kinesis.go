@@ -0,0 +1,150 @@
+// Package kinesis puts deliveries into Amazon Kinesis data streams through
+// the JSON API's PutRecord, signed with Signature Version 4.
+package kinesis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/awssig"
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+const (
+	// MaxRecordBytes is Kinesis's limit on a record's data.
+	MaxRecordBytes = 1 << 20
+	// maxPartitionKey is Kinesis's limit on a partition key, in characters.
+	maxPartitionKey = 256
+)
+
+// Config is a Kinesis action's config. PartitionKey is a reqtemplate over
+// the payload, such as "{{.customer.id}}"; it defaults to the delivery ID,
+// spreading records across shards. Endpoint replaces the regional AWS
+// endpoint, for LocalStack and the like.
+type Config struct {
+	StreamName   string `json:"stream_name"`
+	Region       string `json:"region"`
+	PartitionKey string `json:"partition_key,omitempty"`
+	Endpoint     string `json:"endpoint,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode kinesis config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the access key pair as
+// "ACCESS_KEY_ID:SECRET_ACCESS_KEY".
+func Validate(cfg Config, secret string) error {
+	if _, err := awssig.ParseCredentials(secret); err != nil {
+		return err
+	}
+	if cfg.StreamName == "" {
+		return errors.New("stream_name is required")
+	}
+	if cfg.Region == "" {
+		return errors.New("region is required")
+	}
+	if _, err := endpoint(cfg); err != nil {
+		return err
+	}
+	if cfg.PartitionKey != "" {
+		if _, err := reqtemplate.Parse(cfg.PartitionKey); err != nil {
+			return fmt.Errorf("invalid partition_key template: %w", err)
+		}
+	}
+	return nil
+}
+
+func endpoint(cfg Config) (*url.URL, error) {
+	if cfg.Endpoint == "" {
+		return &url.URL{Scheme: "https", Host: "kinesis." + cfg.Region + ".amazonaws.com", Path: "/"}, nil
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, errors.New("endpoint must be an http(s) URL")
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}, nil
+}
+
+// PartitionKey renders the config's partition key for payload, falling back
+// to deliveryID.
+func PartitionKey(cfg Config, payload json.RawMessage, deliveryID string) (string, error) {
+	if cfg.PartitionKey == "" {
+		return deliveryID, nil
+	}
+	out, err := reqtemplate.Body(cfg.PartitionKey, payload, maxPartitionKey*4)
+	if err != nil {
+		return "", fmt.Errorf("render partition key: %w", err)
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", errors.New("partition key rendered empty")
+	}
+	if n := len([]rune(key)); n > maxPartitionKey {
+		return "", fmt.Errorf("partition key is %d characters, over the limit of %d", n, maxPartitionKey)
+	}
+	return key, nil
+}
+
+// NewRequest builds a signed PutRecord request putting payload into the
+// stream under partitionKey.
+func NewRequest(ctx context.Context, cfg Config, creds awssig.Credentials, payload json.RawMessage, partitionKey string, now time.Time) (*http.Request, error) {
+	u, err := endpoint(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > MaxRecordBytes {
+		return nil, fmt.Errorf("payload exceeds the kinesis record limit of %d bytes", MaxRecordBytes)
+	}
+	// Data is a blob, which encoding/json base64-encodes as []byte
+	body, err := json.Marshal(map[string]any{
+		"StreamName":   cfg.StreamName,
+		"PartitionKey": partitionKey,
+		"Data":         []byte(payload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal kinesis record: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build kinesis request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Kinesis_20131202.PutRecord")
+	awssig.Sign(req, awssig.HashHex(body), creds, cfg.Region, "kinesis", now)
+	return req, nil
+}
+
+// CheckResponse reports a failed put. Errors come back as non-2xx with a
+// JSON body naming the error type.
+func CheckResponse(status int, body []byte) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	var res struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &res) == nil && res.Type != "" {
+		return fmt.Errorf("kinesis error: HTTP %d: %s: %s", status, res.Type, res.Message)
+	}
+	return fmt.Errorf("HTTP %d", status)
+}
@@ -0,0 +1,94 @@
+package kinesis
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/awssig"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(Config{StreamName: "events", Region: "us-east-1", PartitionKey: "{{.customer}}"}, "AKID:secret"); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	cases := map[string]struct {
+		cfg    Config
+		secret string
+	}{
+		"no credentials": {Config{StreamName: "events", Region: "us-east-1"}, ""},
+		"no stream":      {Config{Region: "us-east-1"}, "AKID:secret"},
+		"no region":      {Config{StreamName: "events"}, "AKID:secret"},
+		"bad endpoint":   {Config{StreamName: "events", Region: "us-east-1", Endpoint: "ftp://x"}, "AKID:secret"},
+		"bad key tmpl":   {Config{StreamName: "events", Region: "us-east-1", PartitionKey: "{{"}, "AKID:secret"},
+	}
+	for name, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestPartitionKey(t *testing.T) {
+	payload := []byte(`{"customer":{"id":"c9"}}`)
+	if key, err := PartitionKey(Config{}, payload, "d1"); err != nil || key != "d1" {
+		t.Fatalf("expected the delivery ID by default, got %q, %v", key, err)
+	}
+	if key, err := PartitionKey(Config{PartitionKey: "{{.customer.id}}"}, payload, "d1"); err != nil || key != "c9" {
+		t.Fatalf("expected c9, got %q, %v", key, err)
+	}
+	if _, err := PartitionKey(Config{PartitionKey: "{{.missing}}"}, payload, "d1"); err == nil {
+		t.Fatal("expected an error for an empty key")
+	}
+	if _, err := PartitionKey(Config{PartitionKey: strings.Repeat("k", 257)}, payload, "d1"); err == nil {
+		t.Fatal("expected an error for an overlong key")
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	cfg := Config{StreamName: "events", Region: "eu-west-1"}
+	creds := awssig.Credentials{AccessKey: "AKID", SecretKey: "secret"}
+	req, err := NewRequest(context.Background(), cfg, creds, []byte(`{"a":1}`), "c9", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "https://kinesis.eu-west-1.amazonaws.com/" {
+		t.Fatalf("unexpected endpoint %s", req.URL)
+	}
+	if req.Header.Get("X-Amz-Target") != "Kinesis_20131202.PutRecord" {
+		t.Fatalf("unexpected target %q", req.Header.Get("X-Amz-Target"))
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "/eu-west-1/kinesis/aws4_request") {
+		t.Fatalf("expected eu-west-1 kinesis scope, got %q", req.Header.Get("Authorization"))
+	}
+
+	var rec struct{ StreamName, PartitionKey, Data string }
+	b, _ := io.ReadAll(req.Body)
+	if err := json.Unmarshal(b, &rec); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(rec.Data)
+	if rec.StreamName != "events" || rec.PartitionKey != "c9" || string(data) != `{"a":1}` {
+		t.Fatalf("unexpected record %s", b)
+	}
+
+	cfg.Endpoint = "http://localstack:4566"
+	req, err = NewRequest(context.Background(), cfg, creds, []byte(`{}`), "k", time.Now())
+	if err != nil || req.URL.String() != "http://localstack:4566/" {
+		t.Fatalf("expected the custom endpoint, got %v, %v", req.URL, err)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(200, []byte(`{"ShardId":"shardId-0"}`)); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckResponse(400, []byte(`{"__type":"ResourceNotFoundException","message":"Stream events not found"}`))
+	if err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException: Stream events not found") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the correlation ID between producers, the API and
+// the worker.
+const RequestIDHeader = "X-Request-ID"
+
+type attrsKey struct{}
+type requestIDKey struct{}
+
+// sampled is the logger for high-volume, per-delivery messages. It is replaced
+// by Setup and defaults to the standard logger.
+var sampled = slog.Default()
+
+// Setup installs a slog default logger writing to w in the given format
+// ("json" or "text") at the given level. sampleRate (0-1) is the fraction of
+// Debug/Info records kept by the Sampled logger; warnings and errors are
+// always kept.
+func Setup(w io.Writer, format, level string, sampleRate float64) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("parse log level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text", "":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	h = &contextHandler{Handler: h}
+	slog.SetDefault(slog.New(h))
+	sampled = slog.New(&samplingHandler{Handler: h, rate: sampleRate})
+	return nil
+}
+
+// Sampled returns the logger for high-volume messages such as per-delivery
+// worker logs.
+func Sampled() *slog.Logger {
+	return sampled
+}
+
+// With returns a context whose log records carry the given key/value pairs.
+// A key already on the context is replaced rather than repeated.
+func With(ctx context.Context, args ...any) context.Context {
+	added := slog.Group("", args...).Value.Group()
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+
+	attrs := make([]slog.Attr, 0, len(existing)+len(added))
+	for _, a := range existing {
+		replaced := false
+		for _, b := range added {
+			if a.Key == b.Key {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			attrs = append(attrs, a)
+		}
+	}
+	attrs = append(attrs, added...)
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// RequestID returns the request ID stored on ctx by the RequestID middleware.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID stores a request ID on ctx and adds it to its log records.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return With(ctx, "request_id", id)
+}
+
+// contextHandler adds the attributes stored by With to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// samplingHandler drops a fraction of records below Warn.
+type samplingHandler struct {
+	slog.Handler
+	rate float64
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if !h.Handler.Enabled(ctx, level) {
+		return false
+	}
+	if level >= slog.LevelWarn || h.rate >= 1 {
+		return true
+	}
+	return h.rate > 0 && rand.Float64() < h.rate
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), rate: h.rate}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), rate: h.rate}
+}
+
+// RequestIDMiddleware takes the request ID from the X-Request-ID header,
+// generating one if absent, stores it on the request context and echoes it in
+// the response.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.New().String()
+		}
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// Middleware logs each HTTP request through slog, replacing gin's default
+// stdout logger.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.Request.Context(), level, "http request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
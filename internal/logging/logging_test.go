@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, "json", "warn", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	Sampled().Info("dropped by level")
+	Sampled().Warn("kept", "delivery_id", "abc")
+
+	out := buf.String()
+	if strings.Contains(out, "dropped by level") {
+		t.Fatalf("expected info record to be filtered: %s", out)
+	}
+	if !strings.Contains(out, `"msg":"kept"`) {
+		t.Fatalf("expected JSON warning record: %s", out)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, "text", "debug", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range 100 {
+		Sampled().Info("sampled out")
+	}
+	Sampled().Error("always kept")
+
+	out := buf.String()
+	if strings.Contains(out, "sampled out") {
+		t.Fatalf("expected info records to be sampled out: %s", out)
+	}
+	if !strings.Contains(out, "always kept") {
+		t.Fatalf("expected error record to be kept: %s", out)
+	}
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, "xml", "info", 1); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+	if err := Setup(&buf, "text", "loud", 1); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, "json", "info", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = With(ctx, "delivery_id", "d-1")
+	ctx = With(ctx, "request_id", "req-2")
+	slog.InfoContext(ctx, "hello")
+
+	out := buf.String()
+	if !strings.Contains(out, `"delivery_id":"d-1"`) || !strings.Contains(out, `"request_id":"req-2"`) {
+		t.Fatalf("expected context attrs on record: %s", out)
+	}
+	if strings.Count(out, "request_id") != 1 {
+		t.Fatalf("expected request_id to be replaced, not repeated: %s", out)
+	}
+	if RequestID(ctx) != "req-1" {
+		t.Fatalf("unexpected request ID: %s", RequestID(ctx))
+	}
+}
@@ -0,0 +1,86 @@
+// Package metahook posts events about the relay itself, such as sources
+// nearing their ingest rate limit or actions out of error budget, to the
+// operator's meta-webhook.
+package metahook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/outbound"
+	"github.com/zachbroad/nitrohook/internal/signing"
+)
+
+// Event types.
+const (
+	EventIngestRateWarning    = "source.ingest_rate_warning"
+	EventIngestRateExceeded   = "source.ingest_rate_exceeded"
+	EventErrorBudgetExhausted = "action.error_budget_exhausted"
+	EventErrorBudgetRecovered = "action.error_budget_recovered"
+)
+
+// Event is the body posted to the meta-webhook.
+type Event struct {
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// Notifier posts events to one URL, signing them like webhook actions when
+// it has a secret. A nil Notifier drops events.
+type Notifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// New returns a Notifier for url, or nil when url is empty.
+func New(url, secret string, timeout time.Duration) *Notifier {
+	if url == "" {
+		return nil
+	}
+	return &Notifier{url: url, secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+// Notify posts the event in the background; failures are logged. The
+// request outlives ctx's cancellation but keeps its values.
+func (n *Notifier) Notify(ctx context.Context, eventType string, data any) {
+	if n == nil {
+		return
+	}
+	ev := Event{Type: eventType, OccurredAt: time.Now().UTC(), Data: data}
+	go func() {
+		if err := n.send(context.WithoutCancel(ctx), ev); err != nil {
+			slog.WarnContext(ctx, "failed to send meta-webhook", "type", eventType, "error", err)
+		}
+	}()
+}
+
+func (n *Notifier) send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		req.Header.Set(outbound.SignatureHeader, signing.Sign(body, n.secret))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post event: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post event: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package metahook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/outbound"
+	"github.com/zachbroad/nitrohook/internal/signing"
+)
+
+func TestSend(t *testing.T) {
+	got := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got <- r
+		bodies <- b
+	}))
+	defer srv.Close()
+
+	n := New(srv.URL, "s3cret", time.Second)
+	if err := n.send(context.Background(), Event{Type: EventIngestRateWarning, Data: map[string]string{"source": "orders"}}); err != nil {
+		t.Fatal(err)
+	}
+	req, body := <-got, <-bodies
+	if !signing.Verify(body, "s3cret", req.Header.Get(outbound.SignatureHeader)) {
+		t.Fatal("expected signed body")
+	}
+	var ev struct {
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(body, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != EventIngestRateWarning || ev.Data["source"] != "orders" {
+		t.Fatalf("unexpected event %s", body)
+	}
+}
+
+func TestSend_Non2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := New(srv.URL, "", time.Second).send(context.Background(), Event{Type: EventIngestRateExceeded}); err == nil {
+		t.Fatal("expected error for HTTP 500")
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	if New("", "x", time.Second) != nil {
+		t.Fatal("expected nil notifier without a URL")
+	}
+	var n *Notifier
+	n.Notify(context.Background(), EventIngestRateWarning, nil)
+}
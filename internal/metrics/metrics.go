@@ -0,0 +1,56 @@
+// Package metrics keeps operational counters in Redis, shared by every API
+// and worker process.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const key = "nitrohook:metrics"
+
+// Counter names.
+const (
+	// WorkerPanics counts panics recovered while processing a delivery or
+	// running a stream consumer.
+	WorkerPanics = "worker_panics"
+	// StreamTrimmed counts entries trimmed from the deliveries stream.
+	StreamTrimmed = "stream_trimmed"
+)
+
+// Incr adds one to the named counter. Failures are logged, not returned:
+// a lost increment isn't worth failing the caller over.
+func Incr(ctx context.Context, rdb *redis.Client, name string) {
+	if err := rdb.HIncrBy(ctx, key, name, 1).Err(); err != nil {
+		slog.ErrorContext(ctx, "failed to increment metric", "metric", name, "error", err)
+	}
+}
+
+// Set overwrites the named counter with a total kept elsewhere, such as a
+// count Redis itself tracks. Failures are logged like Incr's.
+func Set(ctx context.Context, rdb *redis.Client, name string, n int64) {
+	if err := rdb.HSet(ctx, key, name, n).Err(); err != nil {
+		slog.ErrorContext(ctx, "failed to set metric", "metric", name, "error", err)
+	}
+}
+
+// All returns every counter by name.
+func All(ctx context.Context, rdb *redis.Client) (map[string]int64, error) {
+	raw, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read metrics: %w", err)
+	}
+	counters := make(map[string]int64, len(raw))
+	for name, v := range raw {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse metric %s: %w", name, err)
+		}
+		counters[name] = n
+	}
+	return counters, nil
+}
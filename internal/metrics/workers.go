@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// workerKeyPrefix namespaces the worker registry; each worker process keeps
+// one key, expiring when it stops reporting.
+const workerKeyPrefix = "nitrohook:workers:"
+
+// WorkerReport is a worker process's latest view of its own resource use.
+type WorkerReport struct {
+	ID          string    `json:"id"`
+	ReportedAt  time.Time `json:"reported_at"`
+	Concurrency int       `json:"concurrency"`
+	Goroutines  int       `json:"goroutines"`
+	HeapAlloc   uint64    `json:"heap_alloc_bytes"`
+	HeapInuse   uint64    `json:"heap_inuse_bytes"`
+	NumGC       uint32    `json:"num_gc"`
+	// ScriptsRunning is how many scripts were running at report time and
+	// ScriptCapacity how many messages the worker processes at once, which
+	// bounds the scripts it can run.
+	ScriptsRunning int     `json:"scripts_running"`
+	ScriptCapacity int     `json:"script_capacity"`
+	ScriptUtil     float64 `json:"script_utilization"`
+	// Throughput is messages handled per second by each consumer over the
+	// last report interval.
+	Throughput map[string]float64 `json:"throughput"`
+}
+
+// Report stores r in the worker registry for ttl. Failures are logged like
+// Incr's.
+func Report(ctx context.Context, rdb *redis.Client, r WorkerReport, ttl time.Duration) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode worker report", "error", err)
+		return
+	}
+	if err := rdb.Set(ctx, workerKeyPrefix+r.ID, b, ttl).Err(); err != nil {
+		slog.ErrorContext(ctx, "failed to store worker report", "worker", r.ID, "error", err)
+	}
+}
+
+// Workers returns the latest report of every worker still reporting.
+func Workers(ctx context.Context, rdb *redis.Client) ([]WorkerReport, error) {
+	var keys []string
+	iter := rdb.Scan(ctx, 0, workerKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scan worker reports: %w", err)
+	}
+	reports := []WorkerReport{}
+	if len(keys) == 0 {
+		return reports, nil
+	}
+	vals, err := rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read worker reports: %w", err)
+	}
+	for i, v := range vals {
+		// Expired between the scan and the read
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var r WorkerReport
+		if err := json.Unmarshal([]byte(s), &r); err != nil {
+			return nil, fmt.Errorf("parse worker report %s: %w", keys[i], err)
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// Rates turns two snapshots of per-consumer message counts taken elapsed
+// apart into messages per second. Consumers missing from prev count from
+// zero.
+func Rates(prev, cur map[string]int64, elapsed time.Duration) map[string]float64 {
+	rates := make(map[string]float64, len(cur))
+	secs := elapsed.Seconds()
+	for name, n := range cur {
+		if secs <= 0 {
+			rates[name] = 0
+			continue
+		}
+		rates[name] = float64(n-prev[name]) / secs
+	}
+	return rates
+}
+
+// Totals sums reports into gauges for the metrics endpoint, so operators can
+// read fleet-wide figures next to the counters.
+func Totals(reports []WorkerReport) map[string]int64 {
+	var goroutines, heap, running, capacity int64
+	for _, r := range reports {
+		goroutines += int64(r.Goroutines)
+		heap += int64(r.HeapInuse)
+		running += int64(r.ScriptsRunning)
+		capacity += int64(r.ScriptCapacity)
+	}
+	return map[string]int64{
+		"workers":                 int64(len(reports)),
+		"worker_goroutines":       goroutines,
+		"worker_heap_inuse_bytes": heap,
+		"worker_scripts_running":  running,
+		"worker_script_capacity":  capacity,
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRates(t *testing.T) {
+	prev := map[string]int64{"worker-0": 10}
+	cur := map[string]int64{"worker-0": 40, "worker-1": 5}
+	got := Rates(prev, cur, 10*time.Second)
+	if got["worker-0"] != 3 {
+		t.Errorf("worker-0 = %v, want 3", got["worker-0"])
+	}
+	if got["worker-1"] != 0.5 {
+		t.Errorf("worker-1 = %v, want 0.5", got["worker-1"])
+	}
+}
+
+func TestRatesZeroElapsed(t *testing.T) {
+	got := Rates(nil, map[string]int64{"worker-0": 7}, 0)
+	if got["worker-0"] != 0 {
+		t.Errorf("worker-0 = %v, want 0", got["worker-0"])
+	}
+}
+
+func TestTotals(t *testing.T) {
+	got := Totals([]WorkerReport{
+		{Goroutines: 20, HeapInuse: 1000, ScriptsRunning: 1, ScriptCapacity: 4},
+		{Goroutines: 30, HeapInuse: 500, ScriptsRunning: 2, ScriptCapacity: 8},
+	})
+	want := map[string]int64{
+		"workers":                 2,
+		"worker_goroutines":       50,
+		"worker_heap_inuse_bytes": 1500,
+		"worker_scripts_running":  3,
+		"worker_script_capacity":  12,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %d, want %d", k, got[k], v)
+		}
+	}
+}
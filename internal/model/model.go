@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,35 +14,393 @@ type Source struct {
 	Slug       string    `json:"slug"`
 	Mode       string    `json:"mode"`
 	ScriptBody *string   `json:"script_body,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	// ExternalID is an optional caller-chosen identifier, unique across
+	// sources, for infrastructure-as-code tooling.
+	ExternalID *string `json:"external_id,omitempty"`
+	// Per-source overrides of the global limits; nil uses the global value.
+	MaxPayloadBytes  *int `json:"max_payload_bytes,omitempty"`
+	MaxResponseBytes *int `json:"max_response_bytes,omitempty"`
+	ScriptTimeoutMs  *int `json:"script_timeout_ms,omitempty"`
+	// Provider names the producer (stripe, github, shopify, slack); when set
+	// it selects the built-in signature verification preset.
+	Provider *string `json:"provider,omitempty"`
+	// Inbound signature verification; disabled while InboundSecret is nil.
+	InboundSignatureScheme *string `json:"inbound_signature_scheme,omitempty"`
+	InboundSignatureHeader *string `json:"inbound_signature_header,omitempty"`
+	InboundSecret          *string `json:"inbound_secret,omitempty"`
+	// InboundSignatureFailure is SignatureReject or SignatureFlag.
+	InboundSignatureFailure string `json:"inbound_signature_failure"`
+	// IngestToken, when set, must be presented on ingest, either as the last
+	// path segment or as a bearer token.
+	IngestToken *string `json:"ingest_token,omitempty"`
+	// AckMode controls when ingest acknowledges a delivery; see AckAccepted,
+	// AckQueued and AckDelivered.
+	AckMode string `json:"ack_mode"`
+	// ChallengeMode selects how GET verification challenges are answered
+	// (echo, hub, crc); nil ingests GETs as deliveries.
+	ChallengeMode   *string `json:"challenge_mode,omitempty"`
+	ChallengeSecret *string `json:"challenge_secret,omitempty"`
+	// DeliveryDelaySeconds holds every delivery back from fan-out for this
+	// long unless the request schedules it explicitly; nil is immediate.
+	DeliveryDelaySeconds *int `json:"delivery_delay_seconds,omitempty"`
+	// DedupWindowSeconds suppresses deliveries whose payload matches one
+	// received within the window; nil only suppresses by idempotency key.
+	DedupWindowSeconds *int `json:"dedup_window_seconds,omitempty"`
+	// MaxInFlight caps how many of the source's deliveries workers process
+	// at once; the rest wait in order. nil is unlimited.
+	MaxInFlight *int `json:"max_in_flight,omitempty"`
+	// CoalesceKey is a payload path ("$.record.id"); deliveries sharing its
+	// value within CoalesceWindowSeconds collapse into the latest one. Both
+	// are nil when coalescing is off.
+	CoalesceKey           *string `json:"coalesce_key,omitempty"`
+	CoalesceWindowSeconds *int    `json:"coalesce_window_seconds,omitempty"`
+	// IngestRateLimit caps requests accepted per minute; past it ingest
+	// answers 429. nil is unlimited.
+	IngestRateLimit *int `json:"ingest_rate_limit,omitempty"`
+	// TransformErrorPolicy decides what happens to a delivery whose
+	// transform script fails; see TransformFailClosed, TransformFailOpen and
+	// TransformQuarantine.
+	TransformErrorPolicy string `json:"transform_error_policy"`
+	// RetrySignal answers a repeat of a delivery whose actions all failed
+	// with 503 rather than 200, so the provider keeps retrying it.
+	RetrySignal bool      `json:"retry_signal"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Stats is only populated by list queries.
+	Stats *SourceStats `json:"stats,omitempty"`
+	// WebhookURL is the public ingest URL, filled in by the API.
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// Ingest acknowledgment modes.
+const (
+	// AckAccepted responds 202 once the delivery is stored.
+	AckAccepted = "accepted"
+	// AckQueued responds 202 only once the delivery is on the Redis stream,
+	// and 503 if it couldn't be queued.
+	AckQueued = "queued"
+	// AckDelivered waits for the delivery to finish fanning out and responds
+	// with its outcome, falling back to 202 after the sync timeout.
+	AckDelivered = "delivered"
+)
+
+// What ingest does with a request whose inbound signature doesn't verify.
+const (
+	// SignatureReject answers 401 and stores nothing.
+	SignatureReject = "reject"
+	// SignatureFlag stores the delivery as quarantined for review.
+	SignatureFlag = "flag"
+)
+
+// Transform error policies.
+const (
+	// TransformFailClosed marks the delivery failed and dispatches nothing.
+	TransformFailClosed = "fail_closed"
+	// TransformFailOpen dispatches the original payload to every subscribed
+	// action, as if there were no transform.
+	TransformFailOpen = "fail_open"
+	// TransformQuarantine holds the delivery as quarantined for review.
+	TransformQuarantine = "quarantine"
+)
+
+// SourceStats summarises a source's delivery activity.
+type SourceStats struct {
+	TotalDeliveries        int64      `json:"total_deliveries"`
+	FailedLast24h          int64      `json:"failed_last_24h"`
+	PartiallyFailedLast24h int64      `json:"partially_failed_last_24h"`
+	LastReceivedAt         *time.Time `json:"last_received_at,omitempty"`
+}
+
+// HourlyActivity is a source's ingest count for one hour, from the rollup.
+type HourlyActivity struct {
+	Hour     time.Time `json:"hour"`
+	Received int64     `json:"received"`
+	Failed   int64     `json:"failed"`
+}
+
+// ProviderSuggestion is the provider most of a source's recent deliveries
+// were fingerprinted as, with the verification preset to configure for it.
+type ProviderSuggestion struct {
+	Provider string `json:"provider,omitempty"`
+	// Preset is the inbound signature preset for Provider, if it has one.
+	Preset string `json:"preset,omitempty"`
+	// Matches of Sampled recent deliveries were detected as Provider.
+	Matches int `json:"matches"`
+	Sampled int `json:"sampled"`
+	// Configured reports whether the source already verifies with Preset.
+	Configured bool `json:"configured"`
+}
+
+// Limits are the resource limits applied to a source's deliveries. The
+// global configuration doubles as the upper bound for per-source overrides.
+type Limits struct {
+	MaxPayloadBytes  int `json:"max_payload_bytes"`
+	MaxResponseBytes int `json:"max_response_bytes"`
+	ScriptTimeoutMs  int `json:"script_timeout_ms"`
+}
+
+// Caps bound how many sources and actions may exist, so a runaway
+// integration can't create enough to overwhelm fan-out. Zero is unlimited.
+type Caps struct {
+	MaxSources          int
+	MaxActionsPerSource int
+}
+
+// ScriptTimeout returns the script execution timeout as a duration.
+func (l Limits) ScriptTimeout() time.Duration {
+	return time.Duration(l.ScriptTimeoutMs) * time.Millisecond
+}
+
+// EffectiveLimits resolves a source's overrides against the global limits.
+// Overrides above the global bound are clamped to it.
+func EffectiveLimits(global Limits, src *Source) Limits {
+	eff := global
+	if src == nil {
+		return eff
+	}
+	if src.MaxPayloadBytes != nil {
+		eff.MaxPayloadBytes = min(*src.MaxPayloadBytes, global.MaxPayloadBytes)
+	}
+	if src.MaxResponseBytes != nil {
+		eff.MaxResponseBytes = min(*src.MaxResponseBytes, global.MaxResponseBytes)
+	}
+	if src.ScriptTimeoutMs != nil {
+		eff.ScriptTimeoutMs = min(*src.ScriptTimeoutMs, global.ScriptTimeoutMs)
+	}
+	return eff
+}
+
+// Script kinds recorded in script run stats.
+const (
+	ScriptKindGlobalTransform = "global_transform"
+	ScriptKindTransform       = "transform"
+	ScriptKindAction          = "action"
+)
+
+// SlowScriptRatio is the fraction of its timeout at which a script is
+// reported as slow.
+const SlowScriptRatio = 0.8
+
+// ScriptStats aggregates recent executions of one script.
+type ScriptStats struct {
+	Kind      string     `json:"kind"`
+	ActionID  *uuid.UUID `json:"action_id,omitempty"`
+	Runs      int64      `json:"runs"`
+	Timeouts  int64      `json:"timeouts"`
+	AvgMs     float64    `json:"avg_ms"`
+	P95Ms     float64    `json:"p95_ms"`
+	MaxMs     int        `json:"max_ms"`
+	TimeoutMs int        `json:"timeout_ms"`
+	// Slow is set when the p95 is at or above SlowScriptRatio of the timeout.
+	Slow bool `json:"slow"`
+}
+
+// ActionOutcomes counts an action's final outcomes, the latest finished
+// attempt per delivery, over an SLO window and its recent burn window.
+type ActionOutcomes struct {
+	ActionID     uuid.UUID
+	Total        int64
+	Failed       int64
+	RecentTotal  int64
+	RecentFailed int64
+}
+
+// RetryReasonCount counts an action's retries scheduled for one reason.
+type RetryReasonCount struct {
+	SourceID uuid.UUID
+	ActionID uuid.UUID
+	Reason   string
+	Count    int64
 }
 
 type ActionType string
 
 const (
-	ActionTypeWebhook    ActionType = "webhook"
-	ActionTypeJavascript ActionType = "javascript"
-	// ActionTypeSMTP       ActionType = "smtp"
+	ActionTypeWebhook       ActionType = "webhook"
+	ActionTypeJavascript    ActionType = "javascript"
+	ActionTypeSlack         ActionType = "slack"
+	ActionTypeSMTP          ActionType = "smtp"
+	ActionTypeOpsGenie      ActionType = "opsgenie"
+	ActionTypeSQS           ActionType = "sqs"
+	ActionTypeKinesis       ActionType = "kinesis"
+	ActionTypeAMQP          ActionType = "amqp"
+	ActionTypeMQTT          ActionType = "mqtt"
+	ActionTypeTelegram      ActionType = "telegram"
+	ActionTypeGRPC          ActionType = "grpc"
+	ActionTypePostgres      ActionType = "postgres"
+	ActionTypeElasticsearch ActionType = "elasticsearch"
+	ActionTypeDatadog       ActionType = "datadog"
+	ActionTypeBigQuery      ActionType = "bigquery"
+	ActionTypeRedis         ActionType = "redis"
 	// ActionTypeDiscord    ActionType = "discord"
-	// ActionTypeSlack      ActionType = "slack"
 	// ActionTypePagerDuty   ActionType = "pagerduty"
-	// ActionTypeOpsGenie    ActionType = "opsgenie"
 	// ActionTypeS3         ActionType = "s3"
-	// ActionTypeSQS        ActionType = "sqs"
-	// ActionTypeKinesis    ActionType = "kinesis"
 )
 
 type Action struct {
-	ID            uuid.UUID  `json:"id"`
-	SourceID      uuid.UUID  `json:"source_id"`
-	Type          ActionType `json:"type"`
-	TargetURL     *string    `json:"target_url,omitempty"`
-	ScriptBody    *string    `json:"script_body,omitempty"`
-	SigningSecret *string    `json:"signing_secret,omitempty"`
-	IsActive      bool       `json:"is_active"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	ID       uuid.UUID  `json:"id"`
+	SourceID uuid.UUID  `json:"source_id"`
+	Type     ActionType `json:"type"`
+	// ExternalID is an optional caller-chosen identifier, unique within the
+	// source.
+	ExternalID        *string     `json:"external_id,omitempty"`
+	TargetURL         *string     `json:"target_url,omitempty"`
+	ScriptBody        *string     `json:"script_body,omitempty"`
+	SigningSecret     *string     `json:"signing_secret,omitempty"`
+	Projection        *Projection `json:"projection,omitempty"`
+	IsActive          bool        `json:"is_active"`
+	VerificationToken *string     `json:"verification_token,omitempty"`
+	VerifiedAt        *time.Time  `json:"verified_at,omitempty"`
+	// Caps on attempts across all deliveries, as a safety valve against
+	// runaway retries. Nil means unlimited.
+	MaxAttemptsPerHour *int `json:"max_attempts_per_hour,omitempty"`
+	MaxAttemptsPerDay  *int `json:"max_attempts_per_day,omitempty"`
+	// MaxRequestsPerSecond rate-limits requests to the target across
+	// workers; requests over it are deferred, not failed. Nil is unlimited.
+	MaxRequestsPerSecond *int `json:"max_requests_per_second,omitempty"`
+	// EventTypes are the event types the action subscribes to ("x.*"
+	// matches a prefix); empty receives every delivery.
+	EventTypes []string `json:"event_types,omitempty"`
+	// CloudEventsMode sends webhook requests as CloudEvents ("binary" or
+	// "structured"); nil sends the plain payload.
+	CloudEventsMode *string `json:"cloudevents_mode,omitempty"`
+	// HTTPMethod overrides POST for webhook requests.
+	HTTPMethod *string `json:"http_method,omitempty"`
+	// URLTemplate and BodyTemplate are Go templates over the payload that
+	// replace target_url and the payload in webhook requests.
+	URLTemplate  *string `json:"url_template,omitempty"`
+	BodyTemplate *string `json:"body_template,omitempty"`
+	// TLSClientCert and TLSClientKey are presented to mTLS targets; the key
+	// is sealed with the secrets key and never returned. TLSCABundle is
+	// trusted instead of the system roots.
+	TLSClientCert *string `json:"tls_client_cert,omitempty"`
+	TLSClientKey  *string `json:"-"`
+	TLSCABundle   *string `json:"tls_ca_bundle,omitempty"`
+	// ProxyURL routes webhook requests through an http, https or socks5
+	// proxy instead of OUTBOUND_PROXY_URL.
+	ProxyURL *string `json:"proxy_url,omitempty"`
+	// DeliveryWindow holds deliveries outside it until it next opens.
+	DeliveryWindow *DeliveryWindow `json:"delivery_window,omitempty"`
+	// Config holds settings specific to the action type, such as a Slack
+	// channel and message template. Secret is the type's credential, sealed
+	// with the secrets key and never returned.
+	Config json.RawMessage `json:"config,omitempty"`
+	Secret *string         `json:"-"`
+	// CredentialID references a stored credential whose auth header the
+	// worker adds to webhook requests.
+	CredentialID *uuid.UUID `json:"credential_id,omitempty"`
+	// AuthType (bearer, basic or header) and AuthConfig add a static auth
+	// header to webhook requests without a stored credential. AuthSecret is
+	// the token, password or header value, sealed and never returned.
+	AuthType   *string         `json:"auth_type,omitempty"`
+	AuthConfig json.RawMessage `json:"auth_config,omitempty"`
+	AuthSecret *string         `json:"-"`
+	// SLOTarget overrides the global delivery success objective. The worker
+	// sets SLOExhaustedAt when the action's error budget runs out and
+	// clears it on recovery.
+	SLOTarget      *float64   `json:"slo_target,omitempty"`
+	SLOExhaustedAt *time.Time `json:"slo_exhausted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// LastAttempt is only populated by list queries.
+	LastAttempt *LastAttempt `json:"last_attempt,omitempty"`
+}
+
+// Credential types.
+const (
+	CredentialBearer = "bearer"
+	CredentialBasic  = "basic"
+	CredentialHeader = "header"
+	CredentialOAuth2 = "oauth2"
+)
+
+// Credential is a reusable destination credential that actions reference
+// instead of embedding secrets. Config holds the type's non-secret settings;
+// Secret is sealed with the secrets key and never returned.
+type Credential struct {
+	ID        uuid.UUID       `json:"id"`
+	Name      string          `json:"name"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config"`
+	Secret    string          `json:"-"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// DeliveryArchive is a cold storage object holding a source's deliveries
+// received on one day (UTC).
+type DeliveryArchive struct {
+	ID            uuid.UUID `json:"id"`
+	SourceID      uuid.UUID `json:"source_id"`
+	Day           string    `json:"day"`
+	ObjectKey     string    `json:"object_key"`
+	DeliveryCount int       `json:"delivery_count"`
+	Bytes         int64     `json:"bytes"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Reasons a request was suppressed as a duplicate.
+const (
+	SuppressedIdempotencyKey = "idempotency_key"
+	SuppressedContentHash    = "content_hash"
+)
+
+// SuppressedDelivery records a request that wasn't stored or fanned out
+// because it duplicated an earlier delivery.
+type SuppressedDelivery struct {
+	ID             uuid.UUID `json:"id"`
+	SourceID       uuid.UUID `json:"source_id"`
+	DuplicateOf    uuid.UUID `json:"duplicate_of"`
+	Reason         string    `json:"reason"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	RequestID      *string   `json:"request_id,omitempty"`
+	RemoteAddr     *string   `json:"remote_addr,omitempty"`
+	EventType      *string   `json:"event_type,omitempty"`
+	SuppressedAt   time.Time `json:"suppressed_at"`
+}
+
+// EventType is an entry in a source's event type catalog, either declared
+// through the API or observed on ingest.
+type EventType struct {
+	Name        string     `json:"name"`
+	Description *string    `json:"description,omitempty"`
+	Declared    bool       `json:"declared"`
+	FirstSeenAt *time.Time `json:"first_seen_at,omitempty"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// LastAttempt summarises an action's most recent dispatched attempt.
+type LastAttempt struct {
+	Status    AttemptStatus `json:"status"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// Projection limits which payload fields an action receives. Mode is either
+// "keep" (allowlist) or "drop" (denylist); Fields are dot-separated paths with
+// an optional "$." prefix, e.g. "$.customer.email".
+type Projection struct {
+	Mode   string   `json:"mode"`
+	Fields []string `json:"fields"`
+}
+
+const (
+	ProjectionKeep = "keep"
+	ProjectionDrop = "drop"
+)
+
+// DeliveryWindow limits when an action is dispatched to. In Timezone (an IANA
+// name, default UTC) it is either Days (mon..sun, default every day) from
+// Start to End ("09:00" to "17:00"; an End before Start runs past midnight),
+// or the minutes matched by a five-field Cron expression.
+type DeliveryWindow struct {
+	Timezone string   `json:"timezone,omitempty"`
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start,omitempty"`
+	End      string   `json:"end,omitempty"`
+	Cron     string   `json:"cron,omitempty"`
 }
 
 type DeliveryStatus string
@@ -52,18 +411,92 @@ const (
 	DeliveryCompleted  DeliveryStatus = "completed"
 	DeliveryFailed     DeliveryStatus = "failed"
 	DeliveryRecorded   DeliveryStatus = "recorded"
+
+	// DeliveryPartiallyFailed means some actions succeeded and the rest
+	// exhausted their retries.
+	DeliveryPartiallyFailed DeliveryStatus = "partially_failed"
+
+	// DeliveryCancelledConfigRemoved means the source or action the delivery
+	// was headed for was deleted while it was in flight.
+	DeliveryCancelledConfigRemoved DeliveryStatus = "cancelled_config_removed"
+
+	// DeliveryCancelled means the delivery was stopped through the API before
+	// it finished; it is never dispatched or retried again.
+	DeliveryCancelled DeliveryStatus = "cancelled"
+
+	// DeliveryNeedsInvestigation means the worker panicked while processing
+	// the delivery; status_reason holds the panic. It isn't retried
+	// automatically.
+	DeliveryNeedsInvestigation DeliveryStatus = "needs_investigation"
+
+	// DeliveryCoalesced means a later delivery with the same coalesce key
+	// arrived within the source's window and was dispatched instead; see
+	// CoalescedInto.
+	DeliveryCoalesced DeliveryStatus = "coalesced"
+
+	// DeliveryQuarantined means the delivery is held for manual review and
+	// won't be dispatched until it is released; status_reason says why.
+	DeliveryQuarantined DeliveryStatus = "quarantined"
 )
 
+// TransformFailedReason prefixes the status reason of deliveries stopped by
+// a failed transform.
+const TransformFailedReason = "transform failed: "
+
 type Delivery struct {
-	ID                 uuid.UUID       `json:"id"`
-	SourceID           uuid.UUID       `json:"source_id"`
-	IdempotencyKey     string          `json:"idempotency_key"`
-	Headers            json.RawMessage `json:"headers"`
-	Payload            json.RawMessage `json:"payload"`
-	Status             DeliveryStatus  `json:"status"`
+	ID             uuid.UUID       `json:"id"`
+	SourceID       uuid.UUID       `json:"source_id"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Headers        json.RawMessage `json:"headers"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	StatusReason   *string         `json:"status_reason,omitempty"`
+	Simulated      bool            `json:"simulated"`
+	RequestID      *string         `json:"request_id,omitempty"`
+	Method         *string         `json:"method,omitempty"`
+	QueryParams    json.RawMessage `json:"query_params,omitempty"`
+	RemoteAddr     *string         `json:"remote_addr,omitempty"`
+	EventType      *string         `json:"event_type,omitempty"`
+	// CloudEvent holds the context attributes when the delivery arrived as a
+	// CloudEvent.
+	CloudEvent json.RawMessage `json:"cloud_event,omitempty"`
+	// ReplayOf is the delivery this one was replayed from.
+	ReplayOf           *uuid.UUID      `json:"replay_of,omitempty"`
 	ReceivedAt         time.Time       `json:"received_at"`
 	TransformedPayload json.RawMessage `json:"transformed_payload,omitempty"`
 	TransformedHeaders json.RawMessage `json:"transformed_headers,omitempty"`
+	// DeliverAt is when a scheduled delivery is released for fan-out.
+	DeliverAt *time.Time `json:"deliver_at,omitempty"`
+	// DetectedProvider is the provider fingerprinted from the request
+	// headers, if any.
+	DetectedProvider *string `json:"detected_provider,omitempty"`
+	// CoalesceKey is the value extracted with the source's coalesce key.
+	CoalesceKey *string `json:"coalesce_key,omitempty"`
+	// CoalescedInto is the delivery dispatched in place of this one.
+	CoalescedInto *uuid.UUID `json:"coalesced_into,omitempty"`
+	// ReprocessCount is how many times the delivery was reprocessed after
+	// its transform failed.
+	ReprocessCount int        `json:"reprocess_count"`
+	ReprocessedAt  *time.Time `json:"reprocessed_at,omitempty"`
+	// Summary folds the delivery's attempts; only set by the API reads.
+	Summary *DeliverySummary `json:"summary,omitempty"`
+}
+
+// TransformFailed reports whether the delivery was stopped by a failed
+// transform, so it can be reprocessed with the current scripts.
+func (d Delivery) TransformFailed() bool {
+	return (d.Status == DeliveryFailed || d.Status == DeliveryQuarantined) &&
+		d.StatusReason != nil && strings.HasPrefix(*d.StatusReason, TransformFailedReason)
+}
+
+// DeliverySummary counts a delivery's actions by the outcome of each
+// action's latest attempt.
+type DeliverySummary struct {
+	TotalActions   int        `json:"total_actions"`
+	Succeeded      int        `json:"succeeded"`
+	Failed         int        `json:"failed"`
+	PendingRetries int        `json:"pending_retries"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
 }
 
 type AttemptStatus string
@@ -81,8 +514,50 @@ type DeliveryAttempt struct {
 	AttemptNumber  int           `json:"attempt_number"`
 	Status         AttemptStatus `json:"status"`
 	ResponseStatus *int          `json:"response_status,omitempty"`
-	ResponseBody   *string       `json:"response_body,omitempty"`
-	ErrorMessage   *string       `json:"error_message,omitempty"`
-	NextRetryAt    *time.Time    `json:"next_retry_at,omitempty"`
-	CreatedAt      time.Time     `json:"created_at"`
+	// ResponseBody may be encrypted; API listings omit it and set
+	// HasResponseBody instead.
+	ResponseBody    *string    `json:"response_body,omitempty"`
+	HasResponseBody bool       `json:"has_response_body,omitempty"`
+	ErrorMessage    *string    `json:"error_message,omitempty"`
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty"`
+	// RetryReason says why the retry was scheduled; see retryreason.
+	RetryReason *string `json:"retry_reason,omitempty"`
+	Capped      bool    `json:"capped,omitempty"`
+	AttemptTiming
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AttemptTiming breaks an attempt's latency into phases, in milliseconds.
+// DNS, connect and TLS are nil when a pooled connection was reused or the
+// phase didn't happen; all are nil for attempts that sent no request.
+type AttemptTiming struct {
+	DNSMs     *int `json:"dns_ms,omitempty"`
+	ConnectMs *int `json:"connect_ms,omitempty"`
+	TLSMs     *int `json:"tls_ms,omitempty"`
+	// TTFBMs runs from the request being written to the first response byte.
+	TTFBMs  *int `json:"ttfb_ms,omitempty"`
+	TotalMs *int `json:"total_ms,omitempty"`
+}
+
+// ManifestEntry is one delivery attempt as recorded in a signed audit manifest.
+type ManifestEntry struct {
+	DeliveryID     uuid.UUID     `json:"delivery_id"`
+	ActionID       uuid.UUID     `json:"action_id"`
+	AttemptNumber  int           `json:"attempt_number"`
+	Status         AttemptStatus `json:"status"`
+	ResponseStatus *int          `json:"response_status,omitempty"`
+	// PayloadSHA256 is the hex SHA-256 of the request body sent, as the
+	// receiver got it; empty when no HTTP request was sent.
+	PayloadSHA256 string    `json:"payload_sha256,omitempty"`
+	ReceivedAt    time.Time `json:"received_at"`
+	AttemptedAt   time.Time `json:"attempted_at"`
+}
+
+// Manifest lists the attempts for a single delivery or a time range.
+type Manifest struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	DeliveryID  *uuid.UUID      `json:"delivery_id,omitempty"`
+	From        *time.Time      `json:"from,omitempty"`
+	To          *time.Time      `json:"to,omitempty"`
+	Entries     []ManifestEntry `json:"entries"`
 }
@@ -0,0 +1,367 @@
+// Package mqtt publishes deliveries to MQTT 3.1.1 brokers. It speaks just
+// enough of the protocol for one publish per connection, at QoS 0, 1 or 2.
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// Packet types, shifted into the fixed header's high nibble.
+const (
+	packetConnect    = 1 << 4
+	packetConnack    = 2 << 4
+	packetPublish    = 3 << 4
+	packetPuback     = 4 << 4
+	packetPubrec     = 5 << 4
+	packetPubrel     = 6<<4 | 0x02
+	packetPubcomp    = 7 << 4
+	packetDisconnect = 14 << 4
+)
+
+const (
+	// maxTopic is the longest topic the protocol can carry, in bytes.
+	maxTopic = 65535
+	// maxRemaining is the largest packet body the protocol can carry.
+	maxRemaining = 268435455
+	// defaultTimeout bounds a publish when ctx has no deadline.
+	defaultTimeout = 30 * time.Second
+	// packetID identifies the one QoS 1 or 2 publish per connection.
+	packetID = 1
+)
+
+// ConnectError is a broker's refusal of the connection, with its CONNACK
+// return code.
+type ConnectError struct {
+	Code byte
+}
+
+func (e *ConnectError) Error() string {
+	reasons := map[byte]string{
+		1: "unacceptable protocol version",
+		2: "client identifier rejected",
+		3: "server unavailable",
+		4: "bad user name or password",
+		5: "not authorized",
+	}
+	if r, ok := reasons[e.Code]; ok {
+		return "mqtt: connection refused: " + r
+	}
+	return fmt.Sprintf("mqtt: connection refused with code %d", e.Code)
+}
+
+// Permanent reports whether a Publish error is a refusal retrying won't
+// fix: anything but the broker being unavailable.
+func Permanent(err error) bool {
+	var cerr *ConnectError
+	return errors.As(err, &cerr) && cerr.Code != 3
+}
+
+// Config is an MQTT action's config. URL names the broker as
+// mqtt[s]://[user@]host[:port]; the password is the action's secret. Topic
+// is a reqtemplate over the payload, such as "devices/{{.device_id}}/events".
+// ClientID defaults to a random ID per connection, since brokers disconnect
+// an existing session when the same ID connects again.
+type Config struct {
+	URL      string `json:"url"`
+	Topic    string `json:"topic"`
+	QoS      int    `json:"qos,omitempty"`
+	Retain   bool   `json:"retain,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode mqtt config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the user's password; brokers
+// that allow anonymous clients take neither.
+func Validate(cfg Config, secret string) error {
+	b, err := parseBroker(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if secret != "" && b.user == "" {
+		return errors.New("a password needs a user in the url, as mqtt://user@host")
+	}
+	if cfg.Topic == "" {
+		return errors.New("topic is required")
+	}
+	if _, err := reqtemplate.Parse(cfg.Topic); err != nil {
+		return fmt.Errorf("invalid topic template: %w", err)
+	}
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return errors.New("qos must be 0, 1 or 2")
+	}
+	return nil
+}
+
+// broker is a parsed broker URL.
+type broker struct {
+	addr string
+	host string
+	tls  bool
+	user string
+}
+
+func parseBroker(raw string) (broker, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "mqtt" && u.Scheme != "mqtts") || u.Hostname() == "" {
+		return broker{}, errors.New("url must be an mqtt:// or mqtts:// URL")
+	}
+	if u.Path != "" && u.Path != "/" {
+		return broker{}, errors.New("url must not have a path; set the topic instead")
+	}
+	b := broker{host: u.Hostname(), tls: u.Scheme == "mqtts"}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return broker{}, errors.New("url must not include a password; set it as the secret")
+		}
+		b.user = u.User.Username()
+	}
+	port := u.Port()
+	if port == "" {
+		port = "1883"
+		if b.tls {
+			port = "8883"
+		}
+	}
+	b.addr = net.JoinHostPort(b.host, port)
+	return b, nil
+}
+
+// Topic renders the config's topic for payload. Wildcards aren't allowed in
+// a published topic.
+func Topic(cfg Config, payload json.RawMessage) (string, error) {
+	out, err := reqtemplate.Body(cfg.Topic, payload, maxTopic)
+	if err != nil {
+		return "", fmt.Errorf("render topic: %w", err)
+	}
+	topic := strings.TrimSpace(string(out))
+	if topic == "" {
+		return "", errors.New("topic rendered empty")
+	}
+	if strings.ContainsAny(topic, "+#\x00") {
+		return "", fmt.Errorf("topic %q contains a wildcard or NUL", topic)
+	}
+	return topic, nil
+}
+
+// Dialer opens the TCP connection to the broker.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Publish connects with a clean session, publishes payload to topic at the
+// config's QoS and, for QoS 1 and 2, waits for the broker's acknowledgement.
+// It returns what the broker acknowledged: "sent" (QoS 0), "puback" or
+// "pubcomp". Refused connections are *ConnectError.
+func Publish(ctx context.Context, dial Dialer, cfg Config, password, topic string, payload []byte) (string, error) {
+	b, err := parseBroker(cfg.URL)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+	conn, err := dial(ctx, "tcp", b.addr)
+	if err != nil {
+		return "", fmt.Errorf("dial mqtt: %w", err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Unblock reads and writes if ctx is cancelled before the deadline
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if b.tls {
+		tconn := tls.Client(conn, &tls.Config{ServerName: b.host})
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			return "", fmt.Errorf("mqtt tls handshake: %w", err)
+		}
+		conn = tconn
+	}
+	r := bufio.NewReader(conn)
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		// 22 bytes, within the 23 every broker must accept
+		var id [6]byte
+		rand.Read(id[:])
+		clientID = "nitrohook-" + hex.EncodeToString(id[:])
+	}
+	if err := writePacket(conn, packetConnect, connectBody(clientID, b.user, password)); err != nil {
+		return "", err
+	}
+	typ, body, err := readPacket(r)
+	if err != nil {
+		return "", err
+	}
+	if typ != packetConnack || len(body) != 2 {
+		return "", fmt.Errorf("mqtt: expected CONNACK, got packet type %d", typ>>4)
+	}
+	if body[1] != 0 {
+		return "", &ConnectError{Code: body[1]}
+	}
+
+	var pub bytes.Buffer
+	writeString(&pub, topic)
+	if cfg.QoS > 0 {
+		pub.Write(binary.BigEndian.AppendUint16(nil, packetID))
+	}
+	pub.Write(payload)
+	header := byte(packetPublish | cfg.QoS<<1)
+	if cfg.Retain {
+		header |= 1
+	}
+	if err := writePacket(conn, header, pub.Bytes()); err != nil {
+		return "", err
+	}
+
+	result := "sent"
+	switch cfg.QoS {
+	case 1:
+		if err := expectAck(r, packetPuback); err != nil {
+			return "", err
+		}
+		result = "puback"
+	case 2:
+		if err := expectAck(r, packetPubrec); err != nil {
+			return "", err
+		}
+		if err := writePacket(conn, packetPubrel, binary.BigEndian.AppendUint16(nil, packetID)); err != nil {
+			return "", err
+		}
+		if err := expectAck(r, packetPubcomp); err != nil {
+			return "", err
+		}
+		result = "pubcomp"
+	}
+	// A QoS 0 message is only handed over once the connection closes
+	// cleanly, so this error counts
+	if err := writePacket(conn, packetDisconnect, nil); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+func connectBody(clientID, user, password string) []byte {
+	var buf bytes.Buffer
+	writeString(&buf, "MQTT")
+	buf.WriteByte(4)    // protocol level 3.1.1
+	flags := byte(0x02) // clean session
+	if user != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	buf.WriteByte(flags)
+	buf.Write([]byte{0, 0}) // no keep alive
+	writeString(&buf, clientID)
+	if user != "" {
+		writeString(&buf, user)
+		if password != "" {
+			writeString(&buf, password)
+		}
+	}
+	return buf.Bytes()
+}
+
+// expectAck reads the acknowledgement of the publish.
+func expectAck(r *bufio.Reader, want byte) error {
+	typ, body, err := readPacket(r)
+	if err != nil {
+		return err
+	}
+	if typ != want&0xF0 || len(body) != 2 || binary.BigEndian.Uint16(body) != packetID {
+		return fmt.Errorf("mqtt: unexpected packet type %d awaiting acknowledgement", typ>>4)
+	}
+	return nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(s))))
+	buf.WriteString(s)
+}
+
+func writePacket(w io.Writer, header byte, body []byte) error {
+	if len(body) > maxRemaining {
+		return fmt.Errorf("mqtt: packet of %d bytes exceeds the protocol limit", len(body))
+	}
+	buf := []byte{header}
+	// Remaining length: 7 bits per byte, low bits first
+	for n := len(body); ; {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			break
+		}
+	}
+	buf = append(buf, body...)
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("mqtt write: %w", err)
+	}
+	return nil
+}
+
+// readPacket reads a packet, returning its type (the fixed header's high
+// nibble) and body. Only small acknowledgements are expected.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("mqtt read: %w", err)
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, fmt.Errorf("mqtt read: %w", err)
+		}
+		n |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+	}
+	if n > 1024 {
+		return 0, nil, fmt.Errorf("mqtt: unexpected %d byte packet", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, fmt.Errorf("mqtt read: %w", err)
+	}
+	return header & 0xF0, body, nil
+}
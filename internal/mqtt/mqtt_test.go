@@ -0,0 +1,193 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		secret  string
+		wantErr bool
+	}{
+		{"anonymous", Config{URL: "mqtt://broker.example.com", Topic: "devices/{{.id}}"}, "", false},
+		{"with user", Config{URL: "mqtts://relay@broker.example.com:8884", Topic: "events", QoS: 2}, "pw", false},
+		{"password without user", Config{URL: "mqtt://broker.example.com", Topic: "events"}, "pw", true},
+		{"password in url", Config{URL: "mqtt://relay:pw@broker.example.com", Topic: "events"}, "", true},
+		{"path in url", Config{URL: "mqtt://broker.example.com/events", Topic: "events"}, "", true},
+		{"no topic", Config{URL: "mqtt://broker.example.com"}, "", true},
+		{"bad qos", Config{URL: "mqtt://broker.example.com", Topic: "events", QoS: 3}, "", true},
+		{"http url", Config{URL: "http://broker.example.com", Topic: "events"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.cfg, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTopic(t *testing.T) {
+	topic, err := Topic(Config{Topic: "devices/{{.id}}/events"}, []byte(`{"id":"d1"}`))
+	if err != nil || topic != "devices/d1/events" {
+		t.Fatalf("got %q, %v", topic, err)
+	}
+	if _, err := Topic(Config{Topic: "devices/{{.id}}"}, []byte(`{"id":"+"}`)); err == nil {
+		t.Fatal("expected error for a wildcard")
+	}
+}
+
+// received is what the fake broker got.
+type received struct {
+	clientID, user, password string
+	header                   byte
+	topic                    string
+	payload                  []byte
+}
+
+// fakeBroker accepts one connection, answers CONNECT with returnCode and
+// acknowledges the publish as its QoS requires.
+func fakeBroker(t *testing.T, returnCode byte) (string, <-chan received) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan received, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+
+		var rec received
+		_, body, err := readRaw(r)
+		if err != nil {
+			return
+		}
+		flags := body[7]
+		rest := body[10:]
+		rec.clientID, rest = readString(rest)
+		if flags&0x80 != 0 {
+			rec.user, rest = readString(rest)
+		}
+		if flags&0x40 != 0 {
+			rec.password, _ = readString(rest)
+		}
+		writePacket(conn, packetConnack, []byte{0, returnCode})
+		if returnCode != 0 {
+			got <- rec
+			return
+		}
+
+		header, body, err := readRaw(r)
+		if err != nil {
+			return
+		}
+		rec.header = header
+		rec.topic, body = readString(body)
+		qos := header >> 1 & 3
+		if qos > 0 {
+			body = body[2:]
+		}
+		rec.payload = body
+		id := binary.BigEndian.AppendUint16(nil, packetID)
+		switch qos {
+		case 1:
+			writePacket(conn, packetPuback, id)
+		case 2:
+			writePacket(conn, packetPubrec, id)
+			if h, _, err := readRaw(r); err != nil || h != packetPubrel {
+				return
+			}
+			writePacket(conn, packetPubcomp, id)
+		}
+		if h, _, err := readRaw(r); err == nil && h == packetDisconnect {
+			got <- rec
+		}
+	}()
+	return ln.Addr().String(), got
+}
+
+// readRaw reads a packet of any size, keeping the header's flags.
+func readRaw(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7F) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func readString(b []byte) (string, []byte) {
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+func publish(t *testing.T, addr string, cfg Config, password string, payload []byte) (string, error) {
+	t.Helper()
+	cfg.URL = "mqtt://relay@" + addr
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return Publish(ctx, d.DialContext, cfg, password, "devices/d1", payload)
+}
+
+func TestPublish(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 300) // two-byte remaining length
+	for qos, want := range []string{"sent", "puback", "pubcomp"} {
+		addr, got := fakeBroker(t, 0)
+		result, err := publish(t, addr, Config{QoS: qos, Retain: true}, "pw", payload)
+		if err != nil || result != want {
+			t.Fatalf("qos %d: got %q, %v", qos, result, err)
+		}
+		rec := <-got
+		if rec.user != "relay" || rec.password != "pw" || !strings.HasPrefix(rec.clientID, "nitrohook-") || len(rec.clientID) > 23 {
+			t.Fatalf("qos %d: connect %+v", qos, rec)
+		}
+		if rec.topic != "devices/d1" || !bytes.Equal(rec.payload, payload) || rec.header&1 == 0 || int(rec.header>>1&3) != qos {
+			t.Fatalf("qos %d: publish header %x topic %q, %d bytes", qos, rec.header, rec.topic, len(rec.payload))
+		}
+	}
+}
+
+func TestPublishRefused(t *testing.T) {
+	addr, _ := fakeBroker(t, 4)
+	_, err := publish(t, addr, Config{QoS: 1}, "wrong", []byte(`{}`))
+	if !Permanent(err) {
+		t.Fatalf("got %v", err)
+	}
+
+	addr, _ = fakeBroker(t, 3)
+	if _, err = publish(t, addr, Config{}, "pw", []byte(`{}`)); err == nil || Permanent(err) {
+		t.Fatalf("got %v", err)
+	}
+}
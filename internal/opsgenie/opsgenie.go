@@ -0,0 +1,213 @@
+// Package opsgenie creates and closes OpsGenie alerts from deliveries through
+// the Alert API.
+package opsgenie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// Alert API hosts by region.
+const (
+	USBaseURL = "https://api.opsgenie.com"
+	EUBaseURL = "https://api.eu.opsgenie.com"
+)
+
+// Alert actions.
+const (
+	ActionCreate = "create"
+	ActionClose  = "close"
+)
+
+// Field limits from the Alert API; longer values are truncated.
+const (
+	maxMessage     = 130
+	maxAlias       = 512
+	maxDescription = 15000
+)
+
+// DefaultMessage is used when an action has no message template.
+const DefaultMessage = "New delivery"
+
+// Config is an OpsGenie action's config. Action, Message, Alias and Priority
+// are reqtemplates over the payload, so each may be a literal ("close", "P2")
+// or derived from fields ({{.incident.id}}). Action renders "create" (the
+// default) or "close"; closing finds the alert by its alias, so Alias is
+// required with anything but a literal "create".
+type Config struct {
+	Region   string   `json:"region,omitempty"`
+	Action   string   `json:"action,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	Alias    string   `json:"alias,omitempty"`
+	Priority string   `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode opsgenie config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the integration's API key.
+// Literal action and priority values are checked here; templated ones when
+// rendered.
+func Validate(cfg Config, secret string) error {
+	if secret == "" {
+		return errors.New("opsgenie actions need an API key as their secret")
+	}
+	if cfg.Region != "" && cfg.Region != "us" && cfg.Region != "eu" {
+		return errors.New("opsgenie region must be 'us' or 'eu'")
+	}
+	for name, text := range map[string]string{"action": cfg.Action, "message": cfg.Message, "alias": cfg.Alias, "priority": cfg.Priority} {
+		if text == "" {
+			continue
+		}
+		if _, err := reqtemplate.Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %w", name, err)
+		}
+	}
+	if !isTemplate(cfg.Action) && cfg.Action != "" && cfg.Action != ActionCreate && cfg.Action != ActionClose {
+		return errors.New("opsgenie action must be 'create', 'close' or a template rendering one of them")
+	}
+	if !isTemplate(cfg.Priority) && cfg.Priority != "" && !validPriority(cfg.Priority) {
+		return errors.New("opsgenie priority must be P1 to P5 or a template rendering one")
+	}
+	if cfg.Action != "" && cfg.Action != ActionCreate && cfg.Alias == "" {
+		return errors.New("alias is required to close opsgenie alerts")
+	}
+	return nil
+}
+
+func isTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+func validPriority(p string) bool {
+	switch p {
+	case "P1", "P2", "P3", "P4", "P5":
+		return true
+	}
+	return false
+}
+
+// NewRequest renders payload into a create or close request for the config,
+// authenticated with the API key.
+func NewRequest(ctx context.Context, cfg Config, apiKey string, payload json.RawMessage, maxBytes int) (*http.Request, error) {
+	action, err := render(cfg.Action, payload, ActionCreate)
+	if err != nil {
+		return nil, fmt.Errorf("render action: %w", err)
+	}
+	alias, err := render(cfg.Alias, payload, "")
+	if err != nil {
+		return nil, fmt.Errorf("render alias: %w", err)
+	}
+	alias = truncate(alias, maxAlias)
+
+	base := USBaseURL
+	if cfg.Region == "eu" {
+		base = EUBaseURL
+	}
+
+	var target string
+	var body map[string]any
+	switch action {
+	case ActionCreate:
+		message, err := render(cfg.Message, payload, DefaultMessage)
+		if err != nil {
+			return nil, fmt.Errorf("render message: %w", err)
+		}
+		priority, err := render(cfg.Priority, payload, "")
+		if err != nil {
+			return nil, fmt.Errorf("render priority: %w", err)
+		}
+		if priority != "" && !validPriority(priority) {
+			return nil, fmt.Errorf("opsgenie priority must be P1 to P5, got %q", priority)
+		}
+		target = base + "/v2/alerts"
+		body = map[string]any{
+			"message":     truncate(message, maxMessage),
+			"description": truncate(indent(payload), maxDescription),
+			"source":      "nitrohook",
+		}
+		if alias != "" {
+			body["alias"] = alias
+		}
+		if priority != "" {
+			body["priority"] = priority
+		}
+		if len(cfg.Tags) > 0 {
+			body["tags"] = cfg.Tags
+		}
+	case ActionClose:
+		if alias == "" {
+			return nil, errors.New("alias rendered empty; can't close an opsgenie alert without one")
+		}
+		target = base + "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		body = map[string]any{"source": "nitrohook", "note": "Closed by delivery"}
+	default:
+		return nil, fmt.Errorf("opsgenie action must be 'create' or 'close', got %q", action)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal opsgenie request: %w", err)
+	}
+	if len(b) > maxBytes {
+		return nil, reqtemplate.ErrTooLarge
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("build opsgenie request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+apiKey)
+	return req, nil
+}
+
+// render renders a template field, trimmed, or returns fallback when unset.
+func render(text string, payload json.RawMessage, fallback string) (string, error) {
+	if text == "" {
+		return fallback, nil
+	}
+	out, err := reqtemplate.Body(text, payload, maxDescription)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func indent(payload json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		return string(payload)
+	}
+	return buf.String()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// Don't split a multi-byte rune
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
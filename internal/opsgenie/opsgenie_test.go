@@ -0,0 +1,84 @@
+package opsgenie
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Config{Action: `{{if eq .status "resolved"}}close{{else}}create{{end}}`, Alias: "{{.id}}", Priority: "P2"}
+	if err := Validate(valid, "key"); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	cases := map[string]struct {
+		cfg    Config
+		secret string
+	}{
+		"no key":          {valid, ""},
+		"bad region":      {Config{Region: "apac"}, "key"},
+		"bad action":      {Config{Action: "ack"}, "key"},
+		"bad priority":    {Config{Priority: "urgent"}, "key"},
+		"close w/o alias": {Config{Action: "close"}, "key"},
+		"bad template":    {Config{Message: "{{.x"}, "key"},
+	}
+	for name, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNewRequest_Create(t *testing.T) {
+	cfg := Config{Region: "eu", Message: "{{.title}}", Alias: "inc-{{.id}}", Priority: "{{.sev}}", Tags: []string{"relay"}}
+	req, err := NewRequest(context.Background(), cfg, "k1", []byte(`{"id":7,"title":"Disk full","sev":"P1"}`), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != EUBaseURL+"/v2/alerts" {
+		t.Fatalf("unexpected URL %s", req.URL)
+	}
+	if req.Header.Get("Authorization") != "GenieKey k1" {
+		t.Fatalf("unexpected auth header %q", req.Header.Get("Authorization"))
+	}
+	var body map[string]any
+	b, _ := io.ReadAll(req.Body)
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["message"] != "Disk full" || body["alias"] != "inc-7" || body["priority"] != "P1" {
+		t.Fatalf("unexpected body %s", b)
+	}
+	if !strings.Contains(body["description"].(string), `"title": "Disk full"`) {
+		t.Fatalf("expected payload in description, got %v", body["description"])
+	}
+}
+
+func TestNewRequest_Close(t *testing.T) {
+	cfg := Config{Action: `{{if eq .status "resolved"}}close{{else}}create{{end}}`, Alias: "inc/{{.id}}"}
+	req, err := NewRequest(context.Background(), cfg, "k1", []byte(`{"id":7,"status":"resolved"}`), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != USBaseURL+"/v2/alerts/inc%2F7/close?identifierType=alias" {
+		t.Fatalf("unexpected URL %s", req.URL)
+	}
+}
+
+func TestNewRequest_RenderedPriorityChecked(t *testing.T) {
+	cfg := Config{Priority: "{{.sev}}"}
+	if _, err := NewRequest(context.Background(), cfg, "k1", []byte(`{"sev":"high"}`), 1<<20); err == nil {
+		t.Fatal("expected error for invalid rendered priority")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "h" {
+		t.Fatalf("expected rune-safe truncation, got %q", got)
+	}
+	if got := truncate("abc", 5); got != "abc" {
+		t.Fatalf("expected short string unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/ssrf"
+)
+
+// IP families an Egress can prefer.
+const (
+	PreferIPv4 = "ipv4"
+	PreferIPv6 = "ipv6"
+)
+
+// Egress pins where outbound connections originate, for targets that
+// allowlist the relay's address. The zero Egress dials as the OS chooses.
+type Egress struct {
+	// LocalAddr is the source address connections bind to; targets are
+	// only dialed over its family.
+	LocalAddr netip.Addr
+	// Interface binds each connection to an address of the named
+	// interface in the target address's family.
+	Interface string
+	// Prefer is PreferIPv4 or PreferIPv6 to try the target's addresses of
+	// that family first, or "" for resolver order.
+	Prefer string
+}
+
+// ParseEgress validates egress settings as given in config. localAddr and
+// iface are mutually exclusive.
+func ParseEgress(localAddr, iface, prefer string) (Egress, error) {
+	var e Egress
+	if localAddr != "" && iface != "" {
+		return e, errors.New("set a local address or an interface, not both")
+	}
+	if localAddr != "" {
+		ip, err := netip.ParseAddr(localAddr)
+		if err != nil {
+			return e, fmt.Errorf("invalid local address %q", localAddr)
+		}
+		e.LocalAddr = ip.Unmap()
+	}
+	if iface != "" {
+		if _, err := net.InterfaceByName(iface); err != nil {
+			return e, fmt.Errorf("find interface %q: %w", iface, err)
+		}
+		e.Interface = iface
+	}
+	switch prefer {
+	case "", PreferIPv4, PreferIPv6:
+		e.Prefer = prefer
+	default:
+		return e, fmt.Errorf("ip preference must be %s or %s, got %q", PreferIPv4, PreferIPv6, prefer)
+	}
+	return e, nil
+}
+
+func (e Egress) isZero() bool {
+	return e == Egress{}
+}
+
+// dialContext returns a DialContext that resolves the target itself so it
+// can order addresses by family and bind each attempt to a matching local
+// address. control is applied to every connection.
+func (e Egress) dialContext(control func(network, address string, c syscall.RawConn) error) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		port, err := net.LookupPort(network, portStr)
+		if err != nil {
+			return nil, err
+		}
+		var targets []netip.Addr
+		if ip, err := netip.ParseAddr(host); err == nil {
+			targets = []netip.Addr{ip}
+		} else if targets, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil {
+			return nil, err
+		}
+		var locals []netip.Addr
+		switch {
+		case e.LocalAddr.IsValid():
+			locals = []netip.Addr{e.LocalAddr}
+		case e.Interface != "":
+			if locals, err = interfaceAddrs(e.Interface); err != nil {
+				return nil, err
+			}
+		}
+
+		lastErr := fmt.Errorf("no address of %s reachable from the egress address", host)
+		for _, target := range preferFamily(targets, e.Prefer) {
+			d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: control}
+			if locals != nil {
+				local, ok := sameFamily(target, locals)
+				if !ok {
+					continue
+				}
+				d.LocalAddr = &net.TCPAddr{IP: local.AsSlice()}
+			}
+			conn, err := d.DialContext(ctx, network, netip.AddrPortFrom(target.Unmap(), uint16(port)).String())
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, lastErr
+	}
+}
+
+func interfaceAddrs(name string) ([]netip.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("find interface %q: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("list addresses of %q: %w", name, err)
+	}
+	var out []netip.Addr
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok {
+			if ip, ok := netip.AddrFromSlice(n.IP); ok && !ip.Unmap().IsLinkLocalUnicast() {
+				out = append(out, ip.Unmap())
+			}
+		}
+	}
+	return out, nil
+}
+
+// preferFamily orders addrs with the preferred family first, keeping the
+// resolver's order within each family.
+func preferFamily(addrs []netip.Addr, prefer string) []netip.Addr {
+	out := slices.Clone(addrs)
+	if prefer == "" {
+		return out
+	}
+	want6 := prefer == PreferIPv6
+	slices.SortStableFunc(out, func(a, b netip.Addr) int {
+		a6, b6 := !a.Unmap().Is4(), !b.Unmap().Is4()
+		switch {
+		case a6 == b6:
+			return 0
+		case a6 == want6:
+			return -1
+		default:
+			return 1
+		}
+	})
+	return out
+}
+
+// sameFamily returns the first of locals in target's family.
+func sameFamily(target netip.Addr, locals []netip.Addr) (netip.Addr, bool) {
+	is4 := target.Unmap().Is4()
+	for _, l := range locals {
+		if l.Is4() == is4 {
+			return l, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// guardControl is the guard's dial check, or nil without a guard.
+func guardControl(g *ssrf.Guard) func(network, address string, c syscall.RawConn) error {
+	if g == nil {
+		return nil
+	}
+	return g.Control
+}
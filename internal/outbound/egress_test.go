@@ -0,0 +1,79 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestParseEgress(t *testing.T) {
+	e, err := ParseEgress("203.0.113.7", "", PreferIPv6)
+	if err != nil || e.LocalAddr != netip.MustParseAddr("203.0.113.7") || e.Prefer != PreferIPv6 {
+		t.Fatalf("unexpected egress %+v, %v", e, err)
+	}
+	for _, tc := range [][3]string{
+		{"not-an-ip", "", ""},
+		{"203.0.113.7", "eth0", ""},
+		{"", "", "ipv5"},
+		{"", "no-such-interface0", ""},
+	} {
+		if _, err := ParseEgress(tc[0], tc[1], tc[2]); err == nil {
+			t.Errorf("expected an error for %v", tc)
+		}
+	}
+	if e, err := ParseEgress("", "", ""); err != nil || !e.isZero() {
+		t.Fatalf("expected a zero egress, got %+v, %v", e, err)
+	}
+}
+
+func TestPreferFamily(t *testing.T) {
+	v4a, v6, v4b := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("192.0.2.2")
+	addrs := []netip.Addr{v4a, v6, v4b}
+	if got := preferFamily(addrs, PreferIPv6); got[0] != v6 || got[1] != v4a || got[2] != v4b {
+		t.Fatalf("unexpected ipv6-first order %v", got)
+	}
+	if got := preferFamily(addrs, PreferIPv4); got[0] != v4a || got[1] != v4b || got[2] != v6 {
+		t.Fatalf("unexpected ipv4-first order %v", got)
+	}
+	if got := preferFamily(addrs, ""); got[1] != v6 {
+		t.Fatalf("expected resolver order kept, got %v", got)
+	}
+}
+
+func TestSameFamily(t *testing.T) {
+	locals := []netip.Addr{netip.MustParseAddr("2001:db8::9"), netip.MustParseAddr("198.51.100.9")}
+	if l, ok := sameFamily(netip.MustParseAddr("192.0.2.1"), locals); !ok || l != locals[1] {
+		t.Fatalf("expected the ipv4 local address, got %v", l)
+	}
+	if _, ok := sameFamily(netip.MustParseAddr("192.0.2.1"), locals[:1]); ok {
+		t.Fatal("expected no ipv4 local address")
+	}
+}
+
+func TestEgressDial(t *testing.T) {
+	var remote string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	}))
+	defer srv.Close()
+
+	e := Egress{LocalAddr: netip.MustParseAddr("127.0.0.1")}
+	client := &http.Client{Timeout: time.Second, Transport: newTransport(nil, false, nil, e)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if host, _, _ := net.SplitHostPort(remote); host != "127.0.0.1" {
+		t.Fatalf("expected the request from 127.0.0.1, got %s", remote)
+	}
+
+	e = Egress{LocalAddr: netip.MustParseAddr("::1")}
+	if _, err := e.dialContext(nil)(context.Background(), "tcp", srv.Listener.Addr().String()); err == nil {
+		t.Fatal("expected an ipv4 target to be unreachable from an ipv6 local address")
+	}
+}
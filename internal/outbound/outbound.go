@@ -0,0 +1,245 @@
+// Package outbound builds the HTTP clients webhook requests are sent with,
+// applying the egress proxy and source address, per-action TLS settings and
+// SSRF guard.
+package outbound
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/clienttls"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/proxy"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
+)
+
+// SignatureHeader carries the HMAC of the request body when the action has a
+// signing secret.
+const SignatureHeader = "X-Webhook-Signature-256"
+
+// Clients hands out HTTP clients for actions' webhook requests. Actions with
+// their own proxy, client certificate or CA bundle get a cached client, so
+// connections to the target are reused; it is rebuilt when their settings
+// change. The rest share one client.
+type Clients struct {
+	base *http.Client
+	// proxy is the default egress proxy; nil connects directly.
+	proxy *url.URL
+	// guard keeps requests away from internal addresses; nil allows them.
+	guard *ssrf.Guard
+	// egress pins the local address connections originate from.
+	egress Egress
+	// secrets opens sealed client keys.
+	secrets *encryption.Cipher
+
+	mu      sync.Mutex
+	clients map[uuid.UUID]actionClient
+	// h2Clients are the gRPC clients, kept apart since they speak only
+	// HTTP/2.
+	h2Clients map[uuid.UUID]actionClient
+}
+
+type actionClient struct {
+	// sum identifies the settings the client was built from.
+	sum    [sha256.Size]byte
+	client *http.Client
+}
+
+// NewClients creates a client set. Any of proxyURL, guard and secrets may be
+// nil.
+func NewClients(timeout time.Duration, proxyURL *url.URL, guard *ssrf.Guard, secrets *encryption.Cipher, egress Egress) *Clients {
+	c := &Clients{
+		base:    &http.Client{Timeout: timeout, Transport: newTransport(proxyURL, false, guard, egress)},
+		proxy:   proxyURL,
+		guard:   guard,
+		secrets: secrets,
+		egress:  egress,
+
+		clients:   map[uuid.UUID]actionClient{},
+		h2Clients: map[uuid.UUID]actionClient{},
+	}
+	if proxyURL != nil {
+		c.base.CheckRedirect = c.checkRedirect
+	}
+	return c
+}
+
+// Guard returns the SSRF guard, for checking URLs before a request.
+func (c *Clients) Guard() *ssrf.Guard {
+	return c.guard
+}
+
+// DirectTransport returns a transport that skips the proxy but keeps the
+// guard and egress address, for requests such as target verification.
+func (c *Clients) DirectTransport() *http.Transport {
+	return newTransport(nil, false, c.guard, c.egress)
+}
+
+// DialContext connects directly, refusing addresses the guard blocks, from
+// the egress address; for integrations that don't speak HTTP.
+func (c *Clients) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if !c.egress.isZero() {
+		return c.egress.dialContext(guardControl(c.guard))(ctx, network, address)
+	}
+	d := net.Dialer{Control: guardControl(c.guard)}
+	return d.DialContext(ctx, network, address)
+}
+
+// Timeout returns the delivery timeout the clients are built with.
+func (c *Clients) Timeout() time.Duration {
+	return c.base.Timeout
+}
+
+// newTransport returns a transport that connects through proxyURL, if set,
+// or directly, refusing addresses the guard blocks. The operator's proxy is
+// exempt from the guard since it is typically internal; an action's own
+// proxy (guardProxy) is not. Targets behind a proxy are checked by URL
+// before dispatch and on redirects instead. Connections, to the proxy or
+// the target, originate from egress.
+func newTransport(proxyURL *url.URL, guardProxy bool, guard *ssrf.Guard, egress Egress) *http.Transport {
+	if proxyURL == nil || guardProxy {
+		t := guard.Transport()
+		if !egress.isZero() {
+			t.DialContext = egress.dialContext(guardControl(guard))
+		}
+		if proxyURL != nil {
+			t.Proxy = http.ProxyURL(proxyURL)
+		}
+		return t
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(proxyURL)
+	if !egress.isZero() {
+		t.DialContext = egress.dialContext(nil)
+	}
+	return t
+}
+
+// checkRedirect applies the guard to redirects followed through a proxy,
+// which resolves and dials the new target itself.
+func (c *Clients) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return c.guard.CheckURL(req.Context(), req.URL.String())
+}
+
+// For returns the HTTP client for the action's requests.
+func (c *Clients) For(a *model.Action) (*http.Client, error) {
+	if a.TLSClientCert == nil && a.TLSCABundle == nil && a.ProxyURL == nil {
+		return c.base, nil
+	}
+	return c.cached(c.clients, a, "", c.base.Timeout, func(transport *http.Transport, _ *url.URL) error {
+		cfg, err := c.tlsConfig(a)
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = cfg
+		return nil
+	})
+}
+
+// cached returns the client cached for the action in clients, keyed on its
+// TLS and proxy settings and variant, or builds one with a transport for its
+// proxy that setup completes.
+func (c *Clients) cached(clients map[uuid.UUID]actionClient, a *model.Action, variant string, timeout time.Duration, setup func(*http.Transport, *url.URL) error) (*http.Client, error) {
+	var cert, sealedKey, ca, proxyRaw string
+	if a.TLSClientCert != nil && a.TLSClientKey != nil {
+		cert, sealedKey = *a.TLSClientCert, *a.TLSClientKey
+	}
+	if a.TLSCABundle != nil {
+		ca = *a.TLSCABundle
+	}
+	if a.ProxyURL != nil {
+		proxyRaw = *a.ProxyURL
+	}
+	sum := sha256.Sum256([]byte(cert + "\x00" + sealedKey + "\x00" + ca + "\x00" + proxyRaw + "\x00" + variant))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := clients[a.ID]; ok && cached.sum == sum {
+		return cached.client, nil
+	}
+
+	proxyURL := c.proxy
+	if proxyRaw != "" {
+		var err error
+		if proxyURL, err = proxy.ParseOutbound(proxyRaw); err != nil {
+			return nil, err
+		}
+	}
+	transport := newTransport(proxyURL, proxyRaw != "", c.guard, c.egress)
+	if err := setup(transport, proxyURL); err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	if proxyURL != nil {
+		client.CheckRedirect = c.checkRedirect
+	}
+
+	if old, ok := clients[a.ID]; ok {
+		old.client.CloseIdleConnections()
+	}
+	clients[a.ID] = actionClient{sum: sum, client: client}
+	return client, nil
+}
+
+// tlsConfig returns the action's client certificate and CA bundle settings,
+// or nil if it has neither.
+func (c *Clients) tlsConfig(a *model.Action) (*tls.Config, error) {
+	var cert, key, ca string
+	if a.TLSClientCert != nil && a.TLSClientKey != nil {
+		var err error
+		if key, err = c.secrets.Open(*a.TLSClientKey); err != nil {
+			return nil, fmt.Errorf("open client key: %w", err)
+		}
+		cert = *a.TLSClientCert
+	}
+	if a.TLSCABundle != nil {
+		ca = *a.TLSCABundle
+	}
+	if cert == "" && ca == "" {
+		return nil, nil
+	}
+	return clienttls.Config(cert, key, ca)
+}
+
+// HTTP2Client returns the client for the action's gRPC calls. It speaks only
+// HTTP/2, over TLS with the action's client certificate and CA bundle, or
+// unencrypted when plaintext, through the same proxy as For. Unencrypted
+// HTTP/2 can't be tunnelled through an HTTP proxy, so plaintext calls
+// require a SOCKS5 proxy or none. Calls set their own deadline, so the
+// client has no timeout.
+func (c *Clients) HTTP2Client(a *model.Action, plaintext bool) (*http.Client, error) {
+	variant := "h2"
+	if plaintext {
+		variant = "h2c"
+	}
+	return c.cached(c.h2Clients, a, variant, 0, func(transport *http.Transport, proxyURL *url.URL) error {
+		transport.Protocols = new(http.Protocols)
+		if !plaintext {
+			cfg, err := c.tlsConfig(a)
+			if err != nil {
+				return err
+			}
+			transport.TLSClientConfig = cfg
+			transport.Protocols.SetHTTP2(true)
+			return nil
+		}
+		if proxyURL != nil && (proxyURL.Scheme == "http" || proxyURL.Scheme == "https") {
+			return errors.New("plaintext grpc can't be sent through an HTTP proxy; use grpcs or a SOCKS5 proxy")
+		}
+		transport.Protocols.SetUnencryptedHTTP2(true)
+		return nil
+	})
+}
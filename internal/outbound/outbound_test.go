@@ -0,0 +1,92 @@
+package outbound
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
+)
+
+func TestProxyGuard(t *testing.T) {
+	// A loopback proxy answering every request itself
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer proxySrv.Close()
+	proxyURL, _ := url.Parse(proxySrv.URL)
+
+	guard := ssrf.NewGuard(false)
+
+	// The operator's proxy is trusted even though it is internal
+	operator := NewClients(time.Second, proxyURL, guard, nil, Egress{})
+	resp, err := operator.base.Get("http://203.0.113.1/")
+	if err != nil {
+		t.Fatalf("expected the operator's proxy to be used, got %v", err)
+	}
+	resp.Body.Close()
+
+	// An action's own proxy isn't
+	clients := NewClients(time.Second, nil, guard, nil, Egress{})
+	raw := proxySrv.URL
+	client, err := clients.For(&model.Action{ID: uuid.New(), ProxyURL: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get("http://203.0.113.1/"); !errors.Is(err, ssrf.ErrBlocked) {
+		t.Fatalf("expected the action's internal proxy to be blocked, got %v", err)
+	}
+}
+
+func TestProxyRedirectGuard(t *testing.T) {
+	// The proxy relays a redirect to the metadata service
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest", http.StatusFound)
+	}))
+	defer proxySrv.Close()
+	proxyURL, _ := url.Parse(proxySrv.URL)
+
+	clients := NewClients(time.Second, proxyURL, ssrf.NewGuard(false), nil, Egress{})
+	if _, err := clients.base.Get("http://203.0.113.1/"); !errors.Is(err, ssrf.ErrBlocked) {
+		t.Fatalf("expected the redirect to be blocked, got %v", err)
+	}
+}
+
+func TestHTTP2ClientCached(t *testing.T) {
+	clients := NewClients(time.Second, nil, nil, nil, Egress{})
+	action := &model.Action{ID: uuid.New()}
+	first, err := clients.HTTP2Client(action, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := clients.HTTP2Client(action, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("expected the client to be reused")
+	}
+	plaintext, err := clients.HTTP2Client(action, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext == first {
+		t.Fatal("expected a separate plaintext client")
+	}
+
+	// Unencrypted HTTP/2 can't be tunnelled through an HTTP proxy
+	raw := "http://proxy.example.com:3128"
+	if _, err := clients.HTTP2Client(&model.Action{ID: uuid.New(), ProxyURL: &raw}, true); err == nil {
+		t.Fatal("expected plaintext through an HTTP proxy to be refused")
+	}
+	client, err := clients.HTTP2Client(&model.Action{ID: uuid.New(), ProxyURL: &raw}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Transport.(*http.Transport).Proxy == nil {
+		t.Fatal("expected the action's proxy to be used")
+	}
+}
@@ -0,0 +1,87 @@
+package outbound
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// Tracer times the phases of one webhook request through httptrace.
+type Tracer struct {
+	now func() time.Time
+
+	mu                   sync.Mutex
+	start                time.Time
+	dnsStart, dnsDone    time.Time
+	connStart, connDone  time.Time
+	tlsStart, tlsDone    time.Time
+	wroteAt, firstByteAt time.Time
+}
+
+// Trace returns req with a tracer attached. Read the timing with
+// Tracer.Timing once the response body has been read or the request failed.
+func Trace(req *http.Request) (*http.Request, *Tracer) {
+	t := &Tracer{now: time.Now}
+	t.start = t.now()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), t.clientTrace())), t
+}
+
+func (t *Tracer) clientTrace() *httptrace.ClientTrace {
+	// Dialing may race several addresses; the first start and the successful
+	// finish of each phase are kept.
+	mark := func(at *time.Time, first bool) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if first && !at.IsZero() {
+			return
+		}
+		*at = t.now()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&t.dnsStart, true) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { mark(&t.dnsDone, false) },
+		ConnectStart: func(string, string) {
+			mark(&t.connStart, true)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				mark(&t.connDone, false)
+			}
+		},
+		TLSHandshakeStart: func() { mark(&t.tlsStart, true) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				mark(&t.tlsDone, false)
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&t.wroteAt, false) },
+		GotFirstResponseByte: func() { mark(&t.firstByteAt, false) },
+	}
+}
+
+// Timing returns the phases measured so far, with the total running until
+// now.
+func (t *Tracer) Timing() *model.AttemptTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &model.AttemptTiming{
+		DNSMs:     phaseMs(t.dnsStart, t.dnsDone),
+		ConnectMs: phaseMs(t.connStart, t.connDone),
+		TLSMs:     phaseMs(t.tlsStart, t.tlsDone),
+		TTFBMs:    phaseMs(t.wroteAt, t.firstByteAt),
+		TotalMs:   phaseMs(t.start, t.now()),
+	}
+}
+
+// phaseMs is the length of a phase, or nil if it didn't start and finish.
+func phaseMs(start, end time.Time) *int {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return nil
+	}
+	ms := int(end.Sub(start).Milliseconds())
+	return &ms
+}
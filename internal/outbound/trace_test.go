@@ -0,0 +1,47 @@
+package outbound
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTraceTimesPhases(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	client := srv.Client()
+
+	for i, fresh := range []bool{true, false} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, tracer := Trace(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		timing := tracer.Timing()
+		if timing.TTFBMs == nil || *timing.TTFBMs < 20 {
+			t.Errorf("request %d: ttfb = %v, want >= 20ms", i, timing.TTFBMs)
+		}
+		if timing.TotalMs == nil || *timing.TotalMs < *timing.TTFBMs {
+			t.Errorf("request %d: total = %v, want >= ttfb", i, timing.TotalMs)
+		}
+		// The second request reuses the pooled connection
+		if (timing.ConnectMs != nil) != fresh || (timing.TLSMs != nil) != fresh {
+			t.Errorf("request %d: connect = %v, tls = %v, fresh connection = %v", i, timing.ConnectMs, timing.TLSMs, fresh)
+		}
+		if timing.DNSMs != nil {
+			t.Errorf("request %d: dns = %v for an IP target", i, *timing.DNSMs)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package pgsink inserts deliveries into a table in a user's own Postgres
+// database, one row per delivery over a short-lived connection.
+package pgsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// identPattern is what table and column names may look like. They are
+// quoted in the statement, so they're case sensitive.
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// Config is a Postgres action's config; the DSN, with its password, is the
+// action's secret. Table is [schema.]table and Column takes the payload,
+// typically jsonb. DeliveryIDColumn and EventTypeColumn optionally take the
+// delivery's ID and event type. IgnoreConflicts adds ON CONFLICT DO NOTHING,
+// so a unique delivery ID column makes retries idempotent.
+type Config struct {
+	Table            string `json:"table"`
+	Column           string `json:"column"`
+	DeliveryIDColumn string `json:"delivery_id_column,omitempty"`
+	EventTypeColumn  string `json:"event_type_column,omitempty"`
+	IgnoreConflicts  bool   `json:"ignore_conflicts,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode postgres config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the DSN.
+func Validate(cfg Config, secret string) error {
+	if secret == "" {
+		return errors.New("secret is required: the database dsn")
+	}
+	// pgx errors can quote the DSN, password included.
+	if _, err := pgx.ParseConfig(secret); err != nil {
+		return errors.New("secret is not a valid postgres dsn")
+	}
+	if _, err := Statement(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Statement builds the INSERT for a config. Its parameters are the payload,
+// then the delivery ID and event type when those columns are set.
+func Statement(cfg Config) (string, error) {
+	parts := strings.Split(cfg.Table, ".")
+	if cfg.Table == "" || len(parts) > 2 {
+		return "", errors.New("table must be table or schema.table")
+	}
+	for _, p := range parts {
+		if !identPattern.MatchString(p) {
+			return "", fmt.Errorf("invalid table name %q", cfg.Table)
+		}
+	}
+	if cfg.Column == "" {
+		return "", errors.New("column is required")
+	}
+
+	columns := []string{cfg.Column}
+	for _, c := range []string{cfg.DeliveryIDColumn, cfg.EventTypeColumn} {
+		if c != "" {
+			columns = append(columns, c)
+		}
+	}
+	seen := map[string]bool{}
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, c := range columns {
+		if !identPattern.MatchString(c) {
+			return "", fmt.Errorf("invalid column name %q", c)
+		}
+		if seen[c] {
+			return "", fmt.Errorf("column %q is used twice", c)
+		}
+		seen[c] = true
+		quoted[i] = pgx.Identifier{c}.Sanitize()
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		pgx.Identifier(parts).Sanitize(), strings.Join(quoted, ", "), strings.Join(params, ", "))
+	if cfg.IgnoreConflicts {
+		stmt += " ON CONFLICT DO NOTHING"
+	}
+	return stmt, nil
+}
+
+// Row is the values inserted for a delivery.
+type Row struct {
+	Payload    json.RawMessage
+	DeliveryID string
+	EventType  *string
+}
+
+func (r Row) args(cfg Config) []any {
+	args := []any{string(r.Payload)}
+	if cfg.DeliveryIDColumn != "" {
+		args = append(args, r.DeliveryID)
+	}
+	if cfg.EventTypeColumn != "" {
+		args = append(args, r.EventType)
+	}
+	return args
+}
+
+// Dialer opens the connection to the database.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Insert connects with dsn through dial, inserts the row and returns the
+// command tag, such as "INSERT 0 1"; "INSERT 0 0" is a conflict ignored.
+func Insert(ctx context.Context, dial Dialer, cfg Config, dsn string, row Row) (string, error) {
+	stmt, err := Statement(cfg)
+	if err != nil {
+		return "", err
+	}
+	connCfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return "", errors.New("secret is not a valid postgres dsn")
+	}
+	connCfg.DialFunc = pgconn.DialFunc(dial)
+	// One statement per connection: skip the prepare round trip.
+	connCfg.DefaultQueryExecMode = pgx.QueryExecModeExec
+	if _, ok := connCfg.RuntimeParams["application_name"]; !ok {
+		connCfg.RuntimeParams["application_name"] = "nitrohook"
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, connCfg)
+	if err != nil {
+		return "", fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	tag, err := conn.Exec(ctx, stmt, row.args(cfg)...)
+	if err != nil {
+		return "", fmt.Errorf("insert into %s: %w", cfg.Table, err)
+	}
+	return tag.String(), nil
+}
+
+// transientClasses are the SQLSTATE classes worth retrying: connection
+// problems, serialization failures, exhausted resources, server shutdowns
+// and system errors.
+var transientClasses = map[string]bool{"08": true, "40": true, "53": true, "57": true, "58": true}
+
+// Permanent reports whether an Insert error is one the server returned that
+// retrying won't fix, such as a missing table, a type mismatch, a constraint
+// violation or failed authentication. Network errors aren't permanent.
+func Permanent(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || len(pgErr.Code) < 2 {
+		return false
+	}
+	return !transientClasses[pgErr.Code[:2]]
+}
@@ -0,0 +1,81 @@
+package pgsink
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestValidate(t *testing.T) {
+	const dsn = "postgres://relay:pw@warehouse.example.com:5432/staging?sslmode=require"
+	cases := []struct {
+		cfg    Config
+		secret string
+		ok     bool
+	}{
+		{Config{Table: "events", Column: "body"}, dsn, true},
+		{Config{Table: "staging.webhook_events", Column: "body", DeliveryIDColumn: "delivery_id", IgnoreConflicts: true}, dsn, true},
+		{Config{Table: "events", Column: "body"}, "", false},
+		{Config{Table: "events", Column: "body"}, "postgres://relay:pw@host:notaport/db", false},
+		{Config{Column: "body"}, dsn, false},
+		{Config{Table: "events"}, dsn, false},
+		{Config{Table: "a.b.c", Column: "body"}, dsn, false},
+		{Config{Table: `events"; DROP TABLE x; --`, Column: "body"}, dsn, false},
+		{Config{Table: "events", Column: "body", EventTypeColumn: "body"}, dsn, false},
+	}
+	for _, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v, %q) = %v, want ok %v", tc.cfg, tc.secret, err, tc.ok)
+		}
+	}
+}
+
+func TestStatement(t *testing.T) {
+	cases := []struct {
+		cfg  Config
+		want string
+		args int
+	}{
+		{Config{Table: "events", Column: "body"}, `INSERT INTO "events" ("body") VALUES ($1)`, 1},
+		{
+			Config{Table: "staging.Events", Column: "body", EventTypeColumn: "kind", IgnoreConflicts: true},
+			`INSERT INTO "staging"."Events" ("body", "kind") VALUES ($1, $2) ON CONFLICT DO NOTHING`, 2,
+		},
+		{
+			Config{Table: "events", Column: "body", DeliveryIDColumn: "id", EventTypeColumn: "kind"},
+			`INSERT INTO "events" ("body", "id", "kind") VALUES ($1, $2, $3)`, 3,
+		},
+	}
+	for _, tc := range cases {
+		got, err := Statement(tc.cfg)
+		if err != nil || got != tc.want {
+			t.Errorf("Statement(%+v) = %q, %v, want %q", tc.cfg, got, err, tc.want)
+		}
+		if n := len(Row{}.args(tc.cfg)); n != tc.args {
+			t.Errorf("Row.args(%+v) has %d values, want %d", tc.cfg, n, tc.args)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "42P01"}, true},  // undefined_table
+		{&pgconn.PgError{Code: "23505"}, true},  // unique_violation
+		{&pgconn.PgError{Code: "28P01"}, true},  // invalid_password
+		{&pgconn.PgError{Code: "40001"}, false}, // serialization_failure
+		{&pgconn.PgError{Code: "57P03"}, false}, // cannot_connect_now
+		{&pgconn.PgError{Code: "53300"}, false}, // too_many_connections
+		{fmt.Errorf("insert into events: %w", &pgconn.PgError{Code: "22P02"}), true},
+		{errors.New("dial tcp: connection refused"), false},
+	}
+	for _, tc := range cases {
+		if got := Permanent(tc.err); got != tc.want {
+			t.Errorf("Permanent(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package projection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+var (
+	ErrInvalidMode = errors.New("projection mode must be 'keep' or 'drop'")
+	ErrNoFields    = errors.New("projection must list at least one field")
+)
+
+// Empty reports whether p sets nothing; updating an action with an empty
+// projection clears it.
+func Empty(p *model.Projection) bool {
+	return p == nil || (p.Mode == "" && len(p.Fields) == 0)
+}
+
+// Validate checks that a projection has a known mode and well-formed paths.
+func Validate(p *model.Projection) error {
+	if p == nil {
+		return nil
+	}
+	if p.Mode != model.ProjectionKeep && p.Mode != model.ProjectionDrop {
+		return ErrInvalidMode
+	}
+	if len(p.Fields) == 0 {
+		return ErrNoFields
+	}
+	for _, f := range p.Fields {
+		if len(splitPath(f)) == 0 {
+			return fmt.Errorf("invalid projection field %q", f)
+		}
+	}
+	return nil
+}
+
+// Apply returns payload with the projection applied. Payloads that are not
+// JSON objects are returned unchanged.
+func Apply(payload json.RawMessage, p *model.Projection) (json.RawMessage, error) {
+	if p == nil || len(p.Fields) == 0 {
+		return payload, nil
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal(payload, &obj); err != nil || obj == nil {
+		return payload, nil
+	}
+
+	var out map[string]any
+	switch p.Mode {
+	case model.ProjectionKeep:
+		out = map[string]any{}
+		for _, f := range p.Fields {
+			keep(obj, out, splitPath(f))
+		}
+	case model.ProjectionDrop:
+		out = obj
+		for _, f := range p.Fields {
+			drop(out, splitPath(f))
+		}
+	default:
+		return nil, ErrInvalidMode
+	}
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("marshal projected payload: %w", err)
+	}
+	return b, nil
+}
+
+// ValidPath reports whether path is a well-formed field path.
+func ValidPath(path string) bool {
+	return len(splitPath(path)) > 0
+}
+
+// Lookup returns the scalar at path in payload as a string: strings as-is,
+// numbers and booleans in their JSON form. It reports false if the path is
+// missing or holds an object, array or null.
+func Lookup(payload json.RawMessage, path string) (string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", false
+	}
+	for _, part := range splitPath(path) {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[part]; !ok {
+			return "", false
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// Value returns the JSON value at path in payload, of any type. It reports
+// false if the path is missing.
+func Value(payload json.RawMessage, path string) (json.RawMessage, bool) {
+	parts := splitPath(path)
+	if len(parts) == 0 {
+		return nil, false
+	}
+	v := payload
+	for _, part := range parts {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(v, &obj); err != nil || obj == nil {
+			return nil, false
+		}
+		var ok bool
+		if v, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// splitPath turns "$.a.b" or "a.b" into ["a", "b"]. Empty segments make the
+// path invalid.
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil
+	}
+	parts := strings.Split(path, ".")
+	for _, part := range parts {
+		if part == "" {
+			return nil
+		}
+	}
+	return parts
+}
+
+// keep copies the value at path from src into dst, creating intermediate
+// objects as needed.
+func keep(src, dst map[string]any, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	v, ok := src[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		dst[path[0]] = v
+		return
+	}
+	child, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	next, ok := dst[path[0]].(map[string]any)
+	if !ok {
+		next = map[string]any{}
+		dst[path[0]] = next
+	}
+	keep(child, next, path[1:])
+}
+
+// drop deletes the value at path from obj.
+func drop(obj map[string]any, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	if len(path) == 1 {
+		delete(obj, path[0])
+		return
+	}
+	child, ok := obj[path[0]].(map[string]any)
+	if !ok {
+		return
+	}
+	drop(child, path[1:])
+}
@@ -0,0 +1,131 @@
+package projection
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+func TestApply_Keep(t *testing.T) {
+	payload := json.RawMessage(`{"id":1,"customer":{"email":"a@b.c","name":"Ann"},"secret":"x"}`)
+	p := &model.Projection{Mode: model.ProjectionKeep, Fields: []string{"$.id", "customer.name"}}
+
+	out, err := Apply(payload, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != `{"customer":{"name":"Ann"},"id":1}` {
+		t.Fatalf("unexpected projection: %s", out)
+	}
+}
+
+func TestApply_Drop(t *testing.T) {
+	payload := json.RawMessage(`{"id":1,"customer":{"email":"a@b.c","name":"Ann"}}`)
+	p := &model.Projection{Mode: model.ProjectionDrop, Fields: []string{"customer.email", "missing.path"}}
+
+	out, err := Apply(payload, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != `{"customer":{"name":"Ann"},"id":1}` {
+		t.Fatalf("unexpected projection: %s", out)
+	}
+}
+
+func TestApply_NilProjection(t *testing.T) {
+	payload := json.RawMessage(`{"id":1}`)
+	out, err := Apply(payload, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != string(payload) {
+		t.Fatalf("expected payload unchanged, got: %s", out)
+	}
+}
+
+func TestApply_NonObjectPayload(t *testing.T) {
+	payload := json.RawMessage(`[1,2,3]`)
+	p := &model.Projection{Mode: model.ProjectionKeep, Fields: []string{"id"}}
+
+	out, err := Apply(payload, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != string(payload) {
+		t.Fatalf("expected payload unchanged, got: %s", out)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(&model.Projection{Mode: "mask", Fields: []string{"a"}}); err != ErrInvalidMode {
+		t.Fatalf("expected ErrInvalidMode, got: %v", err)
+	}
+	if err := Validate(&model.Projection{Mode: model.ProjectionKeep}); err != ErrNoFields {
+		t.Fatalf("expected ErrNoFields, got: %v", err)
+	}
+	if err := Validate(&model.Projection{Mode: model.ProjectionKeep, Fields: []string{"a..b"}}); err == nil {
+		t.Fatal("expected error for empty path segment")
+	}
+	if err := Validate(&model.Projection{Mode: model.ProjectionDrop, Fields: []string{"$.a.b"}}); err != nil {
+		t.Fatalf("expected valid projection, got: %v", err)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	if !Empty(nil) || !Empty(&model.Projection{}) {
+		t.Fatal("expected nil and zero projections to be empty")
+	}
+	if Empty(&model.Projection{Mode: model.ProjectionKeep, Fields: []string{"a"}}) {
+		t.Fatal("expected projection with fields not to be empty")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	payload := json.RawMessage(`{"record":{"id":"r1","version":42,"live":true,"tags":["a"]},"gone":null}`)
+	cases := map[string]struct {
+		want string
+		ok   bool
+	}{
+		"$.record.id":      {"r1", true},
+		"record.version":   {"42", true},
+		"$.record.live":    {"true", true},
+		"$.record.tags":    {"", false},
+		"$.record":         {"", false},
+		"$.gone":           {"", false},
+		"$.record.missing": {"", false},
+		"$.record.id.deep": {"", false},
+	}
+	for path, tc := range cases {
+		got, ok := Lookup(payload, path)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", path, got, ok, tc.want, tc.ok)
+		}
+	}
+	if _, ok := Lookup(json.RawMessage(`not json`), "$.a"); ok {
+		t.Error("expected no value for invalid JSON")
+	}
+}
+
+func TestValue(t *testing.T) {
+	payload := json.RawMessage(`{"record":{"id":"r1","version":42,"tags":["a"],"meta":{"x":1}},"gone":null}`)
+	cases := map[string]struct {
+		want string
+		ok   bool
+	}{
+		"$.record.id":      {`"r1"`, true},
+		"record.version":   {`42`, true},
+		"$.record.tags":    {`["a"]`, true},
+		"$.record.meta":    {`{"x":1}`, true},
+		"$.gone":           {`null`, true},
+		"$.record.missing": {``, false},
+		"$.record.id.deep": {``, false},
+		"$":                {``, false},
+	}
+	for path, tc := range cases {
+		got, ok := Value(payload, path)
+		if string(got) != tc.want || ok != tc.ok {
+			t.Errorf("Value(%q) = %s, %v; want %s, %v", path, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package proxy decides which peers may speak for the original client through
+// forwarding headers, builds the public URLs clients reach us at, and parses
+// the proxies outbound requests egress through.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Trusted is a set of proxy addresses. The zero value trusts no one, so
+// forwarding headers are ignored.
+type Trusted struct {
+	prefixes []netip.Prefix
+}
+
+// Parse builds a Trusted set from IP addresses and CIDR ranges.
+func Parse(entries []string) (*Trusted, error) {
+	t := &Trusted{}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if !strings.Contains(e, "/") {
+			addr, err := netip.ParseAddr(e)
+			if err != nil {
+				return nil, fmt.Errorf("parse trusted proxy %q: %w", e, err)
+			}
+			t.prefixes = append(t.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(e)
+		if err != nil {
+			return nil, fmt.Errorf("parse trusted proxy %q: %w", e, err)
+		}
+		t.prefixes = append(t.prefixes, p.Masked())
+	}
+	return t, nil
+}
+
+// Contains reports whether remoteAddr ("host:port" or a bare IP) is a trusted
+// proxy.
+func (t *Trusted) Contains(remoteAddr string) bool {
+	if t == nil || len(t.prefixes) == 0 {
+		return false
+	}
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range t.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scheme returns "https" or "http" for the request as the client made it.
+// Forwarding headers are only honoured when the peer is trusted.
+func (t *Trusted) Scheme(r *http.Request) string {
+	if t.Contains(r.RemoteAddr) {
+		proto := forwarded(r.Header, "proto")
+		if proto == "" {
+			proto = lastValue(r.Header.Get("X-Forwarded-Proto"))
+		}
+		if proto = strings.ToLower(proto); proto == "https" || proto == "http" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client addressed. Forwarding headers are only
+// honoured when the peer is trusted.
+func (t *Trusted) Host(r *http.Request) string {
+	if t.Contains(r.RemoteAddr) {
+		if host := forwarded(r.Header, "host"); host != "" {
+			return host
+		}
+		if host := lastValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			return host
+		}
+	}
+	return r.Host
+}
+
+// forwarded returns a parameter of the last element of the RFC 7239
+// Forwarded header: the one appended by the proxy nearest to us, which is the
+// one we trust.
+func forwarded(h http.Header, param string) string {
+	values := h.Values("Forwarded")
+	if len(values) == 0 {
+		return ""
+	}
+	elements := strings.Split(values[len(values)-1], ",")
+	for _, pair := range strings.Split(elements[len(elements)-1], ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(k, param) {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
+
+// lastValue returns the last entry of a comma-separated header value.
+func lastValue(v string) string {
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// PublicURL builds externally visible URLs: from a configured base URL when
+// set, else from the request the client made.
+type PublicURL struct {
+	base    string
+	trusted *Trusted
+}
+
+// NewPublicURL validates base, which may be empty or an absolute http(s) URL,
+// optionally with a path prefix.
+func NewPublicURL(base string, trusted *Trusted) (*PublicURL, error) {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base != "" {
+		u, err := url.Parse(base)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("public base URL %q must be an absolute http(s) URL", base)
+		}
+		if u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("public base URL %q must not have a query or fragment", base)
+		}
+	}
+	return &PublicURL{base: base, trusted: trusted}, nil
+}
+
+// Base returns the base URL without a trailing slash. r may be nil outside a
+// request, in which case it is "" unless a base URL is configured.
+func (p *PublicURL) Base(r *http.Request) string {
+	if p.base != "" || r == nil {
+		return p.base
+	}
+	return p.trusted.Scheme(r) + "://" + p.trusted.Host(r)
+}
+
+// Webhook returns the ingest URL of a source.
+func (p *PublicURL) Webhook(r *http.Request, slug string) string {
+	base := p.Base(r)
+	if base == "" {
+		return ""
+	}
+	return base + "/webhooks/" + url.PathEscape(slug)
+}
+
+// ParseOutbound parses the URL of an HTTP, HTTPS or SOCKS5 proxy for outbound
+// requests.
+func ParseOutbound(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy URL scheme must be http, https, socks5 or socks5h")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL must include a host")
+	}
+	return u, nil
+}
@@ -0,0 +1,192 @@
+// Package redispub publishes deliveries to a user's own Redis server, either
+// with PUBLISH to a channel or XADD to a stream.
+package redispub
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// ReservedStream is the relay's own delivery stream, which actions may not
+// write to.
+const ReservedStream = "deliveries"
+
+// maxTarget bounds a rendered channel or stream name, in bytes.
+const maxTarget = 1024
+
+// transientErrors are the Redis error prefixes worth retrying: a server
+// loading, busy, out of memory, read-only or mid-failover.
+var transientErrors = []string{"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "READONLY", "OOM", "ERR max number of clients"}
+
+// Config is a Redis action's config. URL names the server as
+// redis[s]://[user@]host[:port][/db]; the password is the action's secret.
+// Exactly one of Channel and Stream is set, each a reqtemplate over the
+// payload. MaxLen trims the stream to about that many entries.
+type Config struct {
+	URL     string `json:"url"`
+	Channel string `json:"channel,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+	MaxLen  int64  `json:"max_len,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode redis config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the password; servers without
+// auth take none.
+func Validate(cfg Config, secret string) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+		return errors.New("url must be redis[s]://[user@]host[:port][/db]")
+	}
+	if _, ok := u.User.Password(); ok {
+		return errors.New("put the password in the secret, not the url")
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if _, err := strconv.Atoi(db); err != nil {
+			return fmt.Errorf("invalid database number %q", db)
+		}
+	}
+	if u.RawQuery != "" {
+		return errors.New("url can't have a query")
+	}
+	switch {
+	case cfg.Channel == "" && cfg.Stream == "":
+		return errors.New("one of channel or stream is required")
+	case cfg.Channel != "" && cfg.Stream != "":
+		return errors.New("set channel or stream, not both")
+	}
+	if cfg.MaxLen < 0 || (cfg.MaxLen > 0 && cfg.Stream == "") {
+		return errors.New("max_len must be positive and only applies to streams")
+	}
+	if cfg.Stream == ReservedStream {
+		return fmt.Errorf("stream %q is reserved for the relay", ReservedStream)
+	}
+	if _, err := reqtemplate.Parse(cfg.Channel + cfg.Stream); err != nil {
+		return fmt.Errorf("invalid channel or stream template: %w", err)
+	}
+	return nil
+}
+
+// Target renders the channel or stream name for payload.
+func Target(cfg Config, payload json.RawMessage) (string, error) {
+	text := cfg.Channel + cfg.Stream
+	out, err := reqtemplate.Body(text, payload, maxTarget)
+	if err != nil {
+		return "", fmt.Errorf("render channel or stream: %w", err)
+	}
+	target := strings.TrimSpace(string(out))
+	switch {
+	case target == "":
+		return "", errors.New("channel or stream rendered empty")
+	case cfg.Stream != "" && target == ReservedStream:
+		return "", fmt.Errorf("stream %q is reserved for the relay", ReservedStream)
+	}
+	return target, nil
+}
+
+// Message is what a delivery publishes: the payload alone on a channel, or
+// as an entry with the delivery's ID and event type on a stream.
+type Message struct {
+	DeliveryID string
+	EventType  string
+	Payload    []byte
+}
+
+func (m Message) values() []any {
+	values := []any{"delivery_id", m.DeliveryID, "payload", m.Payload}
+	if m.EventType != "" {
+		values = append(values, "event_type", m.EventType)
+	}
+	return values
+}
+
+// Dialer opens connections to the server.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Publish connects through dial, publishes msg to target and describes the
+// outcome: how many subscribers received it, or the stream entry's ID.
+func Publish(ctx context.Context, dial Dialer, cfg Config, password, target string, msg Message) (string, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return "", fmt.Errorf("parse redis url: %w", err)
+	}
+	opts.Password = password
+	// The worker retries failed attempts itself
+	opts.MaxRetries = -1
+	opts.DialerRetries = 1
+	opts.PoolSize = 1
+	tlsConfig := opts.TLSConfig
+	opts.Dialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil || tlsConfig == nil {
+			return conn, err
+		}
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	if cfg.Channel != "" {
+		n, err := client.Publish(ctx, target, msg.Payload).Result()
+		if err != nil {
+			return "", fmt.Errorf("redis publish: %w", err)
+		}
+		return fmt.Sprintf("published to %d subscribers", n), nil
+	}
+	args := &redis.XAddArgs{Stream: target, Values: msg.values()}
+	if cfg.MaxLen > 0 {
+		args.MaxLen = cfg.MaxLen
+		args.Approx = true
+	}
+	id, err := client.XAdd(ctx, args).Result()
+	if err != nil {
+		return "", fmt.Errorf("redis xadd: %w", err)
+	}
+	return "added entry " + id, nil
+}
+
+// Permanent reports whether a Publish error is one the server returned
+// that retrying won't fix, such as bad credentials or a key of the wrong
+// type. Connection errors aren't permanent.
+func Permanent(err error) bool {
+	var rerr redis.Error
+	if !errors.As(err, &rerr) {
+		return false
+	}
+	msg := rerr.Error()
+	for _, prefix := range transientErrors {
+		if strings.HasPrefix(msg, prefix) {
+			return false
+		}
+	}
+	return true
+}
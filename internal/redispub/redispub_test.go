@@ -0,0 +1,90 @@
+package redispub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		cfg    Config
+		secret string
+		ok     bool
+	}{
+		{Config{URL: "redis://cache.internal.example.com:6379", Channel: "events"}, "", true},
+		{Config{URL: "rediss://relay@cache.example.com/2", Stream: "webhooks:{{.type}}", MaxLen: 10000}, "pw", true},
+		{Config{URL: "redis://relay:pw@cache.example.com", Channel: "events"}, "", false},
+		{Config{URL: "http://cache.example.com", Channel: "events"}, "", false},
+		{Config{URL: "redis://cache.example.com/db", Channel: "events"}, "", false},
+		{Config{URL: "redis://cache.example.com"}, "", false},
+		{Config{URL: "redis://cache.example.com", Channel: "a", Stream: "b"}, "", false},
+		{Config{URL: "redis://cache.example.com", Channel: "a", MaxLen: 10}, "", false},
+		{Config{URL: "redis://cache.example.com", Stream: "deliveries"}, "", false},
+		{Config{URL: "redis://cache.example.com", Channel: "{{.x"}, "", false},
+	}
+	for _, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v, %q) = %v, want ok %v", tc.cfg, tc.secret, err, tc.ok)
+		}
+	}
+}
+
+func TestTarget(t *testing.T) {
+	payload := json.RawMessage(`{"type":"order.created","name":"deliveries"}`)
+	cases := []struct {
+		cfg  Config
+		want string
+		ok   bool
+	}{
+		{Config{Channel: "events"}, "events", true},
+		{Config{Stream: "webhooks:{{.type}}"}, "webhooks:order.created", true},
+		{Config{Channel: "{{.name}}"}, "deliveries", true},
+		{Config{Stream: "{{.name}}"}, "", false},
+		{Config{Channel: "{{.missing}}"}, "", false},
+		{Config{Channel: " {{if false}}x{{end}} "}, "", false},
+	}
+	for _, tc := range cases {
+		got, err := Target(tc.cfg, payload)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("Target(%+v) = %q, %v, want %q ok %v", tc.cfg, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestMessageValues(t *testing.T) {
+	got := fmt.Sprint(Message{DeliveryID: "d1", Payload: []byte(`{}`)}.values())
+	if want := fmt.Sprint([]any{"delivery_id", "d1", "payload", []byte(`{}`)}); got != want {
+		t.Errorf("values() = %s, want %s", got, want)
+	}
+	if n := len(Message{DeliveryID: "d1", EventType: "order.created"}.values()); n != 6 {
+		t.Errorf("values() with an event type has %d items, want 6", n)
+	}
+}
+
+// serverError is a reply error as go-redis returns it.
+type serverError string
+
+func (e serverError) Error() string { return string(e) }
+func (serverError) RedisError()     {}
+
+func TestPermanent(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{serverError("WRONGPASS invalid username-password pair or user is disabled."), true},
+		{serverError("NOPERM User relay has no permissions to run the 'xadd' command"), true},
+		{fmt.Errorf("redis xadd: %w", serverError("WRONGTYPE Operation against a key holding the wrong kind of value")), true},
+		{serverError("LOADING Redis is loading the dataset in memory"), false},
+		{serverError("READONLY You can't write against a read only replica."), false},
+		{serverError("ERR max number of clients reached"), false},
+		{errors.New("dial tcp 10.0.0.1:6379: connect: connection refused"), false},
+	}
+	for _, tc := range cases {
+		if got := Permanent(tc.err); got != tc.want {
+			t.Errorf("Permanent(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package reqtemplate renders the method, URL and body of outbound webhook
+// requests, and the bodies of other actions' messages, from Go templates over
+// the delivery payload.
+package reqtemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// ErrTooLarge is returned when a rendered body exceeds the size limit.
+var ErrTooLarge = errors.New("rendered body too large")
+
+// Methods lists the HTTP methods an action may send.
+var Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodGet}
+
+// ValidMethod reports whether m is one of Methods.
+func ValidMethod(m string) bool {
+	for _, v := range Methods {
+		if m == v {
+			return true
+		}
+	}
+	return false
+}
+
+var funcs = template.FuncMap{
+	// json encodes a value, e.g. {{json .items}} to embed a sub-object.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Parse compiles a template. The payload is its root ({{.user.id}}); a
+// missing key is an error rather than "<no value>".
+func Parse(text string) (*template.Template, error) {
+	t, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return t, nil
+}
+
+// Body renders a body template over payload, failing beyond maxBytes.
+func Body(text string, payload json.RawMessage, maxBytes int) ([]byte, error) {
+	out, err := render(text, payload, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("render body template: %w", err)
+	}
+	return out, nil
+}
+
+// ParseHTML compiles a template like Parse, but with html/template so
+// payload values are escaped for HTML.
+func ParseHTML(text string) (*htmltemplate.Template, error) {
+	t, err := htmltemplate.New("").Funcs(htmltemplate.FuncMap(funcs)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return t, nil
+}
+
+// HTML renders an HTML template over payload, failing beyond maxBytes.
+func HTML(text string, payload json.RawMessage, maxBytes int) ([]byte, error) {
+	t, err := ParseHTML(text)
+	if err != nil {
+		return nil, err
+	}
+	out, err := execute(t, payload, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("render html template: %w", err)
+	}
+	return out, nil
+}
+
+// URL renders a URL template over payload. Placeholders are inserted as is;
+// use {{urlquery .x}} for values that may need escaping. The result must
+// keep target's scheme and host, so the payload can only steer the path and
+// query.
+func URL(text, target string, payload json.RawMessage) (string, error) {
+	out, err := render(text, payload, 8192)
+	if err != nil {
+		return "", fmt.Errorf("render url template: %w", err)
+	}
+	s := strings.TrimSpace(string(out))
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("render url template: %q is not an http(s) URL", s)
+	}
+	t, err := url.Parse(target)
+	if err != nil || !sameOrigin(u, t) {
+		return "", fmt.Errorf("render url template: %q leaves the target's scheme and host", s)
+	}
+	return s, nil
+}
+
+// CheckURL reports whether a URL template spells out target's scheme and
+// host before its first placeholder, as URL requires of the result.
+func CheckURL(text, target string) error {
+	if _, err := Parse(text); err != nil {
+		return err
+	}
+	t, err := url.Parse(target)
+	if err != nil || t.Host == "" {
+		return errors.New("url_template needs a target_url")
+	}
+	origin := t.Scheme + "://" + t.Host
+	fixed, _, templated := strings.Cut(strings.TrimSpace(text), "{{")
+	if len(fixed) < len(origin) || !strings.EqualFold(fixed[:len(origin)], origin) {
+		return fmt.Errorf("url_template must start with %s", origin)
+	}
+	// The host must end where the origin does
+	rest := fixed[len(origin):]
+	if rest == "" && templated || rest != "" && rest[0] != '/' && rest[0] != '?' {
+		return fmt.Errorf("url_template may only template the path and query after %s", origin)
+	}
+	return nil
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// ContentType is the Content-Type for a rendered body: JSON when it parses
+// as JSON, plain text otherwise.
+func ContentType(body []byte) string {
+	if json.Valid(body) {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func render(text string, payload json.RawMessage, maxBytes int) ([]byte, error) {
+	t, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return execute(t, payload, maxBytes)
+}
+
+// execute runs a text or HTML template over the decoded payload.
+func execute(t interface {
+	Execute(io.Writer, any) error
+}, payload json.RawMessage, maxBytes int) ([]byte, error) {
+	var data any
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	var buf limitedBuffer
+	buf.max = maxBytes
+	if err := t.Execute(&buf, data); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return nil, ErrTooLarge
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// limitedBuffer stops a runaway template (a range over a large array) from
+// buffering more than max bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, ErrTooLarge
+	}
+	return b.Buffer.Write(p)
+}
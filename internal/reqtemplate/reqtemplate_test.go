@@ -0,0 +1,96 @@
+package reqtemplate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBody(t *testing.T) {
+	payload := []byte(`{"user":{"id":42,"name":"Ada"},"items":[1,2]}`)
+
+	got, err := Body(`{"text":"{{.user.name}} ({{.user.id}})","items":{{json .items}}}`, payload, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"text":"Ada (42)","items":[1,2]}` {
+		t.Fatalf("unexpected body %s", got)
+	}
+	if ContentType(got) != "application/json" {
+		t.Fatalf("expected JSON content type, got %q", ContentType(got))
+	}
+
+	if _, err := Body(`{{.missing}}`, payload, 1024); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+	if _, err := Body(`{{range .items}}{{$.user.name}}{{end}}`, payload, 4); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	payload := []byte(`{"repo":"a b","n":7}`)
+
+	got, err := URL(`https://example.com/repos/{{urlquery .repo}}/issues/{{.n}}`, "https://example.com/hook", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://example.com/repos/a+b/issues/7" {
+		t.Fatalf("unexpected url %q", got)
+	}
+
+	if _, err := URL(`{{.repo}}`, "https://example.com", payload); err == nil || !strings.Contains(err.Error(), "not an http(s) URL") {
+		t.Fatalf("expected invalid URL error, got %v", err)
+	}
+
+	host := []byte(`{"host":"169.254.169.254"}`)
+	if _, err := URL(`https://{{.host}}/latest`, "https://example.com", host); err == nil || !strings.Contains(err.Error(), "scheme and host") {
+		t.Fatalf("expected origin error, got %v", err)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	target := "https://example.com/hook"
+	for _, tmpl := range []string{
+		`https://example.com/repos/{{.n}}`,
+		`https://EXAMPLE.com?n={{.n}}`,
+		`https://example.com`,
+	} {
+		if err := CheckURL(tmpl, target); err != nil {
+			t.Errorf("CheckURL(%q) = %v", tmpl, err)
+		}
+	}
+	for _, tmpl := range []string{
+		`https://{{.host}}/x`,
+		`https://example.com{{.host}}/x`,
+		`https://example.com.{{.host}}/x`,
+		`https://example.com@evil.com/{{.n}}`,
+		`http://example.com/{{.n}}`,
+		`{{.url}}`,
+	} {
+		if err := CheckURL(tmpl, target); err == nil {
+			t.Errorf("CheckURL(%q) accepted", tmpl)
+		}
+	}
+	if err := CheckURL(`https://example.com/{{.n}}`, ""); err == nil {
+		t.Error("CheckURL without a target accepted")
+	}
+}
+
+func TestHTML(t *testing.T) {
+	payload := []byte(`{"name":"<Ada>"}`)
+
+	got, err := HTML(`<p>{{.name}}</p>`, payload, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `<p>&lt;Ada&gt;</p>` {
+		t.Fatalf("expected escaped value, got %s", got)
+	}
+	if _, err := HTML(`{{range $i := .name}}`, payload, 1024); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if _, err := HTML(`<p>{{.name}}</p>`, payload, 4); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package retryreason classifies why an attempt was scheduled for retry
+// into a small set of machine-readable codes for failure breakdowns.
+package retryreason
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Reason codes.
+const (
+	HTTP5xx           = "http_5xx"
+	HTTP4xx           = "http_4xx"
+	RateLimited       = "rate_limited"
+	Timeout           = "timeout"
+	ConnectionRefused = "connection_refused"
+	ConnectionError   = "connection_error"
+	DNS               = "dns"
+	TLS               = "tls"
+	ScriptError       = "script_error"
+	CircuitOpen       = "circuit_open"
+	Interrupted       = "interrupted"
+	// Capped and Deferred are attempts the worker held back: over the
+	// action's attempt cap, or outside its delivery window.
+	Capped   = "capped"
+	Deferred = "deferred"
+	// Manual is a retry requested through the API or portal.
+	Manual = "manual"
+	Other  = "other"
+)
+
+// httpStatus finds the status in messages such as "HTTP 503" or
+// "kinesis error: HTTP 500: ...", which every HTTP-based action uses.
+// Other integrations record non-HTTP codes (SMTP, AMQP) as the response
+// status, so that isn't used.
+var httpStatus = regexp.MustCompile(`\bHTTP (\d{3})\b`)
+
+// Classify returns the reason code for a failed attempt's error message.
+func Classify(errMsg string) string {
+	msg := strings.ToLower(errMsg)
+	switch {
+	case strings.HasPrefix(msg, "dispatch interrupted"):
+		return Interrupted
+	case strings.HasPrefix(msg, "circuit open"):
+		return CircuitOpen
+	case strings.HasPrefix(msg, "script "):
+		return ScriptError
+	}
+	if m := httpStatus.FindStringSubmatch(errMsg); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		switch {
+		case code == 429:
+			return RateLimited
+		case code >= 500:
+			return HTTP5xx
+		case code >= 400:
+			return HTTP4xx
+		}
+	}
+	switch {
+	case strings.Contains(msg, "rate limit") || strings.Contains(msg, "throttl"):
+		return RateLimited
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out") || strings.Contains(msg, "deadline exceeded"):
+		return Timeout
+	case strings.Contains(msg, "connection refused"):
+		return ConnectionRefused
+	case strings.Contains(msg, "no such host") || strings.Contains(msg, "server misbehaving"):
+		return DNS
+	case strings.Contains(msg, "tls:") || strings.Contains(msg, "x509:") || strings.Contains(msg, "tls handshake"):
+		return TLS
+	case strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe") || strings.Contains(msg, "eof") ||
+		strings.Contains(msg, "unreachable") || strings.Contains(msg, "no route to host"):
+		return ConnectionError
+	}
+	return Other
+}
@@ -0,0 +1,33 @@
+package retryreason
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"HTTP 503", HTTP5xx},
+		{"kinesis error: HTTP 500: InternalFailure: oops", HTTP5xx},
+		{"HTTP 404", HTTP4xx},
+		{"HTTP 429", RateLimited},
+		{"HTTP 504", HTTP5xx},
+		{`Post "https://example.com": context deadline exceeded (Client.Timeout exceeded while awaiting headers)`, Timeout},
+		{"dial tcp 10.0.0.1:443: i/o timeout", Timeout},
+		{"dial tcp 127.0.0.1:8080: connect: connection refused", ConnectionRefused},
+		{"dial tcp: lookup nope.example.com: no such host", DNS},
+		{"tls: failed to verify certificate: x509: certificate signed by unknown authority", TLS},
+		{"read tcp 1.2.3.4:5->6.7.8.9:443: read: connection reset by peer", ConnectionError},
+		{"script execution error: ReferenceError: x is not defined", ScriptError},
+		{"script execution timed out", ScriptError},
+		{"circuit open: 5 consecutive failures, next probe in 30s", CircuitOpen},
+		{"dispatch interrupted by worker shutdown", Interrupted},
+		{"smtp rcpt to a@example.com: 421 try later", Other},
+		{"amqp: broker nacked the message", Other},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.msg); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
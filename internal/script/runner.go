@@ -28,11 +28,36 @@ type ActionRef struct {
 	TargetURL string    `json:"target_url"`
 }
 
+// Metadata describes the delivery a script runs for. It is exposed to
+// scripts as event.meta.
+type Metadata struct {
+	DeliveryID uuid.UUID
+	SourceSlug string
+	ReceivedAt time.Time
+	// ActionID and AttemptNumber are only set for action scripts.
+	ActionID      uuid.UUID
+	AttemptNumber int
+}
+
+func (m Metadata) toJS() map[string]any {
+	meta := map[string]any{
+		"delivery_id": m.DeliveryID.String(),
+		"source":      m.SourceSlug,
+		"received_at": m.ReceivedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if m.AttemptNumber > 0 {
+		meta["action_id"] = m.ActionID.String()
+		meta["attempt"] = m.AttemptNumber
+	}
+	return meta
+}
+
 // TransformInput is the data passed to the transform function.
 type TransformInput struct {
 	Payload map[string]any    `json:"payload"`
 	Headers map[string]string `json:"headers"`
 	Actions []ActionRef       `json:"actions"`
+	Meta    Metadata          `json:"-"`
 }
 
 // TransformResult is the output of the transform function.
@@ -68,7 +93,12 @@ func Validate(scriptBody string) error {
 
 // Run executes the transform function with the given input.
 // Returns nil result with Dropped=true if the script returns null/undefined.
-func Run(scriptBody string, input TransformInput) (result *TransformResult, err error) {
+func Run(scriptBody string, input TransformInput) (*TransformResult, error) {
+	return RunWithTimeout(scriptBody, input, execTimeout)
+}
+
+// RunWithTimeout is Run with a caller-supplied execution timeout.
+func RunWithTimeout(scriptBody string, input TransformInput, timeout time.Duration) (result *TransformResult, err error) {
 	if len(scriptBody) > maxScriptSize {
 		return nil, ErrScriptTooLarge
 	}
@@ -90,7 +120,7 @@ func Run(scriptBody string, input TransformInput) (result *TransformResult, err
 	vm := goja.New()
 
 	// Set up timeout
-	timer := time.AfterFunc(execTimeout, func() {
+	timer := time.AfterFunc(timeout, func() {
 		vm.Interrupt("timeout")
 	})
 	defer timer.Stop()
@@ -123,6 +153,7 @@ func Run(scriptBody string, input TransformInput) (result *TransformResult, err
 		}
 	}
 	eventObj["actions"] = actionsForJS
+	eventObj["meta"] = input.Meta.toJS()
 
 	arg := vm.ToValue(eventObj)
 	ret, err := callable(goja.Undefined(), arg)
@@ -207,7 +238,13 @@ func ValidateAction(scriptBody string) error {
 
 // RunAction executes a per-action JS script's process(event) function.
 // Returns the result as a JSON string.
-func RunAction(scriptBody string, payload map[string]any, headers map[string]string) (result string, err error) {
+func RunAction(scriptBody string, payload map[string]any, headers map[string]string) (string, error) {
+	return RunActionWithTimeout(scriptBody, payload, headers, Metadata{}, execTimeout)
+}
+
+// RunActionWithTimeout is RunAction with delivery metadata and a
+// caller-supplied execution timeout.
+func RunActionWithTimeout(scriptBody string, payload map[string]any, headers map[string]string, meta Metadata, timeout time.Duration) (result string, err error) {
 	if len(scriptBody) > maxScriptSize {
 		return "", ErrScriptTooLarge
 	}
@@ -226,7 +263,7 @@ func RunAction(scriptBody string, payload map[string]any, headers map[string]str
 
 	vm := goja.New()
 
-	timer := time.AfterFunc(execTimeout, func() {
+	timer := time.AfterFunc(timeout, func() {
 		vm.Interrupt("timeout")
 	})
 	defer timer.Stop()
@@ -249,6 +286,7 @@ func RunAction(scriptBody string, payload map[string]any, headers map[string]str
 	eventObj := map[string]any{
 		"payload": payload,
 		"headers": headers,
+		"meta":    meta.toJS(),
 	}
 
 	arg := vm.ToValue(eventObj)
@@ -2,6 +2,7 @@ package script
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -266,6 +267,19 @@ func TestRunAction_Timeout(t *testing.T) {
 	}
 }
 
+func TestRunActionWithTimeout(t *testing.T) {
+	scriptBody := `function process(event) { while(true) {} }`
+
+	start := time.Now()
+	_, err := RunActionWithTimeout(scriptBody, map[string]any{}, map[string]string{}, Metadata{}, 50*time.Millisecond)
+	if err != ErrScriptTimeout {
+		t.Fatalf("expected ErrScriptTimeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= execTimeout {
+		t.Fatalf("expected custom timeout to apply, took %v", elapsed)
+	}
+}
+
 func TestRunAction_MissingProcess(t *testing.T) {
 	scriptBody := `function transform(event) { return event; }`
 
@@ -274,3 +288,45 @@ func TestRunAction_MissingProcess(t *testing.T) {
 		t.Fatalf("expected ErrNoProcess, got: %v", err)
 	}
 }
+
+func TestRun_Metadata(t *testing.T) {
+	scriptBody := `function transform(event) {
+		event.payload.trace = event.meta.delivery_id;
+		event.payload.source = event.meta.source;
+		event.payload.received = event.meta.received_at;
+		event.payload.has_attempt = "attempt" in event.meta;
+		return event;
+	}`
+
+	meta := Metadata{
+		DeliveryID: uuid.New(),
+		SourceSlug: "github",
+		ReceivedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	result, err := Run(scriptBody, TransformInput{Payload: map[string]any{}, Meta: meta})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Payload["trace"] != meta.DeliveryID.String() || result.Payload["source"] != "github" {
+		t.Fatalf("unexpected payload: %v", result.Payload)
+	}
+	if result.Payload["received"] != "2024-05-01T12:00:00Z" || result.Payload["has_attempt"] != false {
+		t.Fatalf("unexpected payload: %v", result.Payload)
+	}
+}
+
+func TestRunAction_Metadata(t *testing.T) {
+	scriptBody := `function process(event) {
+		return {attempt: event.meta.attempt, action: event.meta.action_id};
+	}`
+
+	actionID := uuid.New()
+	meta := Metadata{DeliveryID: uuid.New(), ActionID: actionID, AttemptNumber: 3}
+	result, err := RunActionWithTimeout(scriptBody, map[string]any{}, map[string]string{}, meta, execTimeout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"action":"` + actionID.String() + `","attempt":3}`; result != want {
+		t.Fatalf("expected %s, got: %s", want, result)
+	}
+}
@@ -0,0 +1,93 @@
+package scriptcheck
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/script"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+// Samples is how many recent payloads a source's schema is inferred from.
+const Samples = 100
+
+// budget bounds the total time spent running variants, so a slow script
+// doesn't hold up a save; the remaining variants are skipped.
+const budget = 3 * time.Second
+
+// Failure is a generated payload the transform threw on.
+type Failure struct {
+	Shape   string         `json:"shape"`
+	Error   string         `json:"error"`
+	Payload map[string]any `json:"payload"`
+}
+
+// Report is the outcome of running a transform against generated payloads.
+type Report struct {
+	Samples  int       `json:"samples"`
+	Variants int       `json:"variants"`
+	Passed   int       `json:"passed"`
+	Failures []Failure `json:"failures"`
+	// Skipped counts variants not run once the time budget ran out.
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// Check runs scriptBody against payloads generated from the schema of
+// payloads. A non-empty global transform runs first, as in the worker;
+// variants it drops or fails on aren't held against the source's script.
+func Check(scriptBody, global string, payloads []json.RawMessage, sourceSlug string, timeout time.Duration) Report {
+	s := Infer(payloads)
+	variants := s.Variants()
+	r := Report{Samples: s.Samples(), Variants: len(variants), Failures: []Failure{}}
+	deadline := time.Now().Add(budget)
+	for i, v := range variants {
+		if time.Now().After(deadline) {
+			r.Skipped = len(variants) - i
+			break
+		}
+		input := script.TransformInput{
+			Payload: clone(v.Payload).(map[string]any),
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Actions: []script.ActionRef{},
+			Meta:    script.Metadata{DeliveryID: uuid.New(), SourceSlug: sourceSlug, ReceivedAt: time.Now()},
+		}
+		if global != "" {
+			res, err := script.RunWithTimeout(global, input, timeout)
+			if err != nil || res.Dropped {
+				r.Passed++
+				continue
+			}
+			input.Payload, input.Headers, input.Actions = res.Payload, res.Headers, res.Actions
+		}
+		if _, err := script.RunWithTimeout(scriptBody, input, timeout); err != nil {
+			r.Failures = append(r.Failures, Failure{Shape: v.Shape, Error: err.Error(), Payload: v.Payload})
+			continue
+		}
+		r.Passed++
+	}
+	return r
+}
+
+// ForSource checks scriptBody against the source's last Samples payloads,
+// after the global transform if one is set, with the source's script
+// timeout resolved against the global limits.
+func ForSource(ctx context.Context, s *store.Store, src *model.Source, scriptBody string, limits model.Limits) (*Report, error) {
+	payloads, err := s.Deliveries.RecentPayloads(ctx, src.ID, Samples)
+	if err != nil {
+		return nil, err
+	}
+	global, err := s.Settings.Get(ctx, store.SettingGlobalTransform)
+	if err != nil {
+		return nil, err
+	}
+	g := ""
+	if global != nil {
+		g = *global
+	}
+	timeout := time.Duration(model.EffectiveLimits(limits, src).ScriptTimeoutMs) * time.Millisecond
+	r := Check(scriptBody, g, payloads, src.Slug, timeout)
+	return &r, nil
+}
@@ -0,0 +1,266 @@
+// Package scriptcheck infers a schema from a source's recent payloads,
+// generates payloads in the shapes it has seen and runs the source's
+// transform against them, so a script that breaks on an optional field or a
+// null is caught when it's saved rather than by live traffic.
+package scriptcheck
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// maxDepth bounds how deep inference descends into nested values.
+	maxDepth = 8
+	// MaxVariants caps the payloads generated from one schema.
+	MaxVariants = 50
+)
+
+// JSON value types, as named in variant descriptions.
+const (
+	typeObject  = "object"
+	typeArray   = "array"
+	typeString  = "string"
+	typeNumber  = "number"
+	typeBoolean = "boolean"
+	typeNull    = "null"
+)
+
+// Schema describes the values seen at one place in the payloads.
+type Schema struct {
+	// seen counts the values observed here and objects those that were
+	// objects; a property seen fewer times than its parent was an object was
+	// sometimes absent.
+	seen    int
+	objects int
+	// examples holds the first value seen of each type.
+	examples   map[string]any
+	properties map[string]*Schema
+	items      *Schema
+	emptyArray bool
+}
+
+// Infer merges the schema of each JSON object payload; other payloads are
+// skipped since transforms only receive objects.
+func Infer(payloads []json.RawMessage) *Schema {
+	s := &Schema{}
+	for _, p := range payloads {
+		var v map[string]any
+		if json.Unmarshal(p, &v) != nil || v == nil {
+			continue
+		}
+		s.add(v, 0)
+	}
+	return s
+}
+
+// Samples is the number of payloads the schema was inferred from.
+func (s *Schema) Samples() int {
+	return s.seen
+}
+
+func (s *Schema) add(v any, depth int) {
+	s.seen++
+	t := typeOf(v)
+	if s.examples == nil {
+		s.examples = map[string]any{}
+	}
+	if _, ok := s.examples[t]; !ok {
+		s.examples[t] = v
+	}
+	if depth >= maxDepth {
+		return
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		s.objects++
+		if s.properties == nil {
+			s.properties = map[string]*Schema{}
+		}
+		for k, child := range v {
+			if s.properties[k] == nil {
+				s.properties[k] = &Schema{}
+			}
+			s.properties[k].add(child, depth+1)
+		}
+	case []any:
+		if len(v) == 0 {
+			s.emptyArray = true
+		}
+		if s.items == nil && len(v) > 0 {
+			s.items = &Schema{}
+		}
+		for _, item := range v {
+			s.items.add(item, depth+1)
+		}
+	}
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return typeObject
+	case []any:
+		return typeArray
+	case string:
+		return typeString
+	case float64:
+		return typeNumber
+	case bool:
+		return typeBoolean
+	default:
+		return typeNull
+	}
+}
+
+// primary is the type a representative value takes: an object or array if
+// one was seen, since those carry the most structure, otherwise the first
+// non-null type in a fixed order.
+func (s *Schema) primary() string {
+	for _, t := range []string{typeObject, typeArray, typeString, typeNumber, typeBoolean} {
+		if _, ok := s.examples[t]; ok {
+			return t
+		}
+	}
+	return typeNull
+}
+
+// value builds a representative value of type t with every property seen.
+func (s *Schema) value(t string) any {
+	switch t {
+	case typeObject:
+		obj := make(map[string]any, len(s.properties))
+		for k, child := range s.properties {
+			obj[k] = child.value(child.primary())
+		}
+		return obj
+	case typeArray:
+		if s.items == nil {
+			return []any{}
+		}
+		return []any{s.items.value(s.items.primary())}
+	default:
+		return s.examples[t]
+	}
+}
+
+// Variant is a generated payload and the shape it exercises.
+type Variant struct {
+	Shape   string
+	Payload map[string]any
+}
+
+// Variants returns a representative payload with every field seen, followed
+// by one payload per observed variation: an optional field missing, a field
+// holding another type it was seen with (including null), or an array seen
+// empty. At most MaxVariants are returned.
+func (s *Schema) Variants() []Variant {
+	if s.seen == 0 {
+		return nil
+	}
+	base, _ := s.value(typeObject).(map[string]any)
+	out := []Variant{{Shape: "all fields present", Payload: base}}
+	s.variations(base, nil, &out)
+	return out
+}
+
+// variations appends the variations of s's descendants at path.
+func (s *Schema) variations(base map[string]any, path []string, out *[]Variant) {
+	for _, k := range slices.Sorted(maps.Keys(s.properties)) {
+		child(base, append(slices.Clip(path), k), s.properties[k], s.objects, out)
+	}
+	if s.items != nil {
+		child(base, append(slices.Clip(path), "[0]"), s.items, 0, out)
+	}
+}
+
+// child appends the variations of one property (parentObjects is how many
+// objects it could have appeared in) or array item (parentObjects 0), then
+// descends into it.
+func child(base map[string]any, path []string, c *Schema, parentObjects int, out *[]Variant) {
+	name := strings.ReplaceAll(strings.Join(path, "."), ".[0]", "[0]")
+	add := func(shape string, set func(map[string]any)) {
+		if len(*out) >= MaxVariants {
+			return
+		}
+		p := clone(base).(map[string]any)
+		set(p)
+		*out = append(*out, Variant{Shape: shape, Payload: p})
+	}
+
+	if parentObjects > 0 && c.seen < parentObjects {
+		add("missing "+name, func(p map[string]any) { remove(p, path) })
+	}
+	primary := c.primary()
+	for _, t := range []string{typeObject, typeArray, typeString, typeNumber, typeBoolean, typeNull} {
+		if _, ok := c.examples[t]; !ok || t == primary {
+			continue
+		}
+		v := c.value(t)
+		add(name+" is "+t, func(p map[string]any) { set(p, path, v) })
+	}
+	if c.emptyArray && primary == typeArray && c.items != nil {
+		add(name+" is empty", func(p map[string]any) { set(p, path, []any{}) })
+	}
+	if primary == typeObject || primary == typeArray {
+		c.variations(base, path, out)
+	}
+}
+
+func clone(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, child := range v {
+			m[k] = clone(child)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, child := range v {
+			s[i] = clone(child)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// parent walks to the container holding path's last element.
+func parent(root map[string]any, path []string) any {
+	var cur any = root
+	for _, seg := range path[:len(path)-1] {
+		switch c := cur.(type) {
+		case map[string]any:
+			cur = c[seg]
+		case []any:
+			if len(c) == 0 {
+				return nil
+			}
+			cur = c[0]
+		default:
+			return nil
+		}
+	}
+	return cur
+}
+
+func set(root map[string]any, path []string, v any) {
+	last := path[len(path)-1]
+	switch c := parent(root, path).(type) {
+	case map[string]any:
+		c[last] = v
+	case []any:
+		if len(c) > 0 {
+			c[0] = v
+		}
+	}
+}
+
+func remove(root map[string]any, path []string) {
+	if c, ok := parent(root, path).(map[string]any); ok {
+		delete(c, path[len(path)-1])
+	}
+}
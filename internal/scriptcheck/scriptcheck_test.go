@@ -0,0 +1,107 @@
+package scriptcheck
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func payloads(ss ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(ss))
+	for i, s := range ss {
+		out[i] = json.RawMessage(s)
+	}
+	return out
+}
+
+func shapes(vs []Variant) map[string]map[string]any {
+	m := make(map[string]map[string]any, len(vs))
+	for _, v := range vs {
+		m[v.Shape] = v.Payload
+	}
+	return m
+}
+
+func TestVariants(t *testing.T) {
+	s := Infer(payloads(
+		`{"id":1,"customer":{"email":"a@example.com"},"items":[{"sku":"x"}]}`,
+		`{"id":2,"customer":null,"items":[]}`,
+		`"not an object"`,
+	))
+	if s.Samples() != 2 {
+		t.Fatalf("expected 2 samples, got %d", s.Samples())
+	}
+	got := shapes(s.Variants())
+
+	base := got["all fields present"]
+	if base == nil || base["customer"].(map[string]any)["email"] != "a@example.com" {
+		t.Fatalf("unexpected base payload %v", base)
+	}
+	if p, ok := got["customer is null"]; !ok || p["customer"] != nil {
+		t.Fatalf("expected a null customer variant, got %v", got)
+	}
+	if p, ok := got["items is empty"]; !ok || len(p["items"].([]any)) != 0 {
+		t.Fatalf("expected an empty items variant, got %v", got)
+	}
+	for _, shape := range []string{"missing id", "missing customer.email", "missing items[0].sku"} {
+		if _, ok := got[shape]; ok {
+			t.Errorf("unexpected variant %q for a field always present", shape)
+		}
+	}
+}
+
+func TestVariants_MissingNested(t *testing.T) {
+	got := shapes(Infer(payloads(
+		`{"customer":{"email":"a@example.com","name":"A"}}`,
+		`{"customer":{"name":"B"}}`,
+	)).Variants())
+	p, ok := got["missing customer.email"]
+	if !ok {
+		t.Fatalf("expected a missing customer.email variant, got %v", got)
+	}
+	if _, has := p["customer"].(map[string]any)["email"]; has {
+		t.Fatal("expected email removed")
+	}
+	if got["all fields present"]["customer"].(map[string]any)["email"] == nil {
+		t.Fatal("expected the base payload unchanged by variants")
+	}
+}
+
+func TestVariants_Cap(t *testing.T) {
+	var a, b string
+	a, b = "{", "{"
+	for i := range 60 {
+		k := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		if i > 0 {
+			a += ","
+		}
+		a += `"` + k + `":1`
+		if i == 0 {
+			b += `"` + k + `":1`
+		}
+	}
+	if n := len(Infer(payloads(a+"}", b+"}")).Variants()); n != MaxVariants {
+		t.Fatalf("expected %d variants, got %d", MaxVariants, n)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	script := `function transform(e) { e.payload.email = e.payload.customer.email.toLowerCase(); return e; }`
+	r := Check(script, "", payloads(
+		`{"customer":{"email":"A@example.com"}}`,
+		`{"customer":null}`,
+	), "orders", time.Second)
+	if r.Samples != 2 || r.Variants != 2 || r.Passed != 1 || len(r.Failures) != 1 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if r.Failures[0].Shape != "customer is null" {
+		t.Fatalf("unexpected failure %+v", r.Failures[0])
+	}
+}
+
+func TestCheck_NoSamples(t *testing.T) {
+	r := Check(`function transform(e) { return e; }`, "", nil, "orders", time.Second)
+	if r.Variants != 0 || len(r.Failures) != 0 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+}
@@ -0,0 +1,202 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Inbound signature schemes. Hex schemes accept an optional "<algo>=" prefix
+// on the header value, as sent by GitHub and similar providers.
+const (
+	SchemeHMACSHA256       = "hmac-sha256"
+	SchemeHMACSHA256Base64 = "hmac-sha256-base64"
+	SchemeHMACSHA1         = "hmac-sha1"
+)
+
+// Provider presets with built-in verification. A preset overrides the scheme
+// and header.
+const (
+	ProviderStripe  = "stripe"
+	ProviderGitHub  = "github"
+	ProviderShopify = "shopify"
+	ProviderSlack   = "slack"
+)
+
+// timestampTolerance bounds the age of timestamped signatures (Stripe, Slack)
+// to limit replays.
+const timestampTolerance = 5 * time.Minute
+
+// DefaultInboundHeader is used when a source doesn't name a signature header.
+const DefaultInboundHeader = "X-Hub-Signature-256"
+
+var (
+	ErrMissingSignature = errors.New("missing signature header")
+	ErrInvalidSignature = errors.New("signature does not match payload")
+	ErrStaleTimestamp   = errors.New("signature timestamp outside tolerance")
+)
+
+// InboundConfig describes how a source's producer signs its requests.
+type InboundConfig struct {
+	Provider string
+	Scheme   string
+	Header   string
+	Secret   string
+}
+
+// ValidScheme reports whether scheme is a supported inbound scheme.
+func ValidScheme(scheme string) bool {
+	switch scheme {
+	case SchemeHMACSHA256, SchemeHMACSHA256Base64, SchemeHMACSHA1:
+		return true
+	}
+	return false
+}
+
+// ValidProvider reports whether provider has a verification preset.
+func ValidProvider(provider string) bool {
+	switch provider {
+	case ProviderStripe, ProviderGitHub, ProviderShopify, ProviderSlack:
+		return true
+	}
+	return false
+}
+
+// VerifyInbound checks the request signature in headers against body, using
+// the provider preset when one is set.
+func VerifyInbound(cfg InboundConfig, headers http.Header, body []byte) error {
+	switch cfg.Provider {
+	case "":
+	case ProviderStripe:
+		return verifyStripe(cfg.Secret, headers, body)
+	case ProviderGitHub:
+		cfg.Scheme, cfg.Header = SchemeHMACSHA256, "X-Hub-Signature-256"
+	case ProviderShopify:
+		cfg.Scheme, cfg.Header = SchemeHMACSHA256Base64, "X-Shopify-Hmac-Sha256"
+	case ProviderSlack:
+		return verifySlack(cfg.Secret, headers, body)
+	default:
+		return fmt.Errorf("unsupported provider %q", cfg.Provider)
+	}
+
+	header := cfg.Header
+	if header == "" {
+		header = DefaultInboundHeader
+	}
+	sig := strings.TrimSpace(headers.Get(header))
+	if sig == "" {
+		return ErrMissingSignature
+	}
+
+	var (
+		newHash func() hash.Hash
+		prefix  string
+		encode  func([]byte) string
+	)
+	switch cfg.Scheme {
+	case SchemeHMACSHA256:
+		newHash, prefix, encode = sha256.New, "sha256=", hex.EncodeToString
+	case SchemeHMACSHA256Base64:
+		newHash, encode = sha256.New, base64.StdEncoding.EncodeToString
+	case SchemeHMACSHA1:
+		newHash, prefix, encode = sha1.New, "sha1=", hex.EncodeToString
+	default:
+		return fmt.Errorf("unsupported signature scheme %q", cfg.Scheme)
+	}
+
+	mac := hmac.New(newHash, []byte(cfg.Secret))
+	mac.Write(body)
+	expected := encode(mac.Sum(nil))
+
+	if prefix != "" {
+		sig = strings.ToLower(strings.TrimPrefix(sig, prefix))
+	}
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyStripe checks a Stripe-Signature header ("t=<unix>,v1=<hex>,...")
+// where each v1 is HMAC-SHA256 of "<t>.<body>".
+func verifyStripe(secret string, headers http.Header, body []byte) error {
+	header := headers.Get("Stripe-Signature")
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if ts == "" || len(sigs) == 0 {
+		return ErrMissingSignature
+	}
+	if err := checkTimestamp(ts); err != nil {
+		return err
+	}
+
+	expected := hmacHex(secret, ts+"."+string(body))
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(expected), []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// verifySlack checks X-Slack-Signature ("v0=<hex>"), HMAC-SHA256 of
+// "v0:<X-Slack-Request-Timestamp>:<body>".
+func verifySlack(secret string, headers http.Header, body []byte) error {
+	sig := headers.Get("X-Slack-Signature")
+	ts := headers.Get("X-Slack-Request-Timestamp")
+	if sig == "" || ts == "" {
+		return ErrMissingSignature
+	}
+	if err := checkTimestamp(ts); err != nil {
+		return err
+	}
+
+	expected := "v0=" + hmacHex(secret, "v0:"+ts+":"+string(body))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func checkTimestamp(ts string) error {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := time.Since(time.Unix(sec, 0))
+	if age > timestampTolerance || age < -timestampTolerance {
+		return ErrStaleTimestamp
+	}
+	return nil
+}
+
+func hmacHex(secret, msg string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,92 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyInbound_HexWithPrefix(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	h := http.Header{}
+	h.Set(DefaultInboundHeader, Sign(body, "s3cret"))
+
+	cfg := InboundConfig{Scheme: SchemeHMACSHA256, Secret: "s3cret"}
+	if err := VerifyInbound(cfg, h, body); err != nil {
+		t.Fatalf("expected valid signature, got: %v", err)
+	}
+	if err := VerifyInbound(cfg, h, []byte(`{"action":"closed"}`)); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature, got: %v", err)
+	}
+}
+
+func TestVerifyInbound_Base64CustomHeader(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write(body)
+	h := http.Header{}
+	h.Set("X-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	cfg := InboundConfig{Scheme: SchemeHMACSHA256Base64, Header: "X-Signature", Secret: "k"}
+	if err := VerifyInbound(cfg, h, body); err != nil {
+		t.Fatalf("expected valid signature, got: %v", err)
+	}
+}
+
+func TestVerifyInbound_Missing(t *testing.T) {
+	cfg := InboundConfig{Scheme: SchemeHMACSHA256, Secret: "k"}
+	if err := VerifyInbound(cfg, http.Header{}, []byte(`{}`)); err != ErrMissingSignature {
+		t.Fatalf("expected ErrMissingSignature, got: %v", err)
+	}
+}
+
+func TestVerifyInbound_Stripe(t *testing.T) {
+	body := []byte(`{"type":"charge.succeeded"}`)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	h := http.Header{}
+	h.Set("Stripe-Signature", "t="+ts+",v1=deadbeef,v1="+hmacHex("whsec", ts+"."+string(body)))
+
+	cfg := InboundConfig{Provider: ProviderStripe, Secret: "whsec"}
+	if err := VerifyInbound(cfg, h, body); err != nil {
+		t.Fatalf("expected valid signature, got: %v", err)
+	}
+
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	h.Set("Stripe-Signature", "t="+old+",v1="+hmacHex("whsec", old+"."+string(body)))
+	if err := VerifyInbound(cfg, h, body); err != ErrStaleTimestamp {
+		t.Fatalf("expected ErrStaleTimestamp, got: %v", err)
+	}
+}
+
+func TestVerifyInbound_Slack(t *testing.T) {
+	body := []byte(`token=x&team_id=T1`)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	h := http.Header{}
+	h.Set("X-Slack-Request-Timestamp", ts)
+	h.Set("X-Slack-Signature", "v0="+hmacHex("slk", "v0:"+ts+":"+string(body)))
+
+	if err := VerifyInbound(InboundConfig{Provider: ProviderSlack, Secret: "slk"}, h, body); err != nil {
+		t.Fatalf("expected valid signature, got: %v", err)
+	}
+	if err := VerifyInbound(InboundConfig{Provider: ProviderSlack, Secret: "other"}, h, body); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature, got: %v", err)
+	}
+}
+
+func TestVerifyInbound_ShopifyPreset(t *testing.T) {
+	body := []byte(`{"id":820982911946154508}`)
+	mac := hmac.New(sha256.New, []byte("shp"))
+	mac.Write(body)
+	h := http.Header{}
+	h.Set("X-Shopify-Hmac-Sha256", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	// The preset's scheme and header win over the configured ones
+	cfg := InboundConfig{Provider: ProviderShopify, Scheme: SchemeHMACSHA1, Header: "X-Other", Secret: "shp"}
+	if err := VerifyInbound(cfg, h, body); err != nil {
+		t.Fatalf("expected valid signature, got: %v", err)
+	}
+}
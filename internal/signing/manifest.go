@@ -0,0 +1,47 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+)
+
+// ManifestSigner produces Ed25519 signatures over audit manifests.
+type ManifestSigner struct {
+	key ed25519.PrivateKey
+}
+
+// NewManifestSigner builds a signer from a base64-encoded 32-byte Ed25519 seed.
+func NewManifestSigner(seedB64 string) (*ManifestSigner, error) {
+	seed, err := base64.StdEncoding.DecodeString(seedB64)
+	if err != nil {
+		return nil, fmt.Errorf("decode signing key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return &ManifestSigner{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// Sign returns the base64-encoded Ed25519 signature of data.
+func (s *ManifestSigner) Sign(data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, data))
+}
+
+// PublicKey returns the base64-encoded public key partners use to verify manifests.
+func (s *ManifestSigner) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// VerifyManifest checks a base64 signature against data and a base64 public key.
+func VerifyManifest(data []byte, publicKeyB64, signatureB64 string) bool {
+	pub, err := base64.StdEncoding.DecodeString(publicKeyB64)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pub), data, sig)
+}
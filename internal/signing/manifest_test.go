@@ -0,0 +1,33 @@
+package signing
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestManifestSignAndVerify(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	signer, err := NewManifestSigner(seed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := []byte(`{"entries":[]}`)
+	sig := signer.Sign(data)
+
+	if !VerifyManifest(data, signer.PublicKey(), sig) {
+		t.Fatal("VerifyManifest should return true for valid signature")
+	}
+	if VerifyManifest([]byte(`{"entries":[1]}`), signer.PublicKey(), sig) {
+		t.Fatal("VerifyManifest should return false for tampered manifest")
+	}
+}
+
+func TestNewManifestSigner_BadKey(t *testing.T) {
+	if _, err := NewManifestSigner("not-base64!"); err == nil {
+		t.Fatal("expected error for invalid base64")
+	}
+	if _, err := NewManifestSigner(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Fatal("expected error for short seed")
+	}
+}
@@ -0,0 +1,180 @@
+package simulate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown provider")
+	ErrUnknownEvent    = errors.New("unknown event type for provider")
+)
+
+// Sample is a generated provider event ready to be ingested.
+type Sample struct {
+	Headers map[string]string
+	Payload json.RawMessage
+}
+
+// builder produces a payload for an event, given a fresh id and timestamp.
+type builder func(id string, now time.Time) map[string]any
+
+type provider struct {
+	// eventHeader carries the event type, as the real provider would send it.
+	eventHeader string
+	events      map[string]builder
+}
+
+var providers = map[string]provider{
+	"stripe": {
+		eventHeader: "Stripe-Event-Type",
+		events: map[string]builder{
+			"payment_intent.succeeded": func(id string, now time.Time) map[string]any {
+				return stripeEvent(id, now, "payment_intent.succeeded", map[string]any{
+					"id":       "pi_" + short(id),
+					"object":   "payment_intent",
+					"amount":   2000,
+					"currency": "usd",
+					"status":   "succeeded",
+				})
+			},
+			"customer.created": func(id string, now time.Time) map[string]any {
+				return stripeEvent(id, now, "customer.created", map[string]any{
+					"id":     "cus_" + short(id),
+					"object": "customer",
+					"email":  "jenny.rosen@example.com",
+					"name":   "Jenny Rosen",
+				})
+			},
+			"invoice.payment_failed": func(id string, now time.Time) map[string]any {
+				return stripeEvent(id, now, "invoice.payment_failed", map[string]any{
+					"id":                   "in_" + short(id),
+					"object":               "invoice",
+					"amount_due":           4999,
+					"currency":             "usd",
+					"status":               "open",
+					"attempted":            true,
+					"customer":             "cus_" + short(id),
+					"next_payment_attempt": now.Add(72 * time.Hour).Unix(),
+				})
+			},
+		},
+	},
+	"github": {
+		eventHeader: "X-GitHub-Event",
+		events: map[string]builder{
+			"push": func(id string, now time.Time) map[string]any {
+				return map[string]any{
+					"ref":    "refs/heads/main",
+					"before": strings.Repeat("0", 40),
+					"after":  strings.ReplaceAll(id, "-", "")[:32] + "00000000",
+					"repository": map[string]any{
+						"full_name": "octo-org/octo-repo",
+						"private":   false,
+					},
+					"pusher": map[string]any{"name": "octocat"},
+					"commits": []any{map[string]any{
+						"message":   "Update README.md",
+						"timestamp": now.Format(time.RFC3339),
+						"author":    map[string]any{"username": "octocat"},
+					}},
+				}
+			},
+			"pull_request": func(id string, now time.Time) map[string]any {
+				return map[string]any{
+					"action": "opened",
+					"number": 42,
+					"pull_request": map[string]any{
+						"title":      "Add simulated feature",
+						"state":      "open",
+						"created_at": now.Format(time.RFC3339),
+						"user":       map[string]any{"login": "octocat"},
+					},
+					"repository": map[string]any{"full_name": "octo-org/octo-repo"},
+				}
+			},
+		},
+	},
+	"shopify": {
+		eventHeader: "X-Shopify-Topic",
+		events: map[string]builder{
+			"orders/create": func(id string, now time.Time) map[string]any {
+				return map[string]any{
+					"id":                   820982911946154508,
+					"admin_graphql_api_id": "gid://shopify/Order/820982911946154508",
+					"email":                "jon@example.com",
+					"created_at":           now.Format(time.RFC3339),
+					"currency":             "USD",
+					"total_price":          "199.65",
+					"financial_status":     "paid",
+					"line_items": []any{map[string]any{
+						"title":    "IPod Nano - 8GB",
+						"quantity": 1,
+						"price":    "199.00",
+					}},
+				}
+			},
+		},
+	},
+}
+
+// Providers lists the supported providers and their event types, sorted.
+func Providers() map[string][]string {
+	out := make(map[string][]string, len(providers))
+	for name, p := range providers {
+		events := make([]string, 0, len(p.events))
+		for e := range p.events {
+			events = append(events, e)
+		}
+		sort.Strings(events)
+		out[name] = events
+	}
+	return out
+}
+
+// Generate builds a sample event for provider and eventType.
+func Generate(providerName, eventType string) (*Sample, error) {
+	p, ok := providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	build, ok := p.events[eventType]
+	if !ok {
+		return nil, ErrUnknownEvent
+	}
+
+	id := uuid.New().String()
+	payload, err := json.Marshal(build(id, time.Now().UTC()))
+	if err != nil {
+		return nil, fmt.Errorf("marshal sample payload: %w", err)
+	}
+	return &Sample{
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+			"X-Webhook-ID": id,
+			p.eventHeader:  eventType,
+		},
+		Payload: payload,
+	}, nil
+}
+
+func stripeEvent(id string, now time.Time, eventType string, object map[string]any) map[string]any {
+	return map[string]any{
+		"id":       "evt_" + short(id),
+		"object":   "event",
+		"type":     eventType,
+		"created":  now.Unix(),
+		"livemode": false,
+		"data":     map[string]any{"object": object},
+	}
+}
+
+func short(id string) string {
+	return strings.ReplaceAll(id, "-", "")[:24]
+}
@@ -0,0 +1,53 @@
+package simulate
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	for name, events := range Providers() {
+		for _, event := range events {
+			s, err := Generate(name, event)
+			if err != nil {
+				t.Fatalf("%s %s: unexpected error: %v", name, event, err)
+			}
+			if !json.Valid(s.Payload) {
+				t.Fatalf("%s %s: invalid JSON payload", name, event)
+			}
+			if s.Headers[providers[name].eventHeader] != event {
+				t.Fatalf("%s %s: event header not set: %v", name, event, s.Headers)
+			}
+		}
+	}
+}
+
+func TestGenerate_Stripe(t *testing.T) {
+	s, err := Generate("stripe", "payment_intent.succeeded")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var evt struct {
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				Object string `json:"object"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(s.Payload, &evt); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if evt.Type != "payment_intent.succeeded" || evt.Data.Object.Object != "payment_intent" {
+		t.Fatalf("unexpected event: %s", s.Payload)
+	}
+}
+
+func TestGenerate_Unknown(t *testing.T) {
+	if _, err := Generate("paypal", "x"); err != ErrUnknownProvider {
+		t.Fatalf("expected ErrUnknownProvider, got: %v", err)
+	}
+	if _, err := Generate("stripe", "nope"); err != ErrUnknownEvent {
+		t.Fatalf("expected ErrUnknownEvent, got: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+// Package slack posts deliveries to Slack, through an incoming webhook URL or
+// a bot token and channel, rendering the payload into Block Kit.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// PostMessageURL is the Web API method bot tokens post through.
+const PostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// maxText is kept under Slack's 3000 character limit for a section's text.
+const maxText = 2900
+
+// Config is a Slack action's config. Channel is required with a bot token
+// and ignored by incoming webhooks, which post to their own channel.
+// Template is a reqtemplate over the payload rendering a JSON array of
+// blocks, a full message object, or plain text; empty posts the payload as
+// a code block.
+type Config struct {
+	Channel  string `json:"channel,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode slack config: %w", err)
+	}
+	return cfg, nil
+}
+
+// IsWebhookURL reports whether the secret is an incoming webhook URL rather
+// than a bot token.
+func IsWebhookURL(secret string) bool {
+	return strings.HasPrefix(secret, "https://")
+}
+
+// Validate checks a config with its secret: an incoming webhook URL on
+// hooks.slack.com, or a bot token (xoxb-/xoxp-) with a channel.
+func Validate(cfg Config, secret string) error {
+	if cfg.Template != "" {
+		if _, err := reqtemplate.Parse(cfg.Template); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	switch {
+	case secret == "":
+		return errors.New("slack actions need a webhook URL or bot token as their secret")
+	case IsWebhookURL(secret):
+		u, err := url.Parse(secret)
+		if err != nil || u.Host != "hooks.slack.com" {
+			return errors.New("slack webhook URL must be on https://hooks.slack.com")
+		}
+	case strings.HasPrefix(secret, "xoxb-"), strings.HasPrefix(secret, "xoxp-"):
+		if cfg.Channel == "" {
+			return errors.New("channel is required with a slack bot token")
+		}
+	default:
+		return errors.New("slack secret must be a webhook URL or an xoxb-/xoxp- token")
+	}
+	return nil
+}
+
+// Message renders payload into a chat.postMessage body for the config.
+func Message(cfg Config, payload json.RawMessage, maxBytes int) ([]byte, error) {
+	msg := map[string]any{}
+	if cfg.Template == "" {
+		msg["blocks"] = []any{codeBlock(payload)}
+		msg["text"] = "New delivery"
+	} else {
+		out, err := reqtemplate.Body(cfg.Template, payload, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		out = bytes.TrimSpace(out)
+		var v any
+		switch {
+		case json.Unmarshal(out, &v) != nil:
+			msg["text"] = string(out)
+		case isArray(v):
+			msg["blocks"] = v
+			msg["text"] = "New delivery"
+		case isObject(v):
+			msg = v.(map[string]any)
+		default:
+			return nil, errors.New("slack template must render blocks, a message object or text")
+		}
+	}
+	if cfg.Channel != "" {
+		msg["channel"] = cfg.Channel
+	}
+	return json.Marshal(msg)
+}
+
+func codeBlock(payload json.RawMessage) map[string]any {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		buf.Reset()
+		buf.Write(payload)
+	}
+	text := buf.String()
+	if len(text) > maxText {
+		text = text[:maxText] + "…"
+	}
+	return map[string]any{
+		"type": "section",
+		"text": map[string]any{"type": "mrkdwn", "text": "```" + text + "```"},
+	}
+}
+
+func isArray(v any) bool {
+	_, ok := v.([]any)
+	return ok
+}
+
+func isObject(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
+
+// NewRequest builds the request posting body with secret: to the webhook
+// URL itself, or to chat.postMessage with the token as bearer.
+func NewRequest(ctx context.Context, secret string, body []byte) (*http.Request, error) {
+	target := PostMessageURL
+	if IsWebhookURL(secret) {
+		target = secret
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if !IsWebhookURL(secret) {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	return req, nil
+}
+
+// CheckResponse reports a failed post. The Web API answers 200 with
+// {"ok": false, "error": ...}; incoming webhooks use the status code.
+func CheckResponse(status int, body []byte) error {
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("HTTP %d", status)
+	}
+	var res struct {
+		OK    *bool  `json:"ok"`
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &res) == nil && res.OK != nil && !*res.OK {
+		return fmt.Errorf("slack error: %s", res.Error)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package slack
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		cfg    Config
+		secret string
+		ok     bool
+	}{
+		{Config{}, "https://hooks.slack.com/services/T/B/x", true},
+		{Config{}, "https://example.com/services/T/B/x", false},
+		{Config{Channel: "#ops"}, "xoxb-123", true},
+		{Config{}, "xoxb-123", false},
+		{Config{Channel: "#ops"}, "token", false},
+		{Config{}, "", false},
+		{Config{Template: "{{.x"}, "https://hooks.slack.com/services/T/B/x", false},
+	}
+	for _, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v, %q) = %v, want ok %v", tc.cfg, tc.secret, err, tc.ok)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	payload := json.RawMessage(`{"user":"ada","n":2}`)
+	cases := map[string]struct {
+		cfg  Config
+		want string
+	}{
+		"default": {Config{Channel: "C1"}, `{"blocks":[{"text":{"text":"` + "```" + `{\n  \"user\": \"ada\",\n  \"n\": 2\n}` + "```" + `","type":"mrkdwn"},"type":"section"}],"channel":"C1","text":"New delivery"}`},
+		"blocks":  {Config{Template: `[{"type":"section","text":{"type":"plain_text","text":"{{.user}}"}}]`}, `{"blocks":[{"text":{"text":"ada","type":"plain_text"},"type":"section"}],"text":"New delivery"}`},
+		"object":  {Config{Template: `{"text":"hi {{.user}}"}`, Channel: "C1"}, `{"channel":"C1","text":"hi ada"}`},
+		"text":    {Config{Template: `{{.user}} did {{.n}} things`}, `{"text":"ada did 2 things"}`},
+	}
+	for name, tc := range cases {
+		got, err := Message(tc.cfg, payload, 4096)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", name, got, tc.want)
+		}
+	}
+	if _, err := Message(Config{Template: `42`}, payload, 4096); err == nil {
+		t.Error("expected error for a scalar rendering")
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(200, []byte("ok")); err != nil {
+		t.Errorf("webhook ok: %v", err)
+	}
+	if err := CheckResponse(200, []byte(`{"ok":true}`)); err != nil {
+		t.Errorf("api ok: %v", err)
+	}
+	if err := CheckResponse(200, []byte(`{"ok":false,"error":"channel_not_found"}`)); err == nil || err.Error() != "slack error: channel_not_found" {
+		t.Errorf("api error = %v", err)
+	}
+	if err := CheckResponse(404, []byte("no_service")); err == nil {
+		t.Error("expected error for 404")
+	}
+}
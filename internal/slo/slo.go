@@ -0,0 +1,69 @@
+// Package slo turns an action's recent delivery outcomes into a success
+// objective's error budget and burn rate.
+package slo
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+const (
+	// DefaultTarget is the success ratio actions are held to unless they
+	// set their own.
+	DefaultTarget = 0.99
+	// DefaultWindow is the rolling window the objective is measured over.
+	DefaultWindow = 7 * 24 * time.Hour
+	// BurnWindow is the recent window the burn rate is measured over.
+	BurnWindow = time.Hour
+	// MinOutcomes is how many outcomes a window needs before its budget can
+	// be reported exhausted, so a few early failures don't alert.
+	MinOutcomes = 20
+)
+
+// Objective is a success target over a rolling window.
+type Objective struct {
+	Target float64
+	Window time.Duration
+}
+
+// ValidTarget checks the target is a ratio strictly between 0 and 1.
+func ValidTarget(target float64) error {
+	if target <= 0 || target >= 1 {
+		return errors.New("target must be between 0 and 1 exclusive, such as 0.99")
+	}
+	return nil
+}
+
+// Status is an action's standing against its objective.
+type Status struct {
+	Target float64 `json:"target"`
+	// SuccessRate is nil when the window has no outcomes.
+	SuccessRate *float64 `json:"success_rate,omitempty"`
+	// BudgetRemaining is the fraction of the window's error budget left;
+	// it goes negative once the budget is overspent.
+	BudgetRemaining float64 `json:"budget_remaining"`
+	// BurnRate is how fast the budget is being spent over BurnWindow, where
+	// 1 spends exactly the budget over the whole window.
+	BurnRate  float64 `json:"burn_rate"`
+	Exhausted bool    `json:"exhausted"`
+}
+
+// Evaluate scores an action's outcomes against target.
+func Evaluate(target float64, o model.ActionOutcomes) Status {
+	st := Status{Target: target, BudgetRemaining: 1}
+	allowed := 1 - target
+	if o.Total > 0 {
+		rate := float64(o.Total-o.Failed) / float64(o.Total)
+		st.SuccessRate = &rate
+		// Rounded so a budget spent exactly isn't left with float noise
+		st.BudgetRemaining = math.Round((1-float64(o.Failed)/(float64(o.Total)*allowed))*1e9) / 1e9
+		st.Exhausted = o.Total >= MinOutcomes && st.BudgetRemaining <= 0
+	}
+	if o.RecentTotal > 0 {
+		st.BurnRate = float64(o.RecentFailed) / float64(o.RecentTotal) / allowed
+	}
+	return st
+}
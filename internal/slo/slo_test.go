@@ -0,0 +1,50 @@
+package slo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+func TestEvaluate(t *testing.T) {
+	st := Evaluate(0.99, model.ActionOutcomes{Total: 1000, Failed: 5, RecentTotal: 10, RecentFailed: 1})
+	if st.SuccessRate == nil || math.Abs(*st.SuccessRate-0.995) > 1e-9 {
+		t.Fatalf("unexpected success rate %v", st.SuccessRate)
+	}
+	if math.Abs(st.BudgetRemaining-0.5) > 1e-9 {
+		t.Fatalf("expected half the budget left, got %v", st.BudgetRemaining)
+	}
+	if math.Abs(st.BurnRate-10) > 1e-9 {
+		t.Fatalf("expected burn rate 10, got %v", st.BurnRate)
+	}
+	if st.Exhausted {
+		t.Fatal("expected budget not exhausted")
+	}
+
+	st = Evaluate(0.99, model.ActionOutcomes{Total: 1000, Failed: 10})
+	if !st.Exhausted || st.BudgetRemaining > 1e-9 {
+		t.Fatalf("expected an exhausted budget, got %+v", st)
+	}
+}
+
+func TestEvaluate_FewOutcomes(t *testing.T) {
+	st := Evaluate(0.99, model.ActionOutcomes{Total: 3, Failed: 3})
+	if st.Exhausted {
+		t.Fatal("expected too few outcomes not to exhaust the budget")
+	}
+	if st := Evaluate(0.99, model.ActionOutcomes{}); st.SuccessRate != nil || st.BudgetRemaining != 1 || st.BurnRate != 0 {
+		t.Fatalf("unexpected status with no outcomes: %+v", st)
+	}
+}
+
+func TestValidTarget(t *testing.T) {
+	for _, v := range []float64{0, 1, -0.5, 1.5} {
+		if ValidTarget(v) == nil {
+			t.Errorf("expected %v to be invalid", v)
+		}
+	}
+	if err := ValidTarget(0.999); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package sqs publishes deliveries to Amazon SQS queues through the JSON
+// protocol's SendMessage, signed with Signature Version 4.
+package sqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/awssig"
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// MaxMessageBytes is SQS's limit on a message, body and attributes included.
+const MaxMessageBytes = 256 * 1024
+
+// Config is an SQS action's config. Region defaults to the one in the queue
+// URL's host. MessageGroupID is a reqtemplate over the payload used for FIFO
+// queues; it defaults to the source ID so each source's deliveries stay in
+// order.
+type Config struct {
+	QueueURL       string `json:"queue_url"`
+	Region         string `json:"region,omitempty"`
+	MessageGroupID string `json:"message_group_id,omitempty"`
+}
+
+// Metadata describes the delivery a message carries; it is sent as message
+// attributes.
+type Metadata struct {
+	DeliveryID string
+	SourceID   string
+	ActionID   string
+	EventType  string
+	ReceivedAt time.Time
+	Attempt    int
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode sqs config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the access key pair as
+// "ACCESS_KEY_ID:SECRET_ACCESS_KEY".
+func Validate(cfg Config, secret string) error {
+	if _, err := awssig.ParseCredentials(secret); err != nil {
+		return err
+	}
+	u, err := queueURL(cfg.QueueURL)
+	if err != nil {
+		return err
+	}
+	if region(cfg, u) == "" {
+		return errors.New("region is required when the queue URL doesn't name one")
+	}
+	if cfg.MessageGroupID != "" {
+		if _, err := reqtemplate.Parse(cfg.MessageGroupID); err != nil {
+			return fmt.Errorf("invalid message_group_id template: %w", err)
+		}
+	}
+	return nil
+}
+
+func queueURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, errors.New("queue_url must be an http(s) queue URL such as https://sqs.us-east-1.amazonaws.com/123456789012/orders")
+	}
+	return u, nil
+}
+
+// region returns the configured region or the one in an AWS queue host
+// (sqs.<region>.amazonaws.com).
+func region(cfg Config, u *url.URL) string {
+	if cfg.Region != "" {
+		return cfg.Region
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) >= 4 && parts[0] == "sqs" && parts[len(parts)-2] == "amazonaws" {
+		return parts[1]
+	}
+	return ""
+}
+
+// IsFIFO reports whether the queue is a FIFO queue, which needs a message
+// group and deduplication ID.
+func IsFIFO(cfg Config) bool {
+	return strings.HasSuffix(cfg.QueueURL, ".fifo")
+}
+
+// NewRequest builds a signed SendMessage request carrying payload as the body
+// and meta as message attributes. On FIFO queues the deduplication ID is the
+// delivery and action, so a retried send within SQS's five-minute window
+// isn't queued twice.
+func NewRequest(ctx context.Context, cfg Config, creds awssig.Credentials, payload json.RawMessage, meta Metadata, now time.Time) (*http.Request, error) {
+	u, err := queueURL(cfg.QueueURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > MaxMessageBytes {
+		return nil, fmt.Errorf("payload exceeds the sqs message limit of %d bytes", MaxMessageBytes)
+	}
+	msg := map[string]any{
+		"QueueUrl":          cfg.QueueURL,
+		"MessageBody":       string(payload),
+		"MessageAttributes": attributes(meta),
+	}
+	if IsFIFO(cfg) {
+		group := meta.SourceID
+		if cfg.MessageGroupID != "" {
+			out, err := reqtemplate.Body(cfg.MessageGroupID, payload, 128)
+			if err != nil {
+				return nil, fmt.Errorf("render message group id: %w", err)
+			}
+			group = strings.TrimSpace(string(out))
+		}
+		if group == "" {
+			return nil, errors.New("message group id rendered empty")
+		}
+		msg["MessageGroupId"] = group
+		msg["MessageDeduplicationId"] = meta.DeliveryID + ":" + meta.ActionID
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal sqs message: %w", err)
+	}
+
+	endpoint := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build sqs request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS.SendMessage")
+	awssig.Sign(req, awssig.HashHex(body), creds, region(cfg, u), "sqs", now)
+	return req, nil
+}
+
+func attributes(meta Metadata) map[string]any {
+	attrs := map[string]any{
+		"delivery_id": stringAttr(meta.DeliveryID),
+		"source_id":   stringAttr(meta.SourceID),
+		"action_id":   stringAttr(meta.ActionID),
+		"received_at": stringAttr(meta.ReceivedAt.UTC().Format(time.RFC3339Nano)),
+		"attempt":     map[string]string{"DataType": "Number", "StringValue": strconv.Itoa(meta.Attempt)},
+	}
+	if meta.EventType != "" {
+		attrs["event_type"] = stringAttr(meta.EventType)
+	}
+	return attrs
+}
+
+func stringAttr(v string) map[string]string {
+	return map[string]string{"DataType": "String", "StringValue": v}
+}
+
+// CheckResponse reports a failed send. Errors come back as non-2xx with a
+// JSON body naming the error type.
+func CheckResponse(status int, body []byte) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	var res struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &res) == nil && res.Type != "" {
+		// Types are namespaced, e.g. "com.amazonaws.sqs#QueueDoesNotExist"
+		if i := strings.LastIndex(res.Type, "#"); i >= 0 {
+			res.Type = res.Type[i+1:]
+		}
+		return fmt.Errorf("sqs error: HTTP %d: %s: %s", status, res.Type, res.Message)
+	}
+	return fmt.Errorf("HTTP %d", status)
+}
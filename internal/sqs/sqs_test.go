@@ -0,0 +1,95 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/awssig"
+)
+
+var creds = awssig.Credentials{AccessKey: "AKID", SecretKey: "secret"}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(Config{QueueURL: "https://sqs.eu-west-1.amazonaws.com/123/orders"}, "AKID:secret"); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	cases := map[string]struct {
+		cfg    Config
+		secret string
+	}{
+		"no credentials":   {Config{QueueURL: "https://sqs.eu-west-1.amazonaws.com/123/orders"}, ""},
+		"no queue path":    {Config{QueueURL: "https://sqs.eu-west-1.amazonaws.com/"}, "AKID:secret"},
+		"no region":        {Config{QueueURL: "http://localstack:4566/000000000000/orders"}, "AKID:secret"},
+		"bad group tmpl":   {Config{QueueURL: "https://sqs.eu-west-1.amazonaws.com/123/o.fifo", MessageGroupID: "{{"}, "AKID:secret"},
+		"unsupported host": {Config{QueueURL: "ftp://queue/1/orders"}, "AKID:secret"},
+	}
+	for name, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if err := Validate(Config{QueueURL: "http://localstack:4566/000000000000/orders", Region: "us-east-1"}, "AKID:secret"); err != nil {
+		t.Fatalf("expected explicit region to satisfy validation, got %v", err)
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	cfg := Config{QueueURL: "https://sqs.eu-west-1.amazonaws.com/123/orders.fifo", MessageGroupID: "{{.customer}}"}
+	meta := Metadata{DeliveryID: "d1", SourceID: "s1", ActionID: "a1", EventType: "order.created", ReceivedAt: time.Unix(0, 0), Attempt: 2}
+	req, err := NewRequest(context.Background(), cfg, creds, []byte(`{"customer":"c9"}`), meta, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "https://sqs.eu-west-1.amazonaws.com/" {
+		t.Fatalf("unexpected endpoint %s", req.URL)
+	}
+	if req.Header.Get("X-Amz-Target") != "AmazonSQS.SendMessage" {
+		t.Fatalf("unexpected target %q", req.Header.Get("X-Amz-Target"))
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "/eu-west-1/sqs/aws4_request") {
+		t.Fatalf("expected eu-west-1 sqs scope, got %q", req.Header.Get("Authorization"))
+	}
+
+	var msg struct {
+		MessageBody            string
+		MessageGroupId         string
+		MessageDeduplicationId string
+		MessageAttributes      map[string]map[string]string
+	}
+	b, _ := io.ReadAll(req.Body)
+	if err := json.Unmarshal(b, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.MessageBody != `{"customer":"c9"}` || msg.MessageGroupId != "c9" || msg.MessageDeduplicationId != "d1:a1" {
+		t.Fatalf("unexpected message %s", b)
+	}
+	if msg.MessageAttributes["event_type"]["StringValue"] != "order.created" || msg.MessageAttributes["attempt"]["DataType"] != "Number" {
+		t.Fatalf("unexpected attributes %v", msg.MessageAttributes)
+	}
+}
+
+func TestNewRequest_StandardQueue(t *testing.T) {
+	cfg := Config{QueueURL: "https://sqs.us-east-1.amazonaws.com/123/orders"}
+	req, err := NewRequest(context.Background(), cfg, creds, []byte(`{}`), Metadata{SourceID: "s1"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(req.Body)
+	if strings.Contains(string(b), "MessageGroupId") {
+		t.Fatalf("expected no message group on a standard queue: %s", b)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(200, []byte(`{"MessageId":"m1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckResponse(400, []byte(`{"__type":"com.amazonaws.sqs#QueueDoesNotExist","message":"no queue"}`))
+	if err == nil || !strings.Contains(err.Error(), "QueueDoesNotExist: no queue") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package ssrf keeps outbound requests away from internal addresses:
+// loopback, private and link-local networks, and cloud metadata services.
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlocked is returned for targets that resolve to an internal address.
+var ErrBlocked = errors.New("target resolves to a blocked internal address")
+
+// blocked are ranges not covered by the netip.Addr predicates used in
+// Blocked.
+var blocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT; some metadata services
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("255.255.255.255/32"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, can reach IPv4 internals
+}
+
+// Blocked reports whether ip is an internal address that targets may not
+// resolve to.
+func Blocked(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, p := range blocked {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Guard checks outbound targets. A nil Guard allows everything, for
+// self-hosted setups that deliver to internal services.
+type Guard struct {
+	resolver *net.Resolver
+}
+
+// NewGuard returns a guard, or nil when allowPrivate disables the checks.
+func NewGuard(allowPrivate bool) *Guard {
+	if allowPrivate {
+		return nil
+	}
+	return &Guard{resolver: net.DefaultResolver}
+}
+
+// CheckURL resolves the URL's host and fails if any of its addresses is
+// blocked.
+func (g *Guard) CheckURL(ctx context.Context, raw string) error {
+	if g == nil {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parse target URL: %w", err)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("target URL must include a host")
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if Blocked(ip) {
+			return ErrBlocked
+		}
+		return nil
+	}
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve target host: %w", err)
+	}
+	for _, ip := range addrs {
+		if Blocked(ip) {
+			return ErrBlocked
+		}
+	}
+	return nil
+}
+
+// Control is a net.Dialer Control function that refuses connections to
+// blocked addresses. It runs after name resolution, so a host that passed
+// CheckURL and then re-resolves to an internal address is still refused.
+func (g *Guard) Control(network, address string, _ syscall.RawConn) error {
+	if g == nil {
+		return nil
+	}
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("parse dial address: %w", err)
+	}
+	if Blocked(ap.Addr()) {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// Transport returns a transport like http.DefaultTransport that refuses to
+// connect to blocked addresses.
+func (g *Guard) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if g != nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: g.Control}
+		t.DialContext = dialer.DialContext
+	}
+	return t
+}
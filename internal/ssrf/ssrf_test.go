@@ -0,0 +1,51 @@
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.100.100.200":  true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fe80::1":          true,
+		"fd00:ec2::254":    true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+	}
+	for addr, want := range cases {
+		if got := Blocked(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Blocked(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	g := NewGuard(false)
+	ctx := context.Background()
+	if err := g.CheckURL(ctx, "http://169.254.169.254/latest/meta-data"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("metadata URL: err = %v, want ErrBlocked", err)
+	}
+	if err := g.CheckURL(ctx, "https://[::1]:8443/hook"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("loopback URL: err = %v, want ErrBlocked", err)
+	}
+	if err := g.CheckURL(ctx, "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("public URL: %v", err)
+	}
+	if err := NewGuard(true).CheckURL(ctx, "http://127.0.0.1/"); err != nil {
+		t.Errorf("allowPrivate guard: %v", err)
+	}
+	if err := g.Control("tcp", "10.0.0.5:443", nil); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Control private: err = %v, want ErrBlocked", err)
+	}
+}
@@ -2,10 +2,14 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/zachbroad/nitrohook/internal/model"
 )
@@ -14,24 +18,76 @@ type ActionStore struct {
 	pool *pgxpool.Pool
 }
 
-func (s *ActionStore) Create(ctx context.Context, sourceID uuid.UUID, actionType model.ActionType, targetURL *string, signingSecret *string, scriptBody *string) (*model.Action, error) {
+const actionColumns = `id, source_id, type, external_id, target_url, script_body, signing_secret, projection, is_active, verification_token, verified_at, max_attempts_per_hour, max_attempts_per_day, max_requests_per_second, event_types, cloudevents_mode, http_method, url_template, body_template, tls_client_cert, tls_client_key, tls_ca_bundle, proxy_url, config, secret, delivery_window, credential_id, auth_type, auth_config, auth_secret, slo_target, slo_exhausted_at, created_at, updated_at`
+
+// scanAction scans actionColumns into a, followed by any extra columns.
+func scanAction(row pgx.Row, a *model.Action, extra ...any) error {
+	dest := []any{&a.ID, &a.SourceID, &a.Type, &a.ExternalID, &a.TargetURL, &a.ScriptBody, &a.SigningSecret, &a.Projection, &a.IsActive, &a.VerificationToken, &a.VerifiedAt, &a.MaxAttemptsPerHour, &a.MaxAttemptsPerDay, &a.MaxRequestsPerSecond, &a.EventTypes, &a.CloudEventsMode, &a.HTTPMethod, &a.URLTemplate, &a.BodyTemplate, &a.TLSClientCert, &a.TLSClientKey, &a.TLSCABundle, &a.ProxyURL, &a.Config, &a.Secret, &a.DeliveryWindow, &a.CredentialID, &a.AuthType, &a.AuthConfig, &a.AuthSecret, &a.SLOTarget, &a.SLOExhaustedAt, &a.CreatedAt, &a.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// ActionFields holds the optional action settings accepted by Create and
+// Update. Nil fields take the column default on Create and are left unchanged
+// on Update; an empty Projection clears it on Update.
+type ActionFields struct {
+	TargetURL          *string
+	SigningSecret      *string
+	ScriptBody         *string
+	IsActive           *bool
+	Projection         *model.Projection
+	MaxAttemptsPerHour *int
+	MaxAttemptsPerDay  *int
+	// MaxRequestsPerSecond rate-limits outbound requests to the target.
+	MaxRequestsPerSecond *int
+	// EventTypes replaces the subscriptions; an empty slice clears them.
+	EventTypes *[]string
+	// CloudEventsMode is "binary" or "structured"; "" clears it on Update.
+	CloudEventsMode *string
+	// HTTPMethod, URLTemplate and BodyTemplate shape webhook requests; ""
+	// clears them on Update.
+	HTTPMethod   *string
+	URLTemplate  *string
+	BodyTemplate *string
+	// ProxyURL routes webhook requests through a proxy; "" clears it on
+	// Update.
+	ProxyURL *string
+	// Config holds the settings of non-webhook action types. Secret is their
+	// sealed credential; "" clears it on Update.
+	Config json.RawMessage
+	Secret *string
+	// DeliveryWindow holds deliveries outside it; an empty window clears it
+	// on Update.
+	DeliveryWindow *model.DeliveryWindow
+}
+
+func (s *ActionStore) Create(ctx context.Context, sourceID uuid.UUID, actionType model.ActionType, f ActionFields) (*model.Action, error) {
 	var a model.Action
-	err := s.pool.QueryRow(ctx,
-		`INSERT INTO actions (source_id, type, target_url, signing_secret, script_body)
-		 VALUES ($1, $2, $3, $4, $5)
-		 RETURNING id, source_id, type, target_url, script_body, signing_secret, is_active, created_at, updated_at`,
-		sourceID, actionType, targetURL, signingSecret, scriptBody,
-	).Scan(&a.ID, &a.SourceID, &a.Type, &a.TargetURL, &a.ScriptBody, &a.SigningSecret, &a.IsActive, &a.CreatedAt, &a.UpdatedAt)
+	err := scanAction(s.pool.QueryRow(ctx,
+		`INSERT INTO actions (source_id, type, target_url, signing_secret, script_body, is_active, projection, max_attempts_per_hour, max_attempts_per_day, cloudevents_mode, event_types, max_requests_per_second, http_method, url_template, body_template, proxy_url, config, secret, delivery_window)
+		 VALUES ($1, $2, $3, $4, $5, COALESCE($6, true), $7, $8, $9, NULLIF($10, ''), NULLIF($11::text[], '{}'), $12, NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, ''), $17, NULLIF($18, ''), NULLIF($19::jsonb, '{}'))
+		 RETURNING `+actionColumns,
+		sourceID, actionType, f.TargetURL, f.SigningSecret, f.ScriptBody, f.IsActive, f.Projection, f.MaxAttemptsPerHour, f.MaxAttemptsPerDay, f.CloudEventsMode, f.EventTypes, f.MaxRequestsPerSecond, f.HTTPMethod, f.URLTemplate, f.BodyTemplate, f.ProxyURL, f.Config, f.Secret, f.DeliveryWindow,
+	), &a)
 	if err != nil {
 		return nil, fmt.Errorf("create action: %w", err)
 	}
 	return &a, nil
 }
 
+// List returns the source's actions, each with a summary of its most recent
+// (non-capped) attempt.
 func (s *ActionStore) List(ctx context.Context, sourceID uuid.UUID) ([]model.Action, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, source_id, type, target_url, script_body, signing_secret, is_active, created_at, updated_at
-		 FROM actions WHERE source_id = $1 ORDER BY created_at DESC`,
+		`SELECT `+actionColumns+`, la.last_status, la.last_attempt_at
+		 FROM actions
+		 LEFT JOIN LATERAL (
+			SELECT status AS last_status, created_at AS last_attempt_at
+			FROM delivery_attempts
+			WHERE action_id = actions.id AND NOT capped
+			ORDER BY created_at DESC
+			LIMIT 1
+		 ) la ON true
+		 WHERE source_id = $1 AND deleted_at IS NULL ORDER BY created_at DESC`,
 		sourceID,
 	)
 	if err != nil {
@@ -42,48 +98,395 @@ func (s *ActionStore) List(ctx context.Context, sourceID uuid.UUID) ([]model.Act
 	var actions []model.Action
 	for rows.Next() {
 		var a model.Action
-		if err := rows.Scan(&a.ID, &a.SourceID, &a.Type, &a.TargetURL, &a.ScriptBody, &a.SigningSecret, &a.IsActive, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		var lastStatus *model.AttemptStatus
+		var lastAt *time.Time
+		if err := scanAction(rows, &a, &lastStatus, &lastAt); err != nil {
 			return nil, fmt.Errorf("scan action: %w", err)
 		}
+		if lastStatus != nil && lastAt != nil {
+			a.LastAttempt = &model.LastAttempt{Status: *lastStatus, CreatedAt: *lastAt}
+		}
 		actions = append(actions, a)
 	}
 	return actions, rows.Err()
 }
 
+// CountBySource returns how many actions the source has, not counting
+// deleted ones.
+func (s *ActionStore) CountBySource(ctx context.Context, sourceID uuid.UUID) (int, error) {
+	var n int
+	err := s.pool.QueryRow(ctx,
+		`SELECT count(*) FROM actions WHERE source_id = $1 AND deleted_at IS NULL`,
+		sourceID,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count actions: %w", err)
+	}
+	return n, nil
+}
+
+func (s *ActionStore) GetByExternalID(ctx context.Context, sourceID uuid.UUID, externalID string) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`SELECT `+actionColumns+`
+		 FROM actions WHERE source_id = $1 AND external_id = $2 AND deleted_at IS NULL`,
+		sourceID, externalID,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("get action by external id: %w", err)
+	}
+	return &a, nil
+}
+
+// UpsertByExternalID creates the action with the given external ID on the
+// source or, if one exists, replaces its settings with f. An existing
+// action's active flag is kept, except that with deactivateOnRetarget a
+// changed target URL deactivates it pending verification. A nil signing
+// secret keeps the existing one. created reports whether the action was
+// inserted.
+func (s *ActionStore) UpsertByExternalID(ctx context.Context, sourceID uuid.UUID, actionType model.ActionType, externalID string, f ActionFields, deactivateOnRetarget bool) (a *model.Action, created bool, err error) {
+	a = &model.Action{}
+	err = scanAction(s.pool.QueryRow(ctx,
+		`INSERT INTO actions (source_id, type, target_url, signing_secret, script_body, is_active, projection, max_attempts_per_hour, max_attempts_per_day, cloudevents_mode, event_types, external_id, max_requests_per_second, http_method, url_template, body_template, proxy_url, config, secret, delivery_window)
+		 VALUES ($1, $2, $3, $4, $5, COALESCE($6, true), $7, $8, $9, NULLIF($10, ''), NULLIF($11::text[], '{}'), $12, $14, NULLIF($15, ''), NULLIF($16, ''), NULLIF($17, ''), NULLIF($18, ''), $19, NULLIF($20, ''), NULLIF($21::jsonb, '{}'))
+		 ON CONFLICT (source_id, external_id) WHERE external_id IS NOT NULL AND deleted_at IS NULL DO UPDATE SET
+			type                    = EXCLUDED.type,
+			target_url              = EXCLUDED.target_url,
+			signing_secret          = COALESCE(EXCLUDED.signing_secret, actions.signing_secret),
+			script_body             = EXCLUDED.script_body,
+			projection              = EXCLUDED.projection,
+			max_attempts_per_hour   = EXCLUDED.max_attempts_per_hour,
+			max_attempts_per_day    = EXCLUDED.max_attempts_per_day,
+			max_requests_per_second = EXCLUDED.max_requests_per_second,
+			cloudevents_mode        = EXCLUDED.cloudevents_mode,
+			event_types             = EXCLUDED.event_types,
+			http_method             = EXCLUDED.http_method,
+			url_template            = EXCLUDED.url_template,
+			body_template           = EXCLUDED.body_template,
+			proxy_url               = EXCLUDED.proxy_url,
+			config                  = EXCLUDED.config,
+			secret                  = COALESCE(EXCLUDED.secret, actions.secret),
+			delivery_window         = EXCLUDED.delivery_window,
+			is_active               = CASE WHEN $13 AND actions.target_url IS DISTINCT FROM EXCLUDED.target_url THEN false ELSE actions.is_active END,
+			verified_at             = CASE WHEN actions.target_url IS DISTINCT FROM EXCLUDED.target_url THEN NULL ELSE actions.verified_at END,
+			updated_at              = now()
+		 RETURNING `+actionColumns+`, xmax = 0`,
+		sourceID, actionType, f.TargetURL, f.SigningSecret, f.ScriptBody, f.IsActive, f.Projection, f.MaxAttemptsPerHour, f.MaxAttemptsPerDay, f.CloudEventsMode, f.EventTypes, externalID, deactivateOnRetarget, f.MaxRequestsPerSecond, f.HTTPMethod, f.URLTemplate, f.BodyTemplate, f.ProxyURL, f.Config, f.Secret, f.DeliveryWindow,
+	), a, &created)
+	if err != nil {
+		return nil, false, fmt.Errorf("upsert action: %w", err)
+	}
+	return a, created, nil
+}
+
 func (s *ActionStore) GetByID(ctx context.Context, id uuid.UUID) (*model.Action, error) {
 	var a model.Action
-	err := s.pool.QueryRow(ctx,
-		`SELECT id, source_id, type, target_url, script_body, signing_secret, is_active, created_at, updated_at
-		 FROM actions WHERE id = $1`,
+	err := scanAction(s.pool.QueryRow(ctx,
+		`SELECT `+actionColumns+`
+		 FROM actions WHERE id = $1 AND deleted_at IS NULL`,
 		id,
-	).Scan(&a.ID, &a.SourceID, &a.Type, &a.TargetURL, &a.ScriptBody, &a.SigningSecret, &a.IsActive, &a.CreatedAt, &a.UpdatedAt)
+	), &a)
 	if err != nil {
 		return nil, fmt.Errorf("get action: %w", err)
 	}
 	return &a, nil
 }
 
-func (s *ActionStore) Update(ctx context.Context, id uuid.UUID, targetURL *string, signingSecret *string, isActive *bool, scriptBody *string) (*model.Action, error) {
+// Update applies the non-nil fields. Changing target_url clears any previous
+// ownership verification.
+func (s *ActionStore) Update(ctx context.Context, id uuid.UUID, f ActionFields) (*model.Action, error) {
 	var a model.Action
-	err := s.pool.QueryRow(ctx,
+	err := scanAction(s.pool.QueryRow(ctx,
 		`UPDATE actions SET
-			target_url     = COALESCE($2, target_url),
-			signing_secret = COALESCE($3, signing_secret),
-			is_active      = COALESCE($4, is_active),
-			script_body    = COALESCE($5, script_body),
-			updated_at     = $6
-		 WHERE id = $1
-		 RETURNING id, source_id, type, target_url, script_body, signing_secret, is_active, created_at, updated_at`,
-		id, targetURL, signingSecret, isActive, scriptBody, time.Now(),
-	).Scan(&a.ID, &a.SourceID, &a.Type, &a.TargetURL, &a.ScriptBody, &a.SigningSecret, &a.IsActive, &a.CreatedAt, &a.UpdatedAt)
+			verified_at             = CASE WHEN $2::text IS DISTINCT FROM target_url AND $2::text IS NOT NULL THEN NULL ELSE verified_at END,
+			target_url              = COALESCE($2, target_url),
+			signing_secret          = COALESCE($3, signing_secret),
+			is_active               = COALESCE($4, is_active),
+			script_body             = COALESCE($5, script_body),
+			projection              = CASE WHEN $6::jsonb IS NULL THEN projection WHEN $6::jsonb->>'mode' = '' THEN NULL ELSE $6::jsonb END,
+			max_attempts_per_hour   = COALESCE($7, max_attempts_per_hour),
+			max_attempts_per_day    = COALESCE($8, max_attempts_per_day),
+			cloudevents_mode        = NULLIF(COALESCE($9, cloudevents_mode), ''),
+			event_types             = NULLIF(COALESCE($10::text[], event_types), '{}'),
+			max_requests_per_second = COALESCE($11, max_requests_per_second),
+			http_method             = NULLIF(COALESCE($12, http_method), ''),
+			url_template            = NULLIF(COALESCE($13, url_template), ''),
+			body_template           = NULLIF(COALESCE($14, body_template), ''),
+			proxy_url               = NULLIF(COALESCE($15, proxy_url), ''),
+			config                  = COALESCE($16::jsonb, config),
+			secret                  = NULLIF(COALESCE($17, secret), ''),
+			delivery_window         = NULLIF(COALESCE($18::jsonb, delivery_window), '{}'),
+			updated_at              = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id, f.TargetURL, f.SigningSecret, f.IsActive, f.ScriptBody, f.Projection, f.MaxAttemptsPerHour, f.MaxAttemptsPerDay, f.CloudEventsMode, f.EventTypes, f.MaxRequestsPerSecond, f.HTTPMethod, f.URLTemplate, f.BodyTemplate, f.ProxyURL, f.Config, f.Secret, f.DeliveryWindow,
+	), &a)
 	if err != nil {
 		return nil, fmt.Errorf("update action: %w", err)
 	}
 	return &a, nil
 }
 
+// SetVerificationToken stores a fresh ownership challenge token for the action.
+func (s *ActionStore) SetVerificationToken(ctx context.Context, id uuid.UUID, token string) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`UPDATE actions SET verification_token = $2, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id, token,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("set verification token: %w", err)
+	}
+	return &a, nil
+}
+
+// MarkVerified records that the action's target URL passed ownership verification.
+func (s *ActionStore) MarkVerified(ctx context.Context, id uuid.UUID) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`UPDATE actions SET verified_at = now(), updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("mark action verified: %w", err)
+	}
+	return &a, nil
+}
+
+// RotatePortalToken issues a new consumer portal token for the action,
+// replacing any previous one. Only its hash is stored, so the token is
+// returned here and can't be read back later.
+func (s *ActionStore) RotatePortalToken(ctx context.Context, id uuid.UUID) (string, error) {
+	token, err := randomHex(24)
+	if err != nil {
+		return "", fmt.Errorf("generate portal token: %w", err)
+	}
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE actions SET portal_token_hash = $2, updated_at = now() WHERE id = $1 AND deleted_at IS NULL`,
+		id, hashPortalToken(token),
+	)
+	if err != nil {
+		return "", fmt.Errorf("rotate portal token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return "", fmt.Errorf("rotate portal token: %w", pgx.ErrNoRows)
+	}
+	return token, nil
+}
+
+// ClearPortalToken revokes the action's portal access.
+func (s *ActionStore) ClearPortalToken(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `UPDATE actions SET portal_token_hash = NULL, updated_at = now() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("clear portal token: %w", err)
+	}
+	return nil
+}
+
+// GetByPortalToken returns the live action the portal token was issued for.
+func (s *ActionStore) GetByPortalToken(ctx context.Context, token string) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`SELECT `+actionColumns+`
+		 FROM actions WHERE portal_token_hash = $1 AND deleted_at IS NULL`,
+		hashPortalToken(token),
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("get action by portal token: %w", err)
+	}
+	return &a, nil
+}
+
+// RotateSigningSecret replaces the action's signing secret with a random one.
+func (s *ActionStore) RotateSigningSecret(ctx context.Context, id uuid.UUID) (*model.Action, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing secret: %w", err)
+	}
+	var a model.Action
+	err = scanAction(s.pool.QueryRow(ctx,
+		`UPDATE actions SET signing_secret = $2, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id, secret,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("rotate signing secret: %w", err)
+	}
+	return &a, nil
+}
+
+// SetClientTLS replaces the action's client certificate, sealed key and CA
+// bundle; nil clears each.
+func (s *ActionStore) SetClientTLS(ctx context.Context, id uuid.UUID, cert, key, caBundle *string) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`UPDATE actions SET tls_client_cert = $2, tls_client_key = $3, tls_ca_bundle = $4, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id, cert, key, caBundle,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("set client tls: %w", err)
+	}
+	return &a, nil
+}
+
+// SetCredential points the action at a stored credential; nil detaches it.
+func (s *ActionStore) SetCredential(ctx context.Context, id uuid.UUID, credentialID *uuid.UUID) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`UPDATE actions SET credential_id = $2, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id, credentialID,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("set credential: %w", err)
+	}
+	return &a, nil
+}
+
+// SetAuth sets the static auth header the action's webhook requests carry;
+// a nil authType removes it. secret must already be sealed.
+func (s *ActionStore) SetAuth(ctx context.Context, id uuid.UUID, authType *string, config json.RawMessage, secret *string) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`UPDATE actions SET auth_type = $2, auth_config = $3, auth_secret = $4, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id, authType, config, secret,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("set auth: %w", err)
+	}
+	return &a, nil
+}
+
+// SetSLOTarget sets the action's success objective; nil reverts to the
+// global one.
+func (s *ActionStore) SetSLOTarget(ctx context.Context, id uuid.UUID, target *float64) (*model.Action, error) {
+	var a model.Action
+	err := scanAction(s.pool.QueryRow(ctx,
+		`UPDATE actions SET slo_target = $2, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+actionColumns,
+		id, target,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("set slo target: %w", err)
+	}
+	return &a, nil
+}
+
+// SetSLOExhausted records when the action's error budget ran out; nil marks
+// it recovered.
+func (s *ActionStore) SetSLOExhausted(ctx context.Context, id uuid.UUID, at *time.Time) error {
+	_, err := s.pool.Exec(ctx, `UPDATE actions SET slo_exhausted_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("set slo exhausted: %w", err)
+	}
+	return nil
+}
+
+// Outcomes counts the final outcome of each delivery's attempts per action
+// since the given time, and separately since recentSince. A nil sourceID
+// covers every source. Actions without finished attempts are omitted.
+func (s *ActionStore) Outcomes(ctx context.Context, sourceID *uuid.UUID, since, recentSince time.Time) ([]model.ActionOutcomes, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT action_id, count(*), count(*) FILTER (WHERE status = 'failed'),
+		        count(*) FILTER (WHERE created_at >= $3),
+		        count(*) FILTER (WHERE created_at >= $3 AND status = 'failed')
+		 FROM (
+			SELECT DISTINCT ON (da.delivery_id, da.action_id) da.action_id, da.status, da.created_at
+			FROM delivery_attempts da
+			JOIN actions a ON a.id = da.action_id
+			WHERE da.created_at >= $2 AND NOT da.capped AND da.status <> 'pending'
+			  AND a.deleted_at IS NULL AND ($1::uuid IS NULL OR a.source_id = $1)
+			ORDER BY da.delivery_id, da.action_id, da.created_at DESC
+		 ) outcomes
+		 GROUP BY action_id`,
+		sourceID, since, recentSince,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("action outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []model.ActionOutcomes
+	for rows.Next() {
+		var o model.ActionOutcomes
+		if err := rows.Scan(&o.ActionID, &o.Total, &o.Failed, &o.RecentTotal, &o.RecentFailed); err != nil {
+			return nil, fmt.Errorf("scan action outcomes: %w", err)
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// RetryReasons counts the retries scheduled per action and reason for
+// attempts made since the given time. A nil sourceID covers every source.
+func (s *ActionStore) RetryReasons(ctx context.Context, sourceID *uuid.UUID, since time.Time) ([]model.RetryReasonCount, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT a.source_id, da.action_id, da.retry_reason, count(*)
+		 FROM delivery_attempts da
+		 JOIN actions a ON a.id = da.action_id
+		 WHERE da.created_at >= $2 AND da.retry_reason IS NOT NULL
+		   AND a.deleted_at IS NULL AND ($1::uuid IS NULL OR a.source_id = $1)
+		 GROUP BY a.source_id, da.action_id, da.retry_reason`,
+		sourceID, since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("retry reasons: %w", err)
+	}
+	defer rows.Close()
+
+	var out []model.RetryReasonCount
+	for rows.Next() {
+		var r model.RetryReasonCount
+		if err := rows.Scan(&r.SourceID, &r.ActionID, &r.Reason, &r.Count); err != nil {
+			return nil, fmt.Errorf("scan retry reasons: %w", err)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// ListActive returns every source's active actions.
+func (s *ActionStore) ListActive(ctx context.Context) ([]model.Action, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+actionColumns+`
+		 FROM actions WHERE is_active = true AND deleted_at IS NULL`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list active actions: %w", err)
+	}
+	defer rows.Close()
+
+	var actions []model.Action
+	for rows.Next() {
+		var a model.Action
+		if err := scanAction(rows, &a); err != nil {
+			return nil, fmt.Errorf("scan action: %w", err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
+
+func hashPortalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Delete tombstones the action. Its attempts are kept so the worker can tell
+// that pending retries belong to a removed action.
 func (s *ActionStore) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := s.pool.Exec(ctx, `DELETE FROM actions WHERE id = $1`, id)
+	_, err := s.pool.Exec(ctx, `UPDATE actions SET deleted_at = now(), is_active = false WHERE id = $1 AND deleted_at IS NULL`, id)
 	if err != nil {
 		return fmt.Errorf("delete action: %w", err)
 	}
@@ -92,8 +495,8 @@ func (s *ActionStore) Delete(ctx context.Context, id uuid.UUID) error {
 
 func (s *ActionStore) ListActiveBySource(ctx context.Context, sourceID uuid.UUID) ([]model.Action, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, source_id, type, target_url, script_body, signing_secret, is_active, created_at, updated_at
-		 FROM actions WHERE source_id = $1 AND is_active = true`,
+		`SELECT `+actionColumns+`
+		 FROM actions WHERE source_id = $1 AND is_active = true AND deleted_at IS NULL`,
 		sourceID,
 	)
 	if err != nil {
@@ -104,7 +507,7 @@ func (s *ActionStore) ListActiveBySource(ctx context.Context, sourceID uuid.UUID
 	var actions []model.Action
 	for rows.Next() {
 		var a model.Action
-		if err := rows.Scan(&a.ID, &a.SourceID, &a.Type, &a.TargetURL, &a.ScriptBody, &a.SigningSecret, &a.IsActive, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := scanAction(rows, &a); err != nil {
 			return nil, fmt.Errorf("scan action: %w", err)
 		}
 		actions = append(actions, a)
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// ArchiveStore tracks delivery archive objects and moves deliveries out of
+// (and back into) Postgres.
+type ArchiveStore struct {
+	pool *pgxpool.Pool
+}
+
+const archiveColumns = `id, source_id, day::text, object_key, delivery_count, bytes, created_at, updated_at`
+
+func scanArchive(row pgx.Row, a *model.DeliveryArchive) error {
+	return row.Scan(&a.ID, &a.SourceID, &a.Day, &a.ObjectKey, &a.DeliveryCount, &a.Bytes, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// archivable matches deliveries received (or restored) before @cutoff that
+// are settled: not in flight and with no retry scheduled.
+const archivable = `COALESCE(d.restored_at, d.received_at) < @cutoff
+	AND d.status NOT IN ('pending', 'processing')
+	AND NOT EXISTS (SELECT 1 FROM delivery_attempts a WHERE a.delivery_id = d.id AND a.next_retry_at IS NOT NULL)`
+
+// ArchiveGroup is a source's archivable deliveries for one day.
+type ArchiveGroup struct {
+	SourceID uuid.UUID
+	Day      time.Time
+}
+
+// ListGroups returns up to limit (source, day) pairs with archivable
+// deliveries, oldest first.
+func (s *ArchiveStore) ListGroups(ctx context.Context, cutoff time.Time, limit int) ([]ArchiveGroup, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT d.source_id, (d.received_at AT TIME ZONE 'UTC')::date AS day
+		 FROM deliveries d
+		 WHERE `+archivable+`
+		 GROUP BY 1, 2 ORDER BY 2, 1 LIMIT @limit`,
+		pgx.NamedArgs{"cutoff": cutoff, "limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list archive groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []ArchiveGroup
+	for rows.Next() {
+		var g ArchiveGroup
+		if err := rows.Scan(&g.SourceID, &g.Day); err != nil {
+			return nil, fmt.Errorf("scan archive group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// ListArchivable returns a page of a group's archivable deliveries with IDs
+// after the given one, in ID order.
+func (s *ArchiveStore) ListArchivable(ctx context.Context, g ArchiveGroup, cutoff time.Time, after uuid.UUID, limit int) ([]model.Delivery, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+deliveryColumns+`
+		 FROM deliveries d
+		 WHERE d.source_id = @source_id
+		   AND d.received_at >= @day AND d.received_at < @day + interval '1 day'
+		   AND d.id > @after
+		   AND `+archivable+`
+		 ORDER BY d.id LIMIT @limit`,
+		pgx.NamedArgs{"source_id": g.SourceID, "day": g.Day, "after": after, "cutoff": cutoff, "limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list archivable deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.Delivery
+	for rows.Next() {
+		var d model.Delivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ListAttempts returns the attempts of the given deliveries, keyed by
+// delivery ID.
+func (s *ArchiveStore) ListAttempts(ctx context.Context, deliveryIDs []uuid.UUID) (map[uuid.UUID][]model.DeliveryAttempt, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+attemptColumns+`
+		 FROM delivery_attempts WHERE delivery_id = ANY($1)
+		 ORDER BY created_at`,
+		deliveryIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make(map[uuid.UUID][]model.DeliveryAttempt)
+	for rows.Next() {
+		var a model.DeliveryAttempt
+		if err := scanAttempt(rows, &a); err != nil {
+			return nil, fmt.Errorf("scan attempt: %w", err)
+		}
+		attempts[a.DeliveryID] = append(attempts[a.DeliveryID], a)
+	}
+	return attempts, rows.Err()
+}
+
+// Get returns the archive for a source and day.
+func (s *ArchiveStore) Get(ctx context.Context, sourceID uuid.UUID, day time.Time) (*model.DeliveryArchive, error) {
+	var a model.DeliveryArchive
+	err := scanArchive(s.pool.QueryRow(ctx,
+		`SELECT `+archiveColumns+` FROM delivery_archives WHERE source_id = $1 AND day = $2::date`,
+		sourceID, day,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("get archive: %w", err)
+	}
+	return &a, nil
+}
+
+func (s *ArchiveStore) GetByID(ctx context.Context, id uuid.UUID) (*model.DeliveryArchive, error) {
+	var a model.DeliveryArchive
+	err := scanArchive(s.pool.QueryRow(ctx,
+		`SELECT `+archiveColumns+` FROM delivery_archives WHERE id = $1`,
+		id,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("get archive: %w", err)
+	}
+	return &a, nil
+}
+
+// List returns archives newest first, optionally for one source.
+func (s *ArchiveStore) List(ctx context.Context, sourceID *uuid.UUID, limit int) ([]model.DeliveryArchive, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+archiveColumns+` FROM delivery_archives
+		 WHERE $1::uuid IS NULL OR source_id = $1
+		 ORDER BY day DESC, source_id LIMIT $2`,
+		sourceID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list archives: %w", err)
+	}
+	defer rows.Close()
+
+	var archives []model.DeliveryArchive
+	for rows.Next() {
+		var a model.DeliveryArchive
+		if err := scanArchive(rows, &a); err != nil {
+			return nil, fmt.Errorf("scan archive: %w", err)
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+// Commit deletes the deliveries (and their attempts) written to the group's
+// archive object, now bytes long, and records the archive in the same
+// transaction. Deliveries that stopped being archivable since they were read
+// are kept; their copy in the object is harmless.
+func (s *ArchiveStore) Commit(ctx context.Context, g ArchiveGroup, cutoff time.Time, objectKey string, bytes int64, deliveryIDs []uuid.UUID) (int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin archive commit: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`DELETE FROM deliveries d WHERE d.id = ANY(@ids) AND `+archivable,
+		pgx.NamedArgs{"ids": deliveryIDs, "cutoff": cutoff},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete archived deliveries: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO delivery_archives (source_id, day, object_key, delivery_count, bytes)
+		 VALUES ($1, $2::date, $3, $4, $5)
+		 ON CONFLICT (source_id, day) DO UPDATE SET
+			object_key     = EXCLUDED.object_key,
+			delivery_count = delivery_archives.delivery_count + EXCLUDED.delivery_count,
+			bytes          = EXCLUDED.bytes,
+			updated_at     = now()`,
+		g.SourceID, g.Day, objectKey, tag.RowsAffected(), bytes,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("record archive: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit archive: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Restore inserts an archived delivery and its attempts back into Postgres,
+// marking it restored. It returns false if the delivery (or another with its
+// idempotency key) already exists. Attempts for actions that no longer exist
+// are skipped.
+func (s *ArchiveStore) Restore(ctx context.Context, d *model.Delivery, attempts []model.DeliveryAttempt) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin restore: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO deliveries (id, source_id, idempotency_key, headers, payload, status, status_reason, simulated, request_id, method, query_params, remote_addr, event_type, cloud_event, replay_of, received_at, transformed_payload, transformed_headers, deliver_at, detected_provider, coalesce_key, coalesced_into, restored_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, (SELECT id FROM deliveries WHERE id = $15), $16, $17, $18, $19, $20, $21, (SELECT id FROM deliveries WHERE id = $22), now())
+		 ON CONFLICT DO NOTHING`,
+		d.ID, d.SourceID, d.IdempotencyKey, d.Headers, d.Payload, d.Status, d.StatusReason, d.Simulated, d.RequestID, d.Method, d.QueryParams, d.RemoteAddr, d.EventType, d.CloudEvent, d.ReplayOf, d.ReceivedAt, d.TransformedPayload, d.TransformedHeaders, d.DeliverAt, d.DetectedProvider, d.CoalesceKey, d.CoalescedInto,
+	)
+	if err != nil {
+		return false, fmt.Errorf("restore delivery: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	// The insert trigger counted the delivery as received again
+	_, err = tx.Exec(ctx,
+		`UPDATE source_delivery_hourly SET received = received - 1
+		 WHERE source_id = $1 AND hour = date_trunc('hour', $2::timestamptz) AND received > 0`,
+		d.SourceID, d.ReceivedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("adjust rollup: %w", err)
+	}
+
+	for _, a := range attempts {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO delivery_attempts (id, delivery_id, action_id, attempt_number, status, response_status, response_body, error_message, capped, created_at, dns_ms, connect_ms, tls_ms, ttfb_ms, total_ms, retry_reason)
+			 SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
+			 WHERE EXISTS (SELECT 1 FROM actions WHERE id = $3)`,
+			a.ID, a.DeliveryID, a.ActionID, a.AttemptNumber, a.Status, a.ResponseStatus, a.ResponseBody, a.ErrorMessage, a.Capped, a.CreatedAt,
+			a.DNSMs, a.ConnectMs, a.TLSMs, a.TTFBMs, a.TotalMs, a.RetryReason,
+		)
+		if err != nil {
+			return false, fmt.Errorf("restore attempt: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit restore: %w", err)
+	}
+	return true, nil
+}
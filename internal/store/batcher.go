@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// DeliveryBatcher groups delivery inserts from concurrent ingest requests into
+// a single transaction (group commit). Each caller still waits for the commit
+// of its batch, so an acknowledged delivery is as durable as the commit mode
+// allows.
+type DeliveryBatcher struct {
+	pool       *pgxpool.Pool
+	window     time.Duration
+	maxSize    int
+	syncCommit bool
+	reqs       chan *batchItem
+}
+
+type batchItem struct {
+	nd   NewDelivery
+	done chan batchResult
+}
+
+type batchResult struct {
+	d       *model.Delivery
+	created bool
+	err     error
+}
+
+// NewDeliveryBatcher creates a batcher that flushes after window or once
+// maxSize inserts are queued. With syncCommit false batches commit with
+// synchronous_commit off, which trades a small window of possible loss on a
+// database crash for higher throughput.
+func NewDeliveryBatcher(s *Store, window time.Duration, maxSize int, syncCommit bool) *DeliveryBatcher {
+	return &DeliveryBatcher{
+		pool:       s.pool,
+		window:     window,
+		maxSize:    maxSize,
+		syncCommit: syncCommit,
+		reqs:       make(chan *batchItem, maxSize),
+	}
+}
+
+// Run collects and flushes batches until ctx is cancelled, then flushes what
+// is still queued. Cancel ctx only once no more Create calls can arrive, such
+// as after the HTTP server has shut down.
+func (b *DeliveryBatcher) Run(ctx context.Context) {
+	for {
+		var first *batchItem
+		select {
+		case <-ctx.Done():
+			b.drain(context.WithoutCancel(ctx))
+			return
+		case first = <-b.reqs:
+		}
+
+		items := []*batchItem{first}
+		timer := time.NewTimer(b.window)
+	collect:
+		for len(items) < b.maxSize {
+			select {
+			case it := <-b.reqs:
+				items = append(items, it)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		b.flush(context.WithoutCancel(ctx), items)
+	}
+}
+
+// drain flushes the inserts still queued.
+func (b *DeliveryBatcher) drain(ctx context.Context) {
+	for {
+		var items []*batchItem
+	collect:
+		for len(items) < b.maxSize {
+			select {
+			case it := <-b.reqs:
+				items = append(items, it)
+			default:
+				break collect
+			}
+		}
+		if len(items) == 0 {
+			return
+		}
+		b.flush(ctx, items)
+	}
+}
+
+// Create queues a delivery insert and waits for its batch to commit. Like
+// DeliveryStore.Create it returns an existing delivery with the same
+// idempotency key instead of inserting a duplicate.
+func (b *DeliveryBatcher) Create(ctx context.Context, nd NewDelivery) (*model.Delivery, bool, error) {
+	it := &batchItem{nd: nd, done: make(chan batchResult, 1)}
+	select {
+	case b.reqs <- it:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	select {
+	case res := <-it.done:
+		return res.d, res.created, res.err
+	case <-ctx.Done():
+		// The insert may still commit; the catch-up poller will pick it up.
+		return nil, false, ctx.Err()
+	}
+}
+
+// flush inserts the batch in one transaction. If that fails, such as when one
+// item's source was just deleted, the items are inserted one at a time so
+// only the failing ones get an error.
+func (b *DeliveryBatcher) flush(ctx context.Context, items []*batchItem) {
+	results, err := b.insert(ctx, items)
+	if err != nil && len(items) > 1 {
+		slog.WarnContext(ctx, "delivery batch failed, inserting one at a time", "error", err, "size", len(items))
+	}
+	for i, it := range items {
+		if err != nil {
+			d, created, err := (&DeliveryStore{pool: b.pool}).Create(ctx, it.nd)
+			it.done <- batchResult{d: d, created: created, err: err}
+			continue
+		}
+		if results[i].d == nil {
+			// Lost a race with a concurrent insert of the same key
+			d, created, err := (&DeliveryStore{pool: b.pool}).getByIdempotencyKey(ctx, it.nd)
+			it.done <- batchResult{d: d, created: created, err: err}
+			continue
+		}
+		it.done <- results[i]
+	}
+}
+
+func (b *DeliveryBatcher) insert(ctx context.Context, items []*batchItem) ([]batchResult, error) {
+	tx, err := b.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin delivery batch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if !b.syncCommit {
+		if _, err := tx.Exec(ctx, `SET LOCAL synchronous_commit = off`); err != nil {
+			return nil, fmt.Errorf("disable synchronous commit: %w", err)
+		}
+	}
+
+	batch := &pgx.Batch{}
+	for _, it := range items {
+		batch.Queue(insertDelivery, it.nd.args()...)
+	}
+	results := tx.SendBatch(ctx, batch)
+	out := make([]batchResult, len(items))
+	for i := range items {
+		var d model.Delivery
+		var created bool
+		err := scanDelivery(results.QueryRow(), &d, &created)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			results.Close()
+			return nil, fmt.Errorf("create delivery: %w", err)
+		}
+		out[i] = batchResult{d: &d, created: created}
+	}
+	if err := results.Close(); err != nil {
+		return nil, fmt.Errorf("close delivery batch: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit delivery batch: %w", err)
+	}
+	return out, nil
+}
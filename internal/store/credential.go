@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// ErrCredentialInUse is returned when deleting a credential actions still
+// reference.
+var ErrCredentialInUse = errors.New("credential is in use by actions")
+
+// CredentialStore holds reusable destination credentials.
+type CredentialStore struct {
+	pool *pgxpool.Pool
+}
+
+const credentialColumns = `id, name, type, config, secret, created_at, updated_at`
+
+func scanCredential(row pgx.Row, c *model.Credential) error {
+	return row.Scan(&c.ID, &c.Name, &c.Type, &c.Config, &c.Secret, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// Create stores a credential; secret must already be sealed.
+func (s *CredentialStore) Create(ctx context.Context, name, credType string, config json.RawMessage, secret string) (*model.Credential, error) {
+	var c model.Credential
+	err := scanCredential(s.pool.QueryRow(ctx,
+		`INSERT INTO credentials (name, type, config, secret) VALUES ($1, $2, $3, $4)
+		 RETURNING `+credentialColumns,
+		name, credType, config, secret,
+	), &c)
+	if err != nil {
+		return nil, fmt.Errorf("create credential: %w", err)
+	}
+	return &c, nil
+}
+
+// List returns every credential by name.
+func (s *CredentialStore) List(ctx context.Context) ([]model.Credential, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+credentialColumns+` FROM credentials ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var creds []model.Credential
+	for rows.Next() {
+		var c model.Credential
+		if err := scanCredential(rows, &c); err != nil {
+			return nil, fmt.Errorf("scan credential: %w", err)
+		}
+		creds = append(creds, c)
+	}
+	return creds, rows.Err()
+}
+
+func (s *CredentialStore) GetByID(ctx context.Context, id uuid.UUID) (*model.Credential, error) {
+	var c model.Credential
+	err := scanCredential(s.pool.QueryRow(ctx,
+		`SELECT `+credentialColumns+` FROM credentials WHERE id = $1`, id,
+	), &c)
+	if err != nil {
+		return nil, fmt.Errorf("get credential: %w", err)
+	}
+	return &c, nil
+}
+
+// Update replaces the credential's settings. Nil fields are left unchanged;
+// a new secret must already be sealed.
+func (s *CredentialStore) Update(ctx context.Context, id uuid.UUID, name *string, config json.RawMessage, secret *string) (*model.Credential, error) {
+	var c model.Credential
+	err := scanCredential(s.pool.QueryRow(ctx,
+		`UPDATE credentials SET
+			name = COALESCE($2, name),
+			config = COALESCE($3, config),
+			secret = COALESCE($4, secret),
+			updated_at = now()
+		 WHERE id = $1
+		 RETURNING `+credentialColumns,
+		id, name, config, secret,
+	), &c)
+	if err != nil {
+		return nil, fmt.Errorf("update credential: %w", err)
+	}
+	return &c, nil
+}
+
+// Delete removes the credential, failing with ErrCredentialInUse while
+// actions reference it. Tombstoned actions are detached first.
+func (s *CredentialStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE actions SET credential_id = NULL WHERE credential_id = $1 AND deleted_at IS NOT NULL`, id); err != nil {
+		return fmt.Errorf("detach deleted actions: %w", err)
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM credentials WHERE id = $1`, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "foreign key") {
+			return ErrCredentialInUse
+		}
+		return fmt.Errorf("delete credential: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return tx.Commit(ctx)
+}
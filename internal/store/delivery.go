@@ -3,39 +3,294 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/retryreason"
 )
 
 type DeliveryStore struct {
 	pool *pgxpool.Pool
 }
 
-func (s *DeliveryStore) Create(ctx context.Context, sourceID uuid.UUID, idempotencyKey string, headers, payload json.RawMessage) (*model.Delivery, error) {
+const deliveryColumns = `id, source_id, idempotency_key, headers, payload, status, status_reason, simulated, request_id, method, query_params, remote_addr, event_type, cloud_event, replay_of, received_at, transformed_payload, transformed_headers, deliver_at, detected_provider, coalesce_key, coalesced_into, reprocess_count, reprocessed_at`
+
+// scanDelivery scans deliveryColumns into d, followed by any extra columns.
+func scanDelivery(row pgx.Row, d *model.Delivery, extra ...any) error {
+	return row.Scan(append([]any{&d.ID, &d.SourceID, &d.IdempotencyKey, &d.Headers, &d.Payload, &d.Status, &d.StatusReason, &d.Simulated, &d.RequestID, &d.Method, &d.QueryParams, &d.RemoteAddr, &d.EventType, &d.CloudEvent, &d.ReplayOf, &d.ReceivedAt, &d.TransformedPayload, &d.TransformedHeaders, &d.DeliverAt, &d.DetectedProvider, &d.CoalesceKey, &d.CoalescedInto, &d.ReprocessCount, &d.ReprocessedAt}, extra...)...)
+}
+
+// deliverySummaryJoin folds each delivery's attempts by the latest attempt
+// per action; select deliverySummaryColumns and scan into summaryDest.
+const deliverySummaryJoin = `
+	LEFT JOIN LATERAL (
+		SELECT count(*) AS total_actions,
+		       count(*) FILTER (WHERE latest.status = 'success') AS succeeded,
+		       count(*) FILTER (WHERE latest.status = 'failed' AND latest.next_retry_at IS NULL) AS failed,
+		       count(*) FILTER (WHERE latest.status = 'failed' AND latest.next_retry_at IS NOT NULL) AS pending_retries,
+		       max(latest.last_attempt_at) AS last_attempt_at
+		FROM (
+			SELECT DISTINCT ON (action_id) status, next_retry_at,
+			       max(created_at) OVER (PARTITION BY action_id) AS last_attempt_at
+			FROM delivery_attempts
+			WHERE delivery_id = deliveries.id
+			ORDER BY action_id, attempt_number DESC, created_at DESC
+		) latest
+	) summary ON true`
+
+const deliverySummaryColumns = `, summary.total_actions, summary.succeeded, summary.failed, summary.pending_retries, summary.last_attempt_at`
+
+func summaryDest(d *model.Delivery) []any {
+	d.Summary = &model.DeliverySummary{}
+	return []any{&d.Summary.TotalActions, &d.Summary.Succeeded, &d.Summary.Failed, &d.Summary.PendingRetries, &d.Summary.LastAttemptAt}
+}
+
+// NewDelivery holds the fields of a delivery being ingested.
+type NewDelivery struct {
+	SourceID       uuid.UUID
+	IdempotencyKey string
+	Headers        json.RawMessage
+	Payload        json.RawMessage
+	// Simulated marks deliveries injected by the simulator rather than
+	// received from a provider.
+	Simulated bool
+	// RequestID is the ingest request's correlation ID.
+	RequestID string
+	// Method, QueryParams and RemoteAddr describe the inbound HTTP request;
+	// they are empty for simulated deliveries.
+	Method      string
+	QueryParams json.RawMessage
+	RemoteAddr  string
+	// EventType is the detected event type, if any.
+	EventType string
+	// CloudEvent holds the context attributes of a CloudEvents delivery.
+	CloudEvent json.RawMessage
+	// ReplayOf is set on replays to the original delivery's ID.
+	ReplayOf *uuid.UUID
+	// ID and ReceivedAt are set when the delivery was accepted before being
+	// persisted (ingest fast path); otherwise the database assigns them.
+	ID         *uuid.UUID
+	ReceivedAt *time.Time
+	// DeliverAt holds the delivery back from fan-out until then.
+	DeliverAt *time.Time
+	// DelaySeconds, when DeliverAt is nil, holds the delivery back that
+	// long from insert, measured by the database clock.
+	DelaySeconds *int
+	// DetectedProvider is the provider fingerprinted from the request
+	// headers, if any.
+	DetectedProvider string
+	// CoalesceKey is the value of the source's coalesce key in the payload;
+	// empty when the source doesn't coalesce.
+	CoalesceKey string
+	// Quarantine, when set, is why the delivery failed its signature check.
+	// It is inserted as quarantined with this reason and, being unverified,
+	// doesn't claim its idempotency key.
+	Quarantine string
+}
+
+// insertDelivery inserts a delivery unless one with the same idempotency key
+// exists for the source, in which case the existing row is returned. The
+// trailing column reports whether the row was inserted. Unverified rows
+// never conflict or match.
+const insertDelivery = `WITH ins AS (
+		INSERT INTO deliveries (source_id, idempotency_key, headers, payload, simulated, request_id, id, received_at, method, query_params, remote_addr, cloud_event, event_type, replay_of, deliver_at, scheduled, content_hash, detected_provider, coalesce_key, status, status_reason, unverified)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), COALESCE($7, gen_random_uuid()), COALESCE($8, now()), NULLIF($9, ''), $10, NULLIF($11, ''), $12, NULLIF($13, ''), $14, COALESCE($15, now() + $20::int * interval '1 second'), $15 IS NOT NULL OR $20 IS NOT NULL, $16, NULLIF($17, ''), NULLIF($18, ''),
+			CASE WHEN $19 = '' THEN 'pending' ELSE 'quarantined' END::delivery_status, NULLIF($19, ''), $19 <> '')
+		ON CONFLICT (source_id, idempotency_key) WHERE NOT unverified DO NOTHING
+		RETURNING ` + deliveryColumns + `
+	)
+	SELECT ` + deliveryColumns + `, true FROM ins
+	UNION ALL
+	SELECT ` + deliveryColumns + `, false FROM deliveries
+	WHERE source_id = $1 AND idempotency_key = $2 AND NOT unverified AND NOT EXISTS (SELECT 1 FROM ins)`
+
+func (nd NewDelivery) args() []any {
+	return []any{nd.SourceID, nd.IdempotencyKey, nd.Headers, nd.Payload, nd.Simulated, nd.RequestID, nd.ID, nd.ReceivedAt, nd.Method, nd.QueryParams, nd.RemoteAddr, nd.CloudEvent, nd.EventType, nd.ReplayOf, nd.DeliverAt, ContentHash(nd.Payload), nd.DetectedProvider, nd.CoalesceKey, nd.Quarantine, nd.DelaySeconds}
+}
+
+// ErrSourceNotFound is returned when creating a delivery for a source that
+// doesn't exist, such as one deleted after the request was accepted.
+var ErrSourceNotFound = errors.New("source not found")
+
+// Create stores a new pending delivery. If the source already has a delivery
+// with the same idempotency key, that delivery is returned with created false.
+func (s *DeliveryStore) Create(ctx context.Context, nd NewDelivery) (*model.Delivery, bool, error) {
 	var d model.Delivery
-	err := s.pool.QueryRow(ctx,
-		`INSERT INTO deliveries (source_id, idempotency_key, headers, payload)
-		 VALUES ($1, $2, $3, $4)
-		 RETURNING id, source_id, idempotency_key, headers, payload, status, received_at, transformed_payload, transformed_headers`,
-		sourceID, idempotencyKey, headers, payload,
-	).Scan(&d.ID, &d.SourceID, &d.IdempotencyKey, &d.Headers, &d.Payload, &d.Status, &d.ReceivedAt, &d.TransformedPayload, &d.TransformedHeaders)
+	var created bool
+	err := scanDelivery(s.pool.QueryRow(ctx, insertDelivery, nd.args()...), &d, &created)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// A concurrent insert of the same key committed after this
+		// statement's snapshot was taken; it is visible to a new one.
+		return s.getByIdempotencyKey(ctx, nd)
+	}
+	if hasCode(err, codeForeignKeyViolation) {
+		return nil, false, ErrSourceNotFound
+	}
 	if err != nil {
-		return nil, fmt.Errorf("create delivery: %w", err)
+		return nil, false, fmt.Errorf("create delivery: %w", err)
+	}
+	return &d, created, nil
+}
+
+func (s *DeliveryStore) getByIdempotencyKey(ctx context.Context, nd NewDelivery) (*model.Delivery, bool, error) {
+	var d model.Delivery
+	err := scanDelivery(s.pool.QueryRow(ctx,
+		`SELECT `+deliveryColumns+` FROM deliveries WHERE source_id = $1 AND idempotency_key = $2 AND NOT unverified`,
+		nd.SourceID, nd.IdempotencyKey,
+	), &d)
+	if err != nil {
+		return nil, false, fmt.Errorf("get delivery by idempotency key: %w", err)
+	}
+	return &d, false, nil
+}
+
+// FindByContent returns the source's latest delivery with the given payload
+// hash received since the given time.
+func (s *DeliveryStore) FindByContent(ctx context.Context, sourceID uuid.UUID, hash string, since time.Time) (*model.Delivery, error) {
+	var d model.Delivery
+	err := scanDelivery(s.pool.QueryRow(ctx,
+		`SELECT `+deliveryColumns+`
+		 FROM deliveries
+		 WHERE source_id = $1 AND content_hash = $2 AND received_at >= $3 AND replay_of IS NULL AND NOT unverified
+		 ORDER BY received_at DESC LIMIT 1`,
+		sourceID, hash, since,
+	), &d)
+	if err != nil {
+		return nil, fmt.Errorf("find delivery by content: %w", err)
 	}
 	return &d, nil
 }
 
+// Coalesce collapses the source's scheduled pending deliveries sharing key
+// into the latest one, which takes over the earliest release time so a
+// steady stream of events can't postpone dispatch indefinitely. The others
+// are marked coalesced into it. It returns the winner's ID and release time.
+func (s *DeliveryStore) Coalesce(ctx context.Context, sourceID uuid.UUID, key string) (uuid.UUID, time.Time, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, time.Time{}, fmt.Errorf("begin coalesce: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize concurrent coalescing of the same key
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1::text || ':' || $2, 0))`, sourceID, key); err != nil {
+		return uuid.Nil, time.Time{}, fmt.Errorf("lock coalesce key: %w", err)
+	}
+
+	var winner uuid.UUID
+	var deliverAt time.Time
+	err = tx.QueryRow(ctx,
+		`WITH grp AS (
+			SELECT id, received_at, deliver_at FROM deliveries
+			WHERE source_id = $1 AND coalesce_key = $2 AND scheduled AND status = 'pending'
+			FOR UPDATE
+		 ), win AS (
+			SELECT id, (SELECT min(deliver_at) FROM grp) AS deliver_at
+			FROM grp ORDER BY received_at DESC, id DESC LIMIT 1
+		 ), losers AS (
+			UPDATE deliveries d SET status = 'coalesced', scheduled = false, coalesced_into = win.id,
+				status_reason = 'superseded by a later delivery with the same coalesce key'
+			FROM win
+			WHERE d.id IN (SELECT id FROM grp) AND d.id <> win.id
+			RETURNING d.id
+		 ), repointed AS (
+			UPDATE deliveries d SET coalesced_into = win.id
+			FROM win
+			WHERE d.coalesced_into IN (SELECT id FROM losers)
+		 ), moved AS (
+			UPDATE deliveries d SET deliver_at = win.deliver_at
+			FROM win
+			WHERE d.id = win.id
+		 )
+		 SELECT id, deliver_at FROM win`,
+		sourceID, key,
+	).Scan(&winner, &deliverAt)
+	if err != nil {
+		return uuid.Nil, time.Time{}, fmt.Errorf("coalesce deliveries: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, time.Time{}, fmt.Errorf("commit coalesce: %w", err)
+	}
+	return winner, deliverAt, nil
+}
+
+// DetectedProvider returns the provider most often fingerprinted among the
+// source's latest sample non-simulated deliveries, how many of them it was
+// detected on, and how many deliveries were sampled.
+func (s *DeliveryStore) DetectedProvider(ctx context.Context, sourceID uuid.UUID, sample int) (provider string, matches, sampled int, err error) {
+	err = s.pool.QueryRow(ctx,
+		`WITH recent AS (
+			SELECT detected_provider FROM deliveries
+			WHERE source_id = $1 AND NOT simulated AND replay_of IS NULL
+			ORDER BY received_at DESC LIMIT $2
+		 )
+		 SELECT COALESCE(top.detected_provider, ''), COALESCE(top.n, 0), (SELECT count(*) FROM recent)
+		 FROM (SELECT 1) AS one
+		 LEFT JOIN (
+			SELECT detected_provider, count(*) AS n FROM recent
+			WHERE detected_provider IS NOT NULL
+			GROUP BY detected_provider ORDER BY n DESC, detected_provider LIMIT 1
+		 ) AS top ON true`,
+		sourceID, sample,
+	).Scan(&provider, &matches, &sampled)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("detected provider: %w", err)
+	}
+	return provider, matches, sampled, nil
+}
+
+// RecentPayloads returns the payloads of the source's most recent received
+// deliveries, newest first.
+func (s *DeliveryStore) RecentPayloads(ctx context.Context, sourceID uuid.UUID, limit int) ([]json.RawMessage, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT payload FROM deliveries
+		 WHERE source_id = $1 AND NOT simulated AND replay_of IS NULL
+		 ORDER BY received_at DESC LIMIT $2`,
+		sourceID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("recent payloads: %w", err)
+	}
+	defer rows.Close()
+
+	var payloads []json.RawMessage
+	for rows.Next() {
+		var p json.RawMessage
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("scan payload: %w", err)
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, rows.Err()
+}
+
 func (s *DeliveryStore) GetByID(ctx context.Context, id uuid.UUID) (*model.Delivery, error) {
 	var d model.Delivery
-	err := s.pool.QueryRow(ctx,
-		`SELECT id, source_id, idempotency_key, headers, payload, status, received_at, transformed_payload, transformed_headers
+	err := scanDelivery(s.pool.QueryRow(ctx,
+		`SELECT `+deliveryColumns+`
 		 FROM deliveries WHERE id = $1`,
 		id,
-	).Scan(&d.ID, &d.SourceID, &d.IdempotencyKey, &d.Headers, &d.Payload, &d.Status, &d.ReceivedAt, &d.TransformedPayload, &d.TransformedHeaders)
+	), &d)
+	if err != nil {
+		return nil, fmt.Errorf("get delivery: %w", err)
+	}
+	return &d, nil
+}
+
+// GetWithSummary is GetByID with the attempt summary filled in.
+func (s *DeliveryStore) GetWithSummary(ctx context.Context, id uuid.UUID) (*model.Delivery, error) {
+	var d model.Delivery
+	err := scanDelivery(s.pool.QueryRow(ctx,
+		`SELECT `+deliveryColumns+deliverySummaryColumns+`
+		 FROM deliveries`+deliverySummaryJoin+`
+		 WHERE id = $1`,
+		id,
+	), &d, summaryDest(&d)...)
 	if err != nil {
 		return nil, fmt.Errorf("get delivery: %w", err)
 	}
@@ -43,18 +298,18 @@ func (s *DeliveryStore) GetByID(ctx context.Context, id uuid.UUID) (*model.Deliv
 }
 
 func (s *DeliveryStore) List(ctx context.Context, sourceSlug *string, limit int) ([]model.Delivery, error) {
-	query := `SELECT d.id, d.source_id, d.idempotency_key, d.headers, d.payload, d.status, d.received_at, d.transformed_payload, d.transformed_headers
-		 FROM deliveries d`
+	query := `SELECT ` + deliveryColumns + deliverySummaryColumns + `
+		 FROM deliveries` + deliverySummaryJoin
 	args := []any{}
 	argIdx := 1
 
 	if sourceSlug != nil {
-		query += fmt.Sprintf(` JOIN sources s ON d.source_id = s.id WHERE s.slug = $%d`, argIdx)
+		query += fmt.Sprintf(` WHERE source_id = (SELECT id FROM sources WHERE slug = $%d)`, argIdx)
 		args = append(args, *sourceSlug)
 		argIdx++
 	}
 
-	query += ` ORDER BY d.received_at DESC`
+	query += ` ORDER BY received_at DESC`
 	query += fmt.Sprintf(` LIMIT $%d`, argIdx)
 	args = append(args, limit)
 
@@ -67,7 +322,33 @@ func (s *DeliveryStore) List(ctx context.Context, sourceSlug *string, limit int)
 	var deliveries []model.Delivery
 	for rows.Next() {
 		var d model.Delivery
-		if err := rows.Scan(&d.ID, &d.SourceID, &d.IdempotencyKey, &d.Headers, &d.Payload, &d.Status, &d.ReceivedAt, &d.TransformedPayload, &d.TransformedHeaders); err != nil {
+		if err := scanDelivery(rows, &d, summaryDest(&d)...); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ListByAction returns the most recent deliveries the action was attempted
+// for, newest first.
+func (s *DeliveryStore) ListByAction(ctx context.Context, actionID uuid.UUID, limit int) ([]model.Delivery, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+deliveryColumns+`
+		 FROM deliveries
+		 WHERE id IN (SELECT delivery_id FROM delivery_attempts WHERE action_id = $1)
+		 ORDER BY received_at DESC LIMIT $2`,
+		actionID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list deliveries by action: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.Delivery
+	for rows.Next() {
+		var d model.Delivery
+		if err := scanDelivery(rows, &d); err != nil {
 			return nil, fmt.Errorf("scan delivery: %w", err)
 		}
 		deliveries = append(deliveries, d)
@@ -75,14 +356,218 @@ func (s *DeliveryStore) List(ctx context.Context, sourceSlug *string, limit int)
 	return deliveries, rows.Err()
 }
 
+// UpdateStatus sets the delivery's status, unless it was cancelled, which is
+// final.
 func (s *DeliveryStore) UpdateStatus(ctx context.Context, id uuid.UUID, status model.DeliveryStatus) error {
-	_, err := s.pool.Exec(ctx, `UPDATE deliveries SET status = $2 WHERE id = $1`, id, status)
+	_, err := s.pool.Exec(ctx, `UPDATE deliveries SET status = $2, queued_at = NULL WHERE id = $1 AND status <> 'cancelled'`, id, status)
 	if err != nil {
 		return fmt.Errorf("update delivery status: %w", err)
 	}
 	return nil
 }
 
+// MarkQueued records that a pending delivery is waiting in its source's
+// in-flight queue, keeping it out of ListPending for a while.
+func (s *DeliveryStore) MarkQueued(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `UPDATE deliveries SET queued_at = now() WHERE id = $1 AND status = 'pending'`, id)
+	if err != nil {
+		return fmt.Errorf("mark delivery queued: %w", err)
+	}
+	return nil
+}
+
+// SetStatusReason records why a delivery ended up in its status, leaving
+// the status itself alone.
+func (s *DeliveryStore) SetStatusReason(ctx context.Context, id uuid.UUID, reason string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE deliveries SET status_reason = $2 WHERE id = $1`, id, reason)
+	if err != nil {
+		return fmt.Errorf("set delivery status reason: %w", err)
+	}
+	return nil
+}
+
+// ListQuarantined returns quarantined deliveries, newest first, optionally
+// for one source.
+func (s *DeliveryStore) ListQuarantined(ctx context.Context, sourceSlug *string, limit int) ([]model.Delivery, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+deliveryColumns+`
+		 FROM deliveries
+		 WHERE status = 'quarantined'
+		   AND ($1::text IS NULL OR source_id = (SELECT id FROM sources WHERE slug = $1))
+		 ORDER BY received_at DESC
+		 LIMIT $2`,
+		sourceSlug, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list quarantined deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.Delivery
+	for rows.Next() {
+		var d model.Delivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ErrDuplicateKey is returned when approving an unverified delivery whose
+// idempotency key another delivery of the source has since claimed.
+var ErrDuplicateKey = errors.New("idempotency key already used")
+
+// ReleaseQuarantined moves a quarantined delivery back to pending so it can
+// be queued. With skipTransform the original payload and headers are stored
+// as the transformed ones, which makes the worker dispatch them without
+// running transforms. An approved unverified delivery claims its idempotency
+// key, failing with ErrDuplicateKey if it's taken. It returns pgx.ErrNoRows
+// if the delivery isn't quarantined.
+func (s *DeliveryStore) ReleaseQuarantined(ctx context.Context, id uuid.UUID, skipTransform bool) (*model.Delivery, error) {
+	var d model.Delivery
+	err := scanDelivery(s.pool.QueryRow(ctx,
+		`UPDATE deliveries SET
+			status              = 'pending',
+			status_reason       = NULL,
+			unverified          = false,
+			transformed_payload = CASE WHEN $2 THEN payload ELSE NULL END,
+			transformed_headers = CASE WHEN $2 THEN headers ELSE NULL END
+		 WHERE id = $1 AND status = 'quarantined'
+		 RETURNING `+deliveryColumns,
+		id, skipTransform,
+	), &d)
+	if hasCode(err, codeUniqueViolation) {
+		return nil, ErrDuplicateKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("release quarantined delivery: %w", err)
+	}
+	return &d, nil
+}
+
+// reprocessSet resets a delivery stopped by a failed transform to pending,
+// clearing the transform's output so fan-out runs the current scripts.
+const reprocessSet = `
+			status              = 'pending',
+			status_reason       = NULL,
+			transformed_payload = NULL,
+			transformed_headers = NULL,
+			reprocess_count     = reprocess_count + 1,
+			reprocessed_at      = now()`
+
+// transformFailedWhere matches deliveries stopped by a failed transform; $1
+// is model.TransformFailedReason.
+const transformFailedWhere = `status IN ('failed', 'quarantined') AND starts_with(status_reason, $1)`
+
+// Reprocess queues a delivery stopped by a failed transform to run through
+// the current scripts and be dispatched again. pgx.ErrNoRows means its
+// transform didn't fail.
+func (s *DeliveryStore) Reprocess(ctx context.Context, id uuid.UUID) (*model.Delivery, error) {
+	var d model.Delivery
+	err := scanDelivery(s.pool.QueryRow(ctx,
+		`UPDATE deliveries SET `+reprocessSet+`
+		 WHERE id = $2 AND `+transformFailedWhere+`
+		 RETURNING `+deliveryColumns,
+		model.TransformFailedReason, id,
+	), &d)
+	if err != nil {
+		return nil, fmt.Errorf("reprocess delivery: %w", err)
+	}
+	return &d, nil
+}
+
+// ReprocessSource reprocesses up to limit of the source's deliveries stopped
+// by a failed transform, oldest first.
+func (s *DeliveryStore) ReprocessSource(ctx context.Context, sourceID uuid.UUID, limit int) ([]model.Delivery, error) {
+	rows, err := s.pool.Query(ctx,
+		`UPDATE deliveries SET `+reprocessSet+`
+		 WHERE id IN (
+			SELECT id FROM deliveries
+			WHERE source_id = $2 AND `+transformFailedWhere+`
+			ORDER BY received_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+deliveryColumns,
+		model.TransformFailedReason, sourceID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("reprocess deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.Delivery
+	for rows.Next() {
+		var d model.Delivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// DiscardQuarantined cancels a quarantined delivery with reason, reporting
+// false if it isn't quarantined.
+func (s *DeliveryStore) DiscardQuarantined(ctx context.Context, id uuid.UUID, reason string) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE deliveries SET status = 'cancelled', status_reason = $2
+		 WHERE id = $1 AND status = 'quarantined'`,
+		id, reason,
+	)
+	if err != nil {
+		return false, fmt.Errorf("discard quarantined delivery: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Cancel moves a delivery to a terminal cancelled status with a human-readable
+// reason explaining why it will not be delivered.
+func (s *DeliveryStore) Cancel(ctx context.Context, id uuid.UUID, status model.DeliveryStatus, reason string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE deliveries SET status = $2, status_reason = $3 WHERE id = $1`, id, status, reason)
+	if err != nil {
+		return fmt.Errorf("cancel delivery: %w", err)
+	}
+	return nil
+}
+
+// CancelPending cancels a delivery that hasn't been dispatched yet or still
+// has retries scheduled, clearing those retries. It reports false if the
+// delivery has nothing left to cancel.
+func (s *DeliveryStore) CancelPending(ctx context.Context, id uuid.UUID, reason string) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin cancel: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE deliveries SET status = 'cancelled', status_reason = $2
+		 WHERE id = $1 AND (
+			status IN ('pending', 'processing')
+			OR EXISTS (SELECT 1 FROM delivery_attempts WHERE delivery_id = $1 AND next_retry_at IS NOT NULL)
+		 ) AND status NOT IN ('cancelled', 'cancelled_config_removed', 'recorded')`,
+		id, reason,
+	)
+	if err != nil {
+		return false, fmt.Errorf("cancel delivery: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE delivery_attempts SET next_retry_at = NULL WHERE delivery_id = $1 AND next_retry_at IS NOT NULL`,
+		id,
+	); err != nil {
+		return false, fmt.Errorf("clear retries: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit cancel: %w", err)
+	}
+	return true, nil
+}
+
 func (s *DeliveryStore) SetTransformed(ctx context.Context, id uuid.UUID, payload, headers json.RawMessage) error {
 	_, err := s.pool.Exec(ctx,
 		`UPDATE deliveries SET transformed_payload = $2, transformed_headers = $3 WHERE id = $1`,
@@ -94,11 +579,17 @@ func (s *DeliveryStore) SetTransformed(ctx context.Context, id uuid.UUID, payloa
 	return nil
 }
 
-func (s *DeliveryStore) ListPending(ctx context.Context, limit int) ([]model.Delivery, error) {
+// ListPending returns up to limit pending, unscheduled deliveries, oldest
+// first. Deliveries queued for an in-flight slot within queuedFor are left
+// out, since they wait for a handoff.
+func (s *DeliveryStore) ListPending(ctx context.Context, limit int, queuedFor time.Duration) ([]model.Delivery, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, source_id, idempotency_key, headers, payload, status, received_at, transformed_payload, transformed_headers
-		 FROM deliveries WHERE status = 'pending' ORDER BY received_at ASC LIMIT $1`,
-		limit,
+		`SELECT `+deliveryColumns+`
+		 FROM deliveries
+		 WHERE status = 'pending' AND NOT scheduled
+		   AND (queued_at IS NULL OR queued_at <= now() - $2::bigint * interval '1 millisecond')
+		 ORDER BY received_at ASC LIMIT $1`,
+		limit, queuedFor.Milliseconds(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list pending deliveries: %w", err)
@@ -108,7 +599,39 @@ func (s *DeliveryStore) ListPending(ctx context.Context, limit int) ([]model.Del
 	var deliveries []model.Delivery
 	for rows.Next() {
 		var d model.Delivery
-		if err := rows.Scan(&d.ID, &d.SourceID, &d.IdempotencyKey, &d.Headers, &d.Payload, &d.Status, &d.ReceivedAt, &d.TransformedPayload, &d.TransformedHeaders); err != nil {
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ReleaseDue clears the scheduled flag on up to limit deliveries whose
+// deliver_at has passed and returns them for publishing. Released deliveries
+// the caller fails to publish are picked up by ListPending.
+func (s *DeliveryStore) ReleaseDue(ctx context.Context, limit int) ([]model.Delivery, error) {
+	rows, err := s.pool.Query(ctx,
+		`UPDATE deliveries SET scheduled = false
+		 WHERE id IN (
+		     SELECT id FROM deliveries
+		     WHERE scheduled AND deliver_at <= now()
+		     ORDER BY deliver_at
+		     LIMIT $1
+		     FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+deliveryColumns,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("release scheduled deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.Delivery
+	for rows.Next() {
+		var d model.Delivery
+		if err := scanDelivery(rows, &d); err != nil {
 			return nil, fmt.Errorf("scan delivery: %w", err)
 		}
 		deliveries = append(deliveries, d)
@@ -118,30 +641,64 @@ func (s *DeliveryStore) ListPending(ctx context.Context, limit int) ([]model.Del
 
 // Attempt operations
 
+const attemptColumns = `id, delivery_id, action_id, attempt_number, status, response_status, response_body, error_message, next_retry_at, retry_reason, capped, dns_ms, connect_ms, tls_ms, ttfb_ms, total_ms, created_at`
+
+func scanAttempt(row pgx.Row, a *model.DeliveryAttempt) error {
+	return row.Scan(&a.ID, &a.DeliveryID, &a.ActionID, &a.AttemptNumber, &a.Status, &a.ResponseStatus, &a.ResponseBody, &a.ErrorMessage, &a.NextRetryAt, &a.RetryReason, &a.Capped, &a.DNSMs, &a.ConnectMs, &a.TLSMs, &a.TTFBMs, &a.TotalMs, &a.CreatedAt)
+}
+
 func (s *DeliveryStore) CreateAttempt(ctx context.Context, deliveryID, actionID uuid.UUID, attemptNumber int) (*model.DeliveryAttempt, error) {
 	var a model.DeliveryAttempt
-	err := s.pool.QueryRow(ctx,
+	err := scanAttempt(s.pool.QueryRow(ctx,
 		`INSERT INTO delivery_attempts (delivery_id, action_id, attempt_number)
 		 VALUES ($1, $2, $3)
-		 RETURNING id, delivery_id, action_id, attempt_number, status, response_status, response_body, error_message, next_retry_at, created_at`,
+		 RETURNING `+attemptColumns,
 		deliveryID, actionID, attemptNumber,
-	).Scan(&a.ID, &a.DeliveryID, &a.ActionID, &a.AttemptNumber, &a.Status, &a.ResponseStatus, &a.ResponseBody, &a.ErrorMessage, &a.NextRetryAt, &a.CreatedAt)
+	), &a)
 	if err != nil {
 		return nil, fmt.Errorf("create attempt: %w", err)
 	}
 	return &a, nil
 }
 
-func (s *DeliveryStore) UpdateAttempt(ctx context.Context, id uuid.UUID, status model.AttemptStatus, responseStatus *int, responseBody *string, errorMessage *string, nextRetryAt *time.Time) error {
+// UpdateAttempt records the outcome of an attempt. A non-nil retryDelay
+// schedules the next retry relative to the database clock so that workers
+// with skewed wall clocks agree on when it is due. timing is nil when no
+// request was sent.
+func (s *DeliveryStore) UpdateAttempt(ctx context.Context, id uuid.UUID, status model.AttemptStatus, responseStatus *int, responseBody *string, errorMessage *string, retryDelay *time.Duration, timing *model.AttemptTiming) error {
+	var retryDelayMs *int64
+	if retryDelay != nil {
+		ms := retryDelay.Milliseconds()
+		retryDelayMs = &ms
+	}
+	if timing == nil {
+		timing = &model.AttemptTiming{}
+	}
+	// Every scheduled retry is tagged with why, for failure breakdowns
+	var reason *string
+	if retryDelay != nil {
+		r := retryreason.Other
+		if errorMessage != nil {
+			r = retryreason.Classify(*errorMessage)
+		}
+		reason = &r
+	}
 	_, err := s.pool.Exec(ctx,
 		`UPDATE delivery_attempts SET
 			status          = $2,
 			response_status = $3,
 			response_body   = $4,
 			error_message   = $5,
-			next_retry_at   = $6
+			next_retry_at   = now() + $6::bigint * interval '1 millisecond',
+			retry_reason    = $12,
+			dns_ms          = $7,
+			connect_ms      = $8,
+			tls_ms          = $9,
+			ttfb_ms         = $10,
+			total_ms        = $11
 		 WHERE id = $1`,
-		id, status, responseStatus, responseBody, errorMessage, nextRetryAt,
+		id, status, responseStatus, responseBody, errorMessage, retryDelayMs,
+		timing.DNSMs, timing.ConnectMs, timing.TLSMs, timing.TTFBMs, timing.TotalMs, reason,
 	)
 	if err != nil {
 		return fmt.Errorf("update attempt: %w", err)
@@ -151,9 +708,10 @@ func (s *DeliveryStore) UpdateAttempt(ctx context.Context, id uuid.UUID, status
 
 func (s *DeliveryStore) ListRetryableAttempts(ctx context.Context, limit int) ([]model.DeliveryAttempt, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, delivery_id, action_id, attempt_number, status, response_status, response_body, error_message, next_retry_at, created_at
+		`SELECT `+attemptColumns+`
 		 FROM delivery_attempts
 		 WHERE status = 'failed' AND next_retry_at IS NOT NULL AND next_retry_at <= now()
+		   AND NOT EXISTS (SELECT 1 FROM deliveries d WHERE d.id = delivery_attempts.delivery_id AND d.status = 'cancelled')
 		 ORDER BY next_retry_at ASC LIMIT $1`,
 		limit,
 	)
@@ -165,7 +723,7 @@ func (s *DeliveryStore) ListRetryableAttempts(ctx context.Context, limit int) ([
 	var attempts []model.DeliveryAttempt
 	for rows.Next() {
 		var a model.DeliveryAttempt
-		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.ActionID, &a.AttemptNumber, &a.Status, &a.ResponseStatus, &a.ResponseBody, &a.ErrorMessage, &a.NextRetryAt, &a.CreatedAt); err != nil {
+		if err := scanAttempt(rows, &a); err != nil {
 			return nil, fmt.Errorf("scan attempt: %w", err)
 		}
 		attempts = append(attempts, a)
@@ -173,14 +731,45 @@ func (s *DeliveryStore) ListRetryableAttempts(ctx context.Context, limit int) ([
 	return attempts, rows.Err()
 }
 
-func (s *DeliveryStore) ListAttemptsByDelivery(ctx context.Context, deliveryID uuid.UUID) ([]model.DeliveryAttempt, error) {
-	rows, err := s.pool.Query(ctx,
-		`SELECT id, delivery_id, action_id, attempt_number, status, response_status, response_body, error_message, next_retry_at, created_at
+// AttemptFilter narrows and pages ListAttemptsByDelivery. Zero values apply
+// no filter; a zero Limit returns every matching attempt.
+type AttemptFilter struct {
+	Status   *model.AttemptStatus
+	ActionID *uuid.UUID
+	Limit    int
+	Offset   int
+}
+
+func (s *DeliveryStore) ListAttemptsByDelivery(ctx context.Context, deliveryID uuid.UUID, f AttemptFilter) ([]model.DeliveryAttempt, error) {
+	query := `SELECT ` + attemptColumns + `
 		 FROM delivery_attempts
-		 WHERE delivery_id = $1
-		 ORDER BY created_at ASC`,
-		deliveryID,
-	)
+		 WHERE delivery_id = $1`
+	args := []any{deliveryID}
+	argIdx := 2
+
+	if f.Status != nil {
+		query += fmt.Sprintf(` AND status = $%d`, argIdx)
+		args = append(args, *f.Status)
+		argIdx++
+	}
+	if f.ActionID != nil {
+		query += fmt.Sprintf(` AND action_id = $%d`, argIdx)
+		args = append(args, *f.ActionID)
+		argIdx++
+	}
+
+	query += ` ORDER BY created_at ASC, attempt_number ASC`
+	if f.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT $%d`, argIdx)
+		args = append(args, f.Limit)
+		argIdx++
+	}
+	if f.Offset > 0 {
+		query += fmt.Sprintf(` OFFSET $%d`, argIdx)
+		args = append(args, f.Offset)
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list attempts by delivery: %w", err)
 	}
@@ -189,7 +778,7 @@ func (s *DeliveryStore) ListAttemptsByDelivery(ctx context.Context, deliveryID u
 	var attempts []model.DeliveryAttempt
 	for rows.Next() {
 		var a model.DeliveryAttempt
-		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.ActionID, &a.AttemptNumber, &a.Status, &a.ResponseStatus, &a.ResponseBody, &a.ErrorMessage, &a.NextRetryAt, &a.CreatedAt); err != nil {
+		if err := scanAttempt(rows, &a); err != nil {
 			return nil, fmt.Errorf("scan attempt: %w", err)
 		}
 		attempts = append(attempts, a)
@@ -197,14 +786,217 @@ func (s *DeliveryStore) ListAttemptsByDelivery(ctx context.Context, deliveryID u
 	return attempts, rows.Err()
 }
 
-func (s *DeliveryStore) GetMaxAttemptNumber(ctx context.Context, deliveryID, actionID uuid.UUID) (int, error) {
-	var n int
-	err := s.pool.QueryRow(ctx,
-		`SELECT COALESCE(MAX(attempt_number), 0) FROM delivery_attempts WHERE delivery_id = $1 AND action_id = $2`,
+// EachAttemptByAction calls fn for every attempt to the action created at or
+// after since, across all deliveries, oldest first. Iteration stops at the
+// first error fn returns.
+func (s *DeliveryStore) EachAttemptByAction(ctx context.Context, actionID uuid.UUID, since time.Time, fn func(*model.DeliveryAttempt) error) error {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+attemptColumns+`
+		 FROM delivery_attempts
+		 WHERE action_id = $1 AND created_at >= $2
+		 ORDER BY created_at ASC, id ASC`,
+		actionID, since,
+	)
+	if err != nil {
+		return fmt.Errorf("list attempts by action: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a model.DeliveryAttempt
+		if err := scanAttempt(rows, &a); err != nil {
+			return fmt.Errorf("scan attempt: %w", err)
+		}
+		if err := fn(&a); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetAttempt returns one of a delivery's attempts.
+func (s *DeliveryStore) GetAttempt(ctx context.Context, deliveryID, attemptID uuid.UUID) (*model.DeliveryAttempt, error) {
+	var a model.DeliveryAttempt
+	err := scanAttempt(s.pool.QueryRow(ctx,
+		`SELECT `+attemptColumns+` FROM delivery_attempts WHERE id = $1 AND delivery_id = $2`,
+		attemptID, deliveryID,
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("get attempt: %w", err)
+	}
+	return &a, nil
+}
+
+// RetryNow makes the action's latest attempt for the delivery due for retry
+// immediately, so the retry loop sends it again. It reports false if that
+// attempt didn't fail (or there is none).
+func (s *DeliveryStore) RetryNow(ctx context.Context, deliveryID, actionID uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE delivery_attempts SET next_retry_at = now(), retry_reason = 'manual'
+		 WHERE id = (
+			SELECT id FROM delivery_attempts
+			WHERE delivery_id = $1 AND action_id = $2
+			ORDER BY attempt_number DESC, created_at DESC LIMIT 1
+		 ) AND status = 'failed'`,
 		deliveryID, actionID,
-	).Scan(&n)
+	)
+	if err != nil {
+		return false, fmt.Errorf("retry attempt: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RetryAttemptNow makes a failed attempt due for retry immediately, bypassing
+// its backoff. Only the latest attempt of its action can be retried; it
+// reports false otherwise.
+func (s *DeliveryStore) RetryAttemptNow(ctx context.Context, deliveryID, attemptID uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE delivery_attempts a SET next_retry_at = now(), retry_reason = 'manual'
+		 WHERE a.id = $2 AND a.delivery_id = $1 AND a.status = 'failed'
+		   AND NOT EXISTS (
+			SELECT 1 FROM delivery_attempts later
+			WHERE later.delivery_id = a.delivery_id AND later.action_id = a.action_id
+			  AND (later.attempt_number, later.created_at) > (a.attempt_number, a.created_at)
+		   )`,
+		deliveryID, attemptID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("retry attempt: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClaimRetry takes a due retry for immediate dispatch by pushing it back
+// by hold, so the retry poll leaves it alone while it is sent. It returns
+// pgx.ErrNoRows if the attempt has no retry due, e.g. the poll already took it.
+func (s *DeliveryStore) ClaimRetry(ctx context.Context, attemptID uuid.UUID, hold time.Duration) (*model.DeliveryAttempt, error) {
+	var a model.DeliveryAttempt
+	err := scanAttempt(s.pool.QueryRow(ctx,
+		`UPDATE delivery_attempts SET next_retry_at = now() + $2::bigint * interval '1 millisecond'
+		 WHERE id = $1 AND status = 'failed' AND next_retry_at IS NOT NULL AND next_retry_at <= now()
+		 RETURNING `+attemptColumns,
+		attemptID, hold.Milliseconds(),
+	), &a)
+	if err != nil {
+		return nil, fmt.Errorf("claim retry: %w", err)
+	}
+	return &a, nil
+}
+
+// CreateCappedAttempt records an attempt that was not dispatched because the
+// action hit its attempt cap. Capped attempts don't count toward the cap.
+// reason is the retry reason code stored when a retry is scheduled.
+func (s *DeliveryStore) CreateCappedAttempt(ctx context.Context, deliveryID, actionID uuid.UUID, attemptNumber int, errorMessage, reason string, retryDelay *time.Duration) error {
+	var retryDelayMs *int64
+	var retryReason *string
+	if retryDelay != nil {
+		ms := retryDelay.Milliseconds()
+		retryDelayMs, retryReason = &ms, &reason
+	}
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO delivery_attempts (delivery_id, action_id, attempt_number, status, error_message, next_retry_at, retry_reason, capped)
+		 VALUES ($1, $2, $3, 'failed', $4, now() + $5::bigint * interval '1 millisecond', $6, true)`,
+		deliveryID, actionID, attemptNumber, errorMessage, retryDelayMs, retryReason,
+	)
+	if err != nil {
+		return fmt.Errorf("create capped attempt: %w", err)
+	}
+	return nil
+}
+
+// CountRecentAttempts returns how many uncapped attempts an action made in the
+// last hour and the last day, measured against the database clock.
+func (s *DeliveryStore) CountRecentAttempts(ctx context.Context, actionID uuid.UUID) (hour, day int, err error) {
+	err = s.pool.QueryRow(ctx,
+		`SELECT count(*) FILTER (WHERE created_at > now() - interval '1 hour'), count(*)
+		 FROM delivery_attempts
+		 WHERE action_id = $1 AND NOT capped AND created_at > now() - interval '1 day'`,
+		actionID,
+	).Scan(&hour, &day)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count recent attempts: %w", err)
+	}
+	return hour, day, nil
+}
+
+// ActionOutcomes tallies the latest attempt per action of a delivery:
+// succeeded, failed with no retry left (exhausted), and still in flight.
+func (s *DeliveryStore) ActionOutcomes(ctx context.Context, deliveryID uuid.UUID) (succeeded, exhausted, inFlight int, err error) {
+	err = s.pool.QueryRow(ctx,
+		`SELECT count(*) FILTER (WHERE status = 'success'),
+		        count(*) FILTER (WHERE status = 'failed' AND next_retry_at IS NULL),
+		        count(*) FILTER (WHERE NOT (status = 'success' OR (status = 'failed' AND next_retry_at IS NULL)))
+		 FROM (
+		     SELECT DISTINCT ON (action_id) status, next_retry_at
+		     FROM delivery_attempts
+		     WHERE delivery_id = $1
+		     ORDER BY action_id, attempt_number DESC, created_at DESC
+		 ) latest`,
+		deliveryID,
+	).Scan(&succeeded, &exhausted, &inFlight)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("count action outcomes: %w", err)
+	}
+	return succeeded, exhausted, inFlight, nil
+}
+
+// SetAttemptBodyHash records the SHA-256 of the request body the attempt is
+// about to send, for audit manifests.
+func (s *DeliveryStore) SetAttemptBodyHash(ctx context.Context, attemptID uuid.UUID, body []byte) error {
+	_, err := s.pool.Exec(ctx, `UPDATE delivery_attempts SET body_sha256 = $2 WHERE id = $1`, attemptID, ContentHash(body))
+	if err != nil {
+		return fmt.Errorf("set attempt body hash: %w", err)
+	}
+	return nil
+}
+
+// ListManifestEntries returns attempts for a single delivery (when deliveryID is
+// set) or all attempts created in [from, to). The payload hash is that of the
+// exact request body the attempt sent; it is empty for attempts that sent no
+// HTTP request.
+func (s *DeliveryStore) ListManifestEntries(ctx context.Context, deliveryID *uuid.UUID, from, to *time.Time, limit int) ([]model.ManifestEntry, error) {
+	query := `SELECT a.delivery_id, a.action_id, a.attempt_number, a.status, a.response_status,
+			COALESCE(a.body_sha256, ''),
+			d.received_at, a.created_at
+		 FROM delivery_attempts a
+		 JOIN deliveries d ON d.id = a.delivery_id
+		 WHERE true`
+	args := []any{}
+	argIdx := 1
+
+	if deliveryID != nil {
+		query += fmt.Sprintf(` AND a.delivery_id = $%d`, argIdx)
+		args = append(args, *deliveryID)
+		argIdx++
+	}
+	if from != nil {
+		query += fmt.Sprintf(` AND a.created_at >= $%d`, argIdx)
+		args = append(args, *from)
+		argIdx++
+	}
+	if to != nil {
+		query += fmt.Sprintf(` AND a.created_at < $%d`, argIdx)
+		args = append(args, *to)
+		argIdx++
+	}
+
+	query += ` ORDER BY a.created_at ASC, a.id ASC`
+	query += fmt.Sprintf(` LIMIT $%d`, argIdx)
+	args = append(args, limit)
+
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("get max attempt number: %w", err)
+		return nil, fmt.Errorf("list manifest entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.ManifestEntry
+	for rows.Next() {
+		var e model.ManifestEntry
+		if err := rows.Scan(&e.DeliveryID, &e.ActionID, &e.AttemptNumber, &e.Status, &e.ResponseStatus, &e.PayloadSHA256, &e.ReceivedAt, &e.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("scan manifest entry: %w", err)
+		}
+		entries = append(entries, e)
 	}
-	return n, nil
+	return entries, rows.Err()
 }
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// EventTypeStore manages the per-source event type catalog. Observed types
+// are recorded by a trigger on delivery insert; declared ones come from the
+// API.
+type EventTypeStore struct {
+	pool *pgxpool.Pool
+}
+
+func (s *EventTypeStore) List(ctx context.Context, sourceID uuid.UUID) ([]model.EventType, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT name, description, declared, first_seen_at, last_seen_at
+		 FROM event_types WHERE source_id = $1 ORDER BY name`,
+		sourceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list event types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []model.EventType
+	for rows.Next() {
+		var t model.EventType
+		if err := rows.Scan(&t.Name, &t.Description, &t.Declared, &t.FirstSeenAt, &t.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan event type: %w", err)
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// Declare adds an event type to the catalog, or marks an observed one as
+// declared, setting its description.
+func (s *EventTypeStore) Declare(ctx context.Context, sourceID uuid.UUID, name string, description *string) (*model.EventType, error) {
+	var t model.EventType
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO event_types (source_id, name, description, declared)
+		 VALUES ($1, $2, $3, true)
+		 ON CONFLICT (source_id, name) DO UPDATE SET description = EXCLUDED.description, declared = true
+		 RETURNING name, description, declared, first_seen_at, last_seen_at`,
+		sourceID, name, description,
+	).Scan(&t.Name, &t.Description, &t.Declared, &t.FirstSeenAt, &t.LastSeenAt)
+	if err != nil {
+		return nil, fmt.Errorf("declare event type: %w", err)
+	}
+	return &t, nil
+}
+
+// Delete removes an event type from the catalog. An observed type reappears
+// the next time a delivery of that type arrives.
+func (s *EventTypeStore) Delete(ctx context.Context, sourceID uuid.UUID, name string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM event_types WHERE source_id = $1 AND name = $2`, sourceID, name)
+	if err != nil {
+		return false, fmt.Errorf("delete event type: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
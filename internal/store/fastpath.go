@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stream message fields carrying a delivery that hasn't been persisted yet.
+const (
+	fieldSourceID       = "source_id"
+	fieldIdempotencyKey = "idempotency_key"
+	fieldHeaders        = "headers"
+	fieldPayload        = "payload"
+	fieldSimulated      = "simulated"
+	fieldReceivedAt     = "received_at"
+	fieldMethod         = "method"
+	fieldQueryParams    = "query_params"
+	fieldRemoteAddr     = "remote_addr"
+	fieldCloudEvent     = "cloud_event"
+	fieldEventType      = "event_type"
+	fieldReplayOf       = "replay_of"
+	fieldProvider       = "detected_provider"
+)
+
+// StreamValues encodes a delivery for the ingest fast path, where the worker
+// persists it from the stream message. nd.ID must be set.
+func (nd NewDelivery) StreamValues() map[string]any {
+	receivedAt := time.Now()
+	if nd.ReceivedAt != nil {
+		receivedAt = *nd.ReceivedAt
+	}
+	simulated := "0"
+	if nd.Simulated {
+		simulated = "1"
+	}
+	var replayOf string
+	if nd.ReplayOf != nil {
+		replayOf = nd.ReplayOf.String()
+	}
+	return map[string]any{
+		"delivery_id":       nd.ID.String(),
+		"request_id":        nd.RequestID,
+		fieldSourceID:       nd.SourceID.String(),
+		fieldIdempotencyKey: nd.IdempotencyKey,
+		fieldHeaders:        string(nd.Headers),
+		fieldPayload:        string(nd.Payload),
+		fieldSimulated:      simulated,
+		fieldReceivedAt:     receivedAt.Format(time.RFC3339Nano),
+		fieldMethod:         nd.Method,
+		fieldQueryParams:    string(nd.QueryParams),
+		fieldRemoteAddr:     nd.RemoteAddr,
+		fieldCloudEvent:     string(nd.CloudEvent),
+		fieldEventType:      nd.EventType,
+		fieldReplayOf:       replayOf,
+		fieldProvider:       nd.DetectedProvider,
+	}
+}
+
+// ParseStreamDelivery decodes a fast-path stream message. ok is false for
+// messages that only reference an already persisted delivery.
+func ParseStreamDelivery(values map[string]any) (nd NewDelivery, ok bool, err error) {
+	payload, ok := values[fieldPayload].(string)
+	if !ok {
+		return NewDelivery{}, false, nil
+	}
+	str := func(key string) string {
+		v, _ := values[key].(string)
+		return v
+	}
+
+	id, err := uuid.Parse(str("delivery_id"))
+	if err != nil {
+		return NewDelivery{}, true, fmt.Errorf("parse delivery_id: %w", err)
+	}
+	sourceID, err := uuid.Parse(str(fieldSourceID))
+	if err != nil {
+		return NewDelivery{}, true, fmt.Errorf("parse source_id: %w", err)
+	}
+	receivedAt, err := time.Parse(time.RFC3339Nano, str(fieldReceivedAt))
+	if err != nil {
+		return NewDelivery{}, true, fmt.Errorf("parse received_at: %w", err)
+	}
+	headers := json.RawMessage(str(fieldHeaders))
+	if len(headers) == 0 {
+		headers = json.RawMessage(`{}`)
+	}
+	var query, cloudEvent json.RawMessage
+	if q := str(fieldQueryParams); q != "" {
+		query = json.RawMessage(q)
+	}
+	if ce := str(fieldCloudEvent); ce != "" {
+		cloudEvent = json.RawMessage(ce)
+	}
+	var replayOf *uuid.UUID
+	if r := str(fieldReplayOf); r != "" {
+		orig, err := uuid.Parse(r)
+		if err != nil {
+			return NewDelivery{}, true, fmt.Errorf("parse replay_of: %w", err)
+		}
+		replayOf = &orig
+	}
+
+	return NewDelivery{
+		ID:               &id,
+		SourceID:         sourceID,
+		IdempotencyKey:   str(fieldIdempotencyKey),
+		Headers:          headers,
+		Payload:          json.RawMessage(payload),
+		Simulated:        str(fieldSimulated) == "1",
+		RequestID:        str("request_id"),
+		ReceivedAt:       &receivedAt,
+		Method:           str(fieldMethod),
+		QueryParams:      query,
+		RemoteAddr:       str(fieldRemoteAddr),
+		CloudEvent:       cloudEvent,
+		EventType:        str(fieldEventType),
+		ReplayOf:         replayOf,
+		DetectedProvider: str(fieldProvider),
+	}, true, nil
+}
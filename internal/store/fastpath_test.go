@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// toStream mimics a round trip through Redis, which returns every field as a
+// string.
+func toStream(values map[string]any) map[string]any {
+	out := make(map[string]any, len(values))
+	for k, v := range values {
+		out[k] = v.(string)
+	}
+	return out
+}
+
+func TestStreamDeliveryRoundTrip(t *testing.T) {
+	id, sourceID, orig := uuid.New(), uuid.New(), uuid.New()
+	receivedAt := time.Date(2026, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	tests := []struct {
+		name string
+		nd   NewDelivery
+	}{
+		{"minimal", NewDelivery{
+			ID:         &id,
+			SourceID:   sourceID,
+			Headers:    json.RawMessage(`{}`),
+			Payload:    json.RawMessage(`{"a":1}`),
+			ReceivedAt: &receivedAt,
+		}},
+		{"full", NewDelivery{
+			ID:               &id,
+			SourceID:         sourceID,
+			IdempotencyKey:   "evt_1",
+			Headers:          json.RawMessage(`{"Content-Type":"application/json"}`),
+			Payload:          json.RawMessage(`{"a":[1,2,3]}`),
+			Simulated:        true,
+			RequestID:        "req-1",
+			ReceivedAt:       &receivedAt,
+			Method:           "POST",
+			QueryParams:      json.RawMessage(`{"x":["1"]}`),
+			RemoteAddr:       "203.0.113.7",
+			CloudEvent:       json.RawMessage(`{"id":"1","type":"t"}`),
+			EventType:        "order.created",
+			ReplayOf:         &orig,
+			DetectedProvider: "github",
+		}},
+	}
+	for _, tt := range tests {
+		got, ok, err := ParseStreamDelivery(toStream(tt.nd.StreamValues()))
+		if !ok || err != nil {
+			t.Fatalf("%s: ParseStreamDelivery = %v, %v", tt.name, ok, err)
+		}
+		if !reflect.DeepEqual(got, tt.nd) {
+			t.Errorf("%s: round trip = %+v, want %+v", tt.name, got, tt.nd)
+		}
+	}
+}
+
+func TestParseStreamDeliveryReference(t *testing.T) {
+	// Messages for persisted deliveries carry only the ID
+	_, ok, err := ParseStreamDelivery(map[string]any{"delivery_id": uuid.NewString()})
+	if ok || err != nil {
+		t.Fatalf("ParseStreamDelivery = %v, %v; want a plain reference", ok, err)
+	}
+}
+
+func TestParseStreamDeliveryInvalid(t *testing.T) {
+	id := uuid.New()
+	values := toStream(NewDelivery{ID: &id, SourceID: uuid.New(), Payload: json.RawMessage(`{}`)}.StreamValues())
+	values[fieldReceivedAt] = "yesterday"
+	if _, ok, err := ParseStreamDelivery(values); !ok || err == nil {
+		t.Fatalf("ParseStreamDelivery = %v, %v; want an error", ok, err)
+	}
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SchemaVersion is the latest migration the store is written against. Bump it
+// with every new migration.
+const SchemaVersion = 71
+
+// schemaColumns lists the columns the store reads or writes, by table.
+var schemaColumns = map[string]string{
+	"sources":                sourceColumns,
+	"actions":                actionColumns + ", portal_token_hash, deleted_at",
+	"deliveries":             deliveryColumns + ", restored_at, scheduled, content_hash, unverified, queued_at",
+	"delivery_attempts":      attemptColumns + ", body_sha256",
+	"settings":               `key, value, updated_at`,
+	"script_runs":            `id, source_id, action_id, kind, duration_ms, timed_out, created_at`,
+	"event_types":            `source_id, name, description, declared, first_seen_at, last_seen_at`,
+	"source_delivery_hourly": `source_id, hour, received, failed, last_received_at`,
+	"delivery_archives":      `id, source_id, day, object_key, delivery_count, bytes, created_at, updated_at`,
+	"suppressed_deliveries":  suppressedColumns,
+	"credentials":            credentialColumns,
+}
+
+// schemaIndexes lists indexes queries depend on as ON CONFLICT arbiters or
+// for lookups.
+var schemaIndexes = []string{
+	"deliveries_source_id_idempotency_key_key",
+	"sources_external_id_key",
+	"idx_actions_external_id",
+	"idx_actions_portal_token_hash",
+	"delivery_archives_source_id_day_key",
+	"idx_deliveries_scheduled",
+	"idx_deliveries_content_hash",
+	"idx_deliveries_coalesce",
+}
+
+// CheckSchema verifies that migrations are applied up to SchemaVersion and
+// that the tables, columns and indexes the store uses exist, so a stale
+// database fails at startup rather than with scan errors on the first request.
+func (s *Store) CheckSchema(ctx context.Context) error {
+	var version int
+	var dirty bool
+	err := s.pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("no migrations applied: run `make migrate-up`")
+		}
+		return fmt.Errorf("read schema_migrations (run `make migrate-up`?): %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty: fix it by hand, then `migrate force %d` and `make migrate-up`", version, version-1)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("database is at migration %d, want %d: run `make migrate-up`", version, SchemaVersion)
+	}
+
+	have := make(map[string]bool)
+	rows, err := s.pool.Query(ctx,
+		`SELECT table_name || '.' || column_name FROM information_schema.columns WHERE table_schema = current_schema()
+		 UNION ALL
+		 SELECT indexname FROM pg_indexes WHERE schemaname = current_schema()`)
+	if err != nil {
+		return fmt.Errorf("inspect schema: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("scan schema: %w", err)
+		}
+		have[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspect schema: %w", err)
+	}
+
+	var missing []string
+	for table, columns := range schemaColumns {
+		for _, col := range strings.Split(columns, ", ") {
+			if !have[table+"."+col] {
+				missing = append(missing, "column "+table+"."+col)
+			}
+		}
+	}
+	for _, idx := range schemaIndexes {
+		if !have[idx] {
+			missing = append(missing, "index "+idx)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("schema is missing %s: check the migrations applied to this database", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+type ScriptRunStore struct {
+	pool *pgxpool.Pool
+}
+
+// Record stores one script execution. actionID is nil for transforms.
+func (s *ScriptRunStore) Record(ctx context.Context, sourceID uuid.UUID, actionID *uuid.UUID, kind string, duration time.Duration, timedOut bool) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO script_runs (source_id, action_id, kind, duration_ms, timed_out)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		sourceID, actionID, kind, duration.Milliseconds(), timedOut,
+	)
+	if err != nil {
+		return fmt.Errorf("record script run: %w", err)
+	}
+	return nil
+}
+
+// StatsBySource aggregates a source's script runs since the given time, one
+// row per script.
+func (s *ScriptRunStore) StatsBySource(ctx context.Context, sourceID uuid.UUID, since time.Time) ([]model.ScriptStats, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT kind, action_id, count(*), count(*) FILTER (WHERE timed_out),
+		        avg(duration_ms)::float8,
+		        percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms),
+		        max(duration_ms)
+		 FROM script_runs
+		 WHERE source_id = $1 AND created_at >= $2
+		 GROUP BY kind, action_id
+		 ORDER BY kind, action_id`,
+		sourceID, since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("script run stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []model.ScriptStats
+	for rows.Next() {
+		var st model.ScriptStats
+		if err := rows.Scan(&st.Kind, &st.ActionID, &st.Runs, &st.Timeouts, &st.AvgMs, &st.P95Ms, &st.MaxMs); err != nil {
+			return nil, fmt.Errorf("scan script stats: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// Prune deletes script runs older than the given time.
+func (s *ScriptRunStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM script_runs WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune script runs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
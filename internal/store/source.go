@@ -2,8 +2,9 @@ package store
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -15,33 +16,73 @@ type SourceStore struct {
 	pool *pgxpool.Pool
 }
 
+const sourceColumns = `id, name, slug, mode, script_body, max_payload_bytes, max_response_bytes, script_timeout_ms, provider, inbound_signature_scheme, inbound_signature_header, inbound_secret, inbound_signature_failure, ingest_token, external_id, ack_mode, challenge_mode, challenge_secret, delivery_delay_seconds, dedup_window_seconds, max_in_flight, coalesce_key, coalesce_window_seconds, ingest_rate_limit, transform_error_policy, retry_signal, created_at, updated_at`
+
+// scanSource scans sourceColumns into src, followed by any extra columns.
+func scanSource(row pgx.Row, src *model.Source, extra ...any) error {
+	dest := []any{&src.ID, &src.Name, &src.Slug, &src.Mode, &src.ScriptBody, &src.MaxPayloadBytes, &src.MaxResponseBytes, &src.ScriptTimeoutMs, &src.Provider, &src.InboundSignatureScheme, &src.InboundSignatureHeader, &src.InboundSecret, &src.InboundSignatureFailure, &src.IngestToken, &src.ExternalID, &src.AckMode, &src.ChallengeMode, &src.ChallengeSecret, &src.DeliveryDelaySeconds, &src.DedupWindowSeconds, &src.MaxInFlight, &src.CoalesceKey, &src.CoalesceWindowSeconds, &src.IngestRateLimit, &src.TransformErrorPolicy, &src.RetrySignal, &src.CreatedAt, &src.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
 func (s *SourceStore) GetBySlug(ctx context.Context, slug string) (*model.Source, error) {
 	var src model.Source
-	err := s.pool.QueryRow(ctx,
-		`SELECT id, name, slug, mode, script_body, created_at, updated_at FROM sources WHERE slug = $1`,
+	err := scanSource(s.pool.QueryRow(ctx,
+		`SELECT `+sourceColumns+` FROM sources WHERE slug = $1`,
 		slug,
-	).Scan(&src.ID, &src.Name, &src.Slug, &src.Mode, &src.ScriptBody, &src.CreatedAt, &src.UpdatedAt)
+	), &src)
 	if err != nil {
 		return nil, fmt.Errorf("get source by slug: %w", err)
 	}
 	return &src, nil
 }
 
+// Count returns how many sources exist.
+func (s *SourceStore) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM sources`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count sources: %w", err)
+	}
+	return n, nil
+}
+
+func (s *SourceStore) GetByExternalID(ctx context.Context, externalID string) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`SELECT `+sourceColumns+` FROM sources WHERE external_id = $1`,
+		externalID,
+	), &src)
+	if err != nil {
+		return nil, fmt.Errorf("get source by external id: %w", err)
+	}
+	return &src, nil
+}
+
 func (s *SourceStore) GetByID(ctx context.Context, id uuid.UUID) (*model.Source, error) {
 	var src model.Source
-	err := s.pool.QueryRow(ctx,
-		`SELECT id, name, slug, mode, script_body, created_at, updated_at FROM sources WHERE id = $1`,
+	err := scanSource(s.pool.QueryRow(ctx,
+		`SELECT `+sourceColumns+` FROM sources WHERE id = $1`,
 		id,
-	).Scan(&src.ID, &src.Name, &src.Slug, &src.Mode, &src.ScriptBody, &src.CreatedAt, &src.UpdatedAt)
+	), &src)
 	if err != nil {
 		return nil, fmt.Errorf("get source by id: %w", err)
 	}
 	return &src, nil
 }
 
+// List returns all sources with delivery stats read from the hourly rollup.
 func (s *SourceStore) List(ctx context.Context) ([]model.Source, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, name, slug, mode, script_body, created_at, updated_at FROM sources ORDER BY created_at DESC`,
+		`SELECT `+sourceColumns+`, st.total, st.failed_24h, st.partially_failed_24h, st.last_received_at
+		 FROM sources
+		 LEFT JOIN LATERAL (
+			SELECT COALESCE(SUM(received), 0) AS total,
+			       COALESCE(SUM(failed) FILTER (WHERE hour >= now() - interval '24 hours'), 0) AS failed_24h,
+			       COALESCE(SUM(partially_failed) FILTER (WHERE hour >= now() - interval '24 hours'), 0) AS partially_failed_24h,
+			       MAX(last_received_at) AS last_received_at
+			FROM source_delivery_hourly
+			WHERE source_id = sources.id
+		 ) st ON true
+		 ORDER BY created_at DESC`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list sources: %w", err)
@@ -51,27 +92,102 @@ func (s *SourceStore) List(ctx context.Context) ([]model.Source, error) {
 	var sources []model.Source
 	for rows.Next() {
 		var src model.Source
-		if err := rows.Scan(&src.ID, &src.Name, &src.Slug, &src.Mode, &src.ScriptBody, &src.CreatedAt, &src.UpdatedAt); err != nil {
+		var st model.SourceStats
+		if err := scanSource(rows, &src, &st.TotalDeliveries, &st.FailedLast24h, &st.PartiallyFailedLast24h, &st.LastReceivedAt); err != nil {
 			return nil, fmt.Errorf("scan source: %w", err)
 		}
+		src.Stats = &st
 		sources = append(sources, src)
 	}
 	return sources, rows.Err()
 }
 
-func (s *SourceStore) Create(ctx context.Context, name, slug, mode string, scriptBody *string) (*model.Source, error) {
+// Activity returns the source's ingest counts for each of the last hours
+// hours, oldest first, with zeros for hours nothing arrived.
+func (s *SourceStore) Activity(ctx context.Context, sourceID uuid.UUID, hours int) ([]model.HourlyActivity, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT g.hour, COALESCE(r.received, 0), COALESCE(r.failed, 0)
+		 FROM generate_series(
+			date_trunc('hour', now()) - ($2::int - 1) * interval '1 hour',
+			date_trunc('hour', now()),
+			interval '1 hour'
+		 ) AS g(hour)
+		 LEFT JOIN source_delivery_hourly r ON r.source_id = $1 AND r.hour = g.hour
+		 ORDER BY g.hour`,
+		sourceID, hours,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("source activity: %w", err)
+	}
+	defer rows.Close()
+
+	var activity []model.HourlyActivity
+	for rows.Next() {
+		var a model.HourlyActivity
+		if err := rows.Scan(&a.Hour, &a.Received, &a.Failed); err != nil {
+			return nil, fmt.Errorf("scan source activity: %w", err)
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+// Create inserts a source. With withToken, an ingest token is generated and
+// required on ingest from then on.
+func (s *SourceStore) Create(ctx context.Context, name, slug, mode string, scriptBody *string, withToken bool) (*model.Source, error) {
+	var token *string
+	if withToken {
+		t, err := newIngestToken()
+		if err != nil {
+			return nil, err
+		}
+		token = &t
+	}
+
 	var src model.Source
-	err := s.pool.QueryRow(ctx,
-		`INSERT INTO sources (name, slug, mode, script_body) VALUES ($1, $2, $3, $4)
-		 RETURNING id, name, slug, mode, script_body, created_at, updated_at`,
-		name, slug, mode, scriptBody,
-	).Scan(&src.ID, &src.Name, &src.Slug, &src.Mode, &src.ScriptBody, &src.CreatedAt, &src.UpdatedAt)
+	err := scanSource(s.pool.QueryRow(ctx,
+		`INSERT INTO sources (name, slug, mode, script_body, ingest_token) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+sourceColumns,
+		name, slug, mode, scriptBody, token,
+	), &src)
 	if err != nil {
 		return nil, fmt.Errorf("create source: %w", err)
 	}
 	return &src, nil
 }
 
+// UpsertByExternalID creates the source with the given external ID or, if one
+// exists, updates its name, mode and script. The slug of an existing source
+// is kept, and an existing ingest token isn't rotated. created reports whether
+// the source was inserted.
+func (s *SourceStore) UpsertByExternalID(ctx context.Context, externalID, name, slug, mode string, scriptBody *string, withToken bool) (src *model.Source, created bool, err error) {
+	var token *string
+	if withToken {
+		t, err := newIngestToken()
+		if err != nil {
+			return nil, false, err
+		}
+		token = &t
+	}
+
+	src = &model.Source{}
+	err = scanSource(s.pool.QueryRow(ctx,
+		`INSERT INTO sources (name, slug, mode, script_body, ingest_token, external_id) VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (external_id) DO UPDATE SET
+			name         = EXCLUDED.name,
+			mode         = EXCLUDED.mode,
+			script_body  = EXCLUDED.script_body,
+			ingest_token = COALESCE(sources.ingest_token, EXCLUDED.ingest_token),
+			updated_at   = now()
+		 RETURNING `+sourceColumns+`, xmax = 0`,
+		name, slug, mode, scriptBody, token, externalID,
+	), src, &created)
+	if err != nil {
+		return nil, false, fmt.Errorf("upsert source: %w", err)
+	}
+	return src, created, nil
+}
+
 func (s *SourceStore) Update(ctx context.Context, slug string, name *string, mode *string, scriptBody *string, clearScript bool) (*model.Source, error) {
 	var src model.Source
 	// If clearScript is true, we explicitly set script_body to NULL.
@@ -85,27 +201,27 @@ func (s *SourceStore) Update(ctx context.Context, slug string, name *string, mod
 
 	var err error
 	if clearScript {
-		err = s.pool.QueryRow(ctx,
+		err = scanSource(s.pool.QueryRow(ctx,
 			`UPDATE sources SET
 				name        = COALESCE($2, name),
 				mode        = COALESCE($3, mode),
 				script_body = NULL,
-				updated_at  = $4
+				updated_at  = now()
 			 WHERE slug = $1
-			 RETURNING id, name, slug, mode, script_body, created_at, updated_at`,
-			slug, name, mode, time.Now(),
-		).Scan(&src.ID, &src.Name, &src.Slug, &src.Mode, &src.ScriptBody, &src.CreatedAt, &src.UpdatedAt)
+			 RETURNING `+sourceColumns,
+			slug, name, mode,
+		), &src)
 	} else {
-		err = s.pool.QueryRow(ctx,
+		err = scanSource(s.pool.QueryRow(ctx,
 			`UPDATE sources SET
 				name        = COALESCE($2, name),
 				mode        = COALESCE($3, mode),
 				script_body = COALESCE($4, script_body),
-				updated_at  = $5
+				updated_at  = now()
 			 WHERE slug = $1
-			 RETURNING id, name, slug, mode, script_body, created_at, updated_at`,
-			slug, name, mode, scriptArg, time.Now(),
-		).Scan(&src.ID, &src.Name, &src.Slug, &src.Mode, &src.ScriptBody, &src.CreatedAt, &src.UpdatedAt)
+			 RETURNING `+sourceColumns,
+			slug, name, mode, scriptArg,
+		), &src)
 	}
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -116,6 +232,254 @@ func (s *SourceStore) Update(ctx context.Context, slug string, name *string, mod
 	return &src, nil
 }
 
+// SetLimits updates the source's limit overrides. Nil arguments leave an
+// override unchanged; zero clears it back to the global default.
+func (s *SourceStore) SetLimits(ctx context.Context, slug string, maxPayloadBytes, maxResponseBytes, scriptTimeoutMs *int) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET
+			max_payload_bytes  = NULLIF(COALESCE($2, max_payload_bytes), 0),
+			max_response_bytes = NULLIF(COALESCE($3, max_response_bytes), 0),
+			script_timeout_ms  = NULLIF(COALESCE($4, script_timeout_ms), 0),
+			updated_at         = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, maxPayloadBytes, maxResponseBytes, scriptTimeoutMs,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set source limits: %w", err)
+	}
+	return &src, nil
+}
+
+// SetInboundSignature configures inbound signature verification. A nil
+// secret disables verification and clears the scheme and header; a nil
+// provider leaves the provider unchanged.
+func (s *SourceStore) SetInboundSignature(ctx context.Context, slug string, provider, scheme, header, secret *string, failure string) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET
+			provider                  = COALESCE($2, provider),
+			inbound_signature_scheme  = CASE WHEN $5::text IS NULL THEN NULL ELSE $3 END,
+			inbound_signature_header  = CASE WHEN $5::text IS NULL THEN NULL ELSE $4 END,
+			inbound_secret            = $5,
+			inbound_signature_failure = $6,
+			updated_at                = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, provider, scheme, header, secret, failure,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set inbound signature: %w", err)
+	}
+	return &src, nil
+}
+
+// RotateIngestToken sets a new random ingest token on the source, enabling
+// token checks if they were off.
+func (s *SourceStore) RotateIngestToken(ctx context.Context, slug string) (*model.Source, error) {
+	token, err := newIngestToken()
+	if err != nil {
+		return nil, err
+	}
+	return s.setIngestToken(ctx, slug, &token)
+}
+
+// ClearIngestToken removes the source's ingest token, so ingest no longer
+// requires one.
+func (s *SourceStore) ClearIngestToken(ctx context.Context, slug string) (*model.Source, error) {
+	return s.setIngestToken(ctx, slug, nil)
+}
+
+func (s *SourceStore) setIngestToken(ctx context.Context, slug string, token *string) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET ingest_token = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, token,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set ingest token: %w", err)
+	}
+	return &src, nil
+}
+
+func newIngestToken() (string, error) {
+	token, err := randomHex(24)
+	if err != nil {
+		return "", fmt.Errorf("generate ingest token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *SourceStore) SetAckMode(ctx context.Context, slug, mode string) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET ack_mode = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, mode,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set ack mode: %w", err)
+	}
+	return &src, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SetDeliveryDelay sets the delay applied to the source's deliveries before
+// fan-out; nil removes it.
+func (s *SourceStore) SetDeliveryDelay(ctx context.Context, slug string, seconds *int) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET delivery_delay_seconds = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, seconds,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set delivery delay: %w", err)
+	}
+	return &src, nil
+}
+
+// SetDedupWindow sets the window for suppressing deliveries by content; nil
+// removes it.
+func (s *SourceStore) SetDedupWindow(ctx context.Context, slug string, seconds *int) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET dedup_window_seconds = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, seconds,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set dedup window: %w", err)
+	}
+	return &src, nil
+}
+
+// SetCoalesce sets the payload path and window deliveries are coalesced by;
+// nil for both turns coalescing off.
+func (s *SourceStore) SetCoalesce(ctx context.Context, slug string, key *string, seconds *int) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET coalesce_key = $2, coalesce_window_seconds = $3, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, key, seconds,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set coalesce: %w", err)
+	}
+	return &src, nil
+}
+
+// SetTransformErrorPolicy sets what happens to deliveries whose transform
+// fails.
+func (s *SourceStore) SetTransformErrorPolicy(ctx context.Context, slug, policy string) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET transform_error_policy = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, policy,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set transform error policy: %w", err)
+	}
+	return &src, nil
+}
+
+// SetRetrySignal sets whether repeats of failed deliveries are answered
+// with 503.
+func (s *SourceStore) SetRetrySignal(ctx context.Context, slug string, on bool) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET retry_signal = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, on,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set retry signal: %w", err)
+	}
+	return &src, nil
+}
+
+// SetIngestRateLimit caps the requests per minute the source accepts; nil
+// removes the limit.
+func (s *SourceStore) SetIngestRateLimit(ctx context.Context, slug string, perMinute *int) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET ingest_rate_limit = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, perMinute,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set ingest rate limit: %w", err)
+	}
+	return &src, nil
+}
+
+// SetMaxInFlight caps how many of the source's deliveries are processed at
+// once; nil removes the cap.
+func (s *SourceStore) SetMaxInFlight(ctx context.Context, slug string, n *int) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET max_in_flight = $2, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, n,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set max in flight: %w", err)
+	}
+	return &src, nil
+}
+
 func (s *SourceStore) Delete(ctx context.Context, slug string) error {
 	result, err := s.pool.Exec(ctx, `DELETE FROM sources WHERE slug = $1`, slug)
 	if err != nil {
@@ -126,3 +490,22 @@ func (s *SourceStore) Delete(ctx context.Context, slug string) error {
 	}
 	return nil
 }
+
+// SetChallenge sets how GET verification challenges are answered; a nil mode
+// turns challenge handling off.
+func (s *SourceStore) SetChallenge(ctx context.Context, slug string, mode, secret *string) (*model.Source, error) {
+	var src model.Source
+	err := scanSource(s.pool.QueryRow(ctx,
+		`UPDATE sources SET challenge_mode = $2, challenge_secret = $3, updated_at = now()
+		 WHERE slug = $1
+		 RETURNING `+sourceColumns,
+		slug, mode, secret,
+	), &src)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("source not found")
+		}
+		return nil, fmt.Errorf("set challenge: %w", err)
+	}
+	return &src, nil
+}
@@ -1,17 +1,61 @@
 package store
 
-import "github.com/jackc/pgx/v5/pgxpool"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SQLSTATE codes the store maps to its own errors.
+const (
+	codeForeignKeyViolation = "23503"
+	codeUniqueViolation     = "23505"
+)
+
+// hasCode reports whether err is a Postgres error with the given SQLSTATE.
+func hasCode(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
 
 type Store struct {
-	Sources    *SourceStore
-	Actions    *ActionStore
-	Deliveries *DeliveryStore
+	Sources     *SourceStore
+	Actions     *ActionStore
+	Deliveries  *DeliveryStore
+	Settings    *SettingsStore
+	ScriptRuns  *ScriptRunStore
+	EventTypes  *EventTypeStore
+	Archives    *ArchiveStore
+	Suppressed  *SuppressionStore
+	Credentials *CredentialStore
+
+	pool *pgxpool.Pool
 }
 
 func New(pool *pgxpool.Pool) *Store {
 	return &Store{
-		Sources:    &SourceStore{pool: pool},
-		Actions:    &ActionStore{pool: pool},
-		Deliveries: &DeliveryStore{pool: pool},
+		Sources:     &SourceStore{pool: pool},
+		Actions:     &ActionStore{pool: pool},
+		Deliveries:  &DeliveryStore{pool: pool},
+		Settings:    &SettingsStore{pool: pool},
+		ScriptRuns:  &ScriptRunStore{pool: pool},
+		EventTypes:  &EventTypeStore{pool: pool},
+		Archives:    &ArchiveStore{pool: pool},
+		Suppressed:  &SuppressionStore{pool: pool},
+		Credentials: &CredentialStore{pool: pool},
+		pool:        pool,
+	}
+}
+
+// Now returns the database server's current time.
+func (s *Store) Now(ctx context.Context) (time.Time, error) {
+	var t time.Time
+	if err := s.pool.QueryRow(ctx, `SELECT now()`).Scan(&t); err != nil {
+		return time.Time{}, fmt.Errorf("get database time: %w", err)
 	}
+	return t, nil
 }
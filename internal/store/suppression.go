@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+const suppressedColumns = `id, source_id, duplicate_of, reason, idempotency_key, request_id, remote_addr, event_type, suppressed_at`
+
+func scanSuppressed(row pgx.Row, sd *model.SuppressedDelivery) error {
+	return row.Scan(&sd.ID, &sd.SourceID, &sd.DuplicateOf, &sd.Reason, &sd.IdempotencyKey, &sd.RequestID, &sd.RemoteAddr, &sd.EventType, &sd.SuppressedAt)
+}
+
+// ContentHash returns the hex SHA-256 of a payload, as stored in
+// deliveries.content_hash.
+func ContentHash(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+type SuppressionStore struct {
+	pool *pgxpool.Pool
+}
+
+// Record stores a request suppressed as a duplicate of duplicateOf.
+func (s *SuppressionStore) Record(ctx context.Context, nd NewDelivery, duplicateOf uuid.UUID, reason string) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO suppressed_deliveries (source_id, duplicate_of, reason, idempotency_key, request_id, remote_addr, event_type)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''))`,
+		nd.SourceID, duplicateOf, reason, nd.IdempotencyKey, nd.RequestID, nd.RemoteAddr, nd.EventType,
+	)
+	if err != nil {
+		return fmt.Errorf("record suppressed delivery: %w", err)
+	}
+	return nil
+}
+
+// ListBySource returns a source's suppressed requests, newest first.
+func (s *SuppressionStore) ListBySource(ctx context.Context, sourceID uuid.UUID, limit int) ([]model.SuppressedDelivery, error) {
+	return s.list(ctx,
+		`SELECT `+suppressedColumns+` FROM suppressed_deliveries
+		 WHERE source_id = $1
+		 ORDER BY suppressed_at DESC LIMIT $2`,
+		sourceID, limit,
+	)
+}
+
+// ListByAction returns suppressed duplicates of deliveries the action was
+// attempted for, newest first.
+func (s *SuppressionStore) ListByAction(ctx context.Context, actionID uuid.UUID, limit int) ([]model.SuppressedDelivery, error) {
+	return s.list(ctx,
+		`SELECT `+suppressedColumns+` FROM suppressed_deliveries
+		 WHERE duplicate_of IN (SELECT delivery_id FROM delivery_attempts WHERE action_id = $1)
+		 ORDER BY suppressed_at DESC LIMIT $2`,
+		actionID, limit,
+	)
+}
+
+func (s *SuppressionStore) list(ctx context.Context, query string, args ...any) ([]model.SuppressedDelivery, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list suppressed deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var out []model.SuppressedDelivery
+	for rows.Next() {
+		var sd model.SuppressedDelivery
+		if err := scanSuppressed(rows, &sd); err != nil {
+			return nil, fmt.Errorf("scan suppressed delivery: %w", err)
+		}
+		out = append(out, sd)
+	}
+	return out, rows.Err()
+}
+
+// Prune deletes suppression records older than the given time.
+func (s *SuppressionStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM suppressed_deliveries WHERE suppressed_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune suppressed deliveries: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
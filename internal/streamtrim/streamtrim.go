@@ -0,0 +1,67 @@
+// Package streamtrim applies the deliveries stream's trimming policy to
+// XADD calls.
+package streamtrim
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Trimming modes.
+const (
+	// Length keeps roughly the newest MaxLen entries.
+	Length = "length"
+	// TTL drops entries older than MaxAge.
+	TTL = "ttl"
+	// None never trims; the stream grows until entries are deleted by hand.
+	None = "none"
+)
+
+// DefaultMaxLen is the entry cap used by the length mode when none is set.
+const DefaultMaxLen = 10000
+
+// Policy decides what XADD trims. The zero value trims by length to
+// DefaultMaxLen.
+type Policy struct {
+	Mode   string
+	MaxLen int64
+	MaxAge time.Duration
+}
+
+// Parse validates a trimming mode and its limit.
+func Parse(mode string, maxLen int64, maxAge time.Duration) (Policy, error) {
+	switch mode {
+	case "", Length:
+		if maxLen <= 0 {
+			return Policy{}, fmt.Errorf("stream max length must be positive, got %d", maxLen)
+		}
+		return Policy{Mode: Length, MaxLen: maxLen}, nil
+	case TTL:
+		if maxAge <= 0 {
+			return Policy{}, fmt.Errorf("stream max age must be positive, got %s", maxAge)
+		}
+		return Policy{Mode: TTL, MaxAge: maxAge}, nil
+	case None:
+		return Policy{Mode: None}, nil
+	}
+	return Policy{}, fmt.Errorf("unknown stream trim mode %q: use length, ttl or none", mode)
+}
+
+// Apply sets the trimming options of args for an entry added at now.
+// Trimming is approximate so Redis only drops whole radix tree nodes.
+func (p Policy) Apply(args *redis.XAddArgs, now time.Time) {
+	switch p.Mode {
+	case None:
+		return
+	case TTL:
+		args.MinID = fmt.Sprintf("%d-0", now.Add(-p.MaxAge).UnixMilli())
+	default:
+		args.MaxLen = p.MaxLen
+		if args.MaxLen <= 0 {
+			args.MaxLen = DefaultMaxLen
+		}
+	}
+	args.Approx = true
+}
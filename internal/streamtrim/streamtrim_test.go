@@ -0,0 +1,45 @@
+package streamtrim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParse(t *testing.T) {
+	if p, err := Parse("", 500, 0); err != nil || p.Mode != Length || p.MaxLen != 500 {
+		t.Fatalf("Parse default = %+v, %v", p, err)
+	}
+	if _, err := Parse(Length, 0, 0); err == nil {
+		t.Fatal("expected error for zero max length")
+	}
+	if _, err := Parse(TTL, 0, 0); err == nil {
+		t.Fatal("expected error for zero max age")
+	}
+	if _, err := Parse("forever", 1, time.Hour); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestApply(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+
+	var args redis.XAddArgs
+	Policy{}.Apply(&args, now)
+	if args.MaxLen != DefaultMaxLen || !args.Approx || args.MinID != "" {
+		t.Errorf("zero policy = %+v", args)
+	}
+
+	args = redis.XAddArgs{}
+	Policy{Mode: TTL, MaxAge: time.Second}.Apply(&args, now)
+	if args.MinID != "1699999999000-0" || args.MaxLen != 0 || !args.Approx {
+		t.Errorf("ttl policy = %+v", args)
+	}
+
+	args = redis.XAddArgs{}
+	Policy{Mode: None}.Apply(&args, now)
+	if args.MaxLen != 0 || args.MinID != "" || args.Approx {
+		t.Errorf("none policy = %+v", args)
+	}
+}
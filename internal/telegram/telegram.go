@@ -0,0 +1,183 @@
+// Package telegram sends deliveries to a Telegram chat through the Bot API's
+// sendMessage method.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+)
+
+// APIURL is the Bot API's base URL; the token and method follow it.
+const APIURL = "https://api.telegram.org/bot"
+
+// MaxText is Telegram's limit on a message's length, in UTF-16 code units.
+const MaxText = 4096
+
+// ParseModes are the parse_mode values Telegram accepts.
+var ParseModes = []string{"HTML", "MarkdownV2", "Markdown"}
+
+// htmlEscaper escapes the characters Telegram's HTML parse mode requires.
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var (
+	tokenPattern  = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]+$`)
+	chatIDPattern = regexp.MustCompile(`^(-?[0-9]+|@[A-Za-z0-9_]{5,})$`)
+)
+
+// Config is a Telegram action's config. ChatID is a numeric chat ID or a
+// public channel's @username. Template is a reqtemplate over the payload
+// rendering the message text, formatted according to ParseMode; empty sends
+// the payload as preformatted JSON.
+type Config struct {
+	ChatID              string `json:"chat_id"`
+	Template            string `json:"template,omitempty"`
+	ParseMode           string `json:"parse_mode,omitempty"`
+	DisableNotification bool   `json:"disable_notification,omitempty"`
+	DisablePreview      bool   `json:"disable_preview,omitempty"`
+}
+
+// ParseConfig decodes an action's config; nil is the empty config.
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	var cfg Config
+	if len(raw) == 0 {
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("decode telegram config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a config with its secret, the bot token from BotFather.
+func Validate(cfg Config, secret string) error {
+	if !tokenPattern.MatchString(secret) {
+		return errors.New("telegram actions need a bot token such as 123456:ABC-DEF as their secret")
+	}
+	if !chatIDPattern.MatchString(cfg.ChatID) {
+		return errors.New("chat_id must be a numeric chat ID or a channel @username")
+	}
+	if cfg.ParseMode != "" && !validParseMode(cfg.ParseMode) {
+		return fmt.Errorf("parse_mode must be one of %s", strings.Join(ParseModes, ", "))
+	}
+	if cfg.Template != "" {
+		if _, err := reqtemplate.Parse(cfg.Template); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	return nil
+}
+
+func validParseMode(mode string) bool {
+	for _, m := range ParseModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Message renders payload into a sendMessage body for the config. Text over
+// MaxText is truncated with an ellipsis. Cutting formatted text can leave
+// markup unbalanced, which Telegram rejects, so truncated template output is
+// sent as plain text.
+func Message(cfg Config, payload json.RawMessage, maxBytes int) ([]byte, error) {
+	msg := map[string]any{"chat_id": cfg.ChatID}
+	if cfg.Template == "" {
+		// Truncate before escaping: entities count as one character
+		text, _ := truncate(indent(payload), MaxText-len("<pre></pre>"))
+		msg["text"] = "<pre>" + htmlEscaper.Replace(text) + "</pre>"
+		msg["parse_mode"] = "HTML"
+	} else {
+		out, err := reqtemplate.Body(cfg.Template, payload, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimSpace(string(out))
+		if text == "" {
+			return nil, errors.New("telegram template rendered empty text")
+		}
+		text, cut := truncate(text, MaxText)
+		msg["text"] = text
+		if cfg.ParseMode != "" && !cut {
+			msg["parse_mode"] = cfg.ParseMode
+		}
+	}
+	if cfg.DisableNotification {
+		msg["disable_notification"] = true
+	}
+	if cfg.DisablePreview {
+		msg["link_preview_options"] = map[string]any{"is_disabled": true}
+	}
+	return json.Marshal(msg)
+}
+
+func indent(payload json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		return string(payload)
+	}
+	return buf.String()
+}
+
+// truncate shortens s to at most limit UTF-16 code units, ending it with an
+// ellipsis, and reports whether it did.
+func truncate(s string, limit int) (string, bool) {
+	if len(utf16.Encode([]rune(s))) <= limit {
+		return s, false
+	}
+	n := 0
+	for i, r := range s {
+		size := utf16.RuneLen(r)
+		if size < 0 {
+			size = 1
+		}
+		// Leave room for the ellipsis
+		if n+size > limit-1 {
+			return s[:i] + "…", true
+		}
+		n += size
+	}
+	return s, false
+}
+
+// NewRequest builds the sendMessage request for token.
+func NewRequest(ctx context.Context, token string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APIURL+token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		// The error names the URL, which holds the token
+		return nil, errors.New("build telegram request: invalid bot token")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// CheckResponse reports a failed send with Telegram's description of it,
+// such as "HTTP 400: Bad Request: chat not found".
+func CheckResponse(status int, body []byte) error {
+	var res struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	decoded := json.Unmarshal(body, &res) == nil
+	switch {
+	case status < 200 || status >= 300:
+		if decoded && res.Description != "" {
+			return fmt.Errorf("HTTP %d: %s", status, res.Description)
+		}
+		return fmt.Errorf("HTTP %d", status)
+	case decoded && !res.OK:
+		return fmt.Errorf("telegram error: %s", res.Description)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package telegram
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestValidate(t *testing.T) {
+	const token = "123456:ABC-DEF_ghi"
+	cases := []struct {
+		cfg    Config
+		secret string
+		ok     bool
+	}{
+		{Config{ChatID: "-1001234567890"}, token, true},
+		{Config{ChatID: "@nitrohook_alerts", ParseMode: "MarkdownV2"}, token, true},
+		{Config{ChatID: "42"}, "", false},
+		{Config{ChatID: "42"}, "not-a-token", false},
+		{Config{}, token, false},
+		{Config{ChatID: "alerts"}, token, false},
+		{Config{ChatID: "42", ParseMode: "markdown"}, token, false},
+		{Config{ChatID: "42", Template: "{{.x"}, token, false},
+	}
+	for _, tc := range cases {
+		if err := Validate(tc.cfg, tc.secret); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v, %q) = %v, want ok %v", tc.cfg, tc.secret, err, tc.ok)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	payload := json.RawMessage(`{"user":"<ada>","n":2}`)
+	cases := map[string]struct {
+		cfg  Config
+		want string
+	}{
+		"default":  {Config{ChatID: "42"}, `{"chat_id":"42","parse_mode":"HTML","text":"\u003cpre\u003e{\n  \"user\": \"\u0026lt;ada\u0026gt;\",\n  \"n\": 2\n}\u003c/pre\u003e"}`},
+		"template": {Config{ChatID: "42", Template: "*{{.user}}* did {{.n}} things", ParseMode: "MarkdownV2"}, `{"chat_id":"42","parse_mode":"MarkdownV2","text":"*\u003cada\u003e* did 2 things"}`},
+		"options":  {Config{ChatID: "42", Template: "hi", DisableNotification: true, DisablePreview: true}, `{"chat_id":"42","disable_notification":true,"link_preview_options":{"is_disabled":true},"text":"hi"}`},
+	}
+	for name, tc := range cases {
+		got, err := Message(tc.cfg, payload, 4096)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", name, got, tc.want)
+		}
+	}
+	if _, err := Message(Config{ChatID: "42", Template: "{{.missing}}"}, payload, 4096); err == nil {
+		t.Error("expected error for empty text")
+	}
+}
+
+func TestMessageTruncates(t *testing.T) {
+	long := json.RawMessage(`{"text":"` + strings.Repeat("😀", 3000) + `"}`)
+	got, err := Message(Config{ChatID: "42", Template: "<b>{{.text}}</b>", ParseMode: "HTML"}, long, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg map[string]any
+	json.Unmarshal(got, &msg)
+	text := msg["text"].(string)
+	if n := len(utf16.Encode([]rune(text))); n > MaxText || !strings.HasSuffix(text, "😀…") {
+		t.Errorf("got %d code units ending %q", n, text[len(text)-8:])
+	}
+	if _, ok := msg["parse_mode"]; ok {
+		t.Error("truncated text kept its parse mode")
+	}
+
+	got, _ = Message(Config{ChatID: "42"}, long, 1<<20)
+	json.Unmarshal(got, &msg)
+	if text := msg["text"].(string); !strings.HasSuffix(text, "…</pre>") {
+		t.Errorf("default message not truncated inside <pre>: %q", text[len(text)-20:])
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(200, []byte(`{"ok":true,"result":{}}`)); err != nil {
+		t.Errorf("ok: %v", err)
+	}
+	err := CheckResponse(400, []byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+	if err == nil || err.Error() != "HTTP 400: Bad Request: chat not found" {
+		t.Errorf("api error = %v", err)
+	}
+	if err := CheckResponse(502, []byte("<html>")); err == nil || err.Error() != "HTTP 502" {
+		t.Errorf("gateway error = %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package verify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	dnsPrefix    = "_nitrohook-challenge."
+	txtPrefix    = "nitrohook-verification="
+	wellKnownURI = "/.well-known/nitrohook-verification.txt"
+	maxFileLen   = 1024
+)
+
+var ErrTokenNotFound = errors.New("verification token not found")
+
+// Method is how ownership of a target URL's domain is proven.
+type Method string
+
+const (
+	MethodDNS  Method = "dns"
+	MethodHTTP Method = "http"
+)
+
+// Instructions tells the action owner how to publish a verification token.
+type Instructions struct {
+	Token string          `json:"token"`
+	DNS   DNSInstruction  `json:"dns"`
+	HTTP  HTTPInstruction `json:"http"`
+}
+
+type DNSInstruction struct {
+	RecordName  string `json:"record_name"`
+	RecordType  string `json:"record_type"`
+	RecordValue string `json:"record_value"`
+}
+
+type HTTPInstruction struct {
+	URL  string `json:"url"`
+	Body string `json:"body"`
+}
+
+// NewToken returns a random hex-encoded verification token.
+func NewToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// InstructionsFor builds the DNS and HTTP challenge details for targetURL.
+func InstructionsFor(targetURL, token string) (*Instructions, error) {
+	u, err := parseTarget(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Instructions{
+		Token: token,
+		DNS: DNSInstruction{
+			RecordName:  dnsPrefix + u.Hostname(),
+			RecordType:  "TXT",
+			RecordValue: txtPrefix + token,
+		},
+		HTTP: HTTPInstruction{
+			URL:  wellKnownURL(u),
+			Body: token,
+		},
+	}, nil
+}
+
+// CheckDNS looks for a TXT record at _nitrohook-challenge.<host> carrying token.
+func CheckDNS(ctx context.Context, targetURL, token string) error {
+	u, err := parseTarget(targetURL)
+	if err != nil {
+		return err
+	}
+	records, err := net.DefaultResolver.LookupTXT(ctx, dnsPrefix+u.Hostname())
+	if err != nil {
+		return fmt.Errorf("lookup TXT record: %w", err)
+	}
+	for _, r := range records {
+		if strings.TrimSpace(r) == txtPrefix+token {
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// CheckHTTP fetches the well-known verification file from the target's origin
+// and compares its contents with token.
+func CheckHTTP(ctx context.Context, client *http.Client, targetURL, token string) error {
+	u, err := parseTarget(targetURL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnownURL(u), nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch verification file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch verification file: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFileLen))
+	if err != nil {
+		return fmt.Errorf("read verification file: %w", err)
+	}
+	if strings.TrimSpace(string(body)) != token {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func parseTarget(targetURL string) (*url.URL, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid target URL %q", targetURL)
+	}
+	return u, nil
+}
+
+func wellKnownURL(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + wellKnownURI
+}
@@ -0,0 +1,48 @@
+package verify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstructionsFor(t *testing.T) {
+	ins, err := InstructionsFor("https://hooks.example.com:8443/in?x=1", "abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ins.DNS.RecordName != "_nitrohook-challenge.hooks.example.com" {
+		t.Fatalf("unexpected record name: %s", ins.DNS.RecordName)
+	}
+	if ins.DNS.RecordValue != "nitrohook-verification=abc" {
+		t.Fatalf("unexpected record value: %s", ins.DNS.RecordValue)
+	}
+	if ins.HTTP.URL != "https://hooks.example.com:8443/.well-known/nitrohook-verification.txt" {
+		t.Fatalf("unexpected file URL: %s", ins.HTTP.URL)
+	}
+}
+
+func TestInstructionsFor_InvalidURL(t *testing.T) {
+	if _, err := InstructionsFor("not a url", "abc"); err == nil {
+		t.Fatal("expected error for invalid URL")
+	}
+}
+
+func TestCheckHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wellKnownURI {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("good-token\n"))
+	}))
+	defer srv.Close()
+
+	if err := CheckHTTP(context.Background(), srv.Client(), srv.URL+"/hook", "good-token"); err != nil {
+		t.Fatalf("expected verification to pass, got: %v", err)
+	}
+	if err := CheckHTTP(context.Background(), srv.Client(), srv.URL+"/hook", "other-token"); err != ErrTokenNotFound {
+		t.Fatalf("expected ErrTokenNotFound, got: %v", err)
+	}
+}
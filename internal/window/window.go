@@ -0,0 +1,149 @@
+// Package window evaluates actions' delivery windows: when they are open and
+// when a closed one next opens.
+package window
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// horizon bounds the search for the next opening; a window that stays shut
+// that long is rejected by Validate.
+const horizon = 366 * 24 * time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// schedule reports whether a local minute is inside the window. next is the
+// next candidate minute after t, letting cron skip whole hours and days.
+type schedule interface {
+	open(t time.Time) bool
+	next(t time.Time) time.Time
+}
+
+// Validate checks a window's timezone and either its hours or its cron
+// expression. A nil window is always open.
+func Validate(w *model.DeliveryWindow) error {
+	if w == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", w.Timezone)
+	}
+	if _, err := compile(w); err != nil {
+		return err
+	}
+	if NextOpen(w, time.Now()).IsZero() {
+		return errors.New("delivery window never opens")
+	}
+	return nil
+}
+
+// Empty reports whether w sets nothing; updating an action with an empty
+// window clears it.
+func Empty(w *model.DeliveryWindow) bool {
+	return w == nil || (w.Timezone == "" && len(w.Days) == 0 && w.Start == "" && w.End == "" && w.Cron == "")
+}
+
+// Open reports whether t falls inside the window. Invalid windows are treated
+// as open so a bad row can't hold deliveries forever.
+func Open(w *model.DeliveryWindow, t time.Time) bool {
+	return !NextOpen(w, t).After(t)
+}
+
+// NextOpen returns t if the window is open at t, otherwise the start of its
+// next opening, or the zero time if it doesn't open within a year.
+func NextOpen(w *model.DeliveryWindow, t time.Time) time.Time {
+	if w == nil {
+		return t
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return t
+	}
+	s, err := compile(w)
+	if err != nil {
+		return t
+	}
+	if s.open(t.In(loc)) {
+		return t
+	}
+	for c := t.Truncate(time.Minute).Add(time.Minute).In(loc); c.Sub(t) < horizon; c = s.next(c) {
+		if s.open(c) {
+			return c.In(t.Location())
+		}
+	}
+	return time.Time{}
+}
+
+func compile(w *model.DeliveryWindow) (schedule, error) {
+	if w.Cron != "" {
+		if w.Start != "" || w.End != "" || len(w.Days) > 0 {
+			return nil, errors.New("delivery window takes either cron or days/start/end")
+		}
+		return parseCron(w.Cron)
+	}
+	return parseHours(w)
+}
+
+// hours is a daily window from start to end, in minutes since midnight, on
+// the given days. Past midnight, the day it started on decides.
+type hours struct {
+	days       [7]bool
+	start, end int
+}
+
+func parseHours(w *model.DeliveryWindow) (*hours, error) {
+	var h hours
+	var err error
+	if h.start, err = parseClock(w.Start); err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	if h.end, err = parseClock(w.End); err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+	if h.start == h.end {
+		return nil, errors.New("delivery window start and end must differ")
+	}
+	if len(w.Days) == 0 {
+		h.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, d := range w.Days {
+		wd, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q: use mon, tue, ... sun", d)
+		}
+		h.days[wd] = true
+	}
+	return &h, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (h *hours) open(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if h.start < h.end {
+		return h.days[t.Weekday()] && m >= h.start && m < h.end
+	}
+	if m >= h.start {
+		return h.days[t.Weekday()]
+	}
+	return m < h.end && h.days[(t.Weekday()+6)%7]
+}
+
+func (h *hours) next(t time.Time) time.Time {
+	return t.Add(time.Minute)
+}
@@ -0,0 +1,99 @@
+package window
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		w  model.DeliveryWindow
+		ok bool
+	}{
+		{model.DeliveryWindow{Start: "09:00", End: "17:00"}, true},
+		{model.DeliveryWindow{Timezone: "America/New_York", Days: []string{"mon", "Fri"}, Start: "22:00", End: "06:00"}, true},
+		{model.DeliveryWindow{Timezone: "Mars/Olympus", Start: "09:00", End: "17:00"}, false},
+		{model.DeliveryWindow{Start: "9am", End: "17:00"}, false},
+		{model.DeliveryWindow{Start: "09:00", End: "09:00"}, false},
+		{model.DeliveryWindow{Days: []string{"funday"}, Start: "09:00", End: "17:00"}, false},
+		{model.DeliveryWindow{Cron: "* 9-17 * * 1-5"}, true},
+		{model.DeliveryWindow{Cron: "*/15 * * * *"}, true},
+		{model.DeliveryWindow{Cron: "* * *"}, false},
+		{model.DeliveryWindow{Cron: "* 24 * * *"}, false},
+		{model.DeliveryWindow{Cron: "* * 31 2 *"}, false},
+		{model.DeliveryWindow{Cron: "* * * * *", Start: "09:00"}, false},
+	}
+	for _, tc := range cases {
+		if err := Validate(&tc.w); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %v", tc.w, err, tc.ok)
+		}
+	}
+}
+
+func TestNextOpenHours(t *testing.T) {
+	w := &model.DeliveryWindow{Timezone: "Europe/Berlin", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cases := []struct {
+		at, want time.Time
+	}{
+		// Wednesday inside hours
+		{time.Date(2026, 3, 4, 10, 30, 0, 0, berlin), time.Date(2026, 3, 4, 10, 30, 0, 0, berlin)},
+		// Wednesday evening opens Thursday morning
+		{time.Date(2026, 3, 4, 17, 0, 0, 0, berlin), time.Date(2026, 3, 5, 9, 0, 0, 0, berlin)},
+		// Saturday opens Monday
+		{time.Date(2026, 3, 7, 12, 0, 0, 0, berlin), time.Date(2026, 3, 9, 9, 0, 0, 0, berlin)},
+	}
+	for _, tc := range cases {
+		if got := NextOpen(w, tc.at); !got.Equal(tc.want) {
+			t.Errorf("NextOpen(%s) = %s, want %s", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestOvernightWindow(t *testing.T) {
+	// Friday night only: open Fri 22:00 to Sat 06:00
+	w := &model.DeliveryWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00"}
+	cases := map[time.Time]bool{
+		time.Date(2026, 3, 6, 23, 0, 0, 0, time.UTC): true,  // Fri
+		time.Date(2026, 3, 7, 5, 59, 0, 0, time.UTC): true,  // Sat early
+		time.Date(2026, 3, 7, 6, 0, 0, 0, time.UTC):  false, // Sat
+		time.Date(2026, 3, 6, 5, 0, 0, 0, time.UTC):  false, // Fri early belongs to Thu
+		time.Date(2026, 3, 7, 23, 0, 0, 0, time.UTC): false, // Sat night
+	}
+	for at, want := range cases {
+		if got := Open(w, at); got != want {
+			t.Errorf("Open(%s) = %v, want %v", at, got, want)
+		}
+	}
+}
+
+func TestNextOpenCron(t *testing.T) {
+	w := &model.DeliveryWindow{Cron: "0,30 9-17 * * 1-5"}
+	cases := []struct {
+		at, want time.Time
+	}{
+		{time.Date(2026, 3, 4, 9, 30, 10, 0, time.UTC), time.Date(2026, 3, 4, 9, 30, 10, 0, time.UTC)},
+		{time.Date(2026, 3, 4, 9, 31, 0, 0, time.UTC), time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 6, 18, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		if got := NextOpen(w, tc.at); !got.Equal(tc.want) {
+			t.Errorf("NextOpen(%s) = %s, want %s", tc.at, got, tc.want)
+		}
+	}
+	// Both day fields restricted: the 1st of the month or any Sunday
+	w = &model.DeliveryWindow{Cron: "0 12 1 * 0"}
+	at := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) // Monday
+	if got, want := NextOpen(w, at), time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("NextOpen day-of-month or weekday = %s, want %s", got, want)
+	}
+}
+
+func TestNilWindowIsOpen(t *testing.T) {
+	now := time.Now()
+	if !Open(nil, now) || !NextOpen(nil, now).Equal(now) {
+		t.Error("nil window should always be open")
+	}
+}
@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/amqp"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// dispatchAMQPAction publishes the payload to the action's exchange under
+// its rendered routing key and waits for the publisher confirm, recorded as
+// the attempt's response body. The broker's reply code is the response
+// status (200 when acked). Configuration errors and permanent refusals
+// aren't retried; nacks, returns and connection failures are.
+func (w *FanoutWorker) dispatchAMQPAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := amqp.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	secret := ""
+	if action.Secret != nil {
+		if secret, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open amqp secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	key, err := amqp.RoutingKey(cfg, payload)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	msg := amqp.Message{
+		Body:      payload,
+		MessageID: delivery.ID.String(),
+		Timestamp: delivery.ReceivedAt,
+		Headers: map[string]string{
+			"delivery_id": delivery.ID.String(),
+			"source_id":   delivery.SourceID.String(),
+			"action_id":   action.ID.String(),
+			"attempt":     strconv.Itoa(attemptNumber),
+		},
+	}
+	if delivery.EventType != nil {
+		msg.Headers["event_type"] = *delivery.EventType
+	}
+
+	pctx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout := w.clients.Timeout(); timeout > 0 {
+		pctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	err = amqp.Publish(pctx, w.clients.DialContext, cfg, secret, key, msg)
+	cancel()
+
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attempt.ID)
+			return false
+		}
+		errMsg := err.Error()
+		var status *int
+		if code, ok := amqp.Reply(err); ok {
+			status = &code
+		}
+		// The confirm outcome, when the broker got as far as one
+		var body *string
+		var aerr *amqp.Error
+		if errors.Is(err, amqp.ErrNacked) {
+			b := w.responseCipher.Seal("nack")
+			body = &b
+		} else if errors.As(err, &aerr) && aerr.Returned {
+			b := w.responseCipher.Seal("returned: " + aerr.Text)
+			body = &b
+		}
+		var retryDelay *time.Duration
+		if !amqp.Permanent(err) {
+			retryDelay = w.nextRetryDelay(attemptNumber)
+		}
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, status, body, &errMsg, retryDelay, nil)
+		return false
+	}
+	statusCode := amqp.ReplySuccess
+	body := w.responseCipher.Seal("ack")
+	w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptSuccess, &statusCode, &body, nil, nil, nil)
+	return true
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/zachbroad/nitrohook/internal/archive"
+	"github.com/zachbroad/nitrohook/internal/store"
+)
+
+const (
+	archiveInterval = time.Hour
+	// archiveGroupsPerRun bounds how many source-days one run archives.
+	archiveGroupsPerRun = 20
+	archivePageSize     = 500
+)
+
+// SetArchive enables moving deliveries older than after to objects under
+// prefix. Call it before Start.
+func (w *FanoutWorker) SetArchive(objects archive.ObjectStore, prefix string, after time.Duration) {
+	w.archiveObjects = objects
+	w.archivePrefix = prefix
+	w.archiveAfter = after
+}
+
+func (w *FanoutWorker) archiveDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(archiveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.scheduler.Held() {
+				continue
+			}
+			cutoff := time.Now().Add(-w.archiveAfter)
+			groups, err := w.store.Archives.ListGroups(ctx, cutoff, archiveGroupsPerRun)
+			if err != nil {
+				slog.ErrorContext(ctx, "list archive groups error", "error", err)
+				continue
+			}
+			for _, g := range groups {
+				if err := w.archiveGroup(ctx, g, cutoff); err != nil {
+					slog.ErrorContext(ctx, "archive deliveries error", "source_id", g.SourceID, "day", g.Day.Format(time.DateOnly), "error", err)
+				}
+			}
+		}
+	}
+}
+
+// archiveGroup writes a source-day's archivable deliveries to its object,
+// appending to the object of an earlier run, then deletes them.
+func (w *FanoutWorker) archiveGroup(ctx context.Context, g store.ArchiveGroup, cutoff time.Time) error {
+	wr := archive.NewWriter()
+	var ids []uuid.UUID
+	after := uuid.Nil
+	for {
+		page, err := w.store.Archives.ListArchivable(ctx, g, cutoff, after, archivePageSize)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			break
+		}
+		pageIDs := make([]uuid.UUID, len(page))
+		for i, d := range page {
+			pageIDs[i] = d.ID
+		}
+		attempts, err := w.store.Archives.ListAttempts(ctx, pageIDs)
+		if err != nil {
+			return err
+		}
+		for i := range page {
+			if err := wr.Write(&archive.Record{Delivery: page[i], Attempts: attempts[page[i].ID]}); err != nil {
+				return err
+			}
+		}
+		ids = append(ids, pageIDs...)
+		after = pageIDs[len(pageIDs)-1]
+	}
+	if wr.Len() == 0 {
+		return nil
+	}
+
+	data, err := wr.Bytes()
+	if err != nil {
+		return err
+	}
+	key := archive.Key(w.archivePrefix, g.SourceID, g.Day)
+	if _, err := w.store.Archives.Get(ctx, g.SourceID, g.Day); err == nil {
+		existing, err := w.archiveObjects.Get(ctx, key)
+		if err != nil && !errors.Is(err, archive.ErrNotFound) {
+			return fmt.Errorf("read existing archive: %w", err)
+		}
+		data = append(existing, data...)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	if err := w.archiveObjects.Put(ctx, key, data); err != nil {
+		return err
+	}
+	n, err := w.store.Archives.Commit(ctx, g, cutoff, key, int64(len(data)), ids)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "archived deliveries", "source_id", g.SourceID, "day", g.Day.Format(time.DateOnly), "count", n, "object_key", key)
+	return nil
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/bigquery"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// dispatchBigQueryAction streams the payload into the action's table as one
+// row. Configuration and mapping errors aren't retried; failed requests and
+// rejected rows are.
+func (w *FanoutWorker) dispatchBigQueryAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage, limits model.Limits) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := bigquery.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	secret := ""
+	if action.Secret != nil {
+		if secret, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open bigquery secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	key, err := bigquery.ParseKey(secret)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	row, err := bigquery.Row(cfg, payload)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	req, err := bigquery.NewRequest(ctx, cfg, key, delivery.ID.String(), row, time.Now())
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	return w.sendActionRequest(ctx, delivery, action, attempt.ID, attemptNumber, req, limits, bigquery.CheckResponse)
+}
@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// breakers tracks consecutive failures per target URL. After threshold
+// failures a target's circuit opens: attempts fail fast without a request
+// until the cooldown has passed, then a single probe is let through
+// (half-open). The probe's success closes the circuit; its failure reopens it.
+// State is per worker process.
+type breakers struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	circuits  map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	openedAt time.Time
+	// probeAt is when the half-open probe was let through; zero when none is
+	// in flight.
+	probeAt time.Time
+}
+
+// newBreakers returns a breaker set; a threshold of zero disables it.
+func newBreakers(threshold int, cooldown time.Duration) *breakers {
+	return &breakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// allow reports whether a request to target may go out. When it may not, it
+// also returns the consecutive failure count and how long until the next
+// probe.
+func (b *breakers) allow(target string) (bool, int, time.Duration) {
+	if b.threshold <= 0 {
+		return true, 0, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[target]
+	if !ok || c.failures < b.threshold {
+		return true, 0, 0
+	}
+	now := time.Now()
+	if wait := b.cooldown - now.Sub(c.openedAt); wait > 0 {
+		return false, c.failures, wait
+	}
+	// Half-open: one probe at a time. A probe whose outcome was never
+	// recorded stops blocking others after a cooldown.
+	if !c.probeAt.IsZero() && now.Sub(c.probeAt) < b.cooldown {
+		return false, c.failures, b.cooldown - now.Sub(c.probeAt)
+	}
+	c.probeAt = now
+	return true, 0, 0
+}
+
+// record notes the outcome of a request to target and reports whether it
+// changed the circuit's state: opened, reopened by a failed probe, or closed.
+// Failures of requests already in flight when the circuit opened are counted
+// but leave the cooldown alone.
+func (b *breakers) record(target string, success bool) bool {
+	if b.threshold <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[target]
+	if success {
+		if !ok {
+			return false
+		}
+		delete(b.circuits, target)
+		return c.failures >= b.threshold
+	}
+	if !ok {
+		c = &circuit{}
+		b.circuits[target] = c
+	}
+	wasOpen := c.failures >= b.threshold
+	c.failures++
+	if wasOpen && c.probeAt.IsZero() {
+		return false
+	}
+	if c.failures >= b.threshold {
+		c.openedAt = time.Now()
+		c.probeAt = time.Time{}
+		return true
+	}
+	return false
+}
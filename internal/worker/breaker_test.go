@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakers(t *testing.T) {
+	const target = "https://example.com/hook"
+	b := newBreakers(3, time.Hour)
+
+	for i := range 2 {
+		if b.record(target, false) {
+			t.Fatalf("failure %d: circuit opened below the threshold", i+1)
+		}
+		if ok, _, _ := b.allow(target); !ok {
+			t.Fatalf("failure %d: request blocked below the threshold", i+1)
+		}
+	}
+	if !b.record(target, false) {
+		t.Fatal("expected the third failure to open the circuit")
+	}
+	ok, failures, wait := b.allow(target)
+	if ok || failures != 3 || wait <= 0 || wait > time.Hour {
+		t.Fatalf("allow = %v, %d, %s; want blocked after 3 failures", ok, failures, wait)
+	}
+
+	// Requests in flight when the circuit opened neither reopen it nor
+	// extend the cooldown
+	openedAt := b.circuits[target].openedAt
+	if b.record(target, false) {
+		t.Fatal("expected a late failure not to change the circuit")
+	}
+	if got := b.circuits[target].openedAt; !got.Equal(openedAt) {
+		t.Fatal("expected a late failure to leave the cooldown alone")
+	}
+
+	// After the cooldown a single probe goes out
+	b.circuits[target].openedAt = time.Now().Add(-2 * time.Hour)
+	if ok, _, _ := b.allow(target); !ok {
+		t.Fatal("expected a probe after the cooldown")
+	}
+	if ok, _, _ := b.allow(target); ok {
+		t.Fatal("expected only one probe at a time")
+	}
+	// Its failure reopens the circuit
+	if !b.record(target, false) {
+		t.Fatal("expected a failed probe to reopen the circuit")
+	}
+	if ok, _, _ := b.allow(target); ok {
+		t.Fatal("expected the reopened circuit to block requests")
+	}
+
+	// A successful probe closes it
+	b.circuits[target].openedAt = time.Now().Add(-2 * time.Hour)
+	if ok, _, _ := b.allow(target); !ok {
+		t.Fatal("expected a probe after the cooldown")
+	}
+	if !b.record(target, true) {
+		t.Fatal("expected a successful probe to close the circuit")
+	}
+	if ok, _, _ := b.allow(target); !ok {
+		t.Fatal("expected the closed circuit to allow requests")
+	}
+	if b.record(target, true) {
+		t.Fatal("expected a success on a closed circuit not to change it")
+	}
+}
+
+func TestBreakersDisabled(t *testing.T) {
+	b := newBreakers(0, time.Hour)
+	for range 10 {
+		if b.record("https://example.com", false) {
+			t.Fatal("expected a disabled breaker never to open")
+		}
+	}
+	if ok, _, _ := b.allow("https://example.com"); !ok {
+		t.Fatal("expected a disabled breaker to allow requests")
+	}
+}
@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/cloudevents"
+	"github.com/zachbroad/nitrohook/internal/eventtype"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+const (
+	defaultCloudEventType  = "nitrohook.delivery"
+	cloudEventSourcePrefix = "/nitrohook/sources/"
+)
+
+// outboundCloudEvent returns the attributes to send a delivery with. An event
+// that arrived as a CloudEvent keeps its identity; anything else gets one
+// derived from the delivery.
+func outboundCloudEvent(delivery *model.Delivery, headers json.RawMessage) cloudevents.Attributes {
+	var attrs cloudevents.Attributes
+	if len(delivery.CloudEvent) > 0 && json.Unmarshal(delivery.CloudEvent, &attrs) == nil && attrs.ID != "" {
+		attrs.DataContentType = "application/json"
+		return attrs
+	}
+
+	eventType := defaultCloudEventType
+	var headerMap map[string]string
+	switch {
+	case delivery.EventType != nil:
+		eventType = *delivery.EventType
+	case json.Unmarshal(headers, &headerMap) == nil && headerMap[eventtype.Header] != "":
+		eventType = headerMap[eventtype.Header]
+	}
+	return cloudevents.Attributes{
+		SpecVersion:     cloudevents.SpecVersion,
+		ID:              delivery.ID.String(),
+		Source:          cloudEventSourcePrefix + delivery.SourceID.String(),
+		Type:            eventType,
+		Time:            delivery.ReceivedAt.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+	}
+}
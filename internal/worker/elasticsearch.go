@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/zachbroad/nitrohook/internal/elasticsearch"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// dispatchElasticsearchAction indexes the payload as a document keyed by the
+// delivery ID. Configuration and index rendering errors aren't retried;
+// failed requests are.
+func (w *FanoutWorker) dispatchElasticsearchAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage, limits model.Limits) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := elasticsearch.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	secret := ""
+	if action.Secret != nil {
+		if secret, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open elasticsearch secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	index, err := elasticsearch.Index(cfg, payload)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	doc, err := elasticsearch.Document(payload)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	req, err := elasticsearch.NewRequest(ctx, cfg, secret, index, delivery.ID.String(), doc)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	return w.sendActionRequest(ctx, delivery, action, attempt.ID, attemptNumber, req, limits, elasticsearch.CheckResponse)
+}
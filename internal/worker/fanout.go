@@ -4,50 +4,177 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math"
 	"math/rand/v2"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/archive"
+	"github.com/zachbroad/nitrohook/internal/cloudevents"
+	"github.com/zachbroad/nitrohook/internal/credential"
+	"github.com/zachbroad/nitrohook/internal/email"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/eventtype"
+	"github.com/zachbroad/nitrohook/internal/logging"
+	"github.com/zachbroad/nitrohook/internal/metahook"
 	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/outbound"
+	"github.com/zachbroad/nitrohook/internal/projection"
+	"github.com/zachbroad/nitrohook/internal/reqtemplate"
+	"github.com/zachbroad/nitrohook/internal/retryreason"
 	"github.com/zachbroad/nitrohook/internal/script"
 	"github.com/zachbroad/nitrohook/internal/signing"
+	"github.com/zachbroad/nitrohook/internal/slo"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
 	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
+	"github.com/zachbroad/nitrohook/internal/window"
 )
 
 const (
 	streamName    = "deliveries"
 	consumerGroup = "fanout-workers"
-	maxBodyLen    = 4096
+	maxClockDrift = 2 * time.Second
+	recordTimeout = 5 * time.Second
+	lookupRetries = 4
+	lookupBackoff = 50 * time.Millisecond
+	ledgerTTL     = 24 * time.Hour
+	claimMinIdle  = 5 * time.Minute
+	// maxStreamDeliveries is how many times a message may be read before the
+	// reclaimer gives up on it. Deliveries persisted by then are still found
+	// by the catch-up poll.
+	maxStreamDeliveries = 10
+	// retryClaimHold keeps a manual retry sent from the stream away from the
+	// retry poll; the poll takes it back if the worker can't send it.
+	retryClaimHold = 5 * time.Minute
+
+	errInterrupted = "dispatch interrupted by worker shutdown"
+	errCircuitOpen = "circuit open"
 )
 
 type FanoutWorker struct {
-	store          *store.Store
-	rdb            *redis.Client
-	httpClient     *http.Client
-	concurrency    int
+	store       *store.Store
+	rdb         *redis.Client
+	clients     *outbound.Clients
+	concurrency int
+	// fanoutParallelism bounds concurrent dispatches within one delivery.
+	fanoutParallelism int
+	// Stream reads: messages per read, how long a read blocks, and how many
+	// messages each consumer processes at once. See SetStreamReads.
+	batchSize      int
+	blockTimeout   time.Duration
+	prefetch       int
+	trim           streamtrim.Policy
 	maxRetries     int
 	retryBaseDelay time.Duration
 	pollInterval   time.Duration
+	limits         model.Limits
+	// scheduler gates the polling loops so only one worker runs them.
+	scheduler *lease
+	// breakers fail attempts fast for targets that keep failing.
+	breakers *breakers
+	// Archiving is off while archiveObjects is nil; see SetArchive.
+	archiveObjects archive.ObjectStore
+	archivePrefix  string
+	archiveAfter   time.Duration
+	// responseCipher encrypts stored response bodies; nil stores them as is.
+	responseCipher *encryption.Cipher
+	// secrets opens sealed action secrets; see SetSecrets.
+	secrets *encryption.Cipher
+	// credentials resolves stored credentials and caches OAuth2 tokens.
+	credentials *credential.Resolver
+	// smtp is the mail server for smtp actions; see SetSMTP.
+	smtp email.Server
+	// objective and meta drive error budget alerts; see SetSLO.
+	objective slo.Objective
+	meta      *metahook.Notifier
+	// usage feeds the self-report published every reportInterval; see
+	// SetSelfReport.
+	usage          usage
+	reportInterval time.Duration
+	// running tracks stream consumers and in-flight handoffs; see Wait.
+	running sync.WaitGroup
 }
 
-func New(s *store.Store, rdb *redis.Client, concurrency, maxRetries int, retryBaseDelay, deliveryTimeout, pollInterval time.Duration) *FanoutWorker {
+// New creates a FanoutWorker. limits are the global limits that per-source
+// overrides are resolved against. A breakerThreshold of zero disables the
+// per-target circuit breaker.
+func New(s *store.Store, rdb *redis.Client, concurrency, fanoutParallelism, maxRetries int, retryBaseDelay, deliveryTimeout, pollInterval, schedulerLeaseTTL time.Duration, breakerThreshold int, breakerCooldown time.Duration, limits model.Limits) *FanoutWorker {
 	return &FanoutWorker{
-		store:          s,
-		rdb:            rdb,
-		httpClient:     &http.Client{Timeout: deliveryTimeout},
-		concurrency:    concurrency,
-		maxRetries:     maxRetries,
-		retryBaseDelay: retryBaseDelay,
-		pollInterval:   pollInterval,
+		store:             s,
+		rdb:               rdb,
+		clients:           outbound.NewClients(deliveryTimeout, nil, nil, nil, outbound.Egress{}),
+		concurrency:       concurrency,
+		fanoutParallelism: max(fanoutParallelism, 1),
+		batchSize:         1,
+		blockTimeout:      5 * time.Second,
+		prefetch:          1,
+		maxRetries:        maxRetries,
+		retryBaseDelay:    retryBaseDelay,
+		pollInterval:      pollInterval,
+		limits:            limits,
+		scheduler:         newLease(rdb, schedulerLeaseKey, max(schedulerLeaseTTL, time.Second)),
+		breakers:          newBreakers(breakerThreshold, breakerCooldown),
+		objective:         slo.Objective{Target: slo.DefaultTarget, Window: slo.DefaultWindow},
+		credentials:       credential.NewResolver(s, nil),
 	}
 }
 
+// SetStreamReads has each consumer read up to batchSize messages at a time,
+// blocking up to blockTimeout for them, and process up to prefetch messages
+// concurrently, reading ahead while slots are free. Call it before Start.
+func (w *FanoutWorker) SetStreamReads(batchSize int, blockTimeout time.Duration, prefetch int) {
+	w.batchSize = max(batchSize, 1)
+	w.blockTimeout = max(blockTimeout, time.Millisecond)
+	w.prefetch = max(prefetch, 1)
+}
+
+// SetStreamTrim trims the stream with p when publishing deliveries. Call it
+// before Start.
+func (w *FanoutWorker) SetStreamTrim(p streamtrim.Policy) {
+	w.trim = p
+}
+
+// SetSecrets opens action secrets, such as Slack tokens, with c. Call it
+// before Start.
+func (w *FanoutWorker) SetSecrets(c *encryption.Cipher) {
+	w.secrets = c
+	w.credentials = credential.NewResolver(w.store, c)
+}
+
+// SetSMTP sends smtp actions' mail through s. Call it before Start.
+func (w *FanoutWorker) SetSMTP(s email.Server) {
+	w.smtp = s
+}
+
+// SetSLO holds actions to objective unless they set their own target and
+// posts error budget alerts to meta. Call it before Start.
+func (w *FanoutWorker) SetSLO(objective slo.Objective, meta *metahook.Notifier) {
+	w.objective = objective
+	w.meta = meta
+}
+
+// SetResponseCipher stores attempt response bodies encrypted with c. Call it
+// before Start.
+func (w *FanoutWorker) SetResponseCipher(c *encryption.Cipher) {
+	w.responseCipher = c
+}
+
+// SetClients sends webhook requests with clients built by c, which applies
+// the egress proxy, client TLS settings and SSRF guard. Call it before Start.
+func (w *FanoutWorker) SetClients(c *outbound.Clients) {
+	w.clients = c
+}
+
 func (w *FanoutWorker) Start(ctx context.Context) error {
 	// Ensure consumer group exists
 	err := w.rdb.XGroupCreateMkStream(ctx, streamName, consumerGroup, "0").Err()
@@ -55,74 +182,359 @@ func (w *FanoutWorker) Start(ctx context.Context) error {
 		return fmt.Errorf("create consumer group: %w", err)
 	}
 
+	w.checkClockDrift(ctx)
+
 	// Start stream consumers
 	for i := range w.concurrency {
 		consumer := fmt.Sprintf("worker-%d", i)
-		go w.consumeStream(ctx, consumer)
+		w.running.Add(1)
+		go func() {
+			defer w.running.Done()
+			w.superviseConsumer(ctx, consumer)
+		}()
 	}
 
+	// Every worker consumes the stream; the polling loops below only run
+	// their scans in the worker holding the scheduler lease
+	go w.scheduler.run(ctx)
+
 	// Start catch-up poll for pending deliveries
 	go w.pollPending(ctx)
 
 	// Start retry poll
 	go w.pollRetries(ctx)
 
+	// Publish scheduled deliveries when they come due
+	go w.releaseScheduled(ctx)
+
+	// Reclaim messages left unacknowledged by crashed consumers
+	go w.reclaimStale(ctx)
+
+	// Count entries trimmed from the stream
+	go w.watchTrimming(ctx)
+
+	// Drop old script run stats and duplicate suppression records
+	go w.pruneScriptRuns(ctx)
+
+	// Alert on actions whose error budget runs out
+	go w.watchSLOs(ctx)
+
+	// Move old deliveries to cold storage
+	if w.archiveObjects != nil {
+		go w.archiveDeliveries(ctx)
+	}
+
+	// Publish resource use to the worker registry
+	if w.reportInterval > 0 {
+		go w.reportUsage(ctx)
+	}
+
 	return nil
 }
 
+// Wait blocks until the stream consumers and in-flight handoffs started by
+// Start have returned, after its context is cancelled.
+func (w *FanoutWorker) Wait() {
+	w.running.Wait()
+}
+
+// checkClockDrift warns when the local clock disagrees with Postgres. Retry
+// schedules are computed by the database, but log timestamps and timeouts
+// still come from the worker.
+func (w *FanoutWorker) checkClockDrift(ctx context.Context) {
+	before := time.Now()
+	dbNow, err := w.store.Now(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "clock drift check failed", "error", err)
+		return
+	}
+	rtt := time.Since(before)
+	drift := before.Add(rtt / 2).Sub(dbNow)
+	if drift.Abs() > maxClockDrift {
+		slog.WarnContext(ctx, "worker clock differs from database clock", "drift", drift, "max", maxClockDrift)
+		return
+	}
+	slog.InfoContext(ctx, "clock drift check passed", "drift", drift)
+}
+
+// consumeStream reads messages for one consumer in batches and handles them
+// in parallel, up to the prefetch limit.
 func (w *FanoutWorker) consumeStream(ctx context.Context, consumer string) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// slots bounds the messages this consumer has in progress
+	slots := make(chan struct{}, w.prefetch)
+
 	for {
 		if ctx.Err() != nil {
 			return
 		}
 
+		// Read no more than there are free slots for, so messages aren't
+		// claimed by a consumer that can't start them yet
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		count := 1
+	fill:
+		for count < w.batchSize {
+			select {
+			case slots <- struct{}{}:
+				count++
+			default:
+				break fill
+			}
+		}
+
 		streams, err := w.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
 			Group:    consumerGroup,
 			Consumer: consumer,
 			Streams:  []string{streamName, ">"},
-			Count:    1,
-			Block:    5 * time.Second,
+			Count:    int64(count),
+			Block:    w.blockTimeout,
 		}).Result()
 		if err != nil {
+			for range count {
+				<-slots
+			}
 			if err == redis.Nil || ctx.Err() != nil {
 				continue
 			}
-			slog.Error("xreadgroup error", "error", err, "consumer", consumer)
+			slog.ErrorContext(ctx, "xreadgroup error", "error", err, "consumer", consumer)
 			time.Sleep(time.Second)
 			continue
 		}
 
+		read := 0
 		for _, stream := range streams {
 			for _, msg := range stream.Messages {
-				deliveryIDStr, ok := msg.Values["delivery_id"].(string)
-				if !ok {
-					slog.Error("invalid delivery_id in stream message", "msg_id", msg.ID)
-					w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
-					continue
-				}
+				read++
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-slots }()
+					w.handleMessageRecovering(ctx, consumer, msg)
+				}()
+			}
+		}
+		// Give back the slots the read didn't fill
+		for range count - read {
+			<-slots
+		}
+	}
+}
+
+// handleMessage processes one stream message and acknowledges it. A ledger
+// entry is written after processing so that a message redelivered because the
+// worker died before XACK is skipped instead of re-dispatched. The ledger only
+// counts while the delivery has moved on from pending: one reset to pending
+// (reprocessed or approved from quarantine) and published again is processed.
+func (w *FanoutWorker) handleMessage(ctx context.Context, msg redis.XMessage) {
+	deliveryIDStr, ok := msg.Values["delivery_id"].(string)
+	if !ok {
+		slog.ErrorContext(ctx, "invalid delivery_id in stream message", "msg_id", msg.ID)
+		w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+		return
+	}
+
+	deliveryID, err := uuid.Parse(deliveryIDStr)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to parse delivery_id", "error", err, "value", deliveryIDStr)
+		w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+		return
+	}
+
+	ctx = logging.With(ctx, "delivery_id", deliveryID)
+	if requestID, _ := msg.Values["request_id"].(string); requestID != "" {
+		ctx = logging.With(ctx, "request_id", requestID)
+	}
+	if sourceID, _ := msg.Values["source_id"].(string); sourceID != "" {
+		ctx = logging.With(ctx, "source_id", sourceID)
+	}
+
+	// Manual retries from the API are sent now rather than on the next poll
+	if attemptID, _ := msg.Values["retry_attempt_id"].(string); attemptID != "" {
+		w.handleRetryMessage(ctx, msg, attemptID)
+		return
+	}
+
+	// Fast-path messages carry a delivery the API hasn't persisted
+	if nd, ok, err := store.ParseStreamDelivery(msg.Values); ok {
+		if err != nil {
+			slog.ErrorContext(ctx, "invalid fast-path stream message", "error", err, "msg_id", msg.ID)
+			w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+			return
+		}
+		d, _, err := w.store.Deliveries.Create(ctx, nd)
+		if errors.Is(err, store.ErrSourceNotFound) {
+			slog.ErrorContext(ctx, "dropping fast-path delivery for deleted source", "msg_id", msg.ID)
+			w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+			w.rdb.XDel(ctx, streamName, msg.ID)
+			return
+		}
+		if err != nil {
+			// Leave the message pending so it is reclaimed and retried
+			slog.ErrorContext(ctx, "failed to persist fast-path delivery", "error", err)
+			return
+		}
+		if d.ID != deliveryID {
+			logging.Sampled().InfoContext(ctx, "dropping fast-path duplicate", "original_delivery_id", d.ID)
+			if err := w.store.Suppressed.Record(ctx, nd, d.ID, model.SuppressedIdempotencyKey); err != nil {
+				slog.ErrorContext(ctx, "failed to record suppressed delivery", "error", err)
+			}
+			w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+			w.rdb.XDel(ctx, streamName, msg.ID)
+			return
+		}
+	}
+
+	ledgered, err := w.rdb.Exists(ctx, ledgerKey(deliveryID)).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check processing ledger", "error", err)
+	}
+	processed := false
+	if ledgered > 0 {
+		d, err := w.store.Deliveries.GetByID(ctx, deliveryID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			slog.ErrorContext(ctx, "failed to get ledgered delivery", "error", err)
+		}
+		processed = alreadyProcessed(d, err)
+	}
+	if processed {
+		logging.Sampled().InfoContext(ctx, "skipping already-processed stream message", "msg_id", msg.ID)
+	} else {
+		w.processDelivery(ctx, deliveryID)
+		if ctx.Err() != nil {
+			// Leave the message pending so it is reclaimed after restart
+			return
+		}
+		if err := w.rdb.Set(ctx, ledgerKey(deliveryID), msg.ID, ledgerTTL).Err(); err != nil {
+			slog.ErrorContext(ctx, "failed to write processing ledger", "error", err)
+		}
+	}
 
-				deliveryID, err := uuid.Parse(deliveryIDStr)
+	w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+	w.rdb.XDel(ctx, streamName, msg.ID)
+}
+
+// withDeliveryLog adds the delivery's correlation fields to ctx's log records.
+func withDeliveryLog(ctx context.Context, d *model.Delivery) context.Context {
+	ctx = logging.With(ctx, "delivery_id", d.ID)
+	if d.RequestID != nil {
+		ctx = logging.With(ctx, "request_id", *d.RequestID)
+	}
+	return ctx
+}
+
+// alreadyProcessed reports whether a ledgered delivery, as read back with
+// err, needs no processing: it is gone or no longer pending. Lookup errors
+// count as processed, leaving the delivery to the catch-up poll.
+func alreadyProcessed(d *model.Delivery, err error) bool {
+	if err != nil {
+		return true
+	}
+	return d.Status != model.DeliveryPending
+}
+
+func ledgerKey(deliveryID uuid.UUID) string {
+	return "ledger:delivery:" + deliveryID.String()
+}
+
+// reclaimStale periodically claims messages that another consumer read but
+// never acknowledged (e.g. the worker crashed) and runs them through
+// handleMessage, where the ledger and delivery status prevent double dispatch.
+func (w *FanoutWorker) reclaimStale(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := "0-0"
+			for {
+				msgs, next, err := w.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+					Stream:   streamName,
+					Group:    consumerGroup,
+					Consumer: "reclaimer",
+					MinIdle:  claimMinIdle,
+					Start:    start,
+					Count:    100,
+				}).Result()
 				if err != nil {
-					slog.Error("failed to parse delivery_id", "error", err, "value", deliveryIDStr)
-					w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
-					continue
+					if ctx.Err() == nil {
+						slog.ErrorContext(ctx, "xautoclaim error", "error", err)
+					}
+					break
 				}
-
-				w.processDelivery(ctx, deliveryID)
-				w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
-				w.rdb.XDel(ctx, streamName, msg.ID)
+				counts := w.deliveryCounts(ctx, msgs)
+				for _, msg := range msgs {
+					if counts[msg.ID] > maxStreamDeliveries {
+						slog.ErrorContext(ctx, "dropping stream message read too many times",
+							"msg_id", msg.ID, "delivery_id", msg.Values["delivery_id"], "deliveries", counts[msg.ID])
+						w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+						w.rdb.XDel(ctx, streamName, msg.ID)
+						continue
+					}
+					logging.Sampled().InfoContext(ctx, "reclaimed stale stream message", "msg_id", msg.ID)
+					w.handleMessage(ctx, msg)
+				}
+				if next == "0-0" || len(msgs) == 0 {
+					break
+				}
+				start = next
 			}
 		}
 	}
 }
 
+// deliveryCounts returns how many times each of msgs has been read, per
+// XPENDING. Messages it can't look up are missing from the result, so they
+// are handled as usual.
+func (w *FanoutWorker) deliveryCounts(ctx context.Context, msgs []redis.XMessage) map[string]int64 {
+	pipe := w.rdb.Pipeline()
+	cmds := make([]*redis.XPendingExtCmd, len(msgs))
+	for i, msg := range msgs {
+		cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: streamName,
+			Group:  consumerGroup,
+			Start:  msg.ID,
+			End:    msg.ID,
+			Count:  1,
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "xpending error", "error", err)
+	}
+	counts := make(map[string]int64, len(msgs))
+	for _, cmd := range cmds {
+		for _, p := range cmd.Val() {
+			counts[p.ID] = p.RetryCount
+		}
+	}
+	return counts
+}
+
 func (w *FanoutWorker) processDelivery(ctx context.Context, deliveryID uuid.UUID) {
-	delivery, err := w.store.Deliveries.GetByID(ctx, deliveryID)
+	ctx = logging.With(ctx, "delivery_id", deliveryID)
+	defer w.recoverDelivery(ctx, deliveryID)
+	delivery, err := w.getDeliveryWithRetry(ctx, deliveryID)
 	if err != nil {
-		slog.Error("failed to get delivery", "error", err, "delivery_id", deliveryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Either the source was deleted (cascading to its deliveries) or the
+			// row still isn't visible; the catch-up poller covers the latter.
+			logging.Sampled().InfoContext(ctx, "delivery not found, skipping")
+			return
+		}
+		slog.ErrorContext(ctx, "failed to get delivery", "error", err)
 		return
 	}
 
+	ctx = withDeliveryLog(ctx, delivery)
+
 	if delivery.Status != model.DeliveryPending {
 		return
 	}
@@ -130,26 +542,41 @@ func (w *FanoutWorker) processDelivery(ctx context.Context, deliveryID uuid.UUID
 	// Fetch the source to check mode and get script
 	src, err := w.store.Sources.GetByID(ctx, delivery.SourceID)
 	if err != nil {
-		slog.Error("failed to get source for delivery", "error", err, "delivery_id", deliveryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			w.cancelConfigRemoved(ctx, deliveryID, "source was deleted while the delivery was queued")
+			return
+		}
+		slog.ErrorContext(ctx, "failed to get source for delivery", "error", err)
 		return
 	}
 
+	limits := model.EffectiveLimits(w.limits, src)
+
 	// Guard against race: if source switched to record mode after webhook was accepted
 	if src.Mode == "record" {
 		w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryRecorded)
 		return
 	}
 
+	if src.MaxInFlight != nil {
+		if !w.acquireInFlight(ctx, src, delivery, true) {
+			logging.Sampled().InfoContext(ctx, "source at max in-flight deliveries, queued", "max_in_flight", *src.MaxInFlight)
+			return
+		}
+		defer w.releaseInFlight(ctx, src, deliveryID)
+	}
+
 	if err := w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryProcessing); err != nil {
-		slog.Error("failed to update delivery status", "error", err, "delivery_id", deliveryID)
+		slog.ErrorContext(ctx, "failed to update delivery status", "error", err)
 		return
 	}
 
 	actions, err := w.store.Actions.ListActiveBySource(ctx, delivery.SourceID)
 	if err != nil {
-		slog.Error("failed to list actions", "error", err, "delivery_id", deliveryID)
+		slog.ErrorContext(ctx, "failed to list actions", "error", err)
 		return
 	}
+	actions = subscribedActions(actions, delivery.EventType)
 
 	if len(actions) == 0 {
 		w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryCompleted)
@@ -161,50 +588,61 @@ func (w *FanoutWorker) processDelivery(ctx context.Context, deliveryID uuid.UUID
 	headers := delivery.Headers
 	activeActions := actions
 
-	// Run transform script if source has one
-	if src.ScriptBody != nil && *src.ScriptBody != "" {
-		transformResult, err := w.runTransform(*src.ScriptBody, delivery, actions)
-		if err != nil {
-			slog.Error("script execution failed", "error", err, "delivery_id", deliveryID)
-			w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryFailed)
-			return
+	// Run the global transform, then the source's own, if set. A delivery
+	// released from quarantine past its transform already carries the
+	// payload to dispatch.
+	var scripts []transformScript
+	if delivery.TransformedPayload != nil {
+		payload = delivery.TransformedPayload
+		if delivery.TransformedHeaders != nil {
+			headers = delivery.TransformedHeaders
 		}
-
-		if transformResult.Dropped {
-			slog.Info("script dropped delivery", "delivery_id", deliveryID)
-			w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryCompleted)
+	} else if scripts, err = w.transformScripts(ctx, src); err != nil {
+		slog.ErrorContext(ctx, "failed to load transform scripts", "error", err)
+		return
+	}
+	if len(scripts) > 0 {
+		transformResult, err := w.runTransforms(ctx, scripts, src.Slug, delivery, actions, limits)
+		if err != nil && !w.transformFailed(ctx, src, deliveryID, err) {
 			return
 		}
+		if err == nil {
+			if transformResult.Dropped {
+				logging.Sampled().InfoContext(ctx, "script dropped delivery")
+				w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryCompleted)
+				return
+			}
 
-		// Marshal transformed data
-		transformedPayload, err := json.Marshal(transformResult.Payload)
-		if err != nil {
-			slog.Error("failed to marshal transformed payload", "error", err, "delivery_id", deliveryID)
-			w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryFailed)
-			return
-		}
-		transformedHeaders, err := json.Marshal(transformResult.Headers)
-		if err != nil {
-			slog.Error("failed to marshal transformed headers", "error", err, "delivery_id", deliveryID)
-			w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryFailed)
-			return
-		}
+			// Marshal transformed data
+			transformedPayload, err := json.Marshal(transformResult.Payload)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to marshal transformed payload", "error", err)
+				w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryFailed)
+				return
+			}
+			transformedHeaders, err := json.Marshal(transformResult.Headers)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to marshal transformed headers", "error", err)
+				w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryFailed)
+				return
+			}
 
-		// Persist transformed data for retries
-		if err := w.store.Deliveries.SetTransformed(ctx, deliveryID, transformedPayload, transformedHeaders); err != nil {
-			slog.Error("failed to persist transformed data", "error", err, "delivery_id", deliveryID)
-		}
+			// Persist transformed data for retries
+			if err := w.store.Deliveries.SetTransformed(ctx, deliveryID, transformedPayload, transformedHeaders); err != nil {
+				slog.ErrorContext(ctx, "failed to persist transformed data", "error", err)
+			}
 
-		payload = transformedPayload
-		headers = transformedHeaders
+			payload = transformedPayload
+			headers = transformedHeaders
 
-		// Filter actions to only those the script kept
-		if len(transformResult.Actions) > 0 {
-			activeActions = filterActions(actions, transformResult.Actions)
-		} else {
-			// Script filtered all actions out
-			w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryCompleted)
-			return
+			// Filter actions to only those the script kept
+			if len(transformResult.Actions) > 0 {
+				activeActions = filterActions(actions, transformResult.Actions)
+			} else {
+				// Script filtered all actions out
+				w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryCompleted)
+				return
+			}
 		}
 	}
 
@@ -213,27 +651,140 @@ func (w *FanoutWorker) processDelivery(ctx context.Context, deliveryID uuid.UUID
 		return
 	}
 
-	allSuccess := true
-	for _, action := range activeActions {
-		var success bool
-		switch action.Type {
-		case model.ActionTypeJavascript:
-			success = w.dispatchJavascriptAction(ctx, delivery, &action, 1, payload, headers)
-		default:
-			success = w.dispatchWebhookAction(ctx, delivery, &action, 1, payload, headers)
+	// The delivery may have been cancelled while transforms ran
+	if w.isCancelled(ctx, deliveryID) {
+		logging.Sampled().InfoContext(ctx, "delivery cancelled, skipping dispatch")
+		return
+	}
+
+	// Dispatch to actions concurrently, at most fanoutParallelism at a time
+	var (
+		wg      sync.WaitGroup
+		failed  atomic.Bool
+		started int
+		sem     = make(chan struct{}, w.fanoutParallelism)
+		// A dispatch goroutine's panic is re-raised here, where
+		// recoverDelivery can catch it
+		panicked atomic.Value
+	)
+	for i := range activeActions {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
 		}
-		if !success {
-			allSuccess = false
+		if ctx.Err() != nil {
+			break
 		}
+		started++
+		wg.Add(1)
+		go func(action *model.Action) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if r := recover(); r != nil {
+					panicked.CompareAndSwap(nil, fmt.Sprint(r))
+				}
+			}()
+			if !w.dispatch(ctx, delivery, action, 1, payload, headers, limits) {
+				failed.Store(true)
+			}
+		}(&activeActions[i])
+	}
+	wg.Wait()
+	if r := panicked.Load(); r != nil {
+		panic(r)
 	}
 
-	if allSuccess {
+	if ctx.Err() != nil {
+		// Shutting down. If nothing was dispatched yet, hand the delivery
+		// back to the catch-up poller instead of leaving it processing.
+		rctx, cancel := detached(ctx)
+		defer cancel()
+		if started == 0 {
+			w.store.Deliveries.UpdateStatus(rctx, deliveryID, model.DeliveryPending)
+			return
+		}
+		// Otherwise give each action that never started an interrupted
+		// attempt, so the retry poller runs it and settles the delivery
+		for i := started; i < len(activeActions); i++ {
+			attempt, err := w.store.Deliveries.CreateAttempt(rctx, deliveryID, activeActions[i].ID, 1)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to record unstarted action", "error", err, "action_id", activeActions[i].ID)
+				continue
+			}
+			w.recordInterrupted(rctx, attempt.ID)
+		}
+		return
+	}
+
+	if !failed.Load() {
 		w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryCompleted)
+		return
 	}
+	w.settleDeliveryStatus(ctx, deliveryID)
 }
 
-// runTransform executes the source's JS transform script against the delivery.
-func (w *FanoutWorker) runTransform(scriptBody string, delivery *model.Delivery, actions []model.Action) (*script.TransformResult, error) {
+// getDeliveryWithRetry fetches a delivery, retrying briefly with backoff when
+// the row isn't visible yet (a stream message can be read before the
+// inserting transaction is visible to this connection, e.g. on a replica).
+func (w *FanoutWorker) getDeliveryWithRetry(ctx context.Context, id uuid.UUID) (*model.Delivery, error) {
+	backoff := lookupBackoff
+	for i := 0; ; i++ {
+		d, err := w.store.Deliveries.GetByID(ctx, id)
+		if err == nil || !errors.Is(err, pgx.ErrNoRows) || i == lookupRetries {
+			return d, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// transformFailed applies the source's transform error policy to a failed
+// transform, reporting whether to dispatch the original payload instead.
+func (w *FanoutWorker) transformFailed(ctx context.Context, src *model.Source, deliveryID uuid.UUID, err error) bool {
+	slog.ErrorContext(ctx, "script execution failed", "error", err, "policy", src.TransformErrorPolicy)
+	reason := model.TransformFailedReason + err.Error()
+	switch src.TransformErrorPolicy {
+	case model.TransformFailOpen:
+		w.store.Deliveries.SetStatusReason(ctx, deliveryID, reason+"; dispatched the original payload")
+		return true
+	case model.TransformQuarantine:
+		w.store.Deliveries.Cancel(ctx, deliveryID, model.DeliveryQuarantined, reason)
+	default:
+		w.store.Deliveries.Cancel(ctx, deliveryID, model.DeliveryFailed, reason)
+	}
+	return false
+}
+
+// transformScripts returns the transforms to run for src, in order: the global
+// transform followed by the source's own.
+func (w *FanoutWorker) transformScripts(ctx context.Context, src *model.Source) ([]transformScript, error) {
+	var scripts []transformScript
+	global, err := w.store.Settings.Get(ctx, store.SettingGlobalTransform)
+	if err != nil {
+		return nil, err
+	}
+	if global != nil && *global != "" {
+		scripts = append(scripts, transformScript{kind: model.ScriptKindGlobalTransform, body: *global})
+	}
+	if src.ScriptBody != nil && *src.ScriptBody != "" {
+		scripts = append(scripts, transformScript{kind: model.ScriptKindTransform, body: *src.ScriptBody})
+	}
+	return scripts, nil
+}
+
+type transformScript struct {
+	kind string
+	body string
+}
+
+// runTransforms executes the JS transform scripts against the delivery, each
+// one receiving the previous one's output. A drop short-circuits the chain.
+func (w *FanoutWorker) runTransforms(ctx context.Context, scripts []transformScript, sourceSlug string, delivery *model.Delivery, actions []model.Action, limits model.Limits) (*script.TransformResult, error) {
 	// Parse payload into a map
 	var payloadMap map[string]any
 	if err := json.Unmarshal(delivery.Payload, &payloadMap); err != nil {
@@ -260,9 +811,27 @@ func (w *FanoutWorker) runTransform(scriptBody string, delivery *model.Delivery,
 		Payload: payloadMap,
 		Headers: headersMap,
 		Actions: actionRefs,
+		Meta: script.Metadata{
+			DeliveryID: delivery.ID,
+			SourceSlug: sourceSlug,
+			ReceivedAt: delivery.ReceivedAt,
+		},
 	}
 
-	return script.Run(scriptBody, input)
+	var result *script.TransformResult
+	for _, s := range scripts {
+		start := time.Now()
+		var err error
+		w.runScript(func() {
+			result, err = script.RunWithTimeout(s.body, input, limits.ScriptTimeout())
+		})
+		w.recordScriptRun(ctx, delivery.SourceID, nil, s.kind, time.Since(start), limits.ScriptTimeout(), err)
+		if err != nil || result.Dropped {
+			return result, err
+		}
+		input.Payload, input.Headers, input.Actions = result.Payload, result.Headers, result.Actions
+	}
+	return result, nil
 }
 
 // filterActions returns only the actions whose IDs appear in the script result.
@@ -281,7 +850,23 @@ func filterActions(all []model.Action, kept []script.ActionRef) []model.Action {
 	return filtered
 }
 
-func (w *FanoutWorker) dispatchToAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int) bool {
+// subscribedActions keeps the actions whose event type subscriptions match
+// the delivery's event type.
+func subscribedActions(all []model.Action, eventType *string) []model.Action {
+	var t string
+	if eventType != nil {
+		t = *eventType
+	}
+	var subscribed []model.Action
+	for _, a := range all {
+		if eventtype.Matches(a.EventTypes, t) {
+			subscribed = append(subscribed, a)
+		}
+	}
+	return subscribed
+}
+
+func (w *FanoutWorker) dispatchToAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, limits model.Limits) bool {
 	// Use transformed payload/headers if available, otherwise originals
 	payload := delivery.Payload
 	headers := delivery.Headers
@@ -291,18 +876,122 @@ func (w *FanoutWorker) dispatchToAction(ctx context.Context, delivery *model.Del
 	if delivery.TransformedHeaders != nil {
 		headers = delivery.TransformedHeaders
 	}
+	return w.dispatch(ctx, delivery, action, attemptNumber, payload, headers, limits)
+}
+
+// dispatch applies the action's projection to the payload and hands it to the
+// type-specific dispatcher.
+func (w *FanoutWorker) dispatch(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload, headers json.RawMessage, limits model.Limits) bool {
+	ctx = logging.With(ctx, "action_id", action.ID, "attempt", attemptNumber)
+	// Outside the delivery window the attempt is deferred until it opens,
+	// like a rate-limited one
+	if now := time.Now(); !window.Open(action.DeliveryWindow, now) {
+		opens := window.NextOpen(action.DeliveryWindow, now)
+		wait := opens.Sub(now)
+		reason := "outside delivery window until " + opens.UTC().Format(time.RFC3339)
+		if err := w.store.Deliveries.CreateCappedAttempt(ctx, delivery.ID, action.ID, attemptNumber, reason, retryreason.Deferred, &wait); err != nil {
+			slog.ErrorContext(ctx, "failed to record deferred attempt", "error", err)
+		}
+		return false
+	}
+	// A capped attempt doesn't use up a retry, so it is always retried
+	if reason, capWindow, capped := w.attemptCapReached(ctx, action); capped {
+		slog.WarnContext(ctx, "action attempt cap reached", "reason", reason)
+		if err := w.store.Deliveries.CreateCappedAttempt(ctx, delivery.ID, action.ID, attemptNumber, reason, retryreason.Capped, &capWindow); err != nil {
+			slog.ErrorContext(ctx, "failed to record capped attempt", "error", err)
+		}
+		return false
+	}
+	if action.Type != model.ActionTypeJavascript {
+		// Over the rate limit the attempt is deferred like a capped one: it
+		// doesn't count toward caps and is always retried
+		if wait, limited := w.rateLimited(ctx, action); limited {
+			reason := fmt.Sprintf("rate limited: %d per second", *action.MaxRequestsPerSecond)
+			if err := w.store.Deliveries.CreateCappedAttempt(ctx, delivery.ID, action.ID, attemptNumber, reason, retryreason.RateLimited, &wait); err != nil {
+				slog.ErrorContext(ctx, "failed to record rate-limited attempt", "error", err)
+			}
+			return false
+		}
+	}
+
+	// A projection that can't be applied is in the action's configuration,
+	// so the attempt fails without a retry, like a template error
+	projected, err := projection.Apply(payload, action.Projection)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to apply projection", "error", err)
+		attempt, cerr := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+		if cerr != nil {
+			slog.ErrorContext(ctx, "failed to create attempt", "error", cerr)
+			return false
+		}
+		errMsg := "projection: " + err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+
 	switch action.Type {
 	case model.ActionTypeJavascript:
-		return w.dispatchJavascriptAction(ctx, delivery, action, attemptNumber, payload, headers)
+		return w.dispatchJavascriptAction(ctx, delivery, action, attemptNumber, projected, headers, limits)
+	case model.ActionTypeSlack:
+		return w.dispatchSlackAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeSMTP:
+		return w.dispatchSMTPAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeOpsGenie:
+		return w.dispatchOpsGenieAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeSQS:
+		return w.dispatchSQSAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeKinesis:
+		return w.dispatchKinesisAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeAMQP:
+		return w.dispatchAMQPAction(ctx, delivery, action, attemptNumber, projected)
+	case model.ActionTypeMQTT:
+		return w.dispatchMQTTAction(ctx, delivery, action, attemptNumber, projected)
+	case model.ActionTypeTelegram:
+		return w.dispatchTelegramAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeGRPC:
+		return w.dispatchGRPCAction(ctx, delivery, action, attemptNumber, projected, headers)
+	case model.ActionTypePostgres:
+		return w.dispatchPostgresAction(ctx, delivery, action, attemptNumber, projected)
+	case model.ActionTypeElasticsearch:
+		return w.dispatchElasticsearchAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeDatadog:
+		return w.dispatchDatadogAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeBigQuery:
+		return w.dispatchBigQueryAction(ctx, delivery, action, attemptNumber, projected, limits)
+	case model.ActionTypeRedis:
+		return w.dispatchRedisAction(ctx, delivery, action, attemptNumber, projected)
 	default:
-		return w.dispatchWebhookAction(ctx, delivery, action, attemptNumber, payload, headers)
+		return w.dispatchWebhookAction(ctx, delivery, action, attemptNumber, projected, headers, limits)
 	}
 }
 
-func (w *FanoutWorker) dispatchWebhookAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload, headers json.RawMessage) bool {
+// attemptCapReached reports whether the action has used up its hourly or daily
+// attempt budget, along with a reason and the window to wait before retrying.
+// Counting errors fail open so a database hiccup doesn't stall deliveries.
+func (w *FanoutWorker) attemptCapReached(ctx context.Context, action *model.Action) (string, time.Duration, bool) {
+	if action.MaxAttemptsPerHour == nil && action.MaxAttemptsPerDay == nil {
+		return "", 0, false
+	}
+
+	hour, day, err := w.store.Deliveries.CountRecentAttempts(ctx, action.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to count recent attempts", "error", err)
+		return "", 0, false
+	}
+
+	if action.MaxAttemptsPerDay != nil && day >= *action.MaxAttemptsPerDay {
+		return fmt.Sprintf("attempt cap reached: %d per day", *action.MaxAttemptsPerDay), 24 * time.Hour, true
+	}
+	if action.MaxAttemptsPerHour != nil && hour >= *action.MaxAttemptsPerHour {
+		return fmt.Sprintf("attempt cap reached: %d per hour", *action.MaxAttemptsPerHour), time.Hour, true
+	}
+	return "", 0, false
+}
+
+func (w *FanoutWorker) dispatchWebhookAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload, headers json.RawMessage, limits model.Limits) bool {
 	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
 	if err != nil {
-		slog.Error("failed to create attempt", "error", err)
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
 		return false
 	}
 
@@ -311,14 +1000,78 @@ func (w *FanoutWorker) dispatchWebhookAction(ctx context.Context, delivery *mode
 		targetURL = *action.TargetURL
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(payload))
+	// Don't spend a request (and a worker slot) on a target that keeps
+	// failing. No request was sent, so the retry keeps the attempt number.
+	if ok, failures, wait := w.breakers.allow(targetURL); !ok {
+		errMsg := fmt.Sprintf("%s: %d consecutive failures, next probe in %s", errCircuitOpen, failures, wait.Round(time.Second))
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, &wait, nil)
+		return false
+	}
+
+	// Templates replace the URL and body; errors are in the action's
+	// configuration, so the attempt isn't retried
+	method := http.MethodPost
+	if action.HTTPMethod != nil {
+		method = *action.HTTPMethod
+	}
+	requestURL := targetURL
+	if action.URLTemplate != nil {
+		if requestURL, err = reqtemplate.URL(*action.URLTemplate, targetURL, payload); err != nil {
+			errMsg := err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+
+	body := []byte(payload)
+	contentType := "application/json"
+	if action.BodyTemplate != nil {
+		if body, err = reqtemplate.Body(*action.BodyTemplate, payload, limits.MaxPayloadBytes); err != nil {
+			errMsg := err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+		contentType = reqtemplate.ContentType(body)
+	}
+	// Optionally wrap the payload as a CloudEvent
+	var ceHeaders map[string]string
+	if action.CloudEventsMode != nil {
+		attrs := outboundCloudEvent(delivery, headers)
+		// A body template already shapes the body, so only binary mode applies
+		if *action.CloudEventsMode == cloudevents.ModeStructured && action.BodyTemplate == nil {
+			if body, err = cloudevents.Structured(attrs, payload); err != nil {
+				errMsg := err.Error()
+				w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+				return false
+			}
+			contentType = cloudevents.ContentTypeStructured
+		} else {
+			ceHeaders = cloudevents.BinaryHeaders(attrs)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
 	if err != nil {
 		errMsg := err.Error()
-		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil)
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	// Templated URLs are only known now, and DNS may have changed since the
+	// target was saved. Neither an internal target nor a broken certificate,
+	// proxy URL or missing secrets key will fix itself on retry.
+	if err := w.clients.Guard().CheckURL(ctx, requestURL); errors.Is(err, ssrf.ErrBlocked) {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	client, err := w.clients.For(action)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
 		return false
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("X-Delivery-ID", delivery.ID.String())
 
 	// Apply any headers from the (potentially transformed) headers JSON
@@ -331,76 +1084,192 @@ func (w *FanoutWorker) dispatchWebhookAction(ctx context.Context, delivery *mode
 		}
 	}
 
+	for k, v := range ceHeaders {
+		req.Header.Set(k, v)
+	}
+
 	// Signing uses the payload that the subscriber actually receives
 	if action.SigningSecret != nil {
-		sig := signing.Sign(payload, *action.SigningSecret)
-		req.Header.Set("X-Webhook-Signature-256", sig)
+		sig := signing.Sign(body, *action.SigningSecret)
+		req.Header.Set(outbound.SignatureHeader, sig)
 	}
 
-	resp, err := w.httpClient.Do(req)
+	// The action's auth header wins over forwarded ones. A token endpoint
+	// that fails may recover, so stored credentials are retried; a missing
+	// secrets key won't fix itself.
+	name, value, ok, err := w.credentials.ActionHeader(ctx, client, action)
 	if err != nil {
+		errMsg := "auth: " + err.Error()
+		var retryDelay *time.Duration
+		if action.CredentialID != nil {
+			errMsg = "credential: " + err.Error()
+			retryDelay = w.nextRetryDelay(attemptNumber)
+		}
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, retryDelay, nil)
+		return false
+	}
+	if ok {
+		req.Header.Set(name, value)
+	}
+
+	w.recordBodyHash(ctx, attempt.ID, body)
+	req, tracer := outbound.Trace(req)
+	resp, err := client.Do(req)
+
+	// The receiver may have revoked the OAuth2 token before it expired:
+	// renew it and resend once
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && action.CredentialID != nil {
+		if retry := w.renewCredential(ctx, client, req, *action.CredentialID); retry != nil {
+			resp.Body.Close()
+			req, tracer = outbound.Trace(retry)
+			resp, err = client.Do(req)
+		}
+	}
+
+	// Record the outcome even if the worker is shutting down, otherwise the
+	// attempt is left pending forever.
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attempt.ID)
+			return false
+		}
+		w.recordCircuit(ctx, targetURL, false)
 		errMsg := err.Error()
-		nextRetry := w.nextRetryTime(attemptNumber)
-		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nextRetry)
+		retryDelay := w.nextRetryDelay(attemptNumber)
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, retryDelay, tracer.Timing())
 		return false
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodyLen))
-	bodyStr := string(body)
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, int64(limits.MaxResponseBytes)))
+	timing := tracer.Timing()
+	bodyStr := w.responseCipher.Seal(string(respBody))
 	statusCode := resp.StatusCode
 
+	// Any answer short of overload or a server error means the target is up
+	w.recordCircuit(ctx, targetURL, statusCode < 500 && statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests)
+
 	if statusCode >= 200 && statusCode < 300 {
-		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptSuccess, &statusCode, &bodyStr, nil, nil)
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptSuccess, &statusCode, &bodyStr, nil, nil, timing)
 		return true
 	}
 
 	errMsg := fmt.Sprintf("HTTP %d", statusCode)
-	nextRetry := w.nextRetryTime(attemptNumber)
-	w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, &statusCode, &bodyStr, &errMsg, nextRetry)
+	retryDelay := w.nextRetryDelay(attemptNumber)
+	w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, &statusCode, &bodyStr, &errMsg, retryDelay, timing)
 	return false
 }
 
-func (w *FanoutWorker) dispatchJavascriptAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload, headers json.RawMessage) bool {
+// renewCredential renews the credential's OAuth2 token and returns a copy of
+// req carrying it, or nil when there's nothing to renew or renewing failed.
+func (w *FanoutWorker) renewCredential(ctx context.Context, client *http.Client, req *http.Request, credentialID uuid.UUID) *http.Request {
+	name, value, renewed, err := w.credentials.Renew(ctx, client, credentialID)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to renew credential", "error", err, "credential_id", credentialID)
+	}
+	if !renewed || req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	retry := req.Clone(ctx)
+	retry.Body = body
+	retry.Header.Set(name, value)
+	return retry
+}
+
+// recordCircuit feeds an outcome to the target's circuit breaker, logging
+// state changes.
+func (w *FanoutWorker) recordCircuit(ctx context.Context, targetURL string, success bool) {
+	if !w.breakers.record(targetURL, success) {
+		return
+	}
+	if success {
+		slog.InfoContext(ctx, "circuit closed", "target_url", targetURL)
+	} else {
+		slog.WarnContext(ctx, "circuit open", "target_url", targetURL)
+	}
+}
+
+// recordInterrupted marks an attempt cut short by worker shutdown. It is not
+// the subscriber's fault, so the retry is due immediately rather than backed
+// off, and it doesn't use up a retry (see usedRetry), so one is always due.
+func (w *FanoutWorker) recordInterrupted(ctx context.Context, attemptID uuid.UUID) {
+	errMsg := errInterrupted
+	retryDelay := time.Duration(0)
+	if err := w.store.Deliveries.UpdateAttempt(ctx, attemptID, model.AttemptFailed, nil, nil, &errMsg, &retryDelay, nil); err != nil {
+		slog.ErrorContext(ctx, "failed to record interrupted attempt", "error", err, "attempt_id", attemptID)
+	}
+}
+
+// detached returns a context that outlives ctx's cancellation, bounded so
+// recording an outcome can't stall shutdown.
+func detached(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+}
+
+func (w *FanoutWorker) dispatchJavascriptAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload, headers json.RawMessage, limits model.Limits) bool {
 	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
 	if err != nil {
-		slog.Error("failed to create attempt", "error", err)
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
 		return false
 	}
 
 	if action.ScriptBody == nil || *action.ScriptBody == "" {
 		errMsg := "javascript action has no script_body"
-		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil)
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
 		return false
 	}
 
 	var payloadMap map[string]any
 	if err := json.Unmarshal(payload, &payloadMap); err != nil {
 		errMsg := fmt.Sprintf("failed to unmarshal payload: %v", err)
-		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil)
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
 		return false
 	}
 
 	var headersMap map[string]string
 	if err := json.Unmarshal(headers, &headersMap); err != nil {
 		errMsg := fmt.Sprintf("failed to unmarshal headers: %v", err)
-		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil)
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
 		return false
 	}
 
-	result, err := script.RunAction(*action.ScriptBody, payloadMap, headersMap)
+	meta := script.Metadata{
+		DeliveryID:    delivery.ID,
+		ReceivedAt:    delivery.ReceivedAt,
+		ActionID:      action.ID,
+		AttemptNumber: attemptNumber,
+	}
+	if src, err := w.store.Sources.GetByID(ctx, delivery.SourceID); err == nil {
+		meta.SourceSlug = src.Slug
+	}
+
+	start := time.Now()
+	var result string
+	w.runScript(func() {
+		result, err = script.RunActionWithTimeout(*action.ScriptBody, payloadMap, headersMap, meta, limits.ScriptTimeout())
+	})
+	w.recordScriptRun(ctx, delivery.SourceID, &action.ID, model.ScriptKindAction, time.Since(start), limits.ScriptTimeout(), err)
 	if err != nil {
 		errMsg := err.Error()
-		nextRetry := w.nextRetryTime(attemptNumber)
-		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nextRetry)
+		retryDelay := w.nextRetryDelay(attemptNumber)
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, retryDelay, nil)
 		return false
 	}
 
-	w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptSuccess, nil, &result, nil, nil)
+	w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptSuccess, nil, &result, nil, nil, nil)
 	return true
 }
 
-func (w *FanoutWorker) nextRetryTime(attemptNumber int) *time.Time {
+// nextRetryDelay returns how long to wait before the next attempt, or nil once
+// retries are exhausted. The store anchors it to the database clock.
+func (w *FanoutWorker) nextRetryDelay(attemptNumber int) *time.Duration {
 	if attemptNumber >= w.maxRetries {
 		return nil // exhausted retries
 	}
@@ -410,8 +1279,7 @@ func (w *FanoutWorker) nextRetryTime(attemptNumber int) *time.Time {
 	}
 	// Add jitter: +-25%
 	jitter := time.Duration(float64(delay) * (0.75 + rand.Float64()*0.5))
-	t := time.Now().Add(jitter)
-	return &t
+	return &jitter
 }
 
 func (w *FanoutWorker) pollPending(ctx context.Context) {
@@ -423,13 +1291,19 @@ func (w *FanoutWorker) pollPending(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			deliveries, err := w.store.Deliveries.ListPending(ctx, 100)
+			if !w.scheduler.Held() {
+				continue
+			}
+			deliveries, err := w.store.Deliveries.ListPending(ctx, 100, inFlightRecheck)
 			if err != nil {
-				slog.Error("poll pending error", "error", err)
+				slog.ErrorContext(ctx, "poll pending error", "error", err)
 				continue
 			}
 			for _, d := range deliveries {
-				slog.Info("catch-up: processing pending delivery", "delivery_id", d.ID)
+				if !w.stillScheduling(ctx) {
+					break
+				}
+				logging.Sampled().InfoContext(ctx, "catch-up: processing pending delivery", "delivery_id", d.ID)
 				w.processDelivery(ctx, d.ID)
 			}
 		}
@@ -445,67 +1319,185 @@ func (w *FanoutWorker) pollRetries(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if !w.scheduler.Held() {
+				continue
+			}
 			attempts, err := w.store.Deliveries.ListRetryableAttempts(ctx, 100)
 			if err != nil {
-				slog.Error("poll retries error", "error", err)
+				slog.ErrorContext(ctx, "poll retries error", "error", err)
 				continue
 			}
 			for _, a := range attempts {
+				if !w.stillScheduling(ctx) {
+					break
+				}
 				w.retryAttempt(ctx, &a)
 			}
 		}
 	}
 }
 
+// handleRetryMessage dispatches a retry the API asked for. The attempt is
+// claimed first, so a retry the poll already sent is not sent twice.
+func (w *FanoutWorker) handleRetryMessage(ctx context.Context, msg redis.XMessage, attemptIDStr string) {
+	defer func() {
+		if ctx.Err() == nil {
+			w.rdb.XAck(ctx, streamName, consumerGroup, msg.ID)
+			w.rdb.XDel(ctx, streamName, msg.ID)
+		}
+	}()
+	attemptID, err := uuid.Parse(attemptIDStr)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to parse retry_attempt_id", "error", err, "value", attemptIDStr)
+		return
+	}
+	attempt, err := w.store.Deliveries.ClaimRetry(ctx, attemptID, retryClaimHold)
+	if errors.Is(err, pgx.ErrNoRows) {
+		logging.Sampled().InfoContext(ctx, "manual retry already taken", "attempt_id", attemptID)
+		return
+	}
+	if err != nil {
+		// The retry stays due, so the poll still sends it
+		slog.ErrorContext(ctx, "failed to claim manual retry", "error", err, "attempt_id", attemptID)
+		return
+	}
+	w.retryAttempt(ctx, attempt)
+}
+
+// stillScheduling reports whether a scan begun under the scheduler lease may
+// go on. Items are sent one after another, so the lease can be lost midway;
+// the scan then stops rather than repeat the new holder's work.
+func (w *FanoutWorker) stillScheduling(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if !w.scheduler.Held() {
+		slog.WarnContext(ctx, "scheduler lease lost, stopping scan")
+		return false
+	}
+	return true
+}
+
 func (w *FanoutWorker) retryAttempt(ctx context.Context, prev *model.DeliveryAttempt) {
+	ctx = logging.With(ctx, "delivery_id", prev.DeliveryID, "action_id", prev.ActionID)
+	defer w.recoverDelivery(ctx, prev.DeliveryID)
 	delivery, err := w.store.Deliveries.GetByID(ctx, prev.DeliveryID)
 	if err != nil {
-		slog.Error("retry: failed to get delivery", "error", err)
+		slog.ErrorContext(ctx, "retry: failed to get delivery", "error", err)
+		return
+	}
+	ctx = withDeliveryLog(ctx, delivery)
+
+	switch delivery.Status {
+	case model.DeliveryCancelledConfigRemoved, model.DeliveryCancelled, model.DeliveryNeedsInvestigation, model.DeliveryCoalesced:
+		w.clearRetry(ctx, prev)
 		return
 	}
 
 	action, err := w.store.Actions.GetByID(ctx, prev.ActionID)
 	if err != nil {
-		slog.Error("retry: failed to get action", "error", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			w.clearRetry(ctx, prev)
+			w.cancelConfigRemoved(ctx, delivery.ID, fmt.Sprintf("action %s was deleted before its retry", prev.ActionID))
+			return
+		}
+		slog.ErrorContext(ctx, "retry: failed to get action", "error", err)
 		return
 	}
 
-	nextAttempt := prev.AttemptNumber + 1
-	success := w.dispatchToAction(ctx, delivery, action, nextAttempt)
+	// Fall back to the global limits if the source can't be read; a deleted
+	// source would have cascaded the delivery away already.
+	limits := w.limits
+	src, err := w.store.Sources.GetByID(ctx, delivery.SourceID)
+	if err == nil {
+		limits = model.EffectiveLimits(w.limits, src)
+		// Retries count against the in-flight cap too. They don't queue:
+		// one that finds no free slot stays due for the next poll.
+		if src.MaxInFlight != nil {
+			if !w.acquireInFlight(ctx, src, delivery, false) {
+				return
+			}
+			defer w.releaseInFlight(ctx, src, delivery.ID)
+		}
+	}
+
+	nextAttempt := prev.AttemptNumber
+	if usedRetry(prev) {
+		nextAttempt++
+	}
+	w.dispatchToAction(ctx, delivery, action, nextAttempt, limits)
 
 	// Clear the retry marker on the old attempt so it's not picked up again
-	w.store.Deliveries.UpdateAttempt(ctx, prev.ID, model.AttemptFailed, prev.ResponseStatus, prev.ResponseBody, prev.ErrorMessage, nil)
+	w.clearRetry(ctx, prev)
 
-	// Roll up delivery status if this was the last action or all succeeded
-	if success {
-		w.rollUpDeliveryStatus(ctx, delivery.ID)
-	} else if nextAttempt >= w.maxRetries {
-		w.store.Deliveries.UpdateStatus(ctx, delivery.ID, model.DeliveryFailed)
+	w.settleDeliveryStatus(ctx, delivery.ID)
+}
+
+// usedRetry reports whether an attempt counts against the retry budget.
+// Attempts held back before sending (capped, deferred, rate limited or
+// stopped by the circuit breaker) or cut short by shutdown don't, so the
+// attempt that follows them keeps their number and a real failure still
+// gets its retries. A manual retry replaces the reason, so it is read back
+// from the error message.
+func usedRetry(a *model.DeliveryAttempt) bool {
+	if a.Capped {
+		return false
+	}
+	if a.RetryReason == nil {
+		return true
+	}
+	reason := *a.RetryReason
+	if reason == retryreason.Manual && a.ErrorMessage != nil {
+		reason = retryreason.Classify(*a.ErrorMessage)
+	}
+	switch reason {
+	case retryreason.CircuitOpen, retryreason.Interrupted:
+		return false
 	}
+	return true
 }
 
-func (w *FanoutWorker) rollUpDeliveryStatus(ctx context.Context, deliveryID uuid.UUID) {
-	delivery, err := w.store.Deliveries.GetByID(ctx, deliveryID)
-	if err != nil {
-		return
+func (w *FanoutWorker) clearRetry(ctx context.Context, prev *model.DeliveryAttempt) {
+	w.store.Deliveries.UpdateAttempt(ctx, prev.ID, model.AttemptFailed, prev.ResponseStatus, prev.ResponseBody, prev.ErrorMessage, nil, &prev.AttemptTiming)
+}
+
+// isCancelled reports whether the delivery has been cancelled through the API.
+func (w *FanoutWorker) isCancelled(ctx context.Context, deliveryID uuid.UUID) bool {
+	d, err := w.store.Deliveries.GetByID(ctx, deliveryID)
+	return err == nil && d.Status == model.DeliveryCancelled
+}
+
+// cancelConfigRemoved stops a delivery whose source or action was deleted
+// mid-flight, recording why so it doesn't look stuck.
+func (w *FanoutWorker) cancelConfigRemoved(ctx context.Context, deliveryID uuid.UUID, reason string) {
+	slog.InfoContext(ctx, "cancelling delivery: configuration removed", "reason", reason)
+	if err := w.store.Deliveries.Cancel(ctx, deliveryID, model.DeliveryCancelledConfigRemoved, reason); err != nil {
+		slog.ErrorContext(ctx, "failed to cancel delivery", "error", err)
 	}
+}
 
-	actions, err := w.store.Actions.ListActiveBySource(ctx, delivery.SourceID)
+// settleDeliveryStatus sets the final delivery status once every action's
+// latest attempt has either succeeded or run out of retries: completed if all
+// succeeded, failed if none did, partially_failed for a mix. It does nothing
+// while any action still has a retry pending.
+func (w *FanoutWorker) settleDeliveryStatus(ctx context.Context, deliveryID uuid.UUID) {
+	succeeded, exhausted, inFlight, err := w.store.Deliveries.ActionOutcomes(ctx, deliveryID)
 	if err != nil {
+		slog.ErrorContext(ctx, "failed to count action outcomes", "error", err)
 		return
 	}
-
-	allDone := true
-	for _, action := range actions {
-		maxAttempt, err := w.store.Deliveries.GetMaxAttemptNumber(ctx, deliveryID, action.ID)
-		if err != nil || maxAttempt == 0 {
-			allDone = false
-			continue
-		}
-		_ = maxAttempt
+	if inFlight > 0 || succeeded+exhausted == 0 {
+		return
 	}
 
-	if allDone {
-		w.store.Deliveries.UpdateStatus(ctx, deliveryID, model.DeliveryCompleted)
+	status := model.DeliveryPartiallyFailed
+	switch {
+	case exhausted == 0:
+		status = model.DeliveryCompleted
+	case succeeded == 0:
+		status = model.DeliveryFailed
+	}
+	if err := w.store.Deliveries.UpdateStatus(ctx, deliveryID, status); err != nil {
+		slog.ErrorContext(ctx, "failed to update delivery status", "error", err)
 	}
 }
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/retryreason"
+)
+
+func TestUsedRetry(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name string
+		a    model.DeliveryAttempt
+		want bool
+	}{
+		{"no reason", model.DeliveryAttempt{}, true},
+		{"subscriber error", model.DeliveryAttempt{RetryReason: str(retryreason.HTTP5xx), ErrorMessage: str("HTTP 503")}, true},
+		{"capped", model.DeliveryAttempt{Capped: true, RetryReason: str(retryreason.Capped)}, false},
+		{"deferred", model.DeliveryAttempt{Capped: true, RetryReason: str(retryreason.Deferred)}, false},
+		{"circuit open", model.DeliveryAttempt{RetryReason: str(retryreason.CircuitOpen), ErrorMessage: str(errCircuitOpen + ": 5 consecutive failures, next probe in 30s")}, false},
+		{"interrupted", model.DeliveryAttempt{RetryReason: str(retryreason.Interrupted), ErrorMessage: str(errInterrupted)}, false},
+		{"manual after subscriber error", model.DeliveryAttempt{RetryReason: str(retryreason.Manual), ErrorMessage: str("HTTP 503")}, true},
+		{"manual after interruption", model.DeliveryAttempt{RetryReason: str(retryreason.Manual), ErrorMessage: str(errInterrupted)}, false},
+		{"manual after open circuit", model.DeliveryAttempt{RetryReason: str(retryreason.Manual), ErrorMessage: str(errCircuitOpen + ": 5 consecutive failures, next probe in 30s")}, false},
+		{"manual without message", model.DeliveryAttempt{RetryReason: str(retryreason.Manual)}, true},
+	}
+	for _, tt := range tests {
+		if got := usedRetry(&tt.a); got != tt.want {
+			t.Errorf("%s: usedRetry = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// The worker's own error messages must classify as the reasons usedRetry
+// and the retry breakdown rely on.
+func TestWorkerErrorReasons(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{errInterrupted, retryreason.Interrupted},
+		{errCircuitOpen + ": 5 consecutive failures, next probe in 30s", retryreason.CircuitOpen},
+		{"script execution error: ReferenceError: x is not defined", retryreason.ScriptError},
+	}
+	for _, tt := range tests {
+		if got := retryreason.Classify(tt.msg); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestAlreadyProcessed(t *testing.T) {
+	tests := []struct {
+		name string
+		d    *model.Delivery
+		err  error
+		want bool
+	}{
+		{"completed", &model.Delivery{Status: model.DeliveryCompleted}, nil, true},
+		{"failed", &model.Delivery{Status: model.DeliveryFailed}, nil, true},
+		// Reprocessing resets a delivery to pending under the same ID and
+		// publishes it again; the old ledger entry mustn't swallow it
+		{"reprocessed", &model.Delivery{Status: model.DeliveryPending}, nil, false},
+		{"deleted", nil, errors.New("no rows in result set"), true},
+	}
+	for _, tt := range tests {
+		if got := alreadyProcessed(tt.d, tt.err); got != tt.want {
+			t.Errorf("%s: alreadyProcessed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/grpcsink"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// dispatchGRPCAction calls the action's WebhookSink.Deliver with the payload
+// and records the response message as the body. Configuration errors and
+// permanent statuses (INVALID_ARGUMENT, PERMISSION_DENIED and the like)
+// aren't retried; transient statuses and connection failures are.
+func (w *FanoutWorker) dispatchGRPCAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload, headers json.RawMessage) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := grpcsink.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	secret := ""
+	if action.Secret != nil {
+		if secret, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open grpc secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	client, err := w.clients.HTTP2Client(action, grpcsink.Plaintext(cfg))
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+
+	msg := grpcsink.Message{
+		Payload:    payload,
+		DeliveryID: delivery.ID.String(),
+		ActionID:   action.ID.String(),
+		Attempt:    attemptNumber,
+		ReceivedAt: delivery.ReceivedAt,
+	}
+	if delivery.EventType != nil {
+		msg.EventType = *delivery.EventType
+	}
+	json.Unmarshal(headers, &msg.Headers)
+	if src, err := w.store.Sources.GetByID(ctx, delivery.SourceID); err == nil {
+		msg.Source = src.Slug
+	}
+
+	result, err := grpcsink.Deliver(ctx, client, cfg, secret, grpcsink.Timeout(cfg, w.clients.Timeout()), msg)
+
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attempt.ID)
+			return false
+		}
+		errMsg := err.Error()
+		var retryDelay *time.Duration
+		if !grpcsink.Permanent(err) {
+			retryDelay = w.nextRetryDelay(attemptNumber)
+		}
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, retryDelay, nil)
+		return false
+	}
+	body := w.responseCipher.Seal(result)
+	w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptSuccess, nil, &body, nil, nil, nil)
+	return true
+}
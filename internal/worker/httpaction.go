@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/outbound"
+)
+
+// sendActionRequest sends an integration action's request (Slack, OpsGenie)
+// through the action's client and records the outcome on the attempt. check
+// turns a response into a failure; nil accepts any 2xx. Failed requests are
+// retried like webhook requests. Transport errors name only the host, since
+// integration URLs can hold secrets (Slack webhook URLs, Telegram tokens).
+func (w *FanoutWorker) sendActionRequest(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptID uuid.UUID, attemptNumber int, req *http.Request, limits model.Limits, check func(status int, body []byte) error) bool {
+	req.Header.Set("X-Delivery-ID", delivery.ID.String())
+	client, err := w.clients.For(action)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attemptID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			sent, _ := io.ReadAll(body)
+			w.recordBodyHash(ctx, attemptID, sent)
+		}
+	}
+	req, tracer := outbound.Trace(req)
+	resp, err := client.Do(req)
+
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attemptID)
+			return false
+		}
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = req.URL.Scheme + "://" + req.URL.Host
+		}
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(rctx, attemptID, model.AttemptFailed, nil, nil, &errMsg, w.nextRetryDelay(attemptNumber), tracer.Timing())
+		return false
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, int64(limits.MaxResponseBytes)))
+	timing := tracer.Timing()
+	bodyStr := w.responseCipher.Seal(string(respBody))
+	statusCode := resp.StatusCode
+
+	if check == nil {
+		check = checkStatus
+	}
+	if err := check(statusCode, respBody); err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(rctx, attemptID, model.AttemptFailed, &statusCode, &bodyStr, &errMsg, w.nextRetryDelay(attemptNumber), timing)
+		return false
+	}
+	w.store.Deliveries.UpdateAttempt(rctx, attemptID, model.AttemptSuccess, &statusCode, &bodyStr, nil, nil, timing)
+	return true
+}
+
+func checkStatus(status int, _ []byte) error {
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("HTTP %d", status)
+	}
+	return nil
+}
+
+// recordBodyHash stores the hash of the body an attempt sends, which signed
+// manifests cover.
+func (w *FanoutWorker) recordBodyHash(ctx context.Context, attemptID uuid.UUID, body []byte) {
+	if err := w.store.Deliveries.SetAttemptBodyHash(ctx, attemptID, body); err != nil {
+		slog.ErrorContext(ctx, "failed to record attempt body hash", "error", err)
+	}
+}
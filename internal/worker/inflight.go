@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+const (
+	inFlightKeyPrefix = "nitrohook:inflight:"
+	// inFlightLease bounds how long a slot is held if its worker dies
+	// without releasing it, and how long a queued delivery keeps its place
+	// without asking for a slot again.
+	inFlightLease = 10 * time.Minute
+	// inFlightRecheck is how long the catch-up poll leaves a queued delivery
+	// alone. Re-offering it renews its place well before the lease lapses.
+	inFlightRecheck = inFlightLease / 2
+)
+
+// lapseQueued drops queued deliveries whose place in the queue (KEYS[2])
+// lapsed, per KEYS[3] scored by expiry, such as ones cancelled or deleted
+// while queued, so they can't block the queue head.
+const lapseQueued = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+for _, id in ipairs(redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", now)) do
+	redis.call("ZREM", KEYS[2], id)
+end
+redis.call("ZREMRANGEBYSCORE", KEYS[3], "-inf", now)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+`
+
+// acquireSlot grants a delivery (ARGV[1]) one of a source's ARGV[2] slots
+// and returns 1, or returns 0 and, when ARGV[5] is 1, queues it by ARGV[3]
+// (received time in ms). KEYS[1] holds slot holders scored by lease expiry,
+// KEYS[2] the queue. A delivery already holding a slot, such as one handed
+// a slot on release, keeps it; otherwise only the head of the queue may take
+// a free slot, so queued deliveries go in order. Unqueued callers (retries)
+// take any free slot.
+var acquireSlot = redis.NewScript(lapseQueued + `
+local lease = now + tonumber(ARGV[4])
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	redis.call("ZADD", KEYS[1], lease, ARGV[1])
+	return 1
+end
+local head = redis.call("ZRANGE", KEYS[2], 0, 0)[1]
+if (not head or head == ARGV[1] or ARGV[5] ~= "1") and redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call("ZREM", KEYS[2], ARGV[1])
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	redis.call("ZADD", KEYS[1], lease, ARGV[1])
+	return 1
+end
+if ARGV[5] == "1" then
+	redis.call("ZADD", KEYS[2], "NX", ARGV[3], ARGV[1])
+	redis.call("ZADD", KEYS[3], lease, ARGV[1])
+end
+return 0`)
+
+// releaseSlot frees the slot of delivery ARGV[1]. When ARGV[3] is 1 and a
+// slot is free, it hands it to the head of the queue and returns that
+// delivery's ID.
+var releaseSlot = redis.NewScript(lapseQueued + `
+redis.call("ZREM", KEYS[1], ARGV[1])
+if ARGV[3] ~= "1" or redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return false
+end
+local head = redis.call("ZRANGE", KEYS[2], 0, 0)[1]
+if not head then
+	return false
+end
+redis.call("ZREM", KEYS[2], head)
+redis.call("ZREM", KEYS[3], head)
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[4]), head)
+return head`)
+
+func inFlightKeys(sourceID uuid.UUID) []string {
+	key := inFlightKeyPrefix + sourceID.String()
+	return []string{key, key + ":queue", key + ":queued"}
+}
+
+// acquireInFlight takes one of the source's in-flight slots for the delivery
+// and reports whether it got one. Without one, a queued delivery joins the
+// source's queue and is kept out of the catch-up poll for a while. Redis
+// errors fail open.
+func (w *FanoutWorker) acquireInFlight(ctx context.Context, src *model.Source, d *model.Delivery, queue bool) bool {
+	q := 0
+	if queue {
+		q = 1
+	}
+	ok, err := acquireSlot.Run(ctx, w.rdb, inFlightKeys(src.ID),
+		d.ID.String(), *src.MaxInFlight, d.ReceivedAt.UnixMilli(), inFlightLease.Milliseconds(), q).Int()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to acquire in-flight slot", "error", err)
+		return true
+	}
+	if ok == 1 {
+		return true
+	}
+	if queue {
+		if err := w.store.Deliveries.MarkQueued(ctx, d.ID); err != nil {
+			slog.ErrorContext(ctx, "failed to mark delivery queued", "error", err)
+		}
+	}
+	return false
+}
+
+// releaseInFlight frees the delivery's slot and, unless shutting down,
+// processes the next queued delivery of the source in its place.
+func (w *FanoutWorker) releaseInFlight(ctx context.Context, src *model.Source, deliveryID uuid.UUID) {
+	handoff := 1
+	if ctx.Err() != nil {
+		handoff = 0
+	}
+	rctx, cancel := detached(ctx)
+	next, err := releaseSlot.Run(rctx, w.rdb, inFlightKeys(src.ID),
+		deliveryID.String(), *src.MaxInFlight, handoff, inFlightLease.Milliseconds()).Text()
+	cancel()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.ErrorContext(ctx, "failed to release in-flight slot", "error", err)
+		}
+		return
+	}
+	nextID, err := uuid.Parse(next)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid queued delivery id", "value", next)
+		return
+	}
+	w.running.Add(1)
+	go func() {
+		defer w.running.Done()
+		w.processDelivery(ctx, nextID)
+		// Frees the slot if processing returned before taking it over, as
+		// for a delivery cancelled while queued; a no-op otherwise.
+		w.releaseInFlight(ctx, src, nextID)
+	}()
+}
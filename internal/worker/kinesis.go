@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/awssig"
+	"github.com/zachbroad/nitrohook/internal/kinesis"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// dispatchKinesisAction puts the payload into the action's Kinesis stream
+// under its rendered partition key. Configuration errors aren't retried;
+// failed puts are.
+func (w *FanoutWorker) dispatchKinesisAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage, limits model.Limits) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := kinesis.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	secret := ""
+	if action.Secret != nil {
+		if secret, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open kinesis secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	creds, err := awssig.ParseCredentials(secret)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	key, err := kinesis.PartitionKey(cfg, payload, delivery.ID.String())
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	req, err := kinesis.NewRequest(ctx, cfg, creds, payload, key, time.Now())
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	return w.sendActionRequest(ctx, delivery, action, attempt.ID, attemptNumber, req, limits, kinesis.CheckResponse)
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const schedulerLeaseKey = "nitrohook:scheduler"
+
+var (
+	// renewLease extends the lease only if this instance still holds it.
+	renewLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// releaseLease deletes the lease only if this instance holds it.
+	releaseLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// lease elects a single scheduler among worker processes through a Redis key
+// with a TTL. The holder renews it every third of the TTL; if it dies, another
+// worker takes over once the key expires.
+type lease struct {
+	rdb  *redis.Client
+	key  string
+	id   string
+	ttl  time.Duration
+	held atomic.Bool
+}
+
+func newLease(rdb *redis.Client, key string, ttl time.Duration) *lease {
+	host, _ := os.Hostname()
+	return &lease{
+		rdb: rdb,
+		key: key,
+		id:  fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8]),
+		ttl: ttl,
+	}
+}
+
+// Held reports whether this instance currently holds the lease.
+func (l *lease) Held() bool {
+	return l.held.Load()
+}
+
+// run acquires and renews the lease until ctx is done, then releases it so a
+// successor doesn't have to wait for the TTL.
+func (l *lease) run(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		l.tick(ctx)
+		select {
+		case <-ctx.Done():
+			if l.held.Load() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				releaseLease.Run(releaseCtx, l.rdb, []string{l.key}, l.id)
+				cancel()
+				l.held.Store(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *lease) tick(ctx context.Context) {
+	if l.held.Load() {
+		renewed, err := renewLease.Run(ctx, l.rdb, []string{l.key}, l.id, l.ttl.Milliseconds()).Int()
+		if err == nil && renewed == 1 {
+			return
+		}
+		// Stop scheduling on any doubt; another worker may take over
+		l.held.Store(false)
+		slog.WarnContext(ctx, "lost scheduler lease", "id", l.id, "error", err)
+		return
+	}
+
+	acquired, err := l.rdb.SetNX(ctx, l.key, l.id, l.ttl).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "scheduler lease error", "error", err)
+		}
+		return
+	}
+	if acquired {
+		l.held.Store(true)
+		slog.InfoContext(ctx, "acquired scheduler lease", "id", l.id, "ttl", l.ttl)
+	}
+}
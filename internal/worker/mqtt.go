@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/mqtt"
+)
+
+// dispatchMQTTAction publishes the payload to the action's rendered topic
+// and records the broker's acknowledgement as the response body. Refused
+// connections and configuration errors aren't retried, except when the
+// broker reports itself unavailable; connection failures are.
+func (w *FanoutWorker) dispatchMQTTAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := mqtt.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	secret := ""
+	if action.Secret != nil {
+		if secret, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open mqtt secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	topic, err := mqtt.Topic(cfg, payload)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+
+	pctx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout := w.clients.Timeout(); timeout > 0 {
+		pctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	result, err := mqtt.Publish(pctx, w.clients.DialContext, cfg, secret, topic, payload)
+	cancel()
+
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attempt.ID)
+			return false
+		}
+		errMsg := err.Error()
+		var retryDelay *time.Duration
+		if !mqtt.Permanent(err) {
+			retryDelay = w.nextRetryDelay(attemptNumber)
+		}
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, retryDelay, nil)
+		return false
+	}
+	body := w.responseCipher.Seal(result)
+	w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptSuccess, nil, &body, nil, nil, nil)
+	return true
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/opsgenie"
+)
+
+// dispatchOpsGenieAction creates or closes an OpsGenie alert for the payload.
+// Configuration and rendering errors aren't retried; failed requests are.
+func (w *FanoutWorker) dispatchOpsGenieAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage, limits model.Limits) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := opsgenie.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	apiKey := ""
+	if action.Secret != nil {
+		if apiKey, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open opsgenie secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	req, err := opsgenie.NewRequest(ctx, cfg, apiKey, payload, limits.MaxPayloadBytes)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	return w.sendActionRequest(ctx, delivery, action, attempt.ID, attemptNumber, req, limits, nil)
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/metrics"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// consumerRestartDelay spaces out restarts of a consumer that keeps panicking.
+const consumerRestartDelay = time.Second
+
+// recoverDelivery, deferred by the functions that process a delivery, turns a
+// panic into a needs_investigation status instead of killing the goroutine.
+// The delivery isn't retried: the same input would likely panic again.
+func (w *FanoutWorker) recoverDelivery(ctx context.Context, deliveryID uuid.UUID) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	slog.ErrorContext(ctx, "panic while processing delivery", "panic", r, "stack", string(debug.Stack()))
+	rctx, cancel := detached(ctx)
+	defer cancel()
+	metrics.Incr(rctx, w.rdb, metrics.WorkerPanics)
+	reason := fmt.Sprintf("worker panicked: %v", r)
+	if err := w.store.Deliveries.Cancel(rctx, deliveryID, model.DeliveryNeedsInvestigation, reason); err != nil {
+		slog.ErrorContext(ctx, "failed to mark delivery for investigation", "error", err)
+	}
+}
+
+// handleMessageRecovering runs handleMessage, containing a panic outside
+// delivery processing to the one message. The message stays pending and is
+// reclaimed later.
+func (w *FanoutWorker) handleMessageRecovering(ctx context.Context, consumer string, msg redis.XMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "panic while handling stream message", "consumer", consumer, "msg_id", msg.ID, "panic", r, "stack", string(debug.Stack()))
+			metrics.Incr(context.WithoutCancel(ctx), w.rdb, metrics.WorkerPanics)
+		}
+	}()
+	w.usage.countHandled(consumer)
+	w.handleMessage(ctx, msg)
+}
+
+// superviseConsumer runs a stream consumer, restarting it if it panics
+// outside delivery processing, until ctx is done.
+func (w *FanoutWorker) superviseConsumer(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		if !w.runConsumer(ctx, consumer) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(consumerRestartDelay):
+		}
+	}
+}
+
+// runConsumer runs consumeStream and reports whether it panicked.
+func (w *FanoutWorker) runConsumer(ctx context.Context, consumer string) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "stream consumer panicked, restarting", "consumer", consumer, "panic", r, "stack", string(debug.Stack()))
+			metrics.Incr(context.WithoutCancel(ctx), w.rdb, metrics.WorkerPanics)
+			panicked = true
+		}
+	}()
+	w.consumeStream(ctx, consumer)
+	return false
+}
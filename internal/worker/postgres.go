@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/pgsink"
+)
+
+// dispatchPostgresAction inserts the payload into the action's table and
+// records the command tag as the response body. Errors the server returns
+// aren't retried unless they're transient, such as serialization failures
+// or a server shutting down; connection failures are.
+func (w *FanoutWorker) dispatchPostgresAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := pgsink.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	if action.Secret == nil {
+		errMsg := "postgres action has no dsn"
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	dsn, err := w.secrets.Open(*action.Secret)
+	if err != nil {
+		errMsg := "open postgres secret: " + err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	if err := pgsink.Validate(cfg, dsn); err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+
+	ictx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout := w.clients.Timeout(); timeout > 0 {
+		ictx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	tag, err := pgsink.Insert(ictx, w.clients.DialContext, cfg, dsn, pgsink.Row{
+		Payload:    payload,
+		DeliveryID: delivery.ID.String(),
+		EventType:  delivery.EventType,
+	})
+	cancel()
+
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attempt.ID)
+			return false
+		}
+		errMsg := err.Error()
+		var retryDelay *time.Duration
+		if !pgsink.Permanent(err) {
+			retryDelay = w.nextRetryDelay(attemptNumber)
+		}
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, retryDelay, nil)
+		return false
+	}
+	body := w.responseCipher.Seal(tag)
+	w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptSuccess, nil, &body, nil, nil, nil)
+	return true
+}
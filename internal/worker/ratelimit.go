@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+const rateLimitKeyPrefix = "nitrohook:ratelimit:"
+
+// takeToken is a token bucket holding up to ARGV[1] tokens, refilled at
+// ARGV[1] per second against the Redis clock so all workers share it. It
+// takes a token and returns 0, or returns the milliseconds until one is
+// available without taking it.
+var takeToken = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local b = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(b[1]) or rate
+local ts = tonumber(b[2]) or now
+tokens = math.min(rate, tokens + (now - ts) * rate / 1000)
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], 60000)
+return wait`)
+
+// rateLimited takes a token from the action's bucket. When none is left it
+// returns how long to defer the attempt, plus up to a poll interval of jitter
+// so a burst of deferred attempts doesn't all come due together. Redis errors
+// fail open.
+func (w *FanoutWorker) rateLimited(ctx context.Context, action *model.Action) (time.Duration, bool) {
+	if action.MaxRequestsPerSecond == nil {
+		return 0, false
+	}
+	waitMs, err := takeToken.Run(ctx, w.rdb, []string{rateLimitKeyPrefix + action.ID.String()}, *action.MaxRequestsPerSecond).Int64()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check rate limit", "error", err)
+		return 0, false
+	}
+	if waitMs == 0 {
+		return 0, false
+	}
+	wait := time.Duration(waitMs) * time.Millisecond
+	return wait + time.Duration(rand.Int63n(int64(max(w.pollInterval, time.Second)))), true
+}
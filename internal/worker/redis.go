@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/redispub"
+)
+
+// dispatchRedisAction publishes the payload to the action's channel or adds
+// it to its stream, recording the outcome as the response body. Errors the
+// server returns aren't retried unless they're transient, such as a replica
+// being read-only; connection failures are.
+func (w *FanoutWorker) dispatchRedisAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := redispub.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	password := ""
+	if action.Secret != nil {
+		if password, err = w.secrets.Open(*action.Secret); err != nil {
+			errMsg := "open redis secret: " + err.Error()
+			w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+			return false
+		}
+	}
+	target, err := redispub.Target(cfg, payload)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	msg := redispub.Message{DeliveryID: delivery.ID.String(), Payload: payload}
+	if delivery.EventType != nil {
+		msg.EventType = *delivery.EventType
+	}
+
+	pctx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout := w.clients.Timeout(); timeout > 0 {
+		pctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	result, err := redispub.Publish(pctx, w.clients.DialContext, cfg, password, target, msg)
+	cancel()
+
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attempt.ID)
+			return false
+		}
+		errMsg := err.Error()
+		var retryDelay *time.Duration
+		if !redispub.Permanent(err) {
+			retryDelay = w.nextRetryDelay(attemptNumber)
+		}
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, retryDelay, nil)
+		return false
+	}
+	body := w.responseCipher.Seal(result)
+	w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptSuccess, nil, &body, nil, nil, nil)
+	return true
+}
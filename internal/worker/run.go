@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zachbroad/nitrohook/internal/archive"
+	"github.com/zachbroad/nitrohook/internal/config"
+	"github.com/zachbroad/nitrohook/internal/email"
+	"github.com/zachbroad/nitrohook/internal/encryption"
+	"github.com/zachbroad/nitrohook/internal/metahook"
+	"github.com/zachbroad/nitrohook/internal/outbound"
+	"github.com/zachbroad/nitrohook/internal/slo"
+	"github.com/zachbroad/nitrohook/internal/store"
+	"github.com/zachbroad/nitrohook/internal/streamtrim"
+)
+
+// Deps are what an entry point builds from its config and shares with the
+// worker. Archive may be nil.
+type Deps struct {
+	ResponseCipher *encryption.Cipher
+	Secrets        *encryption.Cipher
+	Clients        *outbound.Clients
+	Trim           streamtrim.Policy
+	SMTP           email.Server
+	Objective      slo.Objective
+	Meta           *metahook.Notifier
+	Archive        archive.ObjectStore
+}
+
+// Run creates a worker configured from cfg and starts it until ctx is done.
+// Both the worker binary and the API's --worker mode start workers this way;
+// they call Shutdown once ctx is cancelled.
+func Run(ctx context.Context, cfg config.Config, s *store.Store, rdb *redis.Client, deps Deps) (*FanoutWorker, error) {
+	w := New(s, rdb, cfg.WorkerConcurrency, cfg.FanoutParallelism, cfg.MaxRetries, cfg.RetryBaseDelay, cfg.DeliveryTimeout, cfg.PollInterval, cfg.SchedulerLeaseTTL, cfg.CircuitBreakerThreshold, cfg.CircuitBreakerCooldown, cfg.Limits())
+	w.SetResponseCipher(deps.ResponseCipher)
+	w.SetStreamReads(cfg.WorkerBatchSize, cfg.WorkerBlockTimeout, cfg.WorkerPrefetch)
+	w.SetSelfReport(cfg.WorkerReportInterval)
+	w.SetClients(deps.Clients)
+	w.SetSecrets(deps.Secrets)
+	w.SetStreamTrim(deps.Trim)
+	w.SetSMTP(deps.SMTP)
+	w.SetSLO(deps.Objective, deps.Meta)
+	if deps.Archive != nil && cfg.ArchiveAfterDays > 0 {
+		w.SetArchive(deps.Archive, cfg.ArchiveS3Prefix, time.Duration(cfg.ArchiveAfterDays)*24*time.Hour)
+	}
+	if err := w.Start(ctx); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "fan-out worker started", "concurrency", cfg.WorkerConcurrency)
+	return w, nil
+}
+
+// Shutdown lets in-progress deliveries record their outcome after the
+// worker's context is cancelled, waiting until they finish or ctx is done.
+func (w *FanoutWorker) Shutdown(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		w.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("timed out waiting for deliveries to finish")
+	}
+}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	releaseInterval = time.Second
+	releaseBatch    = 500
+)
+
+// releaseScheduled publishes scheduled deliveries to the stream once their
+// deliver_at passes, so any worker can fan them out.
+func (w *FanoutWorker) releaseScheduled(ctx context.Context) {
+	ticker := time.NewTicker(releaseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.scheduler.Held() {
+				continue
+			}
+			deliveries, err := w.store.Deliveries.ReleaseDue(ctx, releaseBatch)
+			if err != nil {
+				slog.ErrorContext(ctx, "release scheduled deliveries error", "error", err)
+				continue
+			}
+			for _, d := range deliveries {
+				requestID := ""
+				if d.RequestID != nil {
+					requestID = *d.RequestID
+				}
+				args := &redis.XAddArgs{
+					Stream: streamName,
+					Values: map[string]any{
+						"delivery_id": d.ID.String(),
+						"request_id":  requestID,
+						"source_id":   d.SourceID.String(),
+						"received_at": d.ReceivedAt.Format(time.RFC3339Nano),
+					},
+				}
+				w.trim.Apply(args, time.Now())
+				err := w.rdb.XAdd(ctx, args).Err()
+				if err != nil {
+					// No longer scheduled, so the catch-up poll delivers it
+					slog.ErrorContext(ctx, "failed to publish scheduled delivery", "delivery_id", d.ID, "error", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/script"
+)
+
+const (
+	scriptRunRetention     = 7 * 24 * time.Hour
+	scriptRunPruneInterval = time.Hour
+	// suppressedRetention is how long duplicate suppression records are
+	// kept; they are pruned alongside script runs.
+	suppressedRetention = 30 * 24 * time.Hour
+)
+
+// recordScriptRun stores a script execution for the source's script stats and
+// warns when it timed out or came close to doing so.
+func (w *FanoutWorker) recordScriptRun(ctx context.Context, sourceID uuid.UUID, actionID *uuid.UUID, kind string, elapsed, timeout time.Duration, runErr error) {
+	timedOut := errors.Is(runErr, script.ErrScriptTimeout)
+	switch {
+	case timedOut:
+		slog.WarnContext(ctx, "script timed out", "kind", kind, "timeout", timeout)
+	case float64(elapsed) >= model.SlowScriptRatio*float64(timeout):
+		slog.WarnContext(ctx, "slow script", "kind", kind, "elapsed", elapsed, "timeout", timeout)
+	}
+
+	if err := w.store.ScriptRuns.Record(ctx, sourceID, actionID, kind, elapsed, timedOut); err != nil {
+		slog.ErrorContext(ctx, "failed to record script run", "error", err)
+	}
+}
+
+func (w *FanoutWorker) pruneScriptRuns(ctx context.Context) {
+	ticker := time.NewTicker(scriptRunPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.scheduler.Held() {
+				continue
+			}
+			n, err := w.store.ScriptRuns.Prune(ctx, time.Now().Add(-scriptRunRetention))
+			if err != nil {
+				slog.ErrorContext(ctx, "prune script runs error", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "pruned script runs", "count", n)
+			}
+			n, err = w.store.Suppressed.Prune(ctx, time.Now().Add(-suppressedRetention))
+			if err != nil {
+				slog.ErrorContext(ctx, "prune suppressed deliveries error", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "pruned suppressed deliveries", "count", n)
+			}
+		}
+	}
+}
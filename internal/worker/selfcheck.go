@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CheckStreams verifies that Redis supports streams (5.0+) and that the
+// deliveries key, if it exists, is a stream.
+func CheckStreams(ctx context.Context, rdb *redis.Client) error {
+	if err := rdb.XLen(ctx, streamName).Err(); err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "unknown command"):
+			return fmt.Errorf("redis does not support streams: upgrade to Redis 5.0 or later")
+		case strings.HasPrefix(msg, "WRONGTYPE"):
+			return fmt.Errorf("redis key %q is not a stream: delete or rename it", streamName)
+		}
+		return fmt.Errorf("check redis streams: %w", err)
+	}
+	return nil
+}
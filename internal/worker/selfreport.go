@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/metrics"
+)
+
+// usage tracks what the worker reports about itself: scripts running now and
+// messages handled by each consumer.
+type usage struct {
+	scripts atomic.Int64
+
+	mu      sync.Mutex
+	handled map[string]int64
+}
+
+func (u *usage) countHandled(consumer string) {
+	u.mu.Lock()
+	if u.handled == nil {
+		u.handled = map[string]int64{}
+	}
+	u.handled[consumer]++
+	u.mu.Unlock()
+}
+
+func (u *usage) handledSnapshot() map[string]int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return maps.Clone(u.handled)
+}
+
+// runScript counts fn as a running script for the duration of the call.
+func (w *FanoutWorker) runScript(fn func()) {
+	w.usage.scripts.Add(1)
+	defer w.usage.scripts.Add(-1)
+	fn()
+}
+
+// SetSelfReport publishes the worker's resource use to the worker registry
+// every interval. Zero disables reporting. Call it before Start.
+func (w *FanoutWorker) SetSelfReport(interval time.Duration) {
+	w.reportInterval = interval
+}
+
+// workerID names this process in the worker registry.
+func workerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// reportUsage publishes goroutine count, heap use, script utilization and
+// per-consumer throughput until ctx is done. Reports expire after three
+// missed intervals, dropping stopped workers from the registry.
+func (w *FanoutWorker) reportUsage(ctx context.Context) {
+	ticker := time.NewTicker(w.reportInterval)
+	defer ticker.Stop()
+
+	id := workerID()
+	capacity := w.concurrency * w.prefetch
+	prev, prevAt := w.usage.handledSnapshot(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur, now := w.usage.handledSnapshot(), time.Now()
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			running := int(w.usage.scripts.Load())
+			metrics.Report(ctx, w.rdb, metrics.WorkerReport{
+				ID:             id,
+				ReportedAt:     now.UTC(),
+				Concurrency:    w.concurrency,
+				Goroutines:     runtime.NumGoroutine(),
+				HeapAlloc:      mem.HeapAlloc,
+				HeapInuse:      mem.HeapInuse,
+				NumGC:          mem.NumGC,
+				ScriptsRunning: running,
+				ScriptCapacity: capacity,
+				ScriptUtil:     float64(running) / float64(max(capacity, 1)),
+				Throughput:     metrics.Rates(prev, cur, now.Sub(prevAt)),
+			}, 3*w.reportInterval)
+			prev, prevAt = cur, now
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/metahook"
+	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/slo"
+)
+
+const sloCheckInterval = 5 * time.Minute
+
+// sloAlert is the data of an error budget meta-webhook event.
+type sloAlert struct {
+	ActionID string `json:"action_id"`
+	SourceID string `json:"source_id"`
+	slo.Status
+	Window string `json:"window"`
+}
+
+// watchSLOs periodically scores active actions against their objective and
+// alerts once when an action's error budget runs out and again when it
+// recovers.
+func (w *FanoutWorker) watchSLOs(ctx context.Context) {
+	ticker := time.NewTicker(sloCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.scheduler.Held() {
+				continue
+			}
+			if err := w.checkSLOs(ctx); err != nil {
+				slog.ErrorContext(ctx, "slo check error", "error", err)
+			}
+		}
+	}
+}
+
+func (w *FanoutWorker) checkSLOs(ctx context.Context) error {
+	actions, err := w.store.Actions.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	outcomes, err := w.store.Actions.Outcomes(ctx, nil, now.Add(-w.objective.Window), now.Add(-slo.BurnWindow))
+	if err != nil {
+		return err
+	}
+	byAction := make(map[uuid.UUID]model.ActionOutcomes, len(outcomes))
+	for _, o := range outcomes {
+		byAction[o.ActionID] = o
+	}
+
+	for _, a := range actions {
+		target := w.objective.Target
+		if a.SLOTarget != nil {
+			target = *a.SLOTarget
+		}
+		st := slo.Evaluate(target, byAction[a.ID])
+		alert := sloAlert{ActionID: a.ID.String(), SourceID: a.SourceID.String(), Status: st, Window: w.objective.Window.String()}
+		switch {
+		case st.Exhausted && a.SLOExhaustedAt == nil:
+			if err := w.store.Actions.SetSLOExhausted(ctx, a.ID, &now); err != nil {
+				return err
+			}
+			slog.WarnContext(ctx, "action error budget exhausted", "action_id", a.ID, "target", target, "burn_rate", st.BurnRate)
+			w.meta.Notify(ctx, metahook.EventErrorBudgetExhausted, alert)
+		case !st.Exhausted && a.SLOExhaustedAt != nil:
+			if err := w.store.Actions.SetSLOExhausted(ctx, a.ID, nil); err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "action error budget recovered", "action_id", a.ID)
+			w.meta.Notify(ctx, metahook.EventErrorBudgetRecovered, alert)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/email"
+	"github.com/zachbroad/nitrohook/internal/model"
+)
+
+// smtpAccepted is the reply code recorded for a sent message.
+const smtpAccepted = 250
+
+// dispatchSMTPAction renders the payload into an email and sends it through
+// the configured SMTP server. The server's reply code is recorded as the
+// attempt's response status. Configuration errors and permanent (5xx)
+// rejections aren't retried; connection failures and 4xx replies are.
+func (w *FanoutWorker) dispatchSMTPAction(ctx context.Context, delivery *model.Delivery, action *model.Action, attemptNumber int, payload json.RawMessage, limits model.Limits) bool {
+	attempt, err := w.store.Deliveries.CreateAttempt(ctx, delivery.ID, action.ID, attemptNumber)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create attempt", "error", err)
+		return false
+	}
+
+	cfg, err := email.ParseConfig(action.Config)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	to, err := email.Recipients(cfg)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	msg, err := email.Message(cfg, w.smtp.From, delivery.ID.String(), payload, limits.MaxPayloadBytes)
+	if err != nil {
+		errMsg := err.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+	if !w.smtp.Configured() {
+		errMsg := email.ErrNotConfigured.Error()
+		w.store.Deliveries.UpdateAttempt(ctx, attempt.ID, model.AttemptFailed, nil, nil, &errMsg, nil, nil)
+		return false
+	}
+
+	err = w.smtp.Send(ctx, to, msg)
+
+	rctx, cancel := detached(ctx)
+	defer cancel()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			w.recordInterrupted(rctx, attempt.ID)
+			return false
+		}
+		errMsg := err.Error()
+		var status *int
+		if code, _, ok := email.Reply(err); ok {
+			status = &code
+		}
+		var retryDelay *time.Duration
+		if !email.Permanent(err) {
+			retryDelay = w.nextRetryDelay(attemptNumber)
+		}
+		w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptFailed, status, nil, &errMsg, retryDelay, nil)
+		return false
+	}
+	statusCode := smtpAccepted
+	body := w.responseCipher.Seal(fmt.Sprintf("sent to %d recipient(s)", len(to)))
+	w.store.Deliveries.UpdateAttempt(rctx, attempt.ID, model.AttemptSuccess, &statusCode, &body, nil, nil, nil)
+	return true
+}
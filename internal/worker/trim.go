@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zachbroad/nitrohook/internal/metrics"
+)
+
+// watchTrimming records how many entries XADD trimming has dropped from the
+// stream and warns when the count grows. Trimmed deliveries that were never
+// consumed are left to the catch-up poll, or lost outright on the ingest fast
+// path. Needs Redis 7, which reports entries added; older servers are skipped.
+func (w *FanoutWorker) watchTrimming(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	var last int64 = -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.scheduler.Held() {
+				last = -1
+				continue
+			}
+			info, err := w.rdb.XInfoStream(ctx, streamName).Result()
+			if err != nil {
+				slog.ErrorContext(ctx, "xinfo stream error", "error", err)
+				continue
+			}
+			if info.EntriesAdded == 0 {
+				continue
+			}
+			// Nothing deletes stream entries besides trimming
+			trimmed := info.EntriesAdded - info.Length
+			if last >= 0 && trimmed > last {
+				slog.WarnContext(ctx, "deliveries stream trimmed", "entries", trimmed-last, "policy", w.trim.Mode, "length", info.Length)
+			}
+			last = trimmed
+			metrics.Set(ctx, w.rdb, metrics.StreamTrimmed, trimmed)
+		}
+	}
+}
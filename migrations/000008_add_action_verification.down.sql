@@ -0,0 +1,3 @@
+ALTER TABLE actions
+    DROP COLUMN verification_token,
+    DROP COLUMN verified_at;
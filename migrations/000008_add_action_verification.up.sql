@@ -0,0 +1,3 @@
+ALTER TABLE actions
+    ADD COLUMN verification_token TEXT,
+    ADD COLUMN verified_at TIMESTAMPTZ;
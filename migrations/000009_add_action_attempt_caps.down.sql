@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_attempts_action_created;
+
+ALTER TABLE delivery_attempts DROP COLUMN capped;
+
+ALTER TABLE actions
+    DROP COLUMN max_attempts_per_hour,
+    DROP COLUMN max_attempts_per_day;
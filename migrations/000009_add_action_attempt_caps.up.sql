@@ -0,0 +1,7 @@
+ALTER TABLE actions
+    ADD COLUMN max_attempts_per_hour INT CHECK (max_attempts_per_hour > 0),
+    ADD COLUMN max_attempts_per_day INT CHECK (max_attempts_per_day > 0);
+
+ALTER TABLE delivery_attempts ADD COLUMN capped BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_attempts_action_created ON delivery_attempts (action_id, created_at) WHERE NOT capped;
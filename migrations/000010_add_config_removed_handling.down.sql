@@ -0,0 +1,6 @@
+DELETE FROM actions WHERE deleted_at IS NOT NULL;
+ALTER TABLE actions DROP COLUMN deleted_at;
+
+ALTER TABLE deliveries DROP COLUMN status_reason;
+
+-- Note: Cannot remove enum value 'cancelled_config_removed' from delivery_status in PostgreSQL.
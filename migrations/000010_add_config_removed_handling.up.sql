@@ -0,0 +1,7 @@
+ALTER TYPE delivery_status ADD VALUE IF NOT EXISTS 'cancelled_config_removed';
+
+ALTER TABLE deliveries ADD COLUMN status_reason TEXT;
+
+-- Actions are tombstoned rather than deleted so in-flight attempts keep a
+-- reference the worker can recognise.
+ALTER TABLE actions ADD COLUMN deleted_at TIMESTAMPTZ;
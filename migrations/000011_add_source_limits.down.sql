@@ -0,0 +1,4 @@
+ALTER TABLE sources
+    DROP COLUMN max_payload_bytes,
+    DROP COLUMN max_response_bytes,
+    DROP COLUMN script_timeout_ms;
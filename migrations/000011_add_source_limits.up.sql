@@ -0,0 +1,4 @@
+ALTER TABLE sources
+    ADD COLUMN max_payload_bytes INT CHECK (max_payload_bytes > 0),
+    ADD COLUMN max_response_bytes INT CHECK (max_response_bytes > 0),
+    ADD COLUMN script_timeout_ms INT CHECK (script_timeout_ms > 0);
@@ -0,0 +1 @@
+ALTER TABLE deliveries ADD COLUMN simulated BOOLEAN NOT NULL DEFAULT false;
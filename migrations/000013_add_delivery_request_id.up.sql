@@ -0,0 +1 @@
+ALTER TABLE deliveries ADD COLUMN request_id TEXT;
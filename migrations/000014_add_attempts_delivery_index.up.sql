@@ -0,0 +1 @@
+CREATE INDEX idx_attempts_delivery_created ON delivery_attempts (delivery_id, created_at);
@@ -0,0 +1,5 @@
+DROP TRIGGER deliveries_rollup_failed ON deliveries;
+DROP TRIGGER deliveries_rollup_received ON deliveries;
+DROP FUNCTION rollup_delivery_failed();
+DROP FUNCTION rollup_delivery_received();
+DROP TABLE source_delivery_hourly;
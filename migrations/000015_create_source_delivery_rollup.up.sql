@@ -0,0 +1,51 @@
+-- Hourly per-source delivery counts, maintained by triggers so source listings
+-- don't have to scan the deliveries table.
+CREATE TABLE source_delivery_hourly (
+    source_id        UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    hour             TIMESTAMPTZ NOT NULL,
+    received         INT NOT NULL DEFAULT 0,
+    failed           INT NOT NULL DEFAULT 0,
+    last_received_at TIMESTAMPTZ,
+    PRIMARY KEY (source_id, hour)
+);
+
+CREATE FUNCTION rollup_delivery_received() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO source_delivery_hourly (source_id, hour, received, last_received_at)
+    VALUES (NEW.source_id, date_trunc('hour', NEW.received_at), 1, NEW.received_at)
+    ON CONFLICT (source_id, hour) DO UPDATE SET
+        received         = source_delivery_hourly.received + 1,
+        last_received_at = GREATEST(source_delivery_hourly.last_received_at, EXCLUDED.last_received_at);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Failures are bucketed by when the delivery failed, not when it arrived.
+CREATE FUNCTION rollup_delivery_failed() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO source_delivery_hourly (source_id, hour, failed)
+    VALUES (NEW.source_id, date_trunc('hour', now()), 1)
+    ON CONFLICT (source_id, hour) DO UPDATE SET
+        failed = source_delivery_hourly.failed + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER deliveries_rollup_received
+    AFTER INSERT ON deliveries
+    FOR EACH ROW EXECUTE FUNCTION rollup_delivery_received();
+
+CREATE TRIGGER deliveries_rollup_failed
+    AFTER UPDATE OF status ON deliveries
+    FOR EACH ROW
+    WHEN (NEW.status = 'failed' AND OLD.status IS DISTINCT FROM 'failed')
+    EXECUTE FUNCTION rollup_delivery_failed();
+
+INSERT INTO source_delivery_hourly (source_id, hour, received, failed, last_received_at)
+SELECT source_id,
+       date_trunc('hour', received_at),
+       count(*),
+       count(*) FILTER (WHERE status = 'failed'),
+       max(received_at)
+FROM deliveries
+GROUP BY 1, 2;
@@ -0,0 +1,4 @@
+ALTER TABLE sources
+    DROP COLUMN inbound_signature_scheme,
+    DROP COLUMN inbound_signature_header,
+    DROP COLUMN inbound_secret;
@@ -0,0 +1,4 @@
+ALTER TABLE sources
+    ADD COLUMN inbound_signature_scheme TEXT,
+    ADD COLUMN inbound_signature_header TEXT,
+    ADD COLUMN inbound_secret TEXT;
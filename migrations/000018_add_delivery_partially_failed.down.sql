@@ -0,0 +1,3 @@
+UPDATE deliveries SET status = 'failed' WHERE status = 'partially_failed';
+
+-- Note: Cannot remove enum value 'partially_failed' from delivery_status in PostgreSQL.
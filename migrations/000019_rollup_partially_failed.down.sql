@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS deliveries_rollup_partially_failed ON deliveries;
+DROP FUNCTION IF EXISTS rollup_delivery_partially_failed();
+ALTER TABLE source_delivery_hourly DROP COLUMN partially_failed;
@@ -0,0 +1,17 @@
+ALTER TABLE source_delivery_hourly ADD COLUMN partially_failed INT NOT NULL DEFAULT 0;
+
+CREATE FUNCTION rollup_delivery_partially_failed() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO source_delivery_hourly (source_id, hour, partially_failed)
+    VALUES (NEW.source_id, date_trunc('hour', now()), 1)
+    ON CONFLICT (source_id, hour) DO UPDATE SET
+        partially_failed = source_delivery_hourly.partially_failed + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER deliveries_rollup_partially_failed
+    AFTER UPDATE OF status ON deliveries
+    FOR EACH ROW
+    WHEN (NEW.status = 'partially_failed' AND OLD.status IS DISTINCT FROM 'partially_failed')
+    EXECUTE FUNCTION rollup_delivery_partially_failed();
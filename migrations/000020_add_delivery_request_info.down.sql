@@ -0,0 +1,4 @@
+ALTER TABLE deliveries
+    DROP COLUMN remote_addr,
+    DROP COLUMN query_params,
+    DROP COLUMN method;
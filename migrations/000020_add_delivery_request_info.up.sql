@@ -0,0 +1,4 @@
+ALTER TABLE deliveries
+    ADD COLUMN method TEXT,
+    ADD COLUMN query_params JSONB,
+    ADD COLUMN remote_addr TEXT;
@@ -0,0 +1,14 @@
+-- One row per script execution, for per-source script timing stats. Rows
+-- are pruned by the worker after a week.
+CREATE TABLE script_runs (
+    id          BIGSERIAL PRIMARY KEY,
+    source_id   UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    action_id   UUID REFERENCES actions(id) ON DELETE CASCADE,
+    kind        TEXT NOT NULL,
+    duration_ms INT NOT NULL,
+    timed_out   BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_script_runs_source_created ON script_runs (source_id, created_at);
+CREATE INDEX idx_script_runs_created ON script_runs (created_at);
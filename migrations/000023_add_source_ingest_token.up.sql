@@ -0,0 +1 @@
+ALTER TABLE sources ADD COLUMN ingest_token TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE actions DROP COLUMN cloudevents_mode;
+ALTER TABLE deliveries DROP COLUMN cloud_event;
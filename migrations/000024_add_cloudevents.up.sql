@@ -0,0 +1,6 @@
+-- Context attributes of deliveries received as CloudEvents.
+ALTER TABLE deliveries ADD COLUMN cloud_event JSONB;
+
+-- Per-action CloudEvents egress: NULL sends the plain payload.
+ALTER TABLE actions ADD COLUMN cloudevents_mode TEXT
+    CHECK (cloudevents_mode IN ('binary', 'structured'));
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS deliveries_record_event_type ON deliveries;
+DROP FUNCTION IF EXISTS record_event_type();
+DROP TABLE IF EXISTS event_types;
+ALTER TABLE actions DROP COLUMN event_types;
+ALTER TABLE deliveries DROP COLUMN event_type;
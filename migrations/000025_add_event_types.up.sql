@@ -0,0 +1,33 @@
+ALTER TABLE deliveries ADD COLUMN event_type TEXT;
+
+-- Event types an action subscribes to; NULL receives everything.
+ALTER TABLE actions ADD COLUMN event_types TEXT[];
+
+-- Per-source catalog of event types, declared via the API or observed on
+-- ingest (maintained by the trigger below).
+CREATE TABLE event_types (
+    source_id     UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    name          TEXT NOT NULL,
+    description   TEXT,
+    declared      BOOLEAN NOT NULL DEFAULT false,
+    first_seen_at TIMESTAMPTZ,
+    last_seen_at  TIMESTAMPTZ,
+    PRIMARY KEY (source_id, name)
+);
+
+CREATE FUNCTION record_event_type() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO event_types (source_id, name, first_seen_at, last_seen_at)
+    VALUES (NEW.source_id, NEW.event_type, NEW.received_at, NEW.received_at)
+    ON CONFLICT (source_id, name) DO UPDATE SET
+        first_seen_at = LEAST(COALESCE(event_types.first_seen_at, EXCLUDED.first_seen_at), EXCLUDED.first_seen_at),
+        last_seen_at  = GREATEST(event_types.last_seen_at, EXCLUDED.last_seen_at);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER deliveries_record_event_type
+    AFTER INSERT ON deliveries
+    FOR EACH ROW
+    WHEN (NEW.event_type IS NOT NULL)
+    EXECUTE FUNCTION record_event_type();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_actions_portal_token_hash;
+ALTER TABLE actions DROP COLUMN portal_token_hash;
@@ -0,0 +1,5 @@
+-- SHA-256 of the token a consumer uses for the self-service portal. Only the
+-- hash is kept since actions are looked up by it.
+ALTER TABLE actions ADD COLUMN portal_token_hash TEXT;
+
+CREATE UNIQUE INDEX idx_actions_portal_token_hash ON actions (portal_token_hash) WHERE portal_token_hash IS NOT NULL;
@@ -0,0 +1,2 @@
+-- The delivery this one replays; replays are ordinary deliveries otherwise.
+ALTER TABLE deliveries ADD COLUMN replay_of UUID REFERENCES deliveries(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_actions_external_id;
+ALTER TABLE actions DROP COLUMN external_id;
+ALTER TABLE sources DROP COLUMN external_id;
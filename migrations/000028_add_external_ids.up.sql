@@ -0,0 +1,6 @@
+-- Caller-chosen identifiers so infrastructure-as-code tools can find and
+-- upsert resources deterministically.
+ALTER TABLE sources ADD COLUMN external_id TEXT UNIQUE;
+
+ALTER TABLE actions ADD COLUMN external_id TEXT;
+CREATE UNIQUE INDEX idx_actions_external_id ON actions (source_id, external_id) WHERE external_id IS NOT NULL AND deleted_at IS NULL;
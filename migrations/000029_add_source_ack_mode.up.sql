@@ -0,0 +1,4 @@
+-- When ingest acknowledges a delivery to the producer: after the database
+-- write (accepted), after it's on the stream (queued), or once delivered.
+ALTER TABLE sources ADD COLUMN ack_mode TEXT NOT NULL DEFAULT 'accepted'
+    CHECK (ack_mode IN ('accepted', 'queued', 'delivered'));
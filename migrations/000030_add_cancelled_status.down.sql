@@ -0,0 +1,3 @@
+UPDATE deliveries SET status = 'cancelled_config_removed' WHERE status = 'cancelled';
+
+-- Note: Cannot remove enum value 'cancelled' from delivery_status in PostgreSQL.
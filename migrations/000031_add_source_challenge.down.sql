@@ -0,0 +1,2 @@
+ALTER TABLE sources DROP COLUMN challenge_secret;
+ALTER TABLE sources DROP COLUMN challenge_mode;
@@ -0,0 +1,5 @@
+-- How GET verification challenges to the ingest URL are answered; NULL
+-- ingests GETs like any other request.
+ALTER TABLE sources ADD COLUMN challenge_mode TEXT
+    CHECK (challenge_mode IN ('echo', 'hub', 'crc'));
+ALTER TABLE sources ADD COLUMN challenge_secret TEXT;
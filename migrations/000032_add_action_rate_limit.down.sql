@@ -0,0 +1 @@
+ALTER TABLE actions DROP COLUMN max_requests_per_second;
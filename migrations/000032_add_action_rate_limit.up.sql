@@ -0,0 +1,3 @@
+-- Outbound requests per second to the action's target, enforced across
+-- workers by a Redis token bucket. NULL is unlimited.
+ALTER TABLE actions ADD COLUMN max_requests_per_second INT CHECK (max_requests_per_second > 0);
@@ -0,0 +1,2 @@
+ALTER TABLE deliveries DROP COLUMN restored_at;
+DROP TABLE delivery_archives;
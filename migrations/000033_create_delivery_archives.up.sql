@@ -0,0 +1,17 @@
+-- One archive object per source per day of received deliveries. Archived
+-- rows are deleted from deliveries.
+CREATE TABLE delivery_archives (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_id      UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    day            DATE NOT NULL,
+    object_key     TEXT NOT NULL,
+    delivery_count INT NOT NULL DEFAULT 0,
+    bytes          BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (source_id, day)
+);
+
+-- Set when a delivery is rehydrated from an archive, so it isn't archived
+-- again straight away.
+ALTER TABLE deliveries ADD COLUMN restored_at TIMESTAMPTZ;
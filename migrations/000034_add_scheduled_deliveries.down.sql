@@ -0,0 +1,4 @@
+ALTER TABLE sources DROP COLUMN delivery_delay_seconds;
+DROP INDEX idx_deliveries_scheduled;
+ALTER TABLE deliveries DROP COLUMN scheduled;
+ALTER TABLE deliveries DROP COLUMN deliver_at;
@@ -0,0 +1,9 @@
+-- Deliveries held back until deliver_at. scheduled stays true until the
+-- worker releases the delivery to the stream for fan-out.
+ALTER TABLE deliveries ADD COLUMN deliver_at TIMESTAMPTZ;
+ALTER TABLE deliveries ADD COLUMN scheduled BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX idx_deliveries_scheduled ON deliveries (deliver_at) WHERE scheduled;
+
+-- Default delay applied to every delivery the source receives. NULL is
+-- immediate.
+ALTER TABLE sources ADD COLUMN delivery_delay_seconds INT CHECK (delivery_delay_seconds > 0);
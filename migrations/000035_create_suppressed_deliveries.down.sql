@@ -0,0 +1,4 @@
+DROP TABLE suppressed_deliveries;
+ALTER TABLE sources DROP COLUMN dedup_window_seconds;
+DROP INDEX idx_deliveries_content_hash;
+ALTER TABLE deliveries DROP COLUMN content_hash;
@@ -0,0 +1,24 @@
+-- SHA-256 of the payload, for content-based duplicate suppression.
+ALTER TABLE deliveries ADD COLUMN content_hash TEXT;
+CREATE INDEX idx_deliveries_content_hash ON deliveries (source_id, content_hash, received_at DESC);
+
+-- Window in which a delivery with the same payload as an earlier one is
+-- suppressed. NULL only suppresses by idempotency key.
+ALTER TABLE sources ADD COLUMN dedup_window_seconds INT CHECK (dedup_window_seconds > 0);
+
+-- Requests that weren't stored or fanned out because they duplicated an
+-- earlier delivery. duplicate_of has no foreign key so the record outlives
+-- archiving of the original.
+CREATE TABLE suppressed_deliveries (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_id       UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    duplicate_of    UUID NOT NULL,
+    reason          TEXT NOT NULL CHECK (reason IN ('idempotency_key', 'content_hash')),
+    idempotency_key TEXT NOT NULL,
+    request_id      TEXT,
+    remote_addr     TEXT,
+    event_type      TEXT,
+    suppressed_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_suppressed_deliveries_source ON suppressed_deliveries (source_id, suppressed_at DESC);
+CREATE INDEX idx_suppressed_deliveries_duplicate_of ON suppressed_deliveries (duplicate_of);
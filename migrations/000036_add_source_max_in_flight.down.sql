@@ -0,0 +1 @@
+ALTER TABLE sources DROP COLUMN max_in_flight;
@@ -0,0 +1,3 @@
+-- Deliveries of the source processed at once across all workers; the rest
+-- wait in order. NULL is unlimited.
+ALTER TABLE sources ADD COLUMN max_in_flight INT CHECK (max_in_flight > 0);
@@ -0,0 +1,3 @@
+ALTER TABLE actions DROP COLUMN body_template;
+ALTER TABLE actions DROP COLUMN url_template;
+ALTER TABLE actions DROP COLUMN http_method;
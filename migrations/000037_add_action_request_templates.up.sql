@@ -0,0 +1,6 @@
+-- Shape the outbound request of webhook actions: HTTP method, and Go
+-- templates over the payload for the URL and body. NULL keeps the default
+-- (POST to target_url with the payload as the body).
+ALTER TABLE actions ADD COLUMN http_method TEXT CHECK (http_method IN ('POST', 'PUT', 'PATCH', 'DELETE', 'GET'));
+ALTER TABLE actions ADD COLUMN url_template TEXT;
+ALTER TABLE actions ADD COLUMN body_template TEXT;
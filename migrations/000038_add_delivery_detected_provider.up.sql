@@ -0,0 +1,3 @@
+-- Provider guessed from the inbound request's headers, used to suggest a
+-- verification preset for the source.
+ALTER TABLE deliveries ADD COLUMN detected_provider TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE actions DROP CONSTRAINT actions_tls_client_pair;
+ALTER TABLE actions DROP COLUMN tls_ca_bundle;
+ALTER TABLE actions DROP COLUMN tls_client_key;
+ALTER TABLE actions DROP COLUMN tls_client_cert;
@@ -0,0 +1,6 @@
+-- Client certificate (PEM) and its key, sealed with SECRETS_KEY, presented
+-- to mTLS targets, and a PEM CA bundle trusted instead of the system roots.
+ALTER TABLE actions ADD COLUMN tls_client_cert TEXT;
+ALTER TABLE actions ADD COLUMN tls_client_key TEXT;
+ALTER TABLE actions ADD COLUMN tls_ca_bundle TEXT;
+ALTER TABLE actions ADD CONSTRAINT actions_tls_client_pair CHECK ((tls_client_cert IS NULL) = (tls_client_key IS NULL));
@@ -0,0 +1 @@
+ALTER TABLE actions DROP COLUMN proxy_url;
@@ -0,0 +1,3 @@
+-- Proxy (http, https or socks5) webhook requests egress through, overriding
+-- OUTBOUND_PROXY_URL.
+ALTER TABLE actions ADD COLUMN proxy_url TEXT;
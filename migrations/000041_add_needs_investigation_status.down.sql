@@ -0,0 +1,3 @@
+UPDATE deliveries SET status = 'failed' WHERE status = 'needs_investigation';
+
+-- Note: Cannot remove enum value 'needs_investigation' from delivery_status in PostgreSQL.
@@ -0,0 +1,6 @@
+ALTER TABLE delivery_attempts
+    DROP COLUMN dns_ms,
+    DROP COLUMN connect_ms,
+    DROP COLUMN tls_ms,
+    DROP COLUMN ttfb_ms,
+    DROP COLUMN total_ms;
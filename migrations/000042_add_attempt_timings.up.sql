@@ -0,0 +1,8 @@
+-- Phases of an attempt's latency in milliseconds, from httptrace. Connection
+-- phases stay NULL when a pooled connection was reused.
+ALTER TABLE delivery_attempts
+    ADD COLUMN dns_ms INTEGER,
+    ADD COLUMN connect_ms INTEGER,
+    ADD COLUMN tls_ms INTEGER,
+    ADD COLUMN ttfb_ms INTEGER,
+    ADD COLUMN total_ms INTEGER;
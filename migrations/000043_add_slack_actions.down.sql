@@ -0,0 +1,6 @@
+DELETE FROM actions WHERE type = 'slack';
+ALTER TABLE actions DROP CONSTRAINT chk_action_type;
+ALTER TABLE actions ADD CONSTRAINT chk_action_type CHECK (type IN ('webhook', 'javascript'));
+
+ALTER TABLE actions DROP COLUMN secret;
+ALTER TABLE actions DROP COLUMN config;
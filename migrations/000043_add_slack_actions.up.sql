@@ -0,0 +1,7 @@
+-- Type-specific settings for non-webhook actions, and their credential
+-- (a webhook URL or token) sealed with SECRETS_KEY.
+ALTER TABLE actions ADD COLUMN config JSONB;
+ALTER TABLE actions ADD COLUMN secret TEXT;
+
+ALTER TABLE actions DROP CONSTRAINT chk_action_type;
+ALTER TABLE actions ADD CONSTRAINT chk_action_type CHECK (type IN ('webhook', 'javascript', 'slack'));
@@ -0,0 +1,2 @@
+-- Hours or cron minutes outside which the action's deliveries are held.
+ALTER TABLE actions ADD COLUMN delivery_window JSONB;
@@ -0,0 +1,3 @@
+UPDATE deliveries SET status = 'cancelled' WHERE status = 'coalesced';
+
+-- Note: Cannot remove enum value 'coalesced' from delivery_status in PostgreSQL.
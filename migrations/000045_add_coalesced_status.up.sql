@@ -0,0 +1,4 @@
+-- Deliveries collapsed into a later one with the same coalesce key. Kept in
+-- its own migration: a new enum value can't be referenced in the transaction
+-- that adds it.
+ALTER TYPE delivery_status ADD VALUE IF NOT EXISTS 'coalesced';
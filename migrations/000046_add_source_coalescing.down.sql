@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_deliveries_coalesce;
+ALTER TABLE deliveries DROP COLUMN coalesced_into;
+ALTER TABLE deliveries DROP COLUMN coalesce_key;
+ALTER TABLE sources DROP CONSTRAINT chk_source_coalesce;
+ALTER TABLE sources DROP COLUMN coalesce_window_seconds;
+ALTER TABLE sources DROP COLUMN coalesce_key;
@@ -0,0 +1,10 @@
+-- Scheduled deliveries sharing a coalesce key within the window collapse
+-- into the latest one.
+ALTER TABLE sources ADD COLUMN coalesce_key TEXT;
+ALTER TABLE sources ADD COLUMN coalesce_window_seconds INT CHECK (coalesce_window_seconds > 0);
+ALTER TABLE sources ADD CONSTRAINT chk_source_coalesce CHECK ((coalesce_key IS NULL) = (coalesce_window_seconds IS NULL));
+
+ALTER TABLE deliveries ADD COLUMN coalesce_key TEXT;
+ALTER TABLE deliveries ADD COLUMN coalesced_into UUID REFERENCES deliveries(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_deliveries_coalesce ON deliveries (source_id, coalesce_key) WHERE coalesce_key IS NOT NULL AND scheduled;
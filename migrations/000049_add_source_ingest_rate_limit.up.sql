@@ -0,0 +1,2 @@
+-- Requests per minute a source accepts before answering 429.
+ALTER TABLE sources ADD COLUMN ingest_rate_limit INT CHECK (ingest_rate_limit > 0);
@@ -0,0 +1,3 @@
+ALTER TABLE actions
+    DROP COLUMN slo_exhausted_at,
+    DROP COLUMN slo_target;
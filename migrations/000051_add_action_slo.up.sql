@@ -0,0 +1,4 @@
+-- Per-action delivery success objective, and when its error budget ran out.
+ALTER TABLE actions
+    ADD COLUMN slo_target DOUBLE PRECISION CHECK (slo_target > 0 AND slo_target < 1),
+    ADD COLUMN slo_exhausted_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+ALTER TABLE actions DROP COLUMN credential_id;
+DROP TABLE credentials;
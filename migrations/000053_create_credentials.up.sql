@@ -0,0 +1,14 @@
+-- Destination credentials shared by actions: the secret (token, password,
+-- header value or OAuth2 client secret) is sealed with SECRETS_KEY.
+CREATE TABLE credentials (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       TEXT NOT NULL UNIQUE,
+    type       TEXT NOT NULL CONSTRAINT chk_credential_type CHECK (type IN ('bearer', 'basic', 'header', 'oauth2')),
+    config     JSONB NOT NULL DEFAULT '{}',
+    secret     TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Credentials in use can't be deleted.
+ALTER TABLE actions ADD COLUMN credential_id UUID REFERENCES credentials(id) ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+DELETE FROM actions WHERE type = 'amqp';
+ALTER TABLE actions DROP CONSTRAINT chk_action_type;
+ALTER TABLE actions ADD CONSTRAINT chk_action_type CHECK (type IN ('webhook', 'javascript', 'slack', 'smtp', 'opsgenie', 'sqs', 'kinesis'));
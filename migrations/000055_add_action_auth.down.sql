@@ -0,0 +1,5 @@
+ALTER TABLE actions DROP CONSTRAINT chk_action_auth;
+ALTER TABLE actions DROP CONSTRAINT chk_action_auth_type;
+ALTER TABLE actions DROP COLUMN auth_secret;
+ALTER TABLE actions DROP COLUMN auth_config;
+ALTER TABLE actions DROP COLUMN auth_type;
@@ -0,0 +1,5 @@
+ALTER TABLE actions ADD COLUMN auth_type TEXT;
+ALTER TABLE actions ADD COLUMN auth_config JSONB;
+ALTER TABLE actions ADD COLUMN auth_secret TEXT;
+ALTER TABLE actions ADD CONSTRAINT chk_action_auth_type CHECK (auth_type IN ('bearer', 'basic', 'header'));
+ALTER TABLE actions ADD CONSTRAINT chk_action_auth CHECK ((auth_type IS NULL) = (auth_secret IS NULL) AND (auth_type IS NULL OR credential_id IS NULL));
@@ -0,0 +1,2 @@
+UPDATE deliveries SET status = 'failed' WHERE status = 'quarantined';
+ALTER TABLE sources DROP COLUMN transform_error_policy;
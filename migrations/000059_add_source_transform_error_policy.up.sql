@@ -0,0 +1,5 @@
+-- What the worker does when a transform script fails: fail the delivery
+-- (fail_closed), dispatch the original payload (fail_open), or hold it for
+-- review (quarantine).
+ALTER TABLE sources ADD COLUMN transform_error_policy TEXT NOT NULL DEFAULT 'fail_closed'
+    CHECK (transform_error_policy IN ('fail_closed', 'fail_open', 'quarantine'));
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_deliveries_quarantined;
+ALTER TABLE sources DROP COLUMN inbound_signature_failure;
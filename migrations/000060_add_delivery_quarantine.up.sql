@@ -0,0 +1,6 @@
+-- Whether a bad inbound signature is rejected with 401 or the delivery is
+-- stored as quarantined for review.
+ALTER TABLE sources ADD COLUMN inbound_signature_failure TEXT NOT NULL DEFAULT 'reject'
+    CHECK (inbound_signature_failure IN ('reject', 'flag'));
+
+CREATE INDEX idx_deliveries_quarantined ON deliveries (received_at DESC) WHERE status = 'quarantined';
@@ -0,0 +1,3 @@
+ALTER TABLE deliveries
+    DROP COLUMN reprocessed_at,
+    DROP COLUMN reprocess_count;
@@ -0,0 +1,3 @@
+ALTER TABLE deliveries
+    ADD COLUMN reprocess_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN reprocessed_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+ALTER TABLE sources DROP COLUMN retry_signal;
@@ -0,0 +1 @@
+ALTER TABLE sources ADD COLUMN retry_signal BOOLEAN NOT NULL DEFAULT false;
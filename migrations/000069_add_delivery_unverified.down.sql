@@ -0,0 +1,4 @@
+DELETE FROM deliveries WHERE unverified;
+DROP INDEX IF EXISTS deliveries_source_id_idempotency_key_key;
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_source_id_idempotency_key_key UNIQUE (source_id, idempotency_key);
+ALTER TABLE deliveries DROP COLUMN unverified;
@@ -0,0 +1,6 @@
+-- Deliveries quarantined for a bad inbound signature don't claim their
+-- idempotency key, so a forged request can't block the genuine one.
+ALTER TABLE deliveries ADD COLUMN unverified BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE deliveries DROP CONSTRAINT deliveries_source_id_idempotency_key_key;
+CREATE UNIQUE INDEX deliveries_source_id_idempotency_key_key ON deliveries (source_id, idempotency_key) WHERE NOT unverified;
@@ -0,0 +1,3 @@
+-- When a delivery last joined its source's in-flight queue. The catch-up
+-- poll skips recently queued deliveries, which wait for a handoff instead.
+ALTER TABLE deliveries ADD COLUMN queued_at TIMESTAMPTZ;
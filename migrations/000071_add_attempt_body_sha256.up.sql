@@ -0,0 +1,3 @@
+-- SHA-256 (hex) of the request body an attempt sent, as signed in audit
+-- manifests. NULL for attempts that sent no HTTP request.
+ALTER TABLE delivery_attempts ADD COLUMN body_sha256 TEXT;
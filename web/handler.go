@@ -14,11 +14,18 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/zachbroad/nitrohook/internal/fingerprint"
 	"github.com/zachbroad/nitrohook/internal/model"
+	"github.com/zachbroad/nitrohook/internal/proxy"
 	"github.com/zachbroad/nitrohook/internal/script"
+	"github.com/zachbroad/nitrohook/internal/scriptcheck"
+	"github.com/zachbroad/nitrohook/internal/ssrf"
 	"github.com/zachbroad/nitrohook/internal/store"
 )
 
+// maxAttemptsShown bounds the attempt history rendered on the delivery page.
+const maxAttemptsShown = 200
+
 var funcMap = template.FuncMap{
 	"shortID": func(id uuid.UUID) string {
 		s := id.String()
@@ -46,6 +53,21 @@ var funcMap = template.FuncMap{
 		}
 		return strconv.Itoa(*p)
 	},
+	"formatTiming": func(t model.AttemptTiming) string {
+		var parts []string
+		for _, p := range []struct {
+			name string
+			ms   *int
+		}{{"dns", t.DNSMs}, {"connect", t.ConnectMs}, {"tls", t.TLSMs}, {"ttfb", t.TTFBMs}, {"total", t.TotalMs}} {
+			if p.ms != nil {
+				parts = append(parts, fmt.Sprintf("%s %dms", p.name, *p.ms))
+			}
+		}
+		if len(parts) == 0 {
+			return "-"
+		}
+		return strings.Join(parts, " · ")
+	},
 	"derefStr": func(p *string) string {
 		if p == nil {
 			return "-"
@@ -80,14 +102,23 @@ var funcMap = template.FuncMap{
 }
 
 type Handler struct {
-	store     *store.Store
-	templates map[string]*template.Template
+	store               *store.Store
+	templates           map[string]*template.Template
+	requireVerification bool
+	urls                *proxy.PublicURL
+	guard               *ssrf.Guard
+	// limits are the global limits, used to resolve script timeouts.
+	limits model.Limits
 }
 
-func NewHandler(s *store.Store) *Handler {
+func NewHandler(s *store.Store, requireVerification bool, urls *proxy.PublicURL, guard *ssrf.Guard, limits model.Limits) *Handler {
 	h := &Handler{
-		store:     s,
-		templates: make(map[string]*template.Template),
+		store:               s,
+		templates:           make(map[string]*template.Template),
+		requireVerification: requireVerification,
+		urls:                urls,
+		guard:               guard,
+		limits:              limits,
 	}
 	for _, page := range []string{"sources", "source", "deliveries", "delivery"} {
 		h.templates[page] = template.Must(
@@ -123,14 +154,19 @@ type sourcesData struct {
 }
 
 type sourceData struct {
-	Nav           string
-	Source        *model.Source
-	Actions       []model.Action
-	Deliveries    []model.Delivery
-	WebhookURL    string
+	Nav        string
+	Source     *model.Source
+	Actions    []model.Action
+	Deliveries []model.Delivery
+	WebhookURL string
+	// Detected is the provider recent deliveries were fingerprinted as,
+	// with the suggested signature preset.
+	Detected      *model.ProviderSuggestion
 	Error         string
 	ScriptError   string
 	ScriptSuccess string
+	// ScriptCheck reports generated payloads a just-saved script threw on.
+	ScriptCheck   *scriptcheck.Report
 	EditAction    *model.Action
 	ActionError   string
 	ActionSuccess string
@@ -183,12 +219,18 @@ func (h *Handler) SourceDetail(c *gin.Context) {
 		return
 	}
 	deliveries, _ := h.store.Deliveries.List(c.Request.Context(), &slug, 10)
+	var detected *model.ProviderSuggestion
+	if provider, matches, sampled, err := h.store.Deliveries.DetectedProvider(c.Request.Context(), source.ID, fingerprint.Sample); err == nil && provider != "" {
+		s := fingerprint.Suggest(source, provider, matches, sampled)
+		detected = &s
+	}
 	h.render(c, "source", sourceData{
 		Nav:        "sources",
 		Source:     source,
 		Actions:    actions,
 		Deliveries: deliveries,
-		WebhookURL: webhookURL(c, source.Slug),
+		WebhookURL: h.urls.Webhook(c.Request, source.Slug),
+		Detected:   detected,
 	})
 }
 
@@ -229,7 +271,7 @@ func (h *Handler) DeliveryDetail(c *gin.Context) {
 		c.String(http.StatusNotFound, "Delivery not found")
 		return
 	}
-	attempts, err := h.store.Deliveries.ListAttemptsByDelivery(c.Request.Context(), id)
+	attempts, err := h.store.Deliveries.ListAttemptsByDelivery(c.Request.Context(), id, store.AttemptFilter{Limit: maxAttemptsShown})
 	if err != nil {
 		slog.Error("failed to list attempts", "error", err)
 		c.String(http.StatusInternalServerError, "Internal server error")
@@ -256,14 +298,6 @@ func generateSlug(name string) string {
 	return s
 }
 
-func webhookURL(c *gin.Context, slug string) string {
-	scheme := "http"
-	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
-		scheme = "https"
-	}
-	return fmt.Sprintf("%s://%s/webhooks/%s", scheme, c.Request.Host, slug)
-}
-
 func (h *Handler) CreateSource(c *gin.Context) {
 	name := strings.TrimSpace(c.PostForm("name"))
 	if name == "" {
@@ -285,7 +319,7 @@ func (h *Handler) CreateSource(c *gin.Context) {
 		})
 		return
 	}
-	_, err := h.store.Sources.Create(c.Request.Context(), name, slug, "record", nil)
+	_, err := h.store.Sources.Create(c.Request.Context(), name, slug, "record", nil, false)
 	if err != nil {
 		sources, _ := h.store.Sources.List(c.Request.Context())
 		errMsg := "Failed to create source"
@@ -355,6 +389,7 @@ func (h *Handler) UpdateSourceScript(c *gin.Context) {
 	}
 
 	var scriptError, scriptSuccess string
+	var check *scriptcheck.Report
 	if strings.TrimSpace(scriptBody) == "" {
 		// Clear the script
 		source, err = h.store.Sources.Update(c.Request.Context(), slug, nil, nil, nil, true)
@@ -375,6 +410,9 @@ func (h *Handler) UpdateSourceScript(c *gin.Context) {
 				scriptError = "Failed to save script"
 			} else {
 				scriptSuccess = "Script saved"
+				if check, err = scriptcheck.ForSource(c.Request.Context(), h.store, source, scriptBody, h.limits); err != nil {
+					slog.Error("failed to check script", "error", err)
+				}
 			}
 		}
 	}
@@ -387,6 +425,7 @@ func (h *Handler) UpdateSourceScript(c *gin.Context) {
 		Deliveries:    deliveries,
 		ScriptError:   scriptError,
 		ScriptSuccess: scriptSuccess,
+		ScriptCheck:   check,
 	})
 }
 
@@ -425,11 +464,20 @@ func (h *Handler) CreateAction(c *gin.Context) {
 	case model.ActionTypeWebhook:
 		targetURL := strings.TrimSpace(c.PostForm("target_url"))
 		if targetURL != "" {
+			if err := h.guard.CheckURL(c.Request.Context(), targetURL); err != nil {
+				slog.Error("rejected action target", "error", err)
+				break
+			}
 			var signingSecret *string
 			if s := strings.TrimSpace(c.PostForm("signing_secret")); s != "" {
 				signingSecret = &s
 			}
-			if _, err := h.store.Actions.Create(c.Request.Context(), source.ID, actionType, &targetURL, signingSecret, nil); err != nil {
+			fields := store.ActionFields{TargetURL: &targetURL, SigningSecret: signingSecret}
+			if h.requireVerification {
+				inactive := false
+				fields.IsActive = &inactive
+			}
+			if _, err := h.store.Actions.Create(c.Request.Context(), source.ID, actionType, fields); err != nil {
 				slog.Error("failed to create action", "error", err)
 			}
 		}
@@ -439,7 +487,7 @@ func (h *Handler) CreateAction(c *gin.Context) {
 			if err := script.ValidateAction(scriptBody); err != nil {
 				slog.Error("invalid action script", "error", err)
 			} else {
-				if _, err := h.store.Actions.Create(c.Request.Context(), source.ID, actionType, nil, nil, &scriptBody); err != nil {
+				if _, err := h.store.Actions.Create(c.Request.Context(), source.ID, actionType, store.ActionFields{ScriptBody: &scriptBody}); err != nil {
 					slog.Error("failed to create action", "error", err)
 				}
 			}
@@ -466,13 +514,23 @@ func (h *Handler) ToggleAction(c *gin.Context) {
 		return
 	}
 	isActive := c.PostForm("is_active") == "on"
-	if _, err := h.store.Actions.Update(c.Request.Context(), id, nil, nil, &isActive, nil); err != nil {
-		slog.Error("failed to toggle action", "error", err)
+	var actionError string
+	if isActive && h.requireVerification {
+		action, err := h.store.Actions.GetByID(c.Request.Context(), id)
+		if err == nil && action.Type == model.ActionTypeWebhook && action.VerifiedAt == nil {
+			actionError = "Target URL must be verified before the action can be activated"
+		}
+	}
+	if actionError == "" {
+		if _, err := h.store.Actions.Update(c.Request.Context(), id, store.ActionFields{IsActive: &isActive}); err != nil {
+			slog.Error("failed to toggle action", "error", err)
+		}
 	}
 	actions, _ := h.store.Actions.List(c.Request.Context(), source.ID)
 	h.renderFragment(c, "source", "actions-card", sourceData{
-		Source:  source,
-		Actions: actions,
+		Source:      source,
+		Actions:     actions,
+		ActionError: actionError,
 	})
 }
 
@@ -547,6 +605,29 @@ func (h *Handler) TestSourceScript(c *gin.Context) {
 		Payload: payload,
 		Headers: headers,
 		Actions: actionRefs,
+		Meta: script.Metadata{
+			DeliveryID: delivery.ID,
+			SourceSlug: source.Slug,
+			ReceivedAt: delivery.ReceivedAt,
+		},
+	}
+
+	// Feed the script what the worker would: the global transform's output
+	if global, _ := h.store.Settings.Get(c.Request.Context(), store.SettingGlobalTransform); global != nil && *global != "" {
+		globalResult, err := script.Run(*global, input)
+		if err != nil {
+			h.renderFragment(c, "source", "script-test-result", scriptTestData{
+				Error: "global transform: " + err.Error(),
+			})
+			return
+		}
+		if globalResult.Dropped {
+			h.renderFragment(c, "source", "script-test-result", scriptTestData{
+				Result: globalResult,
+			})
+			return
+		}
+		input.Payload, input.Headers, input.Actions = globalResult.Payload, globalResult.Headers, globalResult.Actions
 	}
 
 	result, err := script.Run(scriptBody, input)
@@ -618,12 +699,20 @@ func (h *Handler) UpdateAction(c *gin.Context) {
 		targetURL := strings.TrimSpace(c.PostForm("target_url"))
 		if targetURL == "" {
 			actionError = "Target URL is required for webhook actions"
+		} else if err := h.guard.CheckURL(c.Request.Context(), targetURL); err != nil {
+			actionError = "Invalid target URL: " + err.Error()
 		} else {
 			var signingSecret *string
 			if s := strings.TrimSpace(c.PostForm("signing_secret")); s != "" {
 				signingSecret = &s
 			}
-			if _, err := h.store.Actions.Update(c.Request.Context(), id, &targetURL, signingSecret, nil, nil); err != nil {
+			// A new target needs a fresh verification, so deactivate until then
+			var isActive *bool
+			if h.requireVerification && (action.TargetURL == nil || *action.TargetURL != targetURL) {
+				inactive := false
+				isActive = &inactive
+			}
+			if _, err := h.store.Actions.Update(c.Request.Context(), id, store.ActionFields{TargetURL: &targetURL, SigningSecret: signingSecret, IsActive: isActive}); err != nil {
 				slog.Error("failed to update action", "error", err)
 				actionError = "Failed to update action"
 			}
@@ -635,7 +724,7 @@ func (h *Handler) UpdateAction(c *gin.Context) {
 		} else if err := script.ValidateAction(scriptBody); err != nil {
 			actionError = "Invalid script: " + err.Error()
 		} else {
-			if _, err := h.store.Actions.Update(c.Request.Context(), id, nil, nil, nil, &scriptBody); err != nil {
+			if _, err := h.store.Actions.Update(c.Request.Context(), id, store.ActionFields{ScriptBody: &scriptBody}); err != nil {
 				slog.Error("failed to update action", "error", err)
 				actionError = "Failed to update action"
 			}